@@ -8,11 +8,17 @@ import (
 	"os/signal"
 	"syscall"
 
+	k8s "k8s.io/client-go/kubernetes"
+
 	"k8s-cluster-info-collector/internal/api"
+	"k8s-cluster-info-collector/internal/auth"
 	"k8s-cluster-info-collector/internal/config"
 	"k8s-cluster-info-collector/internal/database"
 	"k8s-cluster-info-collector/internal/kafka"
+	"k8s-cluster-info-collector/internal/kubernetes"
+	"k8s-cluster-info-collector/internal/lifecycle"
 	"k8s-cluster-info-collector/internal/logger"
+	"k8s-cluster-info-collector/internal/metrics"
 	"k8s-cluster-info-collector/internal/store"
 	"k8s-cluster-info-collector/internal/streaming"
 )
@@ -42,7 +48,6 @@ func main() {
 	if err != nil {
 		loggerInstance.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer db.Close()
 
 	// Initialize store
 	dataStore := store.New(db, loggerInstance)
@@ -52,7 +57,19 @@ func main() {
 		loggerInstance.Fatal("Kafka must be enabled for consumer service")
 	}
 
-	consumer, err := kafka.NewConsumer(&cfg.Kafka, dataStore, loggerInstance)
+	// The lifecycle manager runs the API and metrics servers and, on
+	// shutdown, lets the message being processed finish before the database
+	// is closed
+	manager := lifecycle.New(loggerInstance, cfg.Server)
+
+	// Prometheus metrics for retries and dead letters (optional)
+	var metricsInstance *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		metricsInstance = metrics.New(loggerInstance)
+		manager.AddServer("metrics", cfg.Metrics.Address, metricsInstance.ServerHandler(), cfg.Metrics.TLS)
+	}
+
+	consumer, err := kafka.NewConsumer(&cfg.Kafka, dataStore, loggerInstance, metricsInstance)
 	if err != nil {
 		loggerInstance.Fatalf("Failed to initialize Kafka consumer: %v", err)
 	}
@@ -62,51 +79,80 @@ func main() {
 		Enabled: cfg.Consumer.Server.Enabled,
 		Address: fmt.Sprintf("%s:%d", cfg.Consumer.Server.Address, cfg.Consumer.Server.Port),
 		Prefix:  "/api/v1",
+		// CORS origins and request limits are shared with the collector's API server
+		CORSAllowedOrigins: cfg.API.CORSAllowedOrigins,
+		RateLimit:          cfg.API.RateLimit,
+		RateLimitBurst:     cfg.API.RateLimitBurst,
+		MaxBodyBytes:       cfg.API.MaxBodyBytes,
+		CacheEnabled:       cfg.API.CacheEnabled,
+		GraphQLMaxDepth:    cfg.API.GraphQLMaxDepth,
 	}
 
 	// Streaming hub (optional, can be nil if not used)
 	var streamingHub *streaming.Hub = nil
 
 	apiServer := api.New(db, loggerInstance, apiConfig, streamingHub, version, commitHash)
+	if deadLetters := consumer.DeadLetters(); deadLetters != nil {
+		apiServer.SetDeadLetters(deadLetters)
+	}
 
-	// Start API server (runs in goroutine)
-	go func() {
-		if err := apiServer.Start(); err != nil {
-			loggerInstance.Errorf("Failed to start API server: %v", err)
+	if cfg.Auth.Enabled {
+		// A Kubernetes client is only needed to submit TokenReviews
+		var clientset k8s.Interface
+		if cfg.Auth.TokenReview {
+			k8sClient, err := kubernetes.NewClient(&cfg.Kube, loggerInstance)
+			if err != nil {
+				loggerInstance.Fatalf("Failed to initialize Kubernetes client for token review: %v", err)
+			}
+			clientset = k8sClient.Clientset
 		}
-	}()
-
-	// (Removed: consumer health/metrics server startup. All endpoints are now served by the unified API server.)
+		authenticator, policy, err := auth.New(&cfg.Auth, clientset, loggerInstance)
+		if err != nil {
+			loggerInstance.Fatalf("Failed to initialize API authentication: %v", err)
+		}
+		apiServer.SetAuth(authenticator, policy)
+	}
 
-	// Create context that can be cancelled
-	ctx, cancel := context.WithCancel(context.Background())
+	if cfg.Consumer.Server.Enabled {
+		manager.AddServer("api", apiConfig.Address, apiServer.Handler(), cfg.Consumer.Server.TLS)
+	}
+	manager.OnShutdown("kafka consumer", consumer.Shutdown)
+	manager.OnShutdown("database", func(context.Context) error {
+		return db.Close()
+	})
+	manager.Start()
 
 	// Start consumer
-	if err := consumer.Start(ctx); err != nil {
+	if err := consumer.Start(context.Background()); err != nil {
 		loggerInstance.Fatalf("Failed to start Kafka consumer: %v", err)
 	}
 
 	loggerInstance.Info("Kafka consumer service started successfully")
 	if cfg.Consumer.Server.Enabled {
-		loggerInstance.Infof("API server available at http://%s:%d/api/v1", cfg.Consumer.Server.Address, cfg.Consumer.Server.Port)
-		loggerInstance.Infof("Health endpoint: http://%s:%d/api/v1/health", cfg.Consumer.Server.Address, cfg.Consumer.Server.Port)
-		loggerInstance.Infof("Metrics endpoint: http://%s:%d/api/v1/metrics", cfg.Consumer.Server.Address, cfg.Consumer.Server.Port)
+		scheme := "http"
+		if cfg.Consumer.Server.TLS.Enabled {
+			scheme = "https"
+		}
+		loggerInstance.Infof("API server available at %s://%s:%d/api/v1", scheme, cfg.Consumer.Server.Address, cfg.Consumer.Server.Port)
+		loggerInstance.Infof("Health endpoint: %s://%s:%d/api/v1/health", scheme, cfg.Consumer.Server.Address, cfg.Consumer.Server.Port)
+		loggerInstance.Infof("Metrics endpoint: %s://%s:%d/api/v1/metrics", scheme, cfg.Consumer.Server.Address, cfg.Consumer.Server.Port)
 	}
 
-	// Wait for interrupt signal to gracefully shutdown
+	// Wait for interrupt signal (or a failed server) to gracefully shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
-
-	loggerInstance.Info("Received shutdown signal, stopping consumer...")
-
-	// Cancel context and stop consumer
-	cancel()
-	if err := consumer.Stop(); err != nil {
-		loggerInstance.Errorf("Error stopping consumer: %v", err)
+	select {
+	case <-sigChan:
+		loggerInstance.Info("Received shutdown signal, stopping consumer...")
+	case err := <-manager.Errors():
+		loggerInstance.Errorf("Server failed, stopping consumer: %v", err)
 	}
 
-	// No explicit API server shutdown (for simplicity); add if needed
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := manager.Shutdown(ctx); err != nil {
+		loggerInstance.Errorf("Error during shutdown: %v", err)
+	}
 
 	loggerInstance.Info("Kafka consumer service stopped")
 }
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+	"k8s-cluster-info-collector/internal/database"
+	"k8s-cluster-info-collector/internal/offline"
+	"k8s-cluster-info-collector/internal/store"
+	"k8s-cluster-info-collector/pkg/client"
+)
+
+// snapshotFlags adds --snapshot and --at to a flag set
+func snapshotFlags(fs *flag.FlagSet) func() (client.SnapshotOptions, error) {
+	id := fs.Int("snapshot", 0, "read from this snapshot ID (default latest)")
+	at := fs.String("at", "", "read from the latest snapshot at or before TIME")
+	return func() (client.SnapshotOptions, error) {
+		t, err := parseTime(*at)
+		if err != nil {
+			return client.SnapshotOptions{}, err
+		}
+		if *id > 0 && !t.IsZero() {
+			return client.SnapshotOptions{}, errors.New("use either --snapshot or --at, not both")
+		}
+		return client.SnapshotOptions{SnapshotID: *id, At: t}, nil
+	}
+}
+
+// rangeFlags adds --since and --until to a flag set
+func rangeFlags(fs *flag.FlagSet) func() (client.TimeRange, error) {
+	since := fs.String("since", "", "start of the time range (TIME)")
+	until := fs.String("until", "", "end of the time range (TIME)")
+	return func() (client.TimeRange, error) {
+		var window client.TimeRange
+		var err error
+		if window.Since, err = parseTime(*since); err != nil {
+			return window, err
+		}
+		window.Until, err = parseTime(*until)
+		return window, err
+	}
+}
+
+func runClusters(ctx context.Context, args []string, out io.Writer) error {
+	var opts options
+	fs := newFlagSet("clusters", &opts)
+	if _, err := parseArgs(fs, &opts, args); err != nil {
+		return err
+	}
+
+	c, done, err := connect(opts)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	clusters, err := c.Clusters(ctx)
+	if err != nil {
+		return err
+	}
+	if opts.output == outputJSON || opts.output == outputYAML {
+		return printValue(out, opts.output, clusters)
+	}
+
+	t := &table{headers: cells(false, "NAME", "SNAPSHOTS", "LAST SEEN", "AGE")}
+	t.headers = append(t.headers, cells(true, "UID", "FIRST SEEN", "LAST SNAPSHOT")...)
+	for _, cl := range clusters {
+		row := cells(false, cl.Name, strconv.Itoa(cl.Snapshots), cl.LastSeen.UTC().Format(time.RFC3339), age(cl.LastSeen))
+		row = append(row, cells(true, cl.UID, cl.FirstSeen.UTC().Format(time.RFC3339), strconv.Itoa(cl.LastSnapshotID))...)
+		t.rows = append(t.rows, row)
+	}
+	return t.write(out, opts.output == outputWide)
+}
+
+func runSnapshots(ctx context.Context, args []string, out io.Writer) error {
+	var opts options
+	fs := newFlagSet("snapshots", &opts)
+	limit := fs.Int("limit", 20, "number of snapshots to list")
+	if _, err := parseArgs(fs, &opts, args); err != nil {
+		return err
+	}
+
+	c, done, err := connect(opts)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	snapshots, err := c.Snapshots(ctx, *limit)
+	if err != nil {
+		return err
+	}
+	if opts.output == outputJSON || opts.output == outputYAML {
+		return printValue(out, opts.output, snapshots)
+	}
+
+	t := &table{headers: cells(false, "ID", "CLUSTER", "TIMESTAMP", "AGE", "NODES", "PODS", "DEPLOYMENTS", "SERVICES")}
+	t.headers = append(t.headers, cells(true, "INGRESSES", "CONFIGMAPS", "SECRETS", "PVS", "PVCS")...)
+	for _, s := range snapshots {
+		row := cells(false, strconv.Itoa(s.ID), s.Cluster, s.Timestamp.UTC().Format(time.RFC3339), age(s.Timestamp),
+			strconv.Itoa(s.Nodes), strconv.Itoa(s.Pods), strconv.Itoa(s.Deployments), strconv.Itoa(s.Services))
+		row = append(row, cells(true, strconv.Itoa(s.Ingresses), strconv.Itoa(s.ConfigMaps), strconv.Itoa(s.Secrets),
+			strconv.Itoa(s.PersistentVolumes), strconv.Itoa(s.PersistentVolumeClaims))...)
+		t.rows = append(t.rows, row)
+	}
+	return t.write(out, opts.output == outputWide)
+}
+
+func runGet(ctx context.Context, args []string, out io.Writer) error {
+	var opts options
+	fs := newFlagSet("get", &opts)
+	namespace := fs.String("n", "", "only objects in this namespace (default all)")
+	selector := fs.String("l", "", "label selector, e.g. app=web,tier!=cache")
+	phase := fs.String("phase", "", "only pods in this phase")
+	node := fs.String("node", "", "only pods on this node")
+	snapshot := snapshotFlags(fs)
+	positional, err := parseArgs(fs, &opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		return errors.New("usage: kcic get KIND [NAME]")
+	}
+	kind, err := lookupKind(positional[0])
+	if err != nil {
+		return err
+	}
+	snapshotOpts, err := snapshot()
+	if err != nil {
+		return err
+	}
+
+	c, done, err := connect(opts)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if len(positional) == 2 && (opts.output == outputJSON || opts.output == outputYAML) {
+		objectNamespace, name, err := splitObjectName(kind, positional[1], *namespace)
+		if err != nil {
+			return err
+		}
+		detail, err := kind.objects(c).Get(ctx, objectNamespace, name, snapshotOpts)
+		if err != nil {
+			return err
+		}
+		return printValue(out, opts.output, detail)
+	}
+
+	listOpts := client.ListOptions{
+		SnapshotOptions: snapshotOpts,
+		Namespace:       *namespace,
+		LabelSelector:   *selector,
+		Phase:           *phase,
+		Node:            *node,
+		Sort:            "name",
+		Limit:           500,
+	}
+	var name string
+	if len(positional) == 2 {
+		if listOpts.Namespace, name, err = splitObjectName(kind, positional[1], *namespace); err != nil {
+			return err
+		}
+	}
+
+	t, err := kind.list(ctx, c, listOpts, name)
+	if err != nil {
+		return err
+	}
+	if name != "" && len(t.rows) == 0 {
+		return fmt.Errorf("%s %q not found", kind.path, positional[1])
+	}
+	if opts.output == outputJSON || opts.output == outputYAML {
+		return printValue(out, opts.output, t.items)
+	}
+	if len(t.rows) == 0 {
+		fmt.Fprintln(os.Stderr, "No resources found.")
+		return nil
+	}
+	return t.write(out, opts.output == outputWide)
+}
+
+func runDiff(ctx context.Context, args []string, out io.Writer) error {
+	var opts options
+	fs := newFlagSet("diff", &opts)
+	positional, err := parseArgs(fs, &opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return errors.New("usage: kcic diff FROM_ID TO_ID")
+	}
+	from, err := strconv.Atoi(positional[0])
+	if err != nil {
+		return fmt.Errorf("invalid snapshot ID %q", positional[0])
+	}
+	to, err := strconv.Atoi(positional[1])
+	if err != nil {
+		return fmt.Errorf("invalid snapshot ID %q", positional[1])
+	}
+
+	c, done, err := connect(opts)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	result, err := c.Diff(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	if opts.output == outputJSON || opts.output == outputYAML {
+		return printValue(out, opts.output, result)
+	}
+	return result.WriteText(out)
+}
+
+func runHistory(ctx context.Context, args []string, out io.Writer) error {
+	var opts options
+	fs := newFlagSet("history", &opts)
+	namespace := fs.String("n", "", "namespace of the object")
+	window := rangeFlags(fs)
+	positional, err := parseArgs(fs, &opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return errors.New("usage: kcic history KIND [NAMESPACE/]NAME")
+	}
+	kind, err := lookupKind(positional[0])
+	if err != nil {
+		return err
+	}
+	objectNamespace, name, err := splitObjectName(kind, positional[1], *namespace)
+	if err != nil {
+		return err
+	}
+	timeRange, err := window()
+	if err != nil {
+		return err
+	}
+
+	c, done, err := connect(opts)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	history, err := kind.objects(c).History(ctx, objectNamespace, name, timeRange)
+	if err != nil {
+		return err
+	}
+	if opts.output == outputJSON || opts.output == outputYAML {
+		return printValue(out, opts.output, history)
+	}
+
+	t := &table{headers: cells(false, "SNAPSHOT", "TIMESTAMP", "UNTIL", "SNAPSHOTS", "CHANGES")}
+	t.headers = append(t.headers, cells(true, "UID")...)
+	for _, point := range history.History {
+		row := cells(false, strconv.Itoa(point.SnapshotID), point.Timestamp.UTC().Format(time.RFC3339),
+			point.LastSeen.UTC().Format(time.RFC3339), strconv.Itoa(point.Snapshots), describeChanges(point))
+		row = append(row, cells(true, orNone(point.UID))...)
+		t.rows = append(t.rows, row)
+	}
+	return t.write(out, opts.output == outputWide)
+}
+
+// describeChanges lists the changed fields of a change point as key=value.
+// The first point has no previous values, so all of its fields are listed.
+func describeChanges(point client.ChangePoint) string {
+	keys := point.Changed
+	if len(keys) == 0 {
+		for key := range point.Values {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", key, point.Values[key]))
+	}
+	return orNone(strings.Join(parts, " "))
+}
+
+func runExport(ctx context.Context, args []string, out io.Writer) error {
+	var opts options
+	fs := newFlagSet("export", &opts)
+	file := fs.String("f", "-", "write to this file instead of stdout")
+	format := fs.String("format", client.FormatNDJSON, "ndjson, csv or parquet")
+	columns := fs.String("columns", "", "comma-separated columns to export")
+	namespace := fs.String("n", "", "only objects in this namespace")
+	snapshot := snapshotFlags(fs)
+	window := rangeFlags(fs)
+	positional, err := parseArgs(fs, &opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return errors.New("usage: kcic export [KIND]")
+	}
+	snapshotOpts, err := snapshot()
+	if err != nil {
+		return err
+	}
+	timeRange, err := window()
+	if err != nil {
+		return err
+	}
+	var columnList []string
+	if *columns != "" {
+		columnList = strings.Split(*columns, ",")
+	}
+	if *file == "-" && *format == client.FormatParquet {
+		return errors.New("parquet output is binary, write it to a file with -f")
+	}
+
+	c, done, err := connect(opts)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var body io.ReadCloser
+	if len(positional) == 1 {
+		kind, err := lookupKind(positional[0])
+		if err != nil {
+			return err
+		}
+		body, err = c.Export(ctx, kind.path, client.ExportOptions{
+			SnapshotOptions: snapshotOpts,
+			TimeRange:       timeRange,
+			Namespace:       *namespace,
+			Format:          *format,
+			Columns:         columnList,
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		if !snapshotOpts.At.IsZero() || !timeRange.Since.IsZero() || !timeRange.Until.IsZero() || *namespace != "" {
+			return errors.New("exporting a whole snapshot only supports --snapshot; name a KIND to filter")
+		}
+		id := snapshotOpts.SnapshotID
+		if id == 0 {
+			latest, err := c.Snapshots(ctx, 1)
+			if err != nil {
+				return err
+			}
+			if len(latest) == 0 {
+				return errors.New("no snapshots available")
+			}
+			id = latest[0].ID
+		}
+		if body, err = c.ExportSnapshot(ctx, id, *format, columnList); err != nil {
+			return err
+		}
+	}
+	defer body.Close()
+
+	if *file == "-" {
+		_, err = io.Copy(out, body)
+		return err
+	}
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", *file, err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d bytes to %s\n", written, *file)
+	return nil
+}
+
+func runImport(ctx context.Context, args []string, out io.Writer) error {
+	var opts options
+	fs := newFlagSet("import", &opts)
+	positional, err := parseArgs(fs, &opts, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: kcic import DIRECTORY")
+	}
+
+	// Imports always write to the database: the API has no upload endpoint
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+	logger.SetLevel(logrus.WarnLevel)
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	db, err := database.New(&cfg.Database, logger)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	result, err := offline.Import(ctx, positional[0], store.New(db, logger), logger)
+	if err != nil {
+		return fmt.Errorf("import stopped after %d snapshots: %w", result.Imported, err)
+	}
+	if opts.output == outputJSON || opts.output == outputYAML {
+		return printValue(out, opts.output, result)
+	}
+	fmt.Fprintf(out, "Imported %d snapshots, skipped %d already present\n", result.Imported, result.Skipped)
+	return nil
+}
+
+func runVersion(ctx context.Context, args []string, out io.Writer) error {
+	var opts options
+	fs := newFlagSet("version", &opts)
+	if _, err := parseArgs(fs, &opts, args); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "kcic %s (commit %s)\n", version, commitHash)
+
+	c, done, err := connect(opts)
+	if err != nil {
+		return err
+	}
+	defer done()
+	server, err := c.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get server version: %w", err)
+	}
+	fmt.Fprintf(out, "server %s (commit %s)\n", server.Version, server.CommitHash)
+	return nil
+}
+
+// cells turns texts into table cells
+func cells(wide bool, texts ...string) []tableCell {
+	result := make([]tableCell, len(texts))
+	for i, text := range texts {
+		result[i] = tableCell{text: text, wide: wide}
+	}
+	return result
+}
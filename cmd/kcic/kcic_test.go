@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-cluster-info-collector/pkg/client"
+)
+
+func TestParseArgs(t *testing.T) {
+	var opts options
+	fs := newFlagSet("get", &opts)
+	namespace := fs.String("n", "", "")
+
+	positional, err := parseArgs(fs, &opts, []string{"pods", "-n", "shop", "web-1", "-o", "wide"})
+	if err != nil {
+		t.Fatalf("parseArgs() error = %v", err)
+	}
+	if strings.Join(positional, " ") != "pods web-1" || *namespace != "shop" || opts.output != outputWide {
+		t.Errorf("unexpected parse: %v %q %q", positional, *namespace, opts.output)
+	}
+
+	opts = options{}
+	if _, err := parseArgs(newFlagSet("get", &opts), &opts, []string{"-o", "xml"}); err == nil {
+		t.Error("expected an unknown output format to be rejected")
+	}
+}
+
+func TestLookupKind(t *testing.T) {
+	for name, want := range map[string]string{
+		"pods": "pods", "po": "pods", "Pod": "pods", "svc": "services",
+		"pvc": "persistent-volume-claims", "pv": "persistent-volumes", "cm": "configmaps",
+	} {
+		kind, err := lookupKind(name)
+		if err != nil || kind.path != want {
+			t.Errorf("lookupKind(%q) = %q, %v; want %q", name, kind.path, err, want)
+		}
+	}
+	if _, err := lookupKind("widgets"); err == nil {
+		t.Error("expected an unknown kind to be rejected")
+	}
+}
+
+func TestSplitObjectName(t *testing.T) {
+	pods, _ := lookupKind("pods")
+	nodes, _ := lookupKind("nodes")
+
+	tests := []struct {
+		kind                    kindSpec
+		value, defaultNamespace string
+		namespace, name         string
+		wantErr                 bool
+	}{
+		{kind: pods, value: "shop/web-1", namespace: "shop", name: "web-1"},
+		{kind: pods, value: "web-1", defaultNamespace: "shop", namespace: "shop", name: "web-1"},
+		{kind: pods, value: "web-1", wantErr: true},
+		{kind: nodes, value: "node-1", defaultNamespace: "shop", name: "node-1"},
+		{kind: nodes, value: "shop/node-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		namespace, name, err := splitObjectName(tt.kind, tt.value, tt.defaultNamespace)
+		if (err != nil) != tt.wantErr || namespace != tt.namespace || name != tt.name {
+			t.Errorf("splitObjectName(%s, %q) = %q, %q, %v", tt.kind.path, tt.value, namespace, name, err)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	parsed, err := parseTime("2024-05-01T10:00:00Z")
+	if err != nil || !parsed.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected RFC3339 parse: %v, %v", parsed, err)
+	}
+	parsed, err = parseTime("2h")
+	if err != nil || time.Since(parsed) < 2*time.Hour || time.Since(parsed) > 2*time.Hour+time.Minute {
+		t.Errorf("expected 2h to mean two hours ago, got %v, %v", parsed, err)
+	}
+	if _, err := parseTime("yesterday"); err == nil {
+		t.Error("expected an invalid time to be rejected")
+	}
+}
+
+func TestTableWide(t *testing.T) {
+	tbl := &table{headers: append(cells(false, "NAME", "STATUS"), cells(true, "NODE")...)}
+	tbl.rows = append(tbl.rows, append(cells(false, "web-1", "Running"), cells(true, "node-1")...))
+
+	var narrow, wide bytes.Buffer
+	tbl.write(&narrow, false)
+	tbl.write(&wide, true)
+	if strings.Contains(narrow.String(), "NODE") || !strings.Contains(narrow.String(), "Running") {
+		t.Errorf("unexpected table:\n%s", narrow.String())
+	}
+	if !strings.Contains(wide.String(), "node-1") {
+		t.Errorf("expected wide columns with -o wide:\n%s", wide.String())
+	}
+}
+
+func TestHandlerTransport(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/pods", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(client.ResourceList[client.PodRow]{
+			Data:  []client.PodRow{{Name: "web-1", Namespace: "shop", Phase: "Running"}},
+			Count: 1,
+			Total: 1,
+		})
+	})
+	mux.HandleFunc("/api/v1/export/pods", func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 1000; i++ {
+			io.WriteString(w, `{"name":"web"}`+"\n")
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/api/v1/snapshots/7", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"Snapshot not found"}`)
+	})
+
+	c, err := client.New(client.Config{BaseURL: localBaseURL, HTTPClient: &http.Client{Transport: handlerTransport{mux}}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx := context.Background()
+
+	pods, _ := lookupKind("pods")
+	tbl, err := pods.list(ctx, c, client.ListOptions{}, "")
+	if err != nil {
+		t.Fatalf("list error = %v", err)
+	}
+	var out bytes.Buffer
+	tbl.write(&out, false)
+	if !strings.Contains(out.String(), "NAMESPACE") || !strings.Contains(out.String(), "web-1") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	body, err := c.Export(ctx, "pods", client.ExportOptions{})
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if lines := strings.Count(string(data), "\n"); lines != 1000 {
+		t.Errorf("expected 1000 streamed lines, got %d", lines)
+	}
+
+	if _, err := c.Snapshot(ctx, 7); !client.IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s-cluster-info-collector/pkg/client"
+)
+
+// objectAccessor is the part of client.Resources that does not depend on
+// the row type
+type objectAccessor interface {
+	Get(ctx context.Context, namespace, name string, opts client.SnapshotOptions) (*client.ObjectDetail, error)
+	History(ctx context.Context, namespace, name string, window client.TimeRange) (*client.ObjectHistory, error)
+}
+
+// column is one table column of a resource list
+type column[T any] struct {
+	header string
+	wide   bool // only shown with -o wide
+	value  func(T) string
+}
+
+// kindSpec describes a resource kind to the CLI
+type kindSpec struct {
+	path       string // API path segment, e.g. persistent-volume-claims
+	aliases    []string
+	namespaced bool
+	objects    func(*client.Client) objectAccessor
+	// list fetches every matching row. name, when set, keeps only that object.
+	list func(ctx context.Context, c *client.Client, opts client.ListOptions, name string) (*table, error)
+}
+
+func newKind[T any](path string, aliases []string, namespaced bool, resources func(*client.Client) *client.Resources[T],
+	identity func(T) (namespace, name string), columns ...column[T]) kindSpec {
+	spec := kindSpec{
+		path:       path,
+		aliases:    aliases,
+		namespaced: namespaced,
+		objects:    func(c *client.Client) objectAccessor { return resources(c) },
+	}
+
+	spec.list = func(ctx context.Context, c *client.Client, opts client.ListOptions, name string) (*table, error) {
+		result := &table{}
+		showNamespace := namespaced && opts.Namespace == ""
+		if showNamespace {
+			result.headers = append(result.headers, tableCell{text: "NAMESPACE"})
+		}
+		result.headers = append(result.headers, tableCell{text: "NAME"})
+		for _, col := range columns {
+			result.headers = append(result.headers, tableCell{text: col.header, wide: col.wide})
+		}
+
+		items := []T{}
+		for row, err := range resources(c).All(ctx, opts) {
+			if err != nil {
+				return nil, err
+			}
+			namespace, rowName := identity(row)
+			if name != "" && rowName != name {
+				continue
+			}
+			items = append(items, row)
+
+			cells := []tableCell{}
+			if showNamespace {
+				cells = append(cells, tableCell{text: namespace})
+			}
+			cells = append(cells, tableCell{text: rowName})
+			for _, col := range columns {
+				cells = append(cells, tableCell{text: col.value(row), wide: col.wide})
+			}
+			result.rows = append(result.rows, cells)
+		}
+		result.items = items
+		return result, nil
+	}
+	return spec
+}
+
+// kinds lists every resource kind the CLI knows, in API order
+var kinds = []kindSpec{
+	newKind("deployments", []string{"deployment", "deploy"}, true, (*client.Client).Deployments,
+		func(r client.DeploymentRow) (string, string) { return r.Namespace, r.Name },
+		column[client.DeploymentRow]{header: "READY", value: func(r client.DeploymentRow) string {
+			return fmt.Sprintf("%d/%d", r.ReadyReplicas, r.Replicas)
+		}},
+		column[client.DeploymentRow]{header: "AGE", value: func(r client.DeploymentRow) string { return age(r.CreatedTime) }},
+	),
+	newKind("pods", []string{"pod", "po"}, true, (*client.Client).Pods,
+		func(r client.PodRow) (string, string) { return r.Namespace, r.Name },
+		column[client.PodRow]{header: "STATUS", value: func(r client.PodRow) string { return r.Phase }},
+		column[client.PodRow]{header: "RESTARTS", value: func(r client.PodRow) string { return strconv.Itoa(int(r.RestartCount)) }},
+		column[client.PodRow]{header: "AGE", value: func(r client.PodRow) string { return age(r.CreatedTime) }},
+		column[client.PodRow]{header: "NODE", wide: true, value: func(r client.PodRow) string { return orNone(r.NodeName) }},
+	),
+	newKind("nodes", []string{"node", "no"}, false, (*client.Client).Nodes,
+		func(r client.NodeRow) (string, string) { return "", r.Name },
+		column[client.NodeRow]{header: "STATUS", value: func(r client.NodeRow) string {
+			if r.Ready {
+				return "Ready"
+			}
+			return "NotReady"
+		}},
+		column[client.NodeRow]{header: "AGE", value: func(r client.NodeRow) string { return age(r.CreatedTime) }},
+		column[client.NodeRow]{header: "CPU", wide: true, value: func(r client.NodeRow) string { return orNone(r.CPUCapacity) }},
+		column[client.NodeRow]{header: "MEMORY", wide: true, value: func(r client.NodeRow) string { return orNone(r.MemoryCapacity) }},
+	),
+	newKind("services", []string{"service", "svc"}, true, (*client.Client).Services,
+		func(r client.ServiceRow) (string, string) { return r.Namespace, r.Name },
+		column[client.ServiceRow]{header: "TYPE", value: func(r client.ServiceRow) string { return r.Type }},
+		column[client.ServiceRow]{header: "CLUSTER-IP", value: func(r client.ServiceRow) string { return orNone(r.ClusterIP) }},
+		column[client.ServiceRow]{header: "AGE", value: func(r client.ServiceRow) string { return age(r.CreatedTime) }},
+	),
+	newKind("ingresses", []string{"ingress", "ing"}, true, (*client.Client).Ingresses,
+		func(r client.IngressRow) (string, string) { return r.Namespace, r.Name },
+		column[client.IngressRow]{header: "HOSTS", value: func(r client.IngressRow) string { return list(r.Hosts) }},
+		column[client.IngressRow]{header: "AGE", value: func(r client.IngressRow) string { return age(r.CreatedTime) }},
+	),
+	newKind("configmaps", []string{"configmap", "cm"}, true, (*client.Client).ConfigMaps,
+		func(r client.ConfigMapRow) (string, string) { return r.Namespace, r.Name },
+		column[client.ConfigMapRow]{header: "DATA", value: func(r client.ConfigMapRow) string { return strconv.Itoa(len(r.DataKeys)) }},
+		column[client.ConfigMapRow]{header: "AGE", value: func(r client.ConfigMapRow) string { return age(r.CreatedTime) }},
+		column[client.ConfigMapRow]{header: "KEYS", wide: true, value: func(r client.ConfigMapRow) string { return list(r.DataKeys) }},
+	),
+	newKind("secrets", []string{"secret"}, true, (*client.Client).Secrets,
+		func(r client.SecretRow) (string, string) { return r.Namespace, r.Name },
+		column[client.SecretRow]{header: "TYPE", value: func(r client.SecretRow) string { return r.Type }},
+		column[client.SecretRow]{header: "DATA", value: func(r client.SecretRow) string { return strconv.Itoa(len(r.DataKeys)) }},
+		column[client.SecretRow]{header: "AGE", value: func(r client.SecretRow) string { return age(r.CreatedTime) }},
+		column[client.SecretRow]{header: "KEYS", wide: true, value: func(r client.SecretRow) string { return list(r.DataKeys) }},
+	),
+	newKind("persistent-volumes", []string{"persistentvolumes", "persistentvolume", "pv"}, false, (*client.Client).PersistentVolumes,
+		func(r client.PersistentVolumeRow) (string, string) { return "", r.Name },
+		column[client.PersistentVolumeRow]{header: "CAPACITY", value: func(r client.PersistentVolumeRow) string { return r.Capacity }},
+		column[client.PersistentVolumeRow]{header: "ACCESS MODES", value: func(r client.PersistentVolumeRow) string { return list(r.AccessModes) }},
+		column[client.PersistentVolumeRow]{header: "STATUS", value: func(r client.PersistentVolumeRow) string { return r.Status }},
+		column[client.PersistentVolumeRow]{header: "STORAGECLASS", value: func(r client.PersistentVolumeRow) string { return orNone(r.StorageClass) }},
+		column[client.PersistentVolumeRow]{header: "AGE", value: func(r client.PersistentVolumeRow) string { return age(r.CreatedTime) }},
+	),
+	newKind("persistent-volume-claims", []string{"persistentvolumeclaims", "persistentvolumeclaim", "pvc"}, true, (*client.Client).PersistentVolumeClaims,
+		func(r client.PersistentVolumeClaimRow) (string, string) { return r.Namespace, r.Name },
+		column[client.PersistentVolumeClaimRow]{header: "STATUS", value: func(r client.PersistentVolumeClaimRow) string { return r.Status }},
+		column[client.PersistentVolumeClaimRow]{header: "CAPACITY", value: func(r client.PersistentVolumeClaimRow) string { return r.RequestedSize }},
+		column[client.PersistentVolumeClaimRow]{header: "ACCESS MODES", value: func(r client.PersistentVolumeClaimRow) string { return list(r.AccessModes) }},
+		column[client.PersistentVolumeClaimRow]{header: "AGE", value: func(r client.PersistentVolumeClaimRow) string { return age(r.CreatedTime) }},
+	),
+}
+
+// lookupKind finds a kind by its plural, singular or short name
+func lookupKind(name string) (kindSpec, error) {
+	name = strings.ToLower(name)
+	for _, kind := range kinds {
+		if kind.path == name {
+			return kind, nil
+		}
+		for _, alias := range kind.aliases {
+			if alias == name {
+				return kind, nil
+			}
+		}
+	}
+
+	names := make([]string, len(kinds))
+	for i, kind := range kinds {
+		names[i] = kind.path
+	}
+	sort.Strings(names)
+	return kindSpec{}, fmt.Errorf("unknown resource kind %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// splitObjectName splits "namespace/name" for namespaced kinds. A bare name
+// uses defaultNamespace.
+func splitObjectName(kind kindSpec, value, defaultNamespace string) (namespace, name string, err error) {
+	namespace, name, found := strings.Cut(value, "/")
+	if !found {
+		namespace, name = defaultNamespace, value
+	}
+	if !kind.namespaced {
+		if found {
+			return "", "", fmt.Errorf("%s are not namespaced", kind.path)
+		}
+		return "", name, nil
+	}
+	if namespace == "" || name == "" {
+		return "", "", fmt.Errorf("%s are namespaced: use namespace/name or -n", kind.path)
+	}
+	return namespace, name, nil
+}
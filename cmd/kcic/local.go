@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/api"
+	"k8s-cluster-info-collector/internal/config"
+	"k8s-cluster-info-collector/internal/database"
+)
+
+// localBaseURL is the API root of the in-process server. No connection is
+// ever made to it.
+const localBaseURL = "http://kcic.local/api/v1"
+
+// openLocal connects to the database configured by the DB_* environment
+// variables and returns a transport that serves requests with an in-process
+// API server, so both modes go through the same handlers
+func openLocal(logger *logrus.Logger) (http.RoundTripper, func() error, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := database.New(&cfg.Database, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	server := api.New(db, logger, api.APIConfig{Prefix: "/api/v1"}, nil, version, commitHash)
+	return handlerTransport{server.Handler()}, db.Close, nil
+}
+
+// handlerTransport is an http.RoundTripper that calls a handler directly.
+// Response bodies are streamed so large exports are not held in memory.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reader, writer := io.Pipe()
+	w := &pipeResponseWriter{header: http.Header{}, body: writer, ready: make(chan struct{})}
+
+	go func() {
+		defer func() {
+			w.WriteHeader(http.StatusOK)
+			writer.Close()
+		}()
+		t.handler.ServeHTTP(w, req)
+	}()
+
+	select {
+	case <-w.ready:
+	case <-req.Context().Done():
+		reader.CloseWithError(req.Context().Err())
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		Status:     http.StatusText(w.status),
+		StatusCode: w.status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     w.sent,
+		Body:       reader,
+		Request:    req,
+	}, nil
+}
+
+// pipeResponseWriter hands the status and headers to RoundTrip on the first
+// write and pipes the body to the response reader
+type pipeResponseWriter struct {
+	header http.Header
+	sent   http.Header // header as of the first write
+	body   *io.PipeWriter
+	status int
+	once   sync.Once
+	ready  chan struct{}
+}
+
+func (w *pipeResponseWriter) Header() http.Header { return w.header }
+
+func (w *pipeResponseWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.status = status
+		// Headers set after this point are not sent, as with a real connection
+		w.sent = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *pipeResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(data)
+}
+
+// Flush implements http.Flusher; writes already go straight to the reader
+func (w *pipeResponseWriter) Flush() {}
@@ -0,0 +1,169 @@
+// Command kcic queries the cluster snapshots stored by the collector, either
+// through the REST API or straight from the database, and imports snapshots
+// written in offline mode.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/pkg/client"
+)
+
+// Build information (to be set via ldflags during build)
+var (
+	version    = "dev"
+	commitHash = "unknown"
+)
+
+const usage = `kcic queries cluster snapshots stored by the cluster info collector.
+
+Usage:
+  kcic clusters
+  kcic snapshots [--limit N]
+  kcic get KIND [NAME] [-n NAMESPACE] [-l SELECTOR] [--snapshot ID | --at TIME]
+  kcic diff FROM_ID TO_ID
+  kcic history KIND [NAMESPACE/]NAME [--since TIME] [--until TIME]
+  kcic export [KIND] [-f FILE] [--format ndjson|csv|parquet] [--snapshot ID | --at TIME | --since TIME --until TIME]
+  kcic import DIRECTORY
+  kcic version
+
+import reads an offline directory and always writes to the database
+configured by DB_* variables.
+
+Flags for every command:
+  --server URL    API root (default $KCIC_SERVER or http://localhost:8081/api/v1)
+  --token TOKEN   bearer token (default $KCIC_TOKEN)
+  --cluster NAME  only data from this cluster (default $KCIC_CLUSTER, else any cluster)
+  --db            read the database configured by DB_* variables instead of the API
+  -o FORMAT       table (default), wide, json or yaml
+
+TIME is RFC3339 or a duration back from now, e.g. 2h.
+Run "kcic COMMAND -h" for the flags of a command.
+`
+
+// options are the flags shared by every command
+type options struct {
+	server  string
+	token   string
+	cluster string
+	db      bool
+	output  string
+}
+
+// command runs one subcommand with its arguments
+type command func(ctx context.Context, args []string, out io.Writer) error
+
+var commands = map[string]command{
+	"clusters":  runClusters,
+	"snapshots": runSnapshots,
+	"get":       runGet,
+	"diff":      runDiff,
+	"history":   runHistory,
+	"export":    runExport,
+	"import":    runImport,
+	"version":   runVersion,
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	run, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[2:], os.Stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newFlagSet returns a flag set with the shared flags bound to opts
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet("kcic "+name, flag.ContinueOnError)
+	server := os.Getenv("KCIC_SERVER")
+	if server == "" {
+		server = "http://localhost:8081/api/v1"
+	}
+	fs.StringVar(&opts.server, "server", server, "API root URL")
+	fs.StringVar(&opts.token, "token", "", "bearer token (default $KCIC_TOKEN)")
+	fs.StringVar(&opts.cluster, "cluster", os.Getenv("KCIC_CLUSTER"), "only data from this cluster (default any)")
+	fs.BoolVar(&opts.db, "db", false, "read the database configured by DB_* variables instead of the API")
+	fs.StringVar(&opts.output, "o", outputTable, "output format: "+strings.Join(outputFormats, ", "))
+	return fs
+}
+
+// parseArgs parses flags placed anywhere among the positional arguments,
+// as in "kcic get pods -n shop"
+func parseArgs(fs *flag.FlagSet, opts *options, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	for _, format := range outputFormats {
+		if opts.output == format {
+			return positional, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid output format %q, expected one of %s", opts.output, strings.Join(outputFormats, ", "))
+}
+
+// connect returns a client for the API, or for an in-process API server over
+// the database with --db. Call the returned function when done.
+func connect(opts options) (*client.Client, func(), error) {
+	if !opts.db {
+		if opts.token == "" {
+			opts.token = os.Getenv("KCIC_TOKEN")
+		}
+		c, err := client.New(client.Config{BaseURL: opts.server, Token: opts.token, UserAgent: "kcic/" + version, Cluster: opts.cluster})
+		return c, func() {}, err
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+	logger.SetLevel(logrus.WarnLevel)
+	transport, closeDB, err := openLocal(logger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	c, err := client.New(client.Config{
+		BaseURL:    localBaseURL,
+		HTTPClient: &http.Client{Transport: transport},
+		UserAgent:  "kcic/" + version,
+		Cluster:    opts.cluster,
+	})
+	if err != nil {
+		closeDB()
+		return nil, nil, err
+	}
+	return c, func() { closeDB() }, nil
+}
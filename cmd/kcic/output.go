@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Output formats accepted by -o
+const (
+	outputTable = "table"
+	outputWide  = "wide"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFormats = []string{outputTable, outputWide, outputJSON, outputYAML}
+
+// table is a kubectl-style listing. items holds the underlying values for
+// JSON and YAML output.
+type table struct {
+	headers []tableCell
+	rows    [][]tableCell
+	items   interface{}
+}
+
+type tableCell struct {
+	text string
+	wide bool // only shown with -o wide
+}
+
+// write prints the table with aligned columns
+func (t *table) write(w io.Writer, wide bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	for _, row := range append([][]tableCell{t.headers}, t.rows...) {
+		var cells []string
+		for _, cell := range row {
+			if cell.wide && !wide {
+				continue
+			}
+			cells = append(cells, cell.text)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// printValue writes v as indented JSON or as YAML
+func printValue(w io.Writer, format string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if format == outputYAML {
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// age formats the time since t like kubectl: 45s, 12m, 5h, 3d
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
+
+func list(values []string) string {
+	return orNone(strings.Join(values, ","))
+}
+
+// parseTime accepts RFC3339 timestamps and durations, which count back from
+// now: "2h" means two hours ago
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339 or a duration such as 2h", value)
+}
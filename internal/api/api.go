@@ -5,34 +5,58 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	graphql "github.com/graph-gophers/graphql-go"
 	"github.com/sirupsen/logrus"
 
+	"k8s-cluster-info-collector/internal/archive"
+	"k8s-cluster-info-collector/internal/auth"
 	"k8s-cluster-info-collector/internal/database"
+	"k8s-cluster-info-collector/internal/kafka"
 	"k8s-cluster-info-collector/internal/models"
+	"k8s-cluster-info-collector/internal/retention"
 	"k8s-cluster-info-collector/internal/streaming"
 )
 
 // Server represents the REST API server
 type Server struct {
-	db         *database.DB
-	logger     *logrus.Logger
-	router     *mux.Router
-	config     APIConfig
-	hub        *streaming.Hub
-	version    string
-	commitHash string
+	db          *database.DB
+	logger      *logrus.Logger
+	router      *mux.Router
+	config      APIConfig
+	hub         *streaming.Hub
+	version     string
+	commitHash  string
+	archiver    *archive.Archiver
+	retention   *retention.RetentionManager
+	deadLetters *kafka.DeadLetterQueue
+
+	authenticator auth.Authenticator
+	policy        *auth.Policy
+
+	limiter       *clientLimiter
+	cache         responseCache
+	snapshotCount cachedCount
+	graphql       *graphql.Schema
 }
 
 // APIConfig holds API server configuration
 type APIConfig struct {
-	Enabled bool
-	Address string
-	Prefix  string
+	Enabled            bool
+	Address            string
+	Prefix             string
+	CORSAllowedOrigins []string // "*" or exact origins; empty allows none
+	RateLimit          float64  // requests per second per client; 0 disables
+	RateLimitBurst     int
+	MaxBodyBytes       int64 // 0 disables
+	CacheEnabled       bool  // cache aggregate responses until a snapshot is stored
+	GraphQLMaxDepth    int   // 0 uses the default
 }
 
 // New creates a new API server
@@ -46,48 +70,106 @@ func New(db *database.DB, logger *logrus.Logger, config APIConfig, hub *streamin
 		version:    version,
 		commitHash: commitHash,
 	}
+	if config.RateLimit > 0 {
+		s.limiter = newClientLimiter(config.RateLimit, config.RateLimitBurst)
+	}
+	// The schema is static, so parsing only fails on a programming error
+	schema, err := newGraphQLSchema(s.newGraphQLRoot(), config.GraphQLMaxDepth)
+	if err != nil {
+		panic(fmt.Sprintf("invalid GraphQL schema: %v", err))
+	}
+	s.graphql = schema
 	s.setupRoutes()
 	return s
 }
 
+// SetArchiver enables the snapshot archive endpoints
+func (s *Server) SetArchiver(archiver *archive.Archiver) {
+	s.archiver = archiver
+}
+
+// SetRetention enables the retention administration endpoints
+func (s *Server) SetRetention(manager *retention.RetentionManager) {
+	s.retention = manager
+}
+
+// SetDeadLetters enables the Kafka dead-letter endpoints
+func (s *Server) SetDeadLetters(queue *kafka.DeadLetterQueue) {
+	s.deadLetters = queue
+}
+
 // setupRoutes configures all API routes
 func (s *Server) setupRoutes() {
-	prefix := s.config.Prefix
-	if prefix == "" {
-		prefix = "/api/v1"
-	}
-
-	api := s.router.PathPrefix(prefix).Subrouter()
+	api := s.router.PathPrefix(s.prefix()).Subrouter()
 
 	// Middleware
 	api.Use(s.loggingMiddleware)
 	api.Use(s.corsMiddleware)
+	api.Use(s.authMiddleware)
+	api.Use(s.rateLimitMiddleware)
+	api.Use(s.bodyLimitMiddleware)
 
 	// Root endpoint: list available endpoints
 	api.HandleFunc("/", s.rootHandler).Methods("GET")
 
+	// Clusters with stored snapshots
+	api.HandleFunc("/clusters", s.requireFullAccess(s.withETag(s.getClusters))).Methods("GET")
+
 	// Snapshots endpoints
-	api.HandleFunc("/snapshots", s.getSnapshots).Methods("GET")
-	api.HandleFunc("/snapshots/{id}", s.getSnapshot).Methods("GET")
-	api.HandleFunc("/snapshots/latest", s.getLatestSnapshot).Methods("GET")
+	api.HandleFunc("/snapshots", s.requireFullAccess(s.withETag(s.getSnapshots))).Methods("GET")
+	// latest before {id}, which would otherwise match it
+	api.HandleFunc("/snapshots/latest", s.requireFullAccess(s.withETag(s.getLatestSnapshot))).Methods("GET")
+	api.HandleFunc("/snapshots/{id}", s.requireFullAccess(s.withETag(s.getSnapshot))).Methods("GET")
+	api.HandleFunc("/snapshots/{a}/diff/{b}", s.requireFullAccess(s.withETag(s.getSnapshotDiff))).Methods("GET")
+	api.HandleFunc("/snapshots/{id}/export", s.requireFullAccess(s.exportSnapshot)).Methods("GET")
+
+	// Streaming exports
+	api.HandleFunc("/export/{kind}", s.exportKind).Methods("GET")
 
 	// Resource endpoints
-	api.HandleFunc("/deployments", s.getDeployments).Methods("GET")
-	api.HandleFunc("/pods", s.getPods).Methods("GET")
-	api.HandleFunc("/nodes", s.getNodes).Methods("GET")
-	api.HandleFunc("/services", s.getServices).Methods("GET")
-	api.HandleFunc("/ingresses", s.getIngresses).Methods("GET")
-	api.HandleFunc("/configmaps", s.getConfigMaps).Methods("GET")
-	api.HandleFunc("/secrets", s.getSecrets).Methods("GET")
-	api.HandleFunc("/persistent-volumes", s.getPersistentVolumes).Methods("GET")
-	api.HandleFunc("/persistent-volume-claims", s.getPersistentVolumeClaims).Methods("GET")
+	api.HandleFunc("/deployments", s.withETag(s.getDeployments)).Methods("GET")
+	api.HandleFunc("/pods", s.withETag(s.getPods)).Methods("GET")
+	api.HandleFunc("/nodes", s.withETag(s.getNodes)).Methods("GET")
+	api.HandleFunc("/services", s.withETag(s.getServices)).Methods("GET")
+	api.HandleFunc("/ingresses", s.withETag(s.getIngresses)).Methods("GET")
+	api.HandleFunc("/configmaps", s.withETag(s.getConfigMaps)).Methods("GET")
+	api.HandleFunc("/secrets", s.withETag(s.getSecrets)).Methods("GET")
+	api.HandleFunc("/persistent-volumes", s.withETag(s.getPersistentVolumes)).Methods("GET")
+	api.HandleFunc("/persistent-volume-claims", s.withETag(s.getPersistentVolumeClaims)).Methods("GET")
+
+	// Per-object detail and history
+	s.registerObjectRoutes(api)
+
+	// Object lifecycle endpoints
+	api.HandleFunc("/objects", s.requireFullAccess(s.withETag(s.getObjects))).Methods("GET")
+
+	// GraphQL endpoint
+	api.HandleFunc("/graphql", s.handleGraphQL).Methods("GET", "POST", "OPTIONS")
+
+	// Capacity aggregation endpoint
+	api.HandleFunc("/capacity", s.requireFullAccess(s.cached(s.getCapacity))).Methods("GET")
+
+	// Snapshot archive endpoints
+	api.HandleFunc("/archives", s.requireFullAccess(s.getArchives)).Methods("GET")
+	api.HandleFunc("/archives/import", s.requireFullAccess(s.importArchive)).Methods("POST", "OPTIONS")
 
 	// WebSocket streaming endpoints
-	api.HandleFunc("/ws", s.handleWebSocket).Methods("GET")
+	api.HandleFunc("/ws", s.requireFullAccess(s.handleWebSocket)).Methods("GET")
 
 	// Statistics endpoints
-	api.HandleFunc("/stats", s.getStats).Methods("GET")
-	api.HandleFunc("/stats/retention", s.getRetentionStats).Methods("GET")
+	api.HandleFunc("/stats", s.requireFullAccess(s.cached(s.getStats))).Methods("GET")
+	api.HandleFunc("/stats/retention", s.requireFullAccess(s.getRetentionStats)).Methods("GET")
+
+	// Retention administration
+	api.HandleFunc("/retention/cleanup", s.requireFullAccess(s.triggerRetentionCleanup)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/retention/dry-run", s.requireFullAccess(s.getRetentionDryRun)).Methods("GET")
+	api.HandleFunc("/retention/last-run", s.requireFullAccess(s.getRetentionLastRun)).Methods("GET")
+
+	// Kafka dead-letter endpoints (consumer only)
+	api.HandleFunc("/kafka/dead-letters", s.requireFullAccess(s.getDeadLetters)).Methods("GET")
+	api.HandleFunc("/kafka/dead-letters/replay", s.requireFullAccess(s.replayDeadLetters)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/kafka/dead-letters/{partition}/{offset}", s.requireFullAccess(s.getDeadLetter)).Methods("GET")
+	api.HandleFunc("/kafka/dead-letters/{partition}/{offset}/replay", s.requireFullAccess(s.replayDeadLetter)).Methods("POST", "OPTIONS")
 
 	// Health endpoints
 	api.HandleFunc("/health", s.healthHandler).Methods("GET")
@@ -101,14 +183,21 @@ func (s *Server) setupRoutes() {
 
 	// Version endpoint
 	api.HandleFunc("/version", s.versionHandler).Methods("GET")
+
+	// OpenAPI document generated from the routes above
+	api.HandleFunc("/openapi.json", s.serveOpenAPI).Methods("GET")
 }
 
 // rootHandler returns a list of available endpoints
 func (s *Server) rootHandler(w http.ResponseWriter, r *http.Request) {
 	endpoints := []string{
+		"/clusters",
 		"/snapshots",
 		"/snapshots/{id}",
 		"/snapshots/latest",
+		"/snapshots/{a}/diff/{b}",
+		"/snapshots/{id}/export",
+		"/export/{kind}",
 		"/deployments",
 		"/pods",
 		"/nodes",
@@ -118,20 +207,34 @@ func (s *Server) rootHandler(w http.ResponseWriter, r *http.Request) {
 		"/secrets",
 		"/persistent-volumes",
 		"/persistent-volume-claims",
+		"/objects",
+		"/graphql",
+		"/capacity",
+		"/archives",
+		"/archives/import",
 		"/ws",
 		"/stats",
 		"/stats/retention",
+		"/retention/cleanup",
+		"/retention/dry-run",
+		"/retention/last-run",
+		"/kafka/dead-letters",
+		"/kafka/dead-letters/replay",
+		"/kafka/dead-letters/{partition}/{offset}",
+		"/kafka/dead-letters/{partition}/{offset}/replay",
 		"/health",
 		"/healthz",
 		"/metrics",
 		"/ready",
 		"/version",
+		"/openapi.json",
 	}
-	s.writeJSON(w, map[string]interface{}{
-		"service":   "k8s-cluster-info-collector API",
-		"version":   s.version,
-		"commit":    s.commitHash,
-		"endpoints": endpoints,
+	endpoints = append(endpoints, objectPaths()...)
+	s.writeJSON(w, RootResponse{
+		Service:   "k8s-cluster-info-collector API",
+		Version:   s.version,
+		Commit:    s.commitHash,
+		Endpoints: endpoints,
 	})
 }
 
@@ -152,12 +255,12 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 		"server":   "healthy",
 	}
 
-	response := map[string]interface{}{
-		"status":      status,
-		"timestamp":   time.Now().Format(time.RFC3339),
-		"service":     "api-server",
-		"description": description,
-		"checks":      checks,
+	response := HealthResponse{
+		Status:      status,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Service:     "api-server",
+		Description: description,
+		Checks:      checks,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	encoder := json.NewEncoder(w)
@@ -170,25 +273,25 @@ func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	metrics := map[string]interface{}{
-		"go_version":    runtime.Version(),
-		"num_goroutine": runtime.NumGoroutine(),
-		"num_cpu":       runtime.NumCPU(),
-		"memory": map[string]interface{}{
-			"alloc_mb":       bToMb(m.Alloc),
-			"total_alloc_mb": bToMb(m.TotalAlloc),
-			"sys_mb":         bToMb(m.Sys),
-			"heap_alloc_mb":  bToMb(m.HeapAlloc),
-			"heap_sys_mb":    bToMb(m.HeapSys),
-			"heap_idle_mb":   bToMb(m.HeapIdle),
-			"heap_inuse_mb":  bToMb(m.HeapInuse),
-			"num_gc":         m.NumGC,
+	metrics := RuntimeMetrics{
+		GoVersion:    runtime.Version(),
+		NumGoroutine: runtime.NumGoroutine(),
+		NumCPU:       runtime.NumCPU(),
+		Memory: MemoryMetrics{
+			AllocMB:      bToMb(m.Alloc),
+			TotalAllocMB: bToMb(m.TotalAlloc),
+			SysMB:        bToMb(m.Sys),
+			HeapAllocMB:  bToMb(m.HeapAlloc),
+			HeapSysMB:    bToMb(m.HeapSys),
+			HeapIdleMB:   bToMb(m.HeapIdle),
+			HeapInuseMB:  bToMb(m.HeapInuse),
+			NumGC:        m.NumGC,
 		},
-		"os": map[string]interface{}{
-			"goos":   runtime.GOOS,
-			"goarch": runtime.GOARCH,
+		OS: OSInfo{
+			GOOS:   runtime.GOOS,
+			GOARCH: runtime.GOARCH,
 		},
-		"timestamp": time.Now().Format(time.RFC3339),
+		Timestamp: time.Now().Format(time.RFC3339),
 	}
 	w.Header().Set("Content-Type", "application/json")
 	encoder := json.NewEncoder(w)
@@ -210,11 +313,11 @@ func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
 
 // Version handler (merged from consumer/server)
 func (s *Server) versionHandler(w http.ResponseWriter, r *http.Request) {
-	version := map[string]string{
-		"version":     s.version,
-		"commit_hash": s.commitHash,
-		"go_version":  runtime.Version(),
-		"service":     "api-server",
+	version := VersionResponse{
+		Version:    s.version,
+		CommitHash: s.commitHash,
+		GoVersion:  runtime.Version(),
+		Service:    "api-server",
 	}
 	w.Header().Set("Content-Type", "application/json")
 	encoder := json.NewEncoder(w)
@@ -227,15 +330,10 @@ func bToMb(b uint64) uint64 {
 	return b / 1024 / 1024
 }
 
-// Start starts the API server
-func (s *Server) Start() error {
-	if !s.config.Enabled {
-		s.logger.Info("API server is disabled")
-		return nil
-	}
-
-	s.logger.WithField("address", s.config.Address).Info("Starting API server")
-	return http.ListenAndServe(s.config.Address, s.router)
+// Handler returns the HTTP handler serving the API. The server itself is
+// run by the process lifecycle manager.
+func (s *Server) Handler() http.Handler {
+	return s.router
 }
 
 // Middleware functions
@@ -253,9 +351,14 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		if origin := s.allowedOrigin(r.Header.Get("Origin")); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if origin != "*" {
+				w.Header().Add("Vary", "Origin")
+			}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -278,7 +381,7 @@ func (s *Server) writeJSON(w http.ResponseWriter, data interface{}) {
 func (s *Server) writeError(w http.ResponseWriter, message string, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
 }
 
 // API endpoint handlers
@@ -291,7 +394,7 @@ func (s *Server) getSnapshots(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := s.db.Query(`
-		SELECT id, timestamp, 
+		SELECT id, timestamp, cluster,
 			(SELECT COUNT(*) FROM deployments WHERE snapshot_id = cs.id) as deployments,
 			(SELECT COUNT(*) FROM pods WHERE snapshot_id = cs.id) as pods,
 			(SELECT COUNT(*) FROM nodes WHERE snapshot_id = cs.id) as nodes,
@@ -302,9 +405,10 @@ func (s *Server) getSnapshots(w http.ResponseWriter, r *http.Request) {
 			(SELECT COUNT(*) FROM persistent_volumes WHERE snapshot_id = cs.id) as persistent_volumes,
 			(SELECT COUNT(*) FROM persistent_volume_claims WHERE snapshot_id = cs.id) as persistent_volume_claims
 		FROM cluster_snapshots cs
+		WHERE ($2 = '' OR cs.cluster = $2)
 		ORDER BY timestamp DESC
 		LIMIT $1
-	`, limit)
+	`, limit, requestCluster(r))
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to query snapshots")
 		s.writeError(w, "Failed to fetch snapshots", http.StatusInternalServerError)
@@ -312,36 +416,22 @@ func (s *Server) getSnapshots(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	var snapshots []map[string]interface{}
+	snapshots := []SnapshotSummary{}
 	for rows.Next() {
-		var id int
-		var timestamp time.Time
-		var deployments, pods, nodes, services, ingresses, configmaps, secrets, pvs, pvcs int
-
-		err := rows.Scan(&id, &timestamp, &deployments, &pods, &nodes, &services, &ingresses, &configmaps, &secrets, &pvs, &pvcs)
+		var summary SnapshotSummary
+		err := rows.Scan(&summary.ID, &summary.Timestamp, &summary.Cluster, &summary.Deployments, &summary.Pods, &summary.Nodes,
+			&summary.Services, &summary.Ingresses, &summary.ConfigMaps, &summary.Secrets,
+			&summary.PersistentVolumes, &summary.PersistentVolumeClaims)
 		if err != nil {
 			s.logger.WithError(err).Error("Failed to scan snapshot row")
 			continue
 		}
-
-		snapshots = append(snapshots, map[string]interface{}{
-			"id":                       id,
-			"timestamp":                timestamp,
-			"deployments":              deployments,
-			"pods":                     pods,
-			"nodes":                    nodes,
-			"services":                 services,
-			"ingresses":                ingresses,
-			"configmaps":               configmaps,
-			"secrets":                  secrets,
-			"persistent_volumes":       pvs,
-			"persistent_volume_claims": pvcs,
-		})
+		snapshots = append(snapshots, summary)
 	}
 
-	s.writeJSON(w, map[string]interface{}{
-		"snapshots": snapshots,
-		"count":     len(snapshots),
+	s.writeJSON(w, SnapshotList{
+		Snapshots: snapshots,
+		Count:     len(snapshots),
 	})
 }
 
@@ -357,7 +447,8 @@ func (s *Server) getSnapshot(w http.ResponseWriter, r *http.Request) {
 
 	var data string
 	var timestamp time.Time
-	err = s.db.QueryRow("SELECT timestamp, data FROM cluster_snapshots WHERE id = $1", id).Scan(&timestamp, &data)
+	err = s.db.QueryRow("SELECT timestamp, data FROM cluster_snapshots WHERE id = $1 AND ($2 = '' OR cluster = $2)",
+		id, requestCluster(r)).Scan(&timestamp, &data)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			s.writeError(w, "Snapshot not found", http.StatusNotFound)
@@ -375,16 +466,21 @@ func (s *Server) getSnapshot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.writeJSON(w, map[string]interface{}{
-		"id":           id,
-		"timestamp":    timestamp,
-		"cluster_info": clusterInfo,
+	s.writeJSON(w, SnapshotResponse{
+		ID:          id,
+		Timestamp:   timestamp,
+		ClusterInfo: clusterInfo,
 	})
 }
 
 func (s *Server) getLatestSnapshot(w http.ResponseWriter, r *http.Request) {
+	cluster, ok := s.resolveClusterFromRequest(w, r)
+	if !ok {
+		return
+	}
 	var id int
-	err := s.db.QueryRow("SELECT id FROM cluster_snapshots ORDER BY timestamp DESC LIMIT 1").Scan(&id)
+	err := s.db.QueryRow("SELECT id FROM cluster_snapshots WHERE ($1 = '' OR cluster = $1) ORDER BY timestamp DESC LIMIT 1",
+		cluster).Scan(&id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			s.writeError(w, "No snapshots found", http.StatusNotFound)
@@ -396,73 +492,216 @@ func (s *Server) getLatestSnapshot(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Redirect to the specific snapshot endpoint
-	http.Redirect(w, r, fmt.Sprintf("%s/snapshots/%d", s.config.Prefix, id), http.StatusFound)
+	location := fmt.Sprintf("%s/snapshots/%d", s.config.Prefix, id)
+	if cluster != "" {
+		location += "?cluster=" + url.QueryEscape(cluster)
+	}
+	http.Redirect(w, r, location, http.StatusFound)
 }
 
 func (s *Server) getDeployments(w http.ResponseWriter, r *http.Request) {
-	s.getResourceData(w, r, "deployments", "name, namespace, replicas, ready_replicas, created_time")
+	getResourceData(s, w, r, "deployments",
+		"name, namespace, COALESCE(replicas, 0), COALESCE(ready_replicas, 0), created_time",
+		func(row *DeploymentRow) []interface{} {
+			return []interface{}{&row.Name, &row.Namespace, &row.Replicas, &row.ReadyReplicas, &row.CreatedTime}
+		})
 }
 
 func (s *Server) getPods(w http.ResponseWriter, r *http.Request) {
-	s.getResourceData(w, r, "pods", "name, namespace, phase, node_name, restart_count, created_time")
+	getResourceData(s, w, r, "pods",
+		"name, namespace, COALESCE(phase, ''), COALESCE(node_name, ''), COALESCE(restart_count, 0), created_time",
+		func(row *PodRow) []interface{} {
+			return []interface{}{&row.Name, &row.Namespace, &row.Phase, &row.NodeName, &row.RestartCount, &row.CreatedTime}
+		})
 }
 
 func (s *Server) getNodes(w http.ResponseWriter, r *http.Request) {
-	s.getResourceData(w, r, "nodes", "name, ready, cpu_capacity, memory_capacity, created_time")
+	getResourceData(s, w, r, "nodes",
+		"name, COALESCE(ready, false), COALESCE(cpu_capacity, ''), COALESCE(memory_capacity, ''), created_time",
+		func(row *NodeRow) []interface{} {
+			return []interface{}{&row.Name, &row.Ready, &row.CPUCapacity, &row.MemoryCapacity, &row.CreatedTime}
+		})
 }
 
 func (s *Server) getServices(w http.ResponseWriter, r *http.Request) {
-	s.getResourceData(w, r, "services", "name, namespace, type, cluster_ip, created_time")
+	getResourceData(s, w, r, "services",
+		"name, namespace, COALESCE(type, ''), COALESCE(cluster_ip, ''), created_time",
+		func(row *ServiceRow) []interface{} {
+			return []interface{}{&row.Name, &row.Namespace, &row.Type, &row.ClusterIP, &row.CreatedTime}
+		})
 }
 
 func (s *Server) getIngresses(w http.ResponseWriter, r *http.Request) {
-	s.getResourceData(w, r, "ingresses", "name, namespace, hosts, created_time")
+	getResourceData(s, w, r, "ingresses",
+		"name, namespace, hosts, created_time",
+		func(row *IngressRow) []interface{} {
+			return []interface{}{&row.Name, &row.Namespace, &row.Hosts, &row.CreatedTime}
+		})
 }
 
 func (s *Server) getConfigMaps(w http.ResponseWriter, r *http.Request) {
-	s.getResourceData(w, r, "configmaps", "name, namespace, data_keys, created_time")
+	getResourceData(s, w, r, "configmaps",
+		"name, namespace, data_keys, created_time",
+		func(row *ConfigMapRow) []interface{} {
+			return []interface{}{&row.Name, &row.Namespace, &row.DataKeys, &row.CreatedTime}
+		})
 }
 
 func (s *Server) getSecrets(w http.ResponseWriter, r *http.Request) {
-	s.getResourceData(w, r, "secrets", "name, namespace, type, data_keys, created_time")
+	getResourceData(s, w, r, "secrets",
+		"name, namespace, COALESCE(type, ''), data_keys, created_time",
+		func(row *SecretRow) []interface{} {
+			return []interface{}{&row.Name, &row.Namespace, &row.Type, &row.DataKeys, &row.CreatedTime}
+		})
 }
 
 func (s *Server) getPersistentVolumes(w http.ResponseWriter, r *http.Request) {
-	s.getResourceData(w, r, "persistent_volumes", "name, capacity, access_modes, status, storage_class, created_time")
+	getResourceData(s, w, r, "persistent_volumes",
+		"name, COALESCE(capacity, ''), access_modes, COALESCE(status, ''), COALESCE(storage_class, ''), created_time",
+		func(row *PersistentVolumeRow) []interface{} {
+			return []interface{}{&row.Name, &row.Capacity, &row.AccessModes, &row.Status, &row.StorageClass, &row.CreatedTime}
+		})
 }
 
 func (s *Server) getPersistentVolumeClaims(w http.ResponseWriter, r *http.Request) {
-	s.getResourceData(w, r, "persistent_volume_claims", "name, namespace, requested_size, access_modes, status, created_time")
+	getResourceData(s, w, r, "persistent_volume_claims",
+		"name, namespace, COALESCE(requested_size, ''), access_modes, COALESCE(status, ''), created_time",
+		func(row *PersistentVolumeClaimRow) []interface{} {
+			return []interface{}{&row.Name, &row.Namespace, &row.RequestedSize, &row.AccessModes, &row.Status, &row.CreatedTime}
+		})
 }
 
-func (s *Server) getResourceData(w http.ResponseWriter, r *http.Request, table, columns string) {
-	snapshotID := s.getLatestSnapshotID()
-	if snapshotID == 0 {
-		s.writeError(w, "No snapshots available", http.StatusNotFound)
+// getResourceData lists rows of a resource table from one snapshot, selected
+// by snapshot_id or at (default latest). It supports cursor pagination
+// (limit, cursor), sort (e.g. sort=-created_time), the table's filters
+// (namespace, phase, node, status, type) and labelSelector. fields returns
+// scan destinations in the order of columns.
+func getResourceData[T any](s *Server, w http.ResponseWriter, r *http.Request, table, columns string, fields func(*T) []interface{}) {
+	query := r.URL.Query()
+	options := listTables[table]
+
+	access, ok := s.authorizeKind(w, r, kindForTable(table))
+	if !ok {
 		return
 	}
 
 	// Parse query parameters
 	limit := 100
-	if l := r.URL.Query().Get("limit"); l != "" {
+	if l := query.Get("limit"); l != "" {
 		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
 			limit = parsed
 		}
 	}
 
-	namespace := r.URL.Query().Get("namespace")
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = "-created_time"
+	}
+	sortKey, descending := parseSort(sort)
+	sortExpr, ok := options.sortExpression(sortKey)
+	if !ok {
+		s.writeError(w, fmt.Sprintf("Cannot sort %s by %q", table, sortKey), http.StatusBadRequest)
+		return
+	}
+
+	var cursor *listCursor
+	var snapshot models.SnapshotRef
+	if c := query.Get("cursor"); c != "" {
+		decoded, err := decodeCursor(c)
+		if err != nil || decoded.Sort != sort {
+			s.writeError(w, "Invalid cursor for this query", http.StatusBadRequest)
+			return
+		}
+		cursor = &decoded
+
+		// Keep paging through the snapshot the first page came from
+		err = s.db.QueryRow("SELECT id, timestamp, cluster FROM cluster_snapshots WHERE id = $1", decoded.SnapshotID).
+			Scan(&snapshot.ID, &snapshot.Timestamp, &snapshot.Cluster)
+		if err == sql.ErrNoRows {
+			s.writeError(w, "The snapshot this cursor refers to no longer exists", http.StatusGone)
+			return
+		}
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to query snapshot")
+			s.writeError(w, "Failed to fetch snapshot", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		if snapshot, ok = s.snapshotFromRequest(w, r); !ok {
+			return
+		}
+	}
 
-	query := fmt.Sprintf("SELECT %s FROM %s WHERE snapshot_id = $1", columns, table)
-	args := []interface{}{snapshotID}
+	conditions := []string{"snapshot_id = $1"}
+	args := []interface{}{snapshot.ID}
 
-	if namespace != "" {
-		query += " AND namespace = $2"
+	if namespace := query.Get("namespace"); namespace != "" {
+		if !options.namespaced {
+			s.writeError(w, fmt.Sprintf("%s are not namespaced", table), http.StatusBadRequest)
+			return
+		}
+		if !s.authorizeNamespace(w, access, namespace) {
+			return
+		}
 		args = append(args, namespace)
+		conditions = append(conditions, fmt.Sprintf("namespace = $%d", len(args)))
+	}
+	if condition, restricted := namespaceCondition(access, "namespace", args); condition != "" {
+		args = restricted
+		conditions = append(conditions, condition)
 	}
 
-	query += fmt.Sprintf(" ORDER BY created_time DESC LIMIT %d", limit)
+	for _, param := range listFilterParams {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		column, ok := options.filters[param]
+		if !ok {
+			s.writeError(w, fmt.Sprintf("Filter %q is not supported for %s", param, table), http.StatusBadRequest)
+			return
+		}
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
 
-	rows, err := s.db.Query(query, args...)
+	if selector := query.Get("labelSelector"); selector != "" {
+		requirements, err := parseLabelSelector(selector)
+		if err != nil {
+			s.writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, req := range requirements {
+			condition, reqArgs := req.sqlCondition(len(args) + 1)
+			args = append(args, reqArgs...)
+			conditions = append(conditions, condition)
+		}
+	}
+
+	where := strings.Join(conditions, " AND ")
+
+	// Total matching rows, ignoring pagination
+	var total int
+	if err := s.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", table, where), args...).Scan(&total); err != nil {
+		s.logger.WithError(err).Error("Failed to count resource data")
+		s.writeError(w, "Failed to fetch resource data", http.StatusInternalServerError)
+		return
+	}
+
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+	if cursor != nil {
+		args = append(args, cursor.Value, cursor.ID)
+		where += fmt.Sprintf(" AND (%s, id) %s ($%d, $%d)", sortExpr, comparison, len(args)-1, len(args))
+	}
+
+	// Fetch one extra row to learn whether there is another page
+	sqlQuery := fmt.Sprintf("SELECT id, (%s)::text, %s FROM %s WHERE %s ORDER BY %s %s, id %s LIMIT %d",
+		sortExpr, columns, table, where, sortExpr, direction, direction, limit+1)
+
+	rows, err := s.db.Query(sqlQuery, args...)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to query resource data")
 		s.writeError(w, "Failed to fetch resource data", http.StatusInternalServerError)
@@ -470,46 +709,159 @@ func (s *Server) getResourceData(w http.ResponseWriter, r *http.Request, table,
 	}
 	defer rows.Close()
 
-	var results []map[string]interface{}
+	results := []T{}
+	var lastID int64
+	var lastValue sql.NullString
+	hasMore := false
 	for rows.Next() {
-		columns, err := rows.Columns()
-		if err != nil {
+		if len(results) == limit {
+			hasMore = true
+			break
+		}
+
+		var row T
+		dest := append([]interface{}{&lastID, &lastValue}, fields(&row)...)
+		if err := rows.Scan(dest...); err != nil {
+			s.logger.WithError(err).Error("Failed to scan resource row")
 			continue
 		}
+		results = append(results, row)
+	}
 
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
+	response := ResourceList[T]{
+		Data:     results,
+		Count:    len(results),
+		Total:    total,
+		Snapshot: snapshot,
+	}
+	if hasMore {
+		response.NextCursor = encodeCursor(listCursor{
+			SnapshotID: snapshot.ID,
+			Sort:       sort,
+			Value:      lastValue.String,
+			ID:         lastID,
+		})
+	}
+	s.writeJSON(w, response)
+}
+
+// getObjects lists objects created or deleted within a time window.
+// Query parameters: since, until (RFC3339, default last 24h), event
+// (created, deleted or all), kind, namespace and cluster.
+func (s *Server) getObjects(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	until := time.Now()
+	if u := query.Get("until"); u != "" {
+		parsed, err := time.Parse(time.RFC3339, u)
+		if err != nil {
+			s.writeError(w, "Invalid until timestamp, expected RFC3339", http.StatusBadRequest)
+			return
 		}
+		until = parsed
+	}
 
-		if err := rows.Scan(valuePtrs...); err != nil {
-			continue
+	since := until.Add(-24 * time.Hour)
+	if v := query.Get("since"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			s.writeError(w, "Invalid since timestamp, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	event := query.Get("event")
+	if event == "" {
+		event = "all"
+	}
+
+	var condition string
+	switch event {
+	case "created":
+		condition = "(created_time >= $1 AND created_time < $2)"
+	case "deleted":
+		condition = "(deleted_at >= $1 AND deleted_at < $2)"
+	case "all":
+		condition = "((created_time >= $1 AND created_time < $2) OR (deleted_at >= $1 AND deleted_at < $2))"
+	default:
+		s.writeError(w, "Invalid event, expected created, deleted or all", http.StatusBadRequest)
+		return
+	}
+
+	limit := 100
+	if l := query.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
 		}
+	}
+
+	sqlQuery := `
+		SELECT cluster, kind, uid, COALESCE(namespace, ''), name, created_time, first_seen, last_seen, 
+			deleted_at, first_snapshot_id, last_snapshot_id
+		FROM objects WHERE ` + condition
+	args := []interface{}{since, until}
+
+	if kind := query.Get("kind"); kind != "" {
+		args = append(args, kind)
+		sqlQuery += fmt.Sprintf(" AND kind = $%d", len(args))
+	}
+	if namespace := query.Get("namespace"); namespace != "" {
+		args = append(args, namespace)
+		sqlQuery += fmt.Sprintf(" AND namespace = $%d", len(args))
+	}
+	if cluster := requestCluster(r); cluster != "" {
+		args = append(args, cluster)
+		sqlQuery += fmt.Sprintf(" AND cluster = $%d", len(args))
+	}
+
+	sqlQuery += fmt.Sprintf(" ORDER BY GREATEST(created_time, COALESCE(deleted_at, created_time)) DESC LIMIT %d", limit)
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to query objects")
+		s.writeError(w, "Failed to fetch objects", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
 
-		result := make(map[string]interface{})
-		for i, col := range columns {
-			result[col] = values[i]
+	objects := []models.ObjectLifecycle{}
+	for rows.Next() {
+		var obj models.ObjectLifecycle
+		var deletedAt sql.NullTime
+		err := rows.Scan(&obj.Cluster, &obj.Kind, &obj.UID, &obj.Namespace, &obj.Name, &obj.CreatedTime,
+			&obj.FirstSeen, &obj.LastSeen, &deletedAt, &obj.FirstSnapshotID, &obj.LastSnapshotID)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to scan object row")
+			continue
 		}
-		results = append(results, result)
+		if deletedAt.Valid {
+			obj.DeletedAt = &deletedAt.Time
+		}
+		objects = append(objects, obj)
 	}
 
-	s.writeJSON(w, map[string]interface{}{
-		"data":  results,
-		"count": len(results),
+	s.writeJSON(w, ObjectList{
+		Since:   since,
+		Until:   until,
+		Event:   event,
+		Objects: objects,
+		Count:   len(objects),
 	})
 }
 
 func (s *Server) getStats(w http.ResponseWriter, r *http.Request) {
-	stats := make(map[string]interface{})
+	var stats Stats
+	cluster, ok := s.resolveClusterFromRequest(w, r)
+	if !ok {
+		return
+	}
 
 	// Total snapshots
-	var totalSnapshots int
-	s.db.QueryRow("SELECT COUNT(*) FROM cluster_snapshots").Scan(&totalSnapshots)
-	stats["total_snapshots"] = totalSnapshots
+	s.db.QueryRow("SELECT COUNT(*) FROM cluster_snapshots WHERE ($1 = '' OR cluster = $1)", cluster).Scan(&stats.TotalSnapshots)
 
 	// Latest snapshot stats
-	snapshotID := s.getLatestSnapshotID()
+	snapshotID := s.getLatestSnapshotID(cluster)
 	if snapshotID > 0 {
 		latestStats := make(map[string]int)
 		tables := []string{"deployments", "pods", "nodes", "services", "ingresses", "configmaps", "secrets", "persistent_volumes", "persistent_volume_claims"}
@@ -519,29 +871,9 @@ func (s *Server) getStats(w http.ResponseWriter, r *http.Request) {
 			s.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE snapshot_id = $1", table), snapshotID).Scan(&count)
 			latestStats[table] = count
 		}
-		stats["latest_snapshot"] = latestStats
-	}
-
-	s.writeJSON(w, stats)
-}
-
-func (s *Server) getRetentionStats(w http.ResponseWriter, r *http.Request) {
-	stats := make(map[string]interface{})
-
-	// Database size
-	var dbSize string
-	err := s.db.QueryRow("SELECT pg_size_pretty(pg_database_size(current_database()))").Scan(&dbSize)
-	if err == nil {
-		stats["database_size"] = dbSize
+		stats.LatestSnapshot = latestStats
 	}
 
-	// Oldest and newest snapshots
-	var oldest, newest time.Time
-	s.db.QueryRow("SELECT MIN(timestamp), MAX(timestamp) FROM cluster_snapshots").Scan(&oldest, &newest)
-	stats["oldest_snapshot"] = oldest
-	stats["newest_snapshot"] = newest
-	stats["retention_span"] = newest.Sub(oldest).String()
-
 	s.writeJSON(w, stats)
 }
 
@@ -558,9 +890,12 @@ func (s *Server) getHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (s *Server) getLatestSnapshotID() int {
+// getLatestSnapshotID returns the ID of the latest snapshot of a cluster, or
+// of any cluster when cluster is empty, and 0 when there is none. Callers
+// resolve an empty cluster with resolveCluster first.
+func (s *Server) getLatestSnapshotID(cluster string) int {
 	var id int
-	s.db.QueryRow("SELECT id FROM cluster_snapshots ORDER BY timestamp DESC LIMIT 1").Scan(&id)
+	s.db.QueryRow("SELECT id FROM cluster_snapshots WHERE ($1 = '' OR cluster = $1) ORDER BY timestamp DESC LIMIT 1", cluster).Scan(&id)
 	return id
 }
 
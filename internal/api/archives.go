@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"k8s-cluster-info-collector/internal/archive"
+	"k8s-cluster-info-collector/internal/store"
+)
+
+// ImportArchiveRequest is the body of POST /archives/import
+type ImportArchiveRequest struct {
+	Location string `json:"location"`
+}
+
+// getArchives lists the archive catalog
+func (s *Server) getArchives(w http.ResponseWriter, r *http.Request) {
+	if s.archiver == nil {
+		s.writeError(w, "Snapshot archiving not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+
+	entries, err := s.archiver.ListArchives(r.Context(), limit)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list archives")
+		s.writeError(w, "Failed to fetch archives", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, ArchiveList{
+		Archives: entries,
+		Count:    len(entries),
+	})
+}
+
+// importArchive re-imports an archive file into the database
+func (s *Server) importArchive(w http.ResponseWriter, r *http.Request) {
+	if s.archiver == nil {
+		s.writeError(w, "Snapshot archiving not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req ImportArchiveRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		s.writeError(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil || req.Location == "" {
+		s.writeError(w, "Request body must be JSON with a location", http.StatusBadRequest)
+		return
+	}
+
+	imported, err := s.archiver.Import(r.Context(), req.Location, store.New(s.db, s.logger))
+	if errors.Is(err, archive.ErrOutsideDestination) {
+		s.writeError(w, "Archive location is outside the archive destination", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("location", req.Location).Error("Failed to import archive")
+		s.writeError(w, "Failed to import archive", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, ImportArchiveResponse{
+		Location: req.Location,
+		Imported: imported,
+	})
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/auth"
+)
+
+// publicPaths are served without authentication so probes and scrapers work
+var publicPaths = map[string]bool{
+	"/health":  true,
+	"/healthz": true,
+	"/ready":   true,
+	"/metrics": true,
+	"/version": true,
+	// The API description is not sensitive and clients need it to authenticate
+	"/openapi.json": true,
+}
+
+// SetAuth requires callers to authenticate and restricts what they can see
+// according to policy. A nil policy gives every authenticated caller full access.
+func (s *Server) SetAuth(authenticator auth.Authenticator, policy *auth.Policy) {
+	s.authenticator = authenticator
+	s.policy = policy
+}
+
+// authMiddleware authenticates the bearer token and stores the identity in
+// the request context
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.authenticator == nil || r.Method == http.MethodOptions || publicPaths[strings.TrimPrefix(r.URL.Path, s.prefix())] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := auth.BearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			s.writeError(w, "Missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		identity, err := s.authenticator.Authenticate(r.Context(), token)
+		switch {
+		case errors.Is(err, auth.ErrUnauthenticated):
+			s.logger.WithError(err).Debug("Rejected bearer token")
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			s.writeError(w, "Invalid bearer token", http.StatusUnauthorized)
+			return
+		case err != nil:
+			// The token could not be checked, e.g. the JWKS or TokenReview
+			// API is unreachable; the client may retry
+			s.logger.WithError(err).Error("Authentication failed")
+			s.writeError(w, "Authentication is temporarily unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		s.logger.WithFields(logrus.Fields{
+			"subject": identity.Subject,
+			"method":  identity.Method,
+		}).Debug("Authenticated API request")
+		next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+	})
+}
+
+// requireFullAccess wraps handlers that span every kind and namespace, such
+// as whole snapshots, diffs and administrative actions
+func (s *Server) requireFullAccess(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.authenticator != nil && !s.policy.FullAccess(auth.IdentityFrom(r.Context())) {
+			s.writeError(w, "Forbidden: this endpoint requires access to all namespaces and kinds", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorizeKind returns the caller's access to a kind, writing 403 when the
+// caller can see none of it. Cluster-scoped kinds require access to all
+// namespaces.
+func (s *Server) authorizeKind(w http.ResponseWriter, r *http.Request, kind resourceKind) (auth.Access, bool) {
+	if s.authenticator == nil {
+		return auth.Access{AllNamespaces: true}, true
+	}
+	access := s.policy.Access(auth.IdentityFrom(r.Context()), kind.kind)
+	if !access.Allowed() || (!kind.namespaced && !access.AllNamespaces) {
+		s.writeError(w, fmt.Sprintf("Forbidden: no access to %s", kind.path), http.StatusForbidden)
+		return access, false
+	}
+	return access, true
+}
+
+// authorizeNamespace writes 403 unless access covers namespace
+func (s *Server) authorizeNamespace(w http.ResponseWriter, access auth.Access, namespace string) bool {
+	if !access.Allows(namespace) {
+		s.writeError(w, fmt.Sprintf("Forbidden: no access to namespace %q", namespace), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// namespaceCondition restricts a query to the namespaces access covers. It
+// returns an empty condition when every namespace is visible.
+func namespaceCondition(access auth.Access, column string, args []interface{}) (string, []interface{}) {
+	if access.AllNamespaces {
+		return "", args
+	}
+	args = append(args, pq.Array(access.Namespaces))
+	return fmt.Sprintf("%s = ANY($%d)", column, len(args)), args
+}
+
+// kindForTable returns the resource kind stored in table
+func kindForTable(table string) resourceKind {
+	for _, kind := range resourceKinds {
+		if kind.table == table {
+			return kind
+		}
+	}
+	return resourceKind{table: table}
+}
+
+// prefix returns the configured API path prefix
+func (s *Server) prefix() string {
+	if s.config.Prefix == "" {
+		return "/api/v1"
+	}
+	return s.config.Prefix
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for origin,
+// or "" when the origin is not allowed. Without configured origins only
+// same-origin requests work; "*" must be configured explicitly.
+func (s *Server) allowedOrigin(origin string) string {
+	for _, allowed := range s.config.CORSAllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
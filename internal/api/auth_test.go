@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/auth"
+)
+
+type staticAuthenticator map[string]string
+
+func (a staticAuthenticator) Authenticate(_ context.Context, token string) (*auth.Identity, error) {
+	if subject, ok := a[token]; ok {
+		return &auth.Identity{Subject: subject, Method: "token"}, nil
+	}
+	if token == "unavailable" {
+		return nil, errors.New("token review failed: connection refused")
+	}
+	return nil, auth.ErrUnauthenticated
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	s := &Server{logger: logrus.New(), config: APIConfig{Prefix: "/api/v1"}}
+	s.SetAuth(staticAuthenticator{"s3cr3t": "alice"}, nil)
+
+	handler := s.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject := "anonymous"
+		if identity := auth.IdentityFrom(r.Context()); identity != nil {
+			subject = identity.Subject
+		}
+		w.Write([]byte(subject))
+	}))
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		code   int
+		body   string
+	}{
+		{"valid token", "/api/v1/pods", "Bearer s3cr3t", http.StatusOK, "alice"},
+		{"missing token", "/api/v1/pods", "", http.StatusUnauthorized, ""},
+		{"invalid token", "/api/v1/pods", "Bearer wrong", http.StatusUnauthorized, ""},
+		{"authenticator unavailable", "/api/v1/pods", "Bearer unavailable", http.StatusServiceUnavailable, ""},
+		{"public endpoint", "/api/v1/healthz", "", http.StatusOK, "anonymous"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, rec.Code)
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAllowedOrigin(t *testing.T) {
+	s := &Server{config: APIConfig{CORSAllowedOrigins: []string{"https://dashboard.example.com"}}}
+	if got := s.allowedOrigin("https://dashboard.example.com"); got != "https://dashboard.example.com" {
+		t.Errorf("expected matching origin to be echoed, got %q", got)
+	}
+	if got := s.allowedOrigin("https://evil.example.com"); got != "" {
+		t.Errorf("expected other origins to be rejected, got %q", got)
+	}
+
+	s.config.CORSAllowedOrigins = nil
+	if got := s.allowedOrigin("https://dashboard.example.com"); got != "" {
+		t.Errorf("expected no origin to be allowed by default, got %q", got)
+	}
+
+	s.config.CORSAllowedOrigins = []string{"*"}
+	if got := s.allowedOrigin("https://evil.example.com"); got != "*" {
+		t.Errorf("expected wildcard, got %q", got)
+	}
+}
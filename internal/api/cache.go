@@ -0,0 +1,207 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s-cluster-info-collector/internal/auth"
+)
+
+// maxCachedResponseBytes bounds the size of a single cached response
+const maxCachedResponseBytes = 1 << 20
+
+// snapshotCountTTL is how long the snapshot count in the data version is
+// reused before it is counted again
+const snapshotCountTTL = 10 * time.Second
+
+// dataVersion identifies the set of stored snapshots. Snapshots are immutable
+// once stored, so any response computed from them stays valid until a
+// snapshot is added (the latest ID changes) or deleted (the count changes).
+// Keying on the database rather than in-process events also covers
+// snapshots written by another process, such as the Kafka consumer. The
+// latest ID is an index lookup done on every request; the count needs a scan,
+// so deletions are noticed within snapshotCountTTL.
+func (s *Server) dataVersion() (string, error) {
+	var latest int64
+	if err := s.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM cluster_snapshots").Scan(&latest); err != nil {
+		return "", err
+	}
+	count, err := s.snapshotCount.get(func() (int64, error) {
+		var count int64
+		err := s.db.QueryRow("SELECT COUNT(*) FROM cluster_snapshots").Scan(&count)
+		return count, err
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d.%d", latest, count), nil
+}
+
+// cachedCount reuses a count for snapshotCountTTL
+type cachedCount struct {
+	mutex     sync.Mutex
+	value     int64
+	updatedAt time.Time
+}
+
+// get returns the cached count, calling count when it has expired
+func (c *cachedCount) get(count func() (int64, error)) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.updatedAt.IsZero() && time.Since(c.updatedAt) < snapshotCountTTL {
+		return c.value, nil
+	}
+	value, err := count()
+	if err != nil {
+		return 0, err
+	}
+	c.value, c.updatedAt = value, time.Now()
+	return value, nil
+}
+
+// reset makes the next get count again
+func (c *cachedCount) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.updatedAt = time.Time{}
+}
+
+// etagFor derives a strong ETag from the data version and everything else
+// the response depends on: the request URI, the Accept header and the caller
+func etagFor(version string, r *http.Request) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(r.URL.RequestURI()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.Header.Get("Accept")))
+	if identity := auth.IdentityFrom(r.Context()); identity != nil {
+		hash.Write([]byte{0})
+		hash.Write([]byte(identity.Subject))
+	}
+	return fmt.Sprintf(`"%s-%x"`, version, hash.Sum64())
+}
+
+// etagMatches reports whether an If-None-Match header lists etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// withETag answers conditional GETs with 304 Not Modified when no snapshot
+// has been added or removed since the client's copy was produced
+func (s *Server) withETag(next http.HandlerFunc) http.HandlerFunc {
+	return s.versioned(next, false)
+}
+
+// cached is withETag plus an in-process copy of the response, for expensive
+// aggregates such as /stats that many dashboards poll
+func (s *Server) cached(next http.HandlerFunc) http.HandlerFunc {
+	return s.versioned(next, true)
+}
+
+func (s *Server) versioned(next http.HandlerFunc, cache bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version, err := s.dataVersion()
+		if err != nil {
+			next(w, r)
+			return
+		}
+
+		etag := etagFor(version, r)
+		w.Header().Set("ETag", etag)
+		w.Header().Add("Vary", "Authorization")
+		if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if !cache || !s.config.CacheEnabled {
+			next(w, r)
+			return
+		}
+
+		if entry, ok := s.cache.get(version, etag); ok {
+			w.Header().Set("Content-Type", entry.contentType)
+			w.Header().Set("X-Cache", "HIT")
+			w.Write(entry.body)
+			return
+		}
+
+		w.Header().Set("X-Cache", "MISS")
+		recorder := &cacheRecorder{ResponseWriter: w}
+		next(recorder, r)
+		if recorder.status == http.StatusOK {
+			s.cache.put(version, etag, cachedResponse{
+				contentType: w.Header().Get("Content-Type"),
+				body:        bytes.Clone(recorder.body.Bytes()),
+			})
+		}
+	}
+}
+
+// responseCache holds successful responses for the current data version.
+// All entries are dropped as soon as the version changes.
+type responseCache struct {
+	mutex   sync.Mutex
+	version string
+	entries map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	contentType string
+	body        []byte
+}
+
+func (c *responseCache) get(version, key string) (cachedResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.version != version {
+		return cachedResponse{}, false
+	}
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *responseCache) put(version, key string, entry cachedResponse) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.version != version {
+		c.version = version
+		c.entries = make(map[string]cachedResponse)
+	}
+	c.entries[key] = entry
+}
+
+// cacheRecorder captures a response while passing it through
+type cacheRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *cacheRecorder) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *cacheRecorder) Write(data []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if c.body.Len()+len(data) <= maxCachedResponseBytes {
+		c.body.Write(data)
+	} else {
+		// Too large to cache; stop buffering
+		c.status = -1
+	}
+	return c.ResponseWriter.Write(data)
+}
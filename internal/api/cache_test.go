@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s-cluster-info-collector/internal/auth"
+)
+
+func TestETagMatches(t *testing.T) {
+	etag := `"12.3-abc"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"12.3-abc"`, true},
+		{`W/"12.3-abc"`, true},
+		{`"11.3-abc", "12.3-abc"`, true},
+		{`*`, true},
+		{`"11.3-abc"`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestETagFor(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+	base := etagFor("12.3", req)
+
+	if etagFor("13.4", req) == base {
+		t.Error("expected ETag to change with the data version")
+	}
+
+	other := httptest.NewRequest(http.MethodGet, "/api/v1/stats?cluster=a", nil)
+	if etagFor("12.3", other) == base {
+		t.Error("expected ETag to depend on the query")
+	}
+
+	alice := req.WithContext(auth.WithIdentity(req.Context(), &auth.Identity{Subject: "alice"}))
+	bob := req.WithContext(auth.WithIdentity(req.Context(), &auth.Identity{Subject: "bob"}))
+	if etagFor("12.3", alice) == etagFor("12.3", bob) {
+		t.Error("expected ETag to depend on the caller")
+	}
+}
+
+func TestResponseCacheInvalidation(t *testing.T) {
+	var cache responseCache
+	cache.put("1.1", "stats", cachedResponse{body: []byte("old")})
+
+	if entry, ok := cache.get("1.1", "stats"); !ok || string(entry.body) != "old" {
+		t.Fatalf("expected cached entry, got %v %q", ok, entry.body)
+	}
+	if _, ok := cache.get("2.2", "stats"); ok {
+		t.Error("expected miss after a new snapshot")
+	}
+
+	cache.put("2.2", "capacity", cachedResponse{body: []byte("new")})
+	if _, ok := cache.get("2.2", "stats"); ok {
+		t.Error("expected entries from the previous version to be dropped")
+	}
+}
+
+func TestCachedCount(t *testing.T) {
+	var cached cachedCount
+	counts := 0
+	count := func() (int64, error) {
+		counts++
+		return int64(counts), nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if value, err := cached.get(count); err != nil || value != 1 {
+			t.Fatalf("get() = %d, %v, want the first count", value, err)
+		}
+	}
+	cached.reset()
+	if value, _ := cached.get(count); value != 2 {
+		t.Errorf("expected a new count after reset, got %d", value)
+	}
+
+	cached.updatedAt = time.Now().Add(-snapshotCountTTL)
+	if value, _ := cached.get(count); value != 3 {
+		t.Errorf("expected a new count after the TTL, got %d", value)
+	}
+}
+
+func TestCacheRecorderSkipsLargeResponses(t *testing.T) {
+	rec := &cacheRecorder{ResponseWriter: httptest.NewRecorder()}
+	rec.Write(make([]byte, maxCachedResponseBytes))
+	if rec.status != http.StatusOK {
+		t.Fatalf("expected response within the limit to be cacheable, got %d", rec.status)
+	}
+	rec.Write([]byte("x"))
+	if rec.status == http.StatusOK {
+		t.Error("expected oversized response not to be cacheable")
+	}
+}
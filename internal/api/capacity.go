@@ -0,0 +1,147 @@
+package api
+
+import (
+	"math"
+	"net/http"
+)
+
+// CapacityGroup holds aggregated resource requests and allocatable capacity
+// for one group (the whole cluster, a node, a namespace or an owner)
+type CapacityGroup struct {
+	Node      string `json:"node,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	Pods      int    `json:"pods"`
+
+	CPURequestMillicores     int64 `json:"cpu_request_millicores"`
+	CPULimitMillicores       int64 `json:"cpu_limit_millicores"`
+	MemoryRequestBytes       int64 `json:"memory_request_bytes"`
+	MemoryLimitBytes         int64 `json:"memory_limit_bytes"`
+	StorageRequestBytes      int64 `json:"storage_request_bytes"`
+	CPUAllocatableMillicores int64 `json:"cpu_allocatable_millicores"`
+	MemoryAllocatableBytes   int64 `json:"memory_allocatable_bytes"`
+
+	// Requested as a percentage of allocatable. For namespace and owner
+	// groups this is relative to the whole cluster.
+	CPURequestPercent    float64 `json:"cpu_request_percent"`
+	MemoryRequestPercent float64 `json:"memory_request_percent"`
+}
+
+// activePodsFilter excludes pods that no longer hold resources on a node
+const activePodsFilter = "phase NOT IN ('Succeeded', 'Failed')"
+
+// getCapacity aggregates requested vs allocatable resources for a snapshot.
+// Query parameters: snapshot_id or at (default latest) and group_by (cluster, node,
+// namespace or owner; default cluster).
+func (s *Server) getCapacity(w http.ResponseWriter, r *http.Request) {
+	snapshot, ok := s.snapshotFromRequest(w, r)
+	if !ok {
+		return
+	}
+	snapshotID := snapshot.ID
+
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = "cluster"
+	}
+
+	// Cluster-wide allocatable is needed for every grouping
+	var clusterCPU, clusterMemory int64
+	err := s.db.QueryRow(`
+		SELECT COALESCE(SUM(cpu_allocatable_millicores), 0), COALESCE(SUM(memory_allocatable_bytes), 0)
+		FROM nodes WHERE snapshot_id = $1`, snapshotID).Scan(&clusterCPU, &clusterMemory)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to query node allocatable")
+		s.writeError(w, "Failed to fetch capacity", http.StatusInternalServerError)
+		return
+	}
+
+	var query string
+	switch groupBy {
+	case "cluster":
+		query = `
+			SELECT '', '', '', COUNT(*),
+				COALESCE(SUM(cpu_request_millicores), 0), COALESCE(SUM(cpu_limit_millicores), 0),
+				COALESCE(SUM(memory_request_bytes), 0), COALESCE(SUM(memory_limit_bytes), 0),
+				COALESCE(SUM(storage_request_bytes), 0), 0, 0
+			FROM pods WHERE snapshot_id = $1 AND ` + activePodsFilter
+	case "node":
+		query = `
+			SELECT n.name, '', '', COUNT(p.id),
+				COALESCE(SUM(p.cpu_request_millicores), 0), COALESCE(SUM(p.cpu_limit_millicores), 0),
+				COALESCE(SUM(p.memory_request_bytes), 0), COALESCE(SUM(p.memory_limit_bytes), 0),
+				COALESCE(SUM(p.storage_request_bytes), 0),
+				COALESCE(n.cpu_allocatable_millicores, 0), COALESCE(n.memory_allocatable_bytes, 0)
+			FROM nodes n
+			LEFT JOIN pods p ON p.snapshot_id = n.snapshot_id AND p.node_name = n.name AND p.` + activePodsFilter + `
+			WHERE n.snapshot_id = $1
+			GROUP BY n.name, n.cpu_allocatable_millicores, n.memory_allocatable_bytes
+			ORDER BY n.name`
+	case "namespace":
+		query = `
+			SELECT '', namespace, '', COUNT(*),
+				COALESCE(SUM(cpu_request_millicores), 0), COALESCE(SUM(cpu_limit_millicores), 0),
+				COALESCE(SUM(memory_request_bytes), 0), COALESCE(SUM(memory_limit_bytes), 0),
+				COALESCE(SUM(storage_request_bytes), 0), 0, 0
+			FROM pods WHERE snapshot_id = $1 AND ` + activePodsFilter + `
+			GROUP BY namespace
+			ORDER BY namespace`
+	case "owner":
+		query = `
+			SELECT '', namespace, COALESCE(deployment_name, ''), COUNT(*),
+				COALESCE(SUM(cpu_request_millicores), 0), COALESCE(SUM(cpu_limit_millicores), 0),
+				COALESCE(SUM(memory_request_bytes), 0), COALESCE(SUM(memory_limit_bytes), 0),
+				COALESCE(SUM(storage_request_bytes), 0), 0, 0
+			FROM pods WHERE snapshot_id = $1 AND ` + activePodsFilter + `
+			GROUP BY namespace, deployment_name
+			ORDER BY namespace, deployment_name`
+	default:
+		s.writeError(w, "Invalid group_by, expected cluster, node, namespace or owner", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := s.db.Query(query, snapshotID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to query capacity")
+		s.writeError(w, "Failed to fetch capacity", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	groups := []CapacityGroup{}
+	for rows.Next() {
+		var g CapacityGroup
+		err := rows.Scan(&g.Node, &g.Namespace, &g.Owner, &g.Pods,
+			&g.CPURequestMillicores, &g.CPULimitMillicores,
+			&g.MemoryRequestBytes, &g.MemoryLimitBytes, &g.StorageRequestBytes,
+			&g.CPUAllocatableMillicores, &g.MemoryAllocatableBytes)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to scan capacity row")
+			continue
+		}
+
+		if groupBy != "node" {
+			g.CPUAllocatableMillicores = clusterCPU
+			g.MemoryAllocatableBytes = clusterMemory
+		}
+		g.CPURequestPercent = percent(g.CPURequestMillicores, g.CPUAllocatableMillicores)
+		g.MemoryRequestPercent = percent(g.MemoryRequestBytes, g.MemoryAllocatableBytes)
+		groups = append(groups, g)
+	}
+
+	s.writeJSON(w, CapacityResponse{
+		SnapshotID: snapshotID,
+		Snapshot:   snapshot,
+		GroupBy:    groupBy,
+		Groups:     groups,
+		Count:      len(groups),
+	})
+}
+
+// percent returns part as a percentage of total, rounded to two decimals
+func percent(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*10000) / 100
+}
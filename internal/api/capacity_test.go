@@ -0,0 +1,148 @@
+package api
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-cluster-info-collector/internal/testutil"
+)
+
+// capacityQueries answers the snapshot, allocatable and grouping queries of
+// getCapacity for snapshot 7 with two nodes of 4 cores and 16Gi each
+func capacityQueries(t *testing.T) testutil.QueryFunc {
+	const gi = int64(1) << 30
+	return func(query string, args []driver.Value) [][]driver.Value {
+		switch {
+		case strings.Contains(query, "FROM clusters"):
+			return [][]driver.Value{{int64(1)}}
+		case strings.Contains(query, "FROM cluster_snapshots"):
+			return [][]driver.Value{{int64(7), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), "prod"}}
+		case len(args) != 1 || args[0] != int64(7):
+			t.Errorf("expected snapshot 7, got %v for %s", args, query)
+			return nil
+		case strings.Contains(query, "SUM(cpu_allocatable_millicores)"):
+			return [][]driver.Value{{int64(8000), 32 * gi}}
+		case strings.Contains(query, "GROUP BY n.name"):
+			return [][]driver.Value{
+				{"node-1", "", "", int64(2), int64(3000), int64(4000), 8 * gi, 8 * gi, int64(0), int64(4000), 16 * gi},
+				{"node-2", "", "", int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(4000), 16 * gi},
+			}
+		case strings.Contains(query, "GROUP BY namespace, deployment_name"):
+			return [][]driver.Value{
+				{"", "shop", "web", int64(2), int64(2000), int64(2000), 4 * gi, 4 * gi, int64(0), int64(0), int64(0)},
+				{"", "team-b", "api", int64(1), int64(1000), int64(2000), 4 * gi, 4 * gi, int64(0), int64(0), int64(0)},
+			}
+		case strings.Contains(query, "GROUP BY namespace"):
+			return [][]driver.Value{
+				{"", "shop", "", int64(2), int64(2000), int64(2000), 4 * gi, 4 * gi, int64(0), int64(0), int64(0)},
+				{"", "team-b", "", int64(1), int64(1000), int64(2000), 4 * gi, 4 * gi, int64(0), int64(0), int64(0)},
+			}
+		default:
+			return [][]driver.Value{{"", "", "", int64(3), int64(3000), int64(4000), 8 * gi, 8 * gi, int64(0), int64(0), int64(0)}}
+		}
+	}
+}
+
+func TestGetCapacity(t *testing.T) {
+	tests := []struct {
+		groupBy string
+		want    []CapacityGroup
+	}{
+		{
+			groupBy: "",
+			want: []CapacityGroup{
+				{Pods: 3, CPURequestMillicores: 3000, CPUAllocatableMillicores: 8000, CPURequestPercent: 37.5, MemoryRequestPercent: 25},
+			},
+		},
+		{
+			groupBy: "node",
+			want: []CapacityGroup{
+				{Node: "node-1", Pods: 2, CPURequestMillicores: 3000, CPUAllocatableMillicores: 4000, CPURequestPercent: 75, MemoryRequestPercent: 50},
+				{Node: "node-2", CPUAllocatableMillicores: 4000},
+			},
+		},
+		{
+			groupBy: "namespace",
+			want: []CapacityGroup{
+				{Namespace: "shop", Pods: 2, CPURequestMillicores: 2000, CPUAllocatableMillicores: 8000, CPURequestPercent: 25, MemoryRequestPercent: 12.5},
+				{Namespace: "team-b", Pods: 1, CPURequestMillicores: 1000, CPUAllocatableMillicores: 8000, CPURequestPercent: 12.5, MemoryRequestPercent: 12.5},
+			},
+		},
+		{
+			groupBy: "owner",
+			want: []CapacityGroup{
+				{Namespace: "shop", Owner: "web", Pods: 2, CPURequestMillicores: 2000, CPUAllocatableMillicores: 8000, CPURequestPercent: 25, MemoryRequestPercent: 12.5},
+				{Namespace: "team-b", Owner: "api", Pods: 1, CPURequestMillicores: 1000, CPUAllocatableMillicores: 8000, CPURequestPercent: 12.5, MemoryRequestPercent: 12.5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run("group_by="+tt.groupBy, func(t *testing.T) {
+			s := newFakeDBServer(t, capacityQueries(t))
+			rec := httptest.NewRecorder()
+			s.getCapacity(rec, httptest.NewRequest(http.MethodGet, "/api/v1/capacity?group_by="+tt.groupBy, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+			}
+
+			var response CapacityResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if response.SnapshotID != 7 || response.Count != len(tt.want) || len(response.Groups) != len(tt.want) {
+				t.Fatalf("unexpected response %+v", response)
+			}
+			for i, want := range tt.want {
+				got := response.Groups[i]
+				if got.Node != want.Node || got.Namespace != want.Namespace || got.Owner != want.Owner ||
+					got.Pods != want.Pods || got.CPURequestMillicores != want.CPURequestMillicores ||
+					got.CPUAllocatableMillicores != want.CPUAllocatableMillicores ||
+					got.CPURequestPercent != want.CPURequestPercent || got.MemoryRequestPercent != want.MemoryRequestPercent {
+					t.Errorf("group %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetCapacityInvalidGroupBy(t *testing.T) {
+	s := newFakeDBServer(t, capacityQueries(t))
+	rec := httptest.NewRecorder()
+	s.getCapacity(rec, httptest.NewRequest(http.MethodGet, "/api/v1/capacity?group_by=pod", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestGetCapacityRequiresClusterWithSeveralClusters(t *testing.T) {
+	queries := capacityQueries(t)
+	var clustersQuery string
+	s := newFakeDBServer(t, func(query string, args []driver.Value) [][]driver.Value {
+		if strings.Contains(query, "FROM clusters") {
+			clustersQuery = query
+			return [][]driver.Value{{int64(2)}}
+		}
+		return queries(query, args)
+	})
+
+	rec := httptest.NewRecorder()
+	s.getCapacity(rec, httptest.NewRequest(http.MethodGet, "/api/v1/capacity", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without cluster, got %d", rec.Code)
+	}
+	// Clusters pruned by retention must not make the parameter required forever
+	if !strings.Contains(clustersQuery, "EXISTS (SELECT 1 FROM cluster_snapshots") {
+		t.Errorf("expected only clusters with snapshots to be counted, got %s", clustersQuery)
+	}
+
+	rec = httptest.NewRecorder()
+	s.getCapacity(rec, httptest.NewRequest(http.MethodGet, "/api/v1/capacity?cluster=prod", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 with cluster, got %d: %s", rec.Code, rec.Body)
+	}
+}
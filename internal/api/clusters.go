@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// getClusters lists every cluster with its first and last seen times
+func (s *Server) getClusters(w http.ResponseWriter, r *http.Request) {
+	clusters, err := s.listClusters(r.Context())
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to query clusters")
+		s.writeError(w, "Failed to fetch clusters", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, ClusterList{
+		Clusters: clusters,
+		Count:    len(clusters),
+	})
+}
+
+// listClusters returns every known cluster, most recently seen first
+func (s *Server) listClusters(ctx context.Context) ([]ClusterSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c.name, COALESCE(c.uid, ''), c.first_seen, c.last_seen,
+			COALESCE(latest.id, 0), (SELECT COUNT(*) FROM cluster_snapshots WHERE cluster = c.name)
+		FROM clusters c
+		LEFT JOIN LATERAL (
+			SELECT id FROM cluster_snapshots
+			WHERE cluster = c.name
+			ORDER BY timestamp DESC LIMIT 1
+		) latest ON true
+		ORDER BY c.last_seen DESC, c.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clusters := []ClusterSummary{}
+	for rows.Next() {
+		var c ClusterSummary
+		if err := rows.Scan(&c.Name, &c.UID, &c.FirstSeen, &c.LastSeen, &c.LastSnapshotID, &c.Snapshots); err != nil {
+			return nil, fmt.Errorf("failed to scan cluster: %w", err)
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters, rows.Err()
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/database"
+	"k8s-cluster-info-collector/internal/testutil"
+)
+
+// newFakeDBServer returns a server whose database answers every query with
+// respond. Exec statements succeed without effect.
+func newFakeDBServer(t *testing.T, respond testutil.QueryFunc) *Server {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	db := testutil.FakeDB{Query: respond}.Open(t)
+	return New(&database.DB{DB: db}, logger, APIConfig{Prefix: "/api/v1"}, nil, "test", "abc123")
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"k8s-cluster-info-collector/internal/kafka"
+)
+
+// deadLetterTimeout bounds reading the dead-letter topic for one request
+const deadLetterTimeout = 30 * time.Second
+
+// deadLetterFilter reads the cluster, snapshot_id and reason query parameters
+func deadLetterFilter(r *http.Request) kafka.DeadLetterFilter {
+	return kafka.DeadLetterFilter{
+		Cluster:    requestCluster(r),
+		SnapshotID: r.URL.Query().Get("snapshot_id"),
+		Reason:     r.URL.Query().Get("reason"),
+	}
+}
+
+// deadLetterPosition reads the partition and offset path variables
+func deadLetterPosition(r *http.Request) (int32, int64, bool) {
+	vars := mux.Vars(r)
+	partition, err := strconv.ParseInt(vars["partition"], 10, 32)
+	if err != nil || partition < 0 {
+		return 0, 0, false
+	}
+	offset, err := strconv.ParseInt(vars["offset"], 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, false
+	}
+	return int32(partition), offset, true
+}
+
+// getDeadLetters lists the messages on the dead-letter topic, newest first
+func (s *Server) getDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if s.deadLetters == nil {
+		s.writeError(w, "Kafka dead-letter topic not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), deadLetterTimeout)
+	defer cancel()
+	letters, total, err := s.deadLetters.List(ctx, deadLetterFilter(r), limit)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list dead letters")
+		s.writeError(w, "Failed to read the dead-letter topic", http.StatusInternalServerError)
+		return
+	}
+
+	if letters == nil {
+		letters = []kafka.DeadLetter{}
+	}
+	s.writeJSON(w, DeadLetterList{
+		Topic:       s.deadLetters.Topic(),
+		DeadLetters: letters,
+		Count:       len(letters),
+		Total:       total,
+	})
+}
+
+// getDeadLetter returns one dead letter with its headers and payload
+func (s *Server) getDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if s.deadLetters == nil {
+		s.writeError(w, "Kafka dead-letter topic not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	partition, offset, ok := deadLetterPosition(r)
+	if !ok {
+		s.writeError(w, "Invalid partition or offset", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), deadLetterTimeout)
+	defer cancel()
+	letter, err := s.deadLetters.Get(ctx, partition, offset)
+	if errors.Is(err, kafka.ErrDeadLetterNotFound) {
+		s.writeError(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to read dead letter")
+		s.writeError(w, "Failed to read the dead-letter topic", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, letter)
+}
+
+// replayDeadLetter sends one dead letter back to the consumed topic
+func (s *Server) replayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if s.deadLetters == nil {
+		s.writeError(w, "Kafka dead-letter topic not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	partition, offset, ok := deadLetterPosition(r)
+	if !ok {
+		s.writeError(w, "Invalid partition or offset", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), deadLetterTimeout)
+	defer cancel()
+	err := s.deadLetters.ReplayMessage(ctx, partition, offset)
+	if errors.Is(err, kafka.ErrDeadLetterNotFound) {
+		s.writeError(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to replay dead letter")
+		s.writeError(w, "Failed to replay dead letter", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, DeadLetterReplay{Replayed: 1})
+}
+
+// replayDeadLetters sends every dead letter matching the query back to the
+// consumed topic, oldest first
+func (s *Server) replayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if s.deadLetters == nil {
+		s.writeError(w, "Kafka dead-letter topic not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), deadLetterTimeout)
+	defer cancel()
+	replayed, err := s.deadLetters.Replay(ctx, deadLetterFilter(r))
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to replay dead letters")
+		s.writeError(w, "Failed to replay dead letters", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, DeadLetterReplay{Replayed: replayed})
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"k8s-cluster-info-collector/internal/diff"
+)
+
+// getSnapshotDiff reports added, removed and modified objects between two
+// snapshots. Either ID may be "latest". Query parameters: format (json or
+// text; default json).
+func (s *Server) getSnapshotDiff(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "text" {
+		s.writeError(w, "Invalid format, expected json or text", http.StatusBadRequest)
+		return
+	}
+
+	from, fromInfo, ok := s.loadSnapshotForRequest(w, r, vars["a"])
+	if !ok {
+		return
+	}
+	to, toInfo, ok := s.loadSnapshotForRequest(w, r, vars["b"])
+	if !ok {
+		return
+	}
+
+	result, err := diff.Compare(from, to, fromInfo, toInfo)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to compare snapshots")
+		s.writeError(w, "Failed to compare snapshots", http.StatusInternalServerError)
+		return
+	}
+
+	if format == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := result.WriteText(w); err != nil {
+			s.logger.WithError(err).Error("Failed to write snapshot diff")
+		}
+		return
+	}
+	s.writeJSON(w, result)
+}
@@ -0,0 +1,340 @@
+package api
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/export"
+)
+
+// exportFlushInterval is how many rows are written between flushes to the client
+const exportFlushInterval = 1000
+
+// snapshotExportColumns are the columns of /snapshots/{id}/export, which
+// spans every kind and therefore exports each object as one JSON document
+var snapshotExportColumns = []export.Column{
+	{Name: "snapshot_id", Type: export.Int},
+	{Name: "kind", Type: export.String},
+	{Name: "namespace", Type: export.String},
+	{Name: "name", Type: export.String},
+	{Name: "uid", Type: export.String},
+	{Name: "created_time", Type: export.Time},
+	{Name: "data", Type: export.JSON},
+}
+
+// exportKind streams every row of one resource table. Query parameters:
+// format (ndjson, csv or parquet), columns (comma-separated), namespace,
+// cluster, and either snapshot_id/at (default latest) or since/until to
+// export a time range across snapshots.
+func (s *Server) exportKind(w http.ResponseWriter, r *http.Request) {
+	path := mux.Vars(r)["kind"]
+	var kind *resourceKind
+	for i := range resourceKinds {
+		if resourceKinds[i].path == path {
+			kind = &resourceKinds[i]
+		}
+	}
+	if kind == nil {
+		s.writeError(w, fmt.Sprintf("Unknown kind %q", path), http.StatusNotFound)
+		return
+	}
+
+	access, ok := s.authorizeKind(w, r, *kind)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	format, ok := s.exportFormat(w, r)
+	if !ok {
+		return
+	}
+
+	available, err := s.tableColumns(kind.table)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to read table columns")
+		s.writeError(w, "Failed to prepare export", http.StatusInternalServerError)
+		return
+	}
+	columns, err := selectColumns(available, query.Get("columns"))
+	if err != nil {
+		s.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	selects := make([]string, len(columns))
+	for i, column := range columns {
+		selects[i] = "r." + column.Name
+		if column.Type == export.JSON {
+			// Arrays are exported as JSON so every format can represent them
+			selects[i] = "to_jsonb(r." + column.Name + ")"
+		}
+	}
+
+	var conditions []string
+	var args []interface{}
+	since, until := query.Get("since"), query.Get("until")
+	if since != "" || until != "" {
+		for _, bound := range []struct{ value, op string }{{since, ">="}, {until, "<="}} {
+			if bound.value == "" {
+				continue
+			}
+			parsed, err := time.Parse(time.RFC3339, bound.value)
+			if err != nil {
+				s.writeError(w, "Invalid since/until timestamp, expected RFC3339", http.StatusBadRequest)
+				return
+			}
+			args = append(args, parsed)
+			conditions = append(conditions, fmt.Sprintf("s.timestamp %s $%d", bound.op, len(args)))
+		}
+		if cluster := requestCluster(r); cluster != "" {
+			args = append(args, cluster)
+			conditions = append(conditions, fmt.Sprintf("s.cluster = $%d", len(args)))
+		}
+	} else {
+		snapshot, ok := s.snapshotFromRequest(w, r)
+		if !ok {
+			return
+		}
+		args = append(args, snapshot.ID)
+		conditions = append(conditions, fmt.Sprintf("r.snapshot_id = $%d", len(args)))
+	}
+
+	if namespace := query.Get("namespace"); namespace != "" && kind.namespaced {
+		if !s.authorizeNamespace(w, access, namespace) {
+			return
+		}
+		args = append(args, namespace)
+		conditions = append(conditions, fmt.Sprintf("r.namespace = $%d", len(args)))
+	}
+	if condition, restricted := namespaceCondition(access, "r.namespace", args); condition != "" {
+		args = restricted
+		conditions = append(conditions, condition)
+	}
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT %s FROM %s r
+		JOIN cluster_snapshots s ON s.id = r.snapshot_id
+		WHERE %s
+		ORDER BY r.snapshot_id, r.id`, strings.Join(selects, ", "), kind.table, strings.Join(conditions, " AND "))
+
+	s.streamExport(w, r, format, kind.path, columns, sqlQuery, args)
+}
+
+// exportSnapshot streams every object of one snapshot. Query parameters:
+// format (ndjson, csv or parquet), columns and cluster.
+func (s *Server) exportSnapshot(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.writeError(w, "Invalid snapshot ID", http.StatusBadRequest)
+		return
+	}
+	cluster := requestCluster(r)
+	var exists bool
+	err = s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM cluster_snapshots WHERE id = $1 AND ($2 = '' OR cluster = $2))", id, cluster).Scan(&exists)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to query snapshot")
+		s.writeError(w, "Failed to fetch snapshot", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		s.writeError(w, fmt.Sprintf("Snapshot %d not found%s", id, inCluster(cluster)), http.StatusNotFound)
+		return
+	}
+
+	format, ok := s.exportFormat(w, r)
+	if !ok {
+		return
+	}
+	columns, err := selectColumns(snapshotExportColumns, r.URL.Query().Get("columns"))
+	if err != nil {
+		s.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var parts []string
+	for _, kind := range resourceKinds {
+		namespace := "namespace"
+		if !kind.namespaced {
+			namespace = "NULL::varchar"
+		}
+		parts = append(parts, fmt.Sprintf(
+			"SELECT snapshot_id, '%s' AS kind, %s AS namespace, name, uid, created_time, data, id FROM %s WHERE snapshot_id = $1",
+			kind.kind, namespace, kind.table))
+	}
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+	}
+	sqlQuery := fmt.Sprintf("SELECT %s FROM (%s) objects ORDER BY kind, id",
+		strings.Join(names, ", "), strings.Join(parts, " UNION ALL "))
+
+	s.streamExport(w, r, format, fmt.Sprintf("snapshot-%d", id), columns, sqlQuery, []interface{}{id})
+}
+
+// streamExport runs the query and writes each row to the client as it is
+// read, compressing with gzip when the client accepts it
+func (s *Server) streamExport(w http.ResponseWriter, r *http.Request, format, name string, columns []export.Column, query string, args []interface{}) {
+	rows, err := s.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to query export rows")
+		s.writeError(w, "Failed to export data", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	// Large exports outlast the server's write timeout, so lift it for this response
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.WithError(err).Debug("Could not clear write deadline for export")
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+
+	var out io.Writer = w
+	flusher, _ := w.(http.Flusher)
+	var gz *gzip.Writer
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Add("Vary", "Accept-Encoding")
+		gz = gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+
+	writer, err := export.NewWriter(format, out, columns)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to create export writer")
+		return
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			s.logger.WithError(err).Error("Failed to scan export row")
+			return
+		}
+		if err := writer.WriteRow(values); err != nil {
+			// Usually the client went away; the response is already committed
+			s.logger.WithError(err).Warn("Export aborted while writing")
+			return
+		}
+
+		count++
+		if count%exportFlushInterval == 0 && flusher != nil && format != "parquet" {
+			if gz != nil {
+				gz.Flush()
+			}
+			flusher.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.WithError(err).Error("Export query failed mid-stream")
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		s.logger.WithError(err).Error("Failed to finish export")
+		return
+	}
+	s.logger.WithFields(logrus.Fields{"export": name, "format": format, "rows": count}).Debug("Export completed")
+}
+
+// exportFormat validates the format query parameter (default ndjson)
+func (s *Server) exportFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		return "ndjson", true
+	}
+	for _, supported := range export.Formats {
+		if format == supported {
+			return format, true
+		}
+	}
+	s.writeError(w, "Invalid format, expected one of "+strings.Join(export.Formats, ", "), http.StatusBadRequest)
+	return "", false
+}
+
+// tableColumns returns the exportable columns of a resource table in table order
+func (s *Server) tableColumns(table string) ([]export.Column, error) {
+	rows, err := s.db.Query(`
+		SELECT column_name, data_type FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1 AND column_name <> 'id'
+		ORDER BY ordinal_position`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []export.Column
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			return nil, err
+		}
+		columns = append(columns, export.Column{Name: name, Type: columnType(dataType)})
+	}
+	return columns, rows.Err()
+}
+
+// columnType maps an information_schema data type to an export column type
+func columnType(dataType string) export.ColumnType {
+	switch dataType {
+	case "integer", "bigint", "smallint":
+		return export.Int
+	case "double precision", "real":
+		return export.Float
+	case "boolean":
+		return export.Bool
+	case "timestamp without time zone", "timestamp with time zone":
+		return export.Time
+	case "jsonb", "json", "ARRAY":
+		return export.JSON
+	default:
+		return export.String
+	}
+}
+
+// selectColumns picks the requested comma-separated columns, in request
+// order, from the available ones. An empty request selects all of them; a
+// column may be requested only once.
+func selectColumns(available []export.Column, requested string) ([]export.Column, error) {
+	if requested == "" {
+		return available, nil
+	}
+
+	byName := make(map[string]export.Column, len(available))
+	for _, column := range available {
+		byName[column.Name] = column
+	}
+
+	var columns []export.Column
+	seen := make(map[string]bool, len(available))
+	for _, name := range strings.Split(requested, ",") {
+		name = strings.TrimSpace(name)
+		column, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		seen[name] = true
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
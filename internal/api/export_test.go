@@ -0,0 +1,46 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s-cluster-info-collector/internal/export"
+)
+
+func TestSelectColumns(t *testing.T) {
+	available := []export.Column{
+		{Name: "name", Type: export.String},
+		{Name: "namespace", Type: export.String},
+		{Name: "restart_count", Type: export.Int},
+	}
+
+	tests := []struct {
+		name      string
+		requested string
+		expected  []string
+		wantErr   bool
+	}{
+		{name: "all columns", requested: "", expected: []string{"name", "namespace", "restart_count"}},
+		{name: "request order", requested: "restart_count, name", expected: []string{"restart_count", "name"}},
+		{name: "unknown column", requested: "name,phase", wantErr: true},
+		{name: "duplicate column", requested: "name,name", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, err := selectColumns(available, tt.requested)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectColumns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var names []string
+			for _, column := range columns {
+				names = append(names, column.Name)
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
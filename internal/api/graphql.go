@@ -0,0 +1,567 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"k8s-cluster-info-collector/internal/auth"
+	"k8s-cluster-info-collector/internal/models"
+)
+
+// defaultGraphQLMaxDepth is used when APIConfig.GraphQLMaxDepth is not set.
+// It is deep enough for Deployment -> Pods -> Node -> PVCs -> volume.
+const defaultGraphQLMaxDepth = 10
+
+// maxGraphQLSnapshots is how many different snapshots one query may load.
+// Every snapshot field loads a whole snapshot, so aliased fields would
+// otherwise multiply the cost of a single request.
+const maxGraphQLSnapshots = 5
+
+// graphqlSchema exposes every kind of a snapshot together with the
+// relationships between them. Relationships are resolved in memory from the
+// snapshot, so traversing them costs no further queries.
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+"RFC 3339 timestamp"
+scalar Time
+
+"Arbitrary JSON value, used for label, annotation and data maps"
+scalar JSON
+
+type Query {
+	"A snapshot by id, the latest one at or before at, or the latest one, optionally of one cluster"
+	snapshot(id: Int, at: Time, cluster: String): Snapshot
+	"The most recent snapshots, newest first, optionally of one cluster"
+	snapshots(limit: Int = 20, cluster: String): [SnapshotRef!]!
+}
+
+type SnapshotRef {
+	id: Int!
+	timestamp: Time!
+	cluster: String!
+}
+
+type Snapshot {
+	id: Int!
+	timestamp: Time!
+	cluster: String!
+	deployments(namespace: String): [Deployment!]!
+	deployment(namespace: String!, name: String!): Deployment
+	pods(namespace: String, node: String): [Pod!]!
+	pod(namespace: String!, name: String!): Pod
+	nodes: [Node!]!
+	node(name: String!): Node
+	services(namespace: String): [Service!]!
+	service(namespace: String!, name: String!): Service
+	ingresses(namespace: String): [Ingress!]!
+	ingress(namespace: String!, name: String!): Ingress
+	configMaps(namespace: String): [ConfigMap!]!
+	configMap(namespace: String!, name: String!): ConfigMap
+	secrets(namespace: String): [Secret!]!
+	secret(namespace: String!, name: String!): Secret
+	persistentVolumes: [PersistentVolume!]!
+	persistentVolume(name: String!): PersistentVolume
+	persistentVolumeClaims(namespace: String): [PersistentVolumeClaim!]!
+	persistentVolumeClaim(namespace: String!, name: String!): PersistentVolumeClaim
+}
+
+type Deployment {
+	name: String!
+	namespace: String!
+	uid: String!
+	resourceVersion: String!
+	generation: Int!
+	createdTime: Time!
+	replicas: Int!
+	readyReplicas: Int!
+	updatedReplicas: Int!
+	images: [String!]!
+	conditions: JSON
+	labels: JSON
+	annotations: JSON
+	"Pods owned through the deployment's ReplicaSets"
+	pods: [Pod!]!
+	"Services selecting any of the deployment's pods"
+	services: [Service!]!
+}
+
+type Pod {
+	name: String!
+	namespace: String!
+	uid: String!
+	resourceVersion: String!
+	generation: Int!
+	createdTime: Time!
+	phase: String!
+	nodeName: String!
+	podIP: String!
+	hostIP: String!
+	restartCount: Int!
+	cpuRequest: String!
+	cpuLimit: String!
+	memoryRequest: String!
+	memoryLimit: String!
+	storageRequest: String!
+	labels: JSON
+	annotations: JSON
+	containerStatuses: [ContainerStatus!]!
+	volumeClaims: [String!]!
+	"The deployment owning the pod"
+	owner: Deployment
+	node: Node
+	"Services whose selector matches the pod"
+	services: [Service!]!
+	persistentVolumeClaims: [PersistentVolumeClaim!]!
+}
+
+type ContainerStatus {
+	name: String!
+	ready: Boolean!
+	restartCount: Int!
+	image: String!
+	state: String!
+}
+
+type Node {
+	name: String!
+	uid: String!
+	resourceVersion: String!
+	generation: Int!
+	createdTime: Time!
+	ready: Boolean!
+	cpuCapacity: String!
+	memoryCapacity: String!
+	storageCapacity: String!
+	cpuAllocatable: String!
+	memoryAllocatable: String!
+	storageAllocatable: String!
+	osImage: String!
+	kernelVersion: String!
+	kubeletVersion: String!
+	labels: JSON
+	annotations: JSON
+	pods: [Pod!]!
+	"Claims mounted by pods running on the node"
+	persistentVolumeClaims: [PersistentVolumeClaim!]!
+}
+
+type Service {
+	name: String!
+	namespace: String!
+	uid: String!
+	resourceVersion: String!
+	generation: Int!
+	createdTime: Time!
+	type: String!
+	clusterIP: String!
+	externalIPs: [String!]!
+	ports: [ServicePort!]!
+	selector: JSON
+	labels: JSON
+	annotations: JSON
+	"Pods matching the selector"
+	pods: [Pod!]!
+	"Ingresses routing to the service"
+	ingresses: [Ingress!]!
+}
+
+type ServicePort {
+	name: String!
+	protocol: String!
+	port: Int!
+	targetPort: String!
+	nodePort: Int!
+}
+
+type Ingress {
+	name: String!
+	namespace: String!
+	uid: String!
+	resourceVersion: String!
+	generation: Int!
+	createdTime: Time!
+	hosts: [String!]!
+	paths: [IngressPath!]!
+	tls: [IngressTLS!]!
+	labels: JSON
+	annotations: JSON
+	"Services the ingress routes to"
+	services: [Service!]!
+}
+
+type IngressPath {
+	path: String!
+	pathType: String!
+	serviceName: String!
+	servicePort: Int!
+}
+
+type IngressTLS {
+	hosts: [String!]!
+	secretName: String!
+}
+
+type ConfigMap {
+	name: String!
+	namespace: String!
+	uid: String!
+	resourceVersion: String!
+	generation: Int!
+	createdTime: Time!
+	data: JSON
+	labels: JSON
+	annotations: JSON
+}
+
+type Secret {
+	name: String!
+	namespace: String!
+	uid: String!
+	resourceVersion: String!
+	generation: Int!
+	createdTime: Time!
+	type: String!
+	dataKeys: [String!]!
+	labels: JSON
+	annotations: JSON
+}
+
+type PersistentVolume {
+	name: String!
+	uid: String!
+	resourceVersion: String!
+	generation: Int!
+	createdTime: Time!
+	capacity: String!
+	accessModes: [String!]!
+	reclaimPolicy: String!
+	storageClass: String!
+	volumeMode: String!
+	status: String!
+	claimRef: String!
+	volumeSource: String!
+	labels: JSON
+	annotations: JSON
+	"The claim bound to the volume"
+	claim: PersistentVolumeClaim
+}
+
+type PersistentVolumeClaim {
+	name: String!
+	namespace: String!
+	uid: String!
+	resourceVersion: String!
+	generation: Int!
+	createdTime: Time!
+	requestedSize: String!
+	accessModes: [String!]!
+	storageClass: String!
+	volumeMode: String!
+	status: String!
+	volumeName: String!
+	labels: JSON
+	annotations: JSON
+	"The volume bound to the claim"
+	volume: PersistentVolume
+	"Pods mounting the claim"
+	pods: [Pod!]!
+}
+`
+
+// newGraphQLSchema parses the schema against the root resolver
+func newGraphQLSchema(root *graphqlRoot, maxDepth int) (*graphql.Schema, error) {
+	if maxDepth <= 0 {
+		maxDepth = defaultGraphQLMaxDepth
+	}
+	return graphql.ParseSchema(graphqlSchema, root,
+		graphql.UseFieldResolvers(),
+		graphql.MaxDepth(maxDepth),
+	)
+}
+
+// GraphQLRequest is a GraphQL request as sent over HTTP
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// handleGraphQL executes a GraphQL query sent as a JSON POST body or as GET
+// query parameters. Query errors, including depth limit violations, are
+// reported in the response's errors list.
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				s.writeError(w, "Invalid variables, expected a JSON object", http.StatusBadRequest)
+				return
+			}
+		}
+	} else {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.writeError(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			s.writeError(w, "Request body must be a JSON GraphQL request", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Query == "" {
+		s.writeError(w, "Missing query", http.StatusBadRequest)
+		return
+	}
+
+	ctx := withGraphQLSnapshots(r.Context())
+	s.writeJSON(w, s.graphql.Exec(ctx, req.Query, req.OperationName, req.Variables))
+}
+
+type graphqlSnapshotsKey struct{}
+
+// graphqlSnapshots holds the snapshots loaded for one query, so that snapshot
+// fields with the same arguments share one load and the number of loads is
+// capped at maxGraphQLSnapshots
+type graphqlSnapshots struct {
+	mutex  sync.Mutex
+	loaded map[string]*graphqlSnapshotLoad
+}
+
+type graphqlSnapshotLoad struct {
+	once     sync.Once
+	snapshot *snapshotResolver
+	err      error
+}
+
+// withGraphQLSnapshots returns a context for executing one query. Snapshot
+// fields fail without it.
+func withGraphQLSnapshots(ctx context.Context) context.Context {
+	return context.WithValue(ctx, graphqlSnapshotsKey{}, &graphqlSnapshots{loaded: make(map[string]*graphqlSnapshotLoad)})
+}
+
+// load returns the snapshot for the arguments, loading it on first use.
+// Fields resolve concurrently, so a load in progress is waited for.
+func (c *graphqlSnapshots) load(key string, load func() (*snapshotResolver, error)) (*snapshotResolver, error) {
+	c.mutex.Lock()
+	entry, ok := c.loaded[key]
+	if !ok {
+		if len(c.loaded) >= maxGraphQLSnapshots {
+			c.mutex.Unlock()
+			return nil, fmt.Errorf("a query can load at most %d different snapshots", maxGraphQLSnapshots)
+		}
+		entry = &graphqlSnapshotLoad{}
+		c.loaded[key] = entry
+	}
+	c.mutex.Unlock()
+
+	entry.once.Do(func() { entry.snapshot, entry.err = load() })
+	return entry.snapshot, entry.err
+}
+
+// graphqlRoot resolves the Query type. Snapshots are read through load and
+// list so tests can run queries without a database.
+type graphqlRoot struct {
+	load func(ctx context.Context, cluster string, id *int32, at *graphql.Time) (*snapshotResolver, error)
+	list func(ctx context.Context, cluster string, limit int) ([]models.SnapshotRef, error)
+}
+
+func (s *Server) newGraphQLRoot() *graphqlRoot {
+	return &graphqlRoot{
+		load: s.loadGraphQLSnapshot,
+		list: s.listSnapshotRefs,
+	}
+}
+
+func (r *graphqlRoot) Snapshot(ctx context.Context, args struct {
+	ID      *int32
+	At      *graphql.Time
+	Cluster *string
+}) (*snapshotResolver, error) {
+	if args.ID != nil && args.At != nil {
+		return nil, errors.New("use either id or at, not both")
+	}
+	snapshots, ok := ctx.Value(graphqlSnapshotsKey{}).(*graphqlSnapshots)
+	if !ok {
+		return nil, errors.New("query executed without a snapshot cache")
+	}
+
+	key := "latest"
+	switch {
+	case args.ID != nil:
+		key = fmt.Sprintf("id=%d", *args.ID)
+	case args.At != nil:
+		key = "at=" + args.At.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return snapshots.load(key+" cluster="+stringValue(args.Cluster), func() (*snapshotResolver, error) {
+		return r.load(ctx, stringValue(args.Cluster), args.ID, args.At)
+	})
+}
+
+func (r *graphqlRoot) Snapshots(ctx context.Context, args struct {
+	Limit   int32
+	Cluster *string
+}) ([]*snapshotRefResolver, error) {
+	limit := int(args.Limit)
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+	refs, err := r.list(ctx, stringValue(args.Cluster), limit)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*snapshotRefResolver, len(refs))
+	for i, ref := range refs {
+		resolvers[i] = &snapshotRefResolver{ref}
+	}
+	return resolvers, nil
+}
+
+// loadGraphQLSnapshot loads the requested snapshot, returning nil when there
+// is none or it belongs to another cluster than the requested one. Latest and
+// point-in-time lookups need a cluster when several clusters are stored.
+func (s *Server) loadGraphQLSnapshot(ctx context.Context, cluster string, id *int32, at *graphql.Time) (*snapshotResolver, error) {
+	var snapshotID int
+	if id == nil {
+		resolved, err := s.resolveCluster(ctx, cluster)
+		if errors.Is(err, errClusterRequired) {
+			return nil, err
+		}
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to count clusters")
+			return nil, errors.New("failed to fetch snapshot")
+		}
+		cluster = resolved
+	}
+	switch {
+	case id != nil:
+		snapshotID = int(*id)
+	case at != nil:
+		err := s.db.QueryRowContext(ctx, `
+			SELECT id FROM cluster_snapshots
+			WHERE timestamp <= $1 AND ($2 = '' OR cluster = $2)
+			ORDER BY timestamp DESC LIMIT 1`, at.Time, cluster).Scan(&snapshotID)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to query snapshot")
+			return nil, errors.New("failed to fetch snapshot")
+		}
+	default:
+		if snapshotID = s.getLatestSnapshotID(cluster); snapshotID == 0 {
+			return nil, nil
+		}
+	}
+
+	ref, info, err := s.loadSnapshot(snapshotID)
+	if errors.Is(err, errSnapshotNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("snapshot_id", snapshotID).Error("Failed to load snapshot")
+		return nil, errors.New("failed to fetch snapshot")
+	}
+	if cluster != "" && ref.Cluster != cluster {
+		return nil, nil
+	}
+	return &snapshotResolver{ref: ref, graph: newSnapshotGraph(info, s.graphqlAccess(ctx))}, nil
+}
+
+// listSnapshotRefs returns the most recent snapshots, newest first
+func (s *Server) listSnapshotRefs(ctx context.Context, cluster string, limit int) ([]models.SnapshotRef, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, timestamp, cluster FROM cluster_snapshots
+		WHERE $2 = '' OR cluster = $2
+		ORDER BY timestamp DESC LIMIT $1`, limit, cluster)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to query snapshots")
+		return nil, errors.New("failed to fetch snapshots")
+	}
+	defer rows.Close()
+
+	var refs []models.SnapshotRef
+	for rows.Next() {
+		var ref models.SnapshotRef
+		if err := rows.Scan(&ref.ID, &ref.Timestamp, &ref.Cluster); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// graphqlAccess returns whether the caller may see objects of a kind in a
+// namespace. Objects the caller may not see are left out of the graph, so
+// lists and relationships never reveal them.
+func (s *Server) graphqlAccess(ctx context.Context) func(kind, namespace string) bool {
+	if s.authenticator == nil {
+		return func(string, string) bool { return true }
+	}
+	identity := auth.IdentityFrom(ctx)
+	accessByKind := make(map[string]auth.Access)
+	for _, kind := range models.Kinds {
+		accessByKind[kind] = s.policy.Access(identity, kind)
+	}
+	return func(kind, namespace string) bool {
+		access := accessByKind[kind]
+		return access.Allowed() && access.Allows(namespace)
+	}
+}
+
+type snapshotRefResolver struct {
+	ref models.SnapshotRef
+}
+
+func (r *snapshotRefResolver) ID() int32 { return int32(r.ref.ID) }
+
+func (r *snapshotRefResolver) Timestamp() graphql.Time { return graphqlTime(r.ref.Timestamp) }
+
+func (r *snapshotRefResolver) Cluster() string { return r.ref.Cluster }
+
+// jsonValue is the JSON scalar
+type jsonValue struct {
+	value interface{}
+}
+
+func (jsonValue) ImplementsGraphQLType(name string) bool { return name == "JSON" }
+
+func (j *jsonValue) UnmarshalGraphQL(input interface{}) error {
+	j.value = input
+	return nil
+}
+
+func (j jsonValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.value)
+}
+
+func jsonOf(value interface{}) *jsonValue {
+	return &jsonValue{value}
+}
+
+// stringValue returns the value of an optional string argument, or ""
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func graphqlTime(t time.Time) graphql.Time {
+	return graphql.Time{Time: t}
+}
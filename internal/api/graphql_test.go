@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"k8s-cluster-info-collector/internal/models"
+)
+
+func graphqlFixture() models.ClusterInfo {
+	return models.ClusterInfo{
+		Deployments: []models.DeploymentInfo{
+			{Name: "web", Namespace: "shop", Replicas: 2},
+			{Name: "api", Namespace: "team-b", Replicas: 1},
+		},
+		Pods: []models.PodInfo{
+			{Name: "web-5d9c7-abcde", Namespace: "shop", DeploymentName: "web-5d9c7", NodeName: "node-1",
+				Labels: map[string]string{"app": "web"}, VolumeClaims: []string{"web-data"}},
+			{Name: "web-5d9c7-fghij", Namespace: "shop", DeploymentName: "web-5d9c7", NodeName: "node-2",
+				Labels: map[string]string{"app": "web"}},
+			{Name: "api-7f8b9-klmno", Namespace: "team-b", DeploymentName: "api-7f8b9", NodeName: "node-1"},
+		},
+		Nodes: []models.NodeInfo{{Name: "node-1", Ready: true}, {Name: "node-2", Ready: true}},
+		Services: []models.ServiceInfo{
+			{Name: "web", Namespace: "shop", Selector: map[string]string{"app": "web"}},
+			{Name: "headless", Namespace: "shop"},
+		},
+		Ingresses: []models.IngressInfo{
+			{Name: "web", Namespace: "shop", Paths: []models.IngressPath{{Path: "/", ServiceName: "web", ServicePort: 80}}},
+		},
+		PersistentVolumes: []models.PersistentVolumeInfo{{Name: "pv-1", ClaimRef: "shop/web-data"}},
+		PersistentVolumeClaims: []models.PersistentVolumeClaimInfo{
+			{Name: "web-data", Namespace: "shop", VolumeName: "pv-1"},
+		},
+	}
+}
+
+func execGraphQL(t *testing.T, allowed func(kind, namespace string) bool, maxDepth int, query string) *graphql.Response {
+	t.Helper()
+	root := &graphqlRoot{
+		load: func(ctx context.Context, cluster string, id *int32, at *graphql.Time) (*snapshotResolver, error) {
+			ref := models.SnapshotRef{ID: 7, Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Cluster: "prod"}
+			return &snapshotResolver{ref: ref, graph: newSnapshotGraph(graphqlFixture(), allowed)}, nil
+		},
+		list: func(ctx context.Context, cluster string, limit int) ([]models.SnapshotRef, error) {
+			return nil, nil
+		},
+	}
+	schema, err := newGraphQLSchema(root, maxDepth)
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+	return schema.Exec(withGraphQLSnapshots(context.Background()), query, "", nil)
+}
+
+func allowAll(string, string) bool { return true }
+
+func TestGraphQLRelationships(t *testing.T) {
+	response := execGraphQL(t, allowAll, 0, `{
+		snapshot {
+			id
+			cluster
+			deployment(namespace: "shop", name: "web") {
+				pods {
+					name
+					owner { name }
+					node { name persistentVolumeClaims { name volume { name claim { name } } } }
+				}
+				services { name ingresses { name } }
+			}
+		}
+	}`)
+	if len(response.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", response.Errors)
+	}
+
+	var result struct {
+		Snapshot struct {
+			ID         int
+			Cluster    string
+			Deployment struct {
+				Pods []struct {
+					Name  string
+					Owner struct{ Name string }
+					Node  struct {
+						Name                   string
+						PersistentVolumeClaims []struct {
+							Name   string
+							Volume struct {
+								Name  string
+								Claim struct{ Name string }
+							}
+						}
+					}
+				}
+				Services []struct {
+					Name      string
+					Ingresses []struct{ Name string }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	deployment := result.Snapshot.Deployment
+	if result.Snapshot.ID != 7 || result.Snapshot.Cluster != "prod" || len(deployment.Pods) != 2 {
+		t.Fatalf("expected snapshot 7 of prod with 2 web pods, got %s", response.Data)
+	}
+	first := deployment.Pods[0]
+	if first.Owner.Name != "web" || first.Node.Name != "node-1" {
+		t.Errorf("unexpected owner or node: %+v", first)
+	}
+	if len(first.Node.PersistentVolumeClaims) != 1 {
+		t.Fatalf("expected node-1 to have one claim, got %s", response.Data)
+	}
+	claim := first.Node.PersistentVolumeClaims[0]
+	if claim.Name != "web-data" || claim.Volume.Name != "pv-1" || claim.Volume.Claim.Name != "web-data" {
+		t.Errorf("unexpected claim: %+v", claim)
+	}
+	if len(deployment.Pods[1].Node.PersistentVolumeClaims) != 0 {
+		t.Errorf("expected node-2 to have no claims")
+	}
+	if len(deployment.Services) != 1 || deployment.Services[0].Name != "web" ||
+		len(deployment.Services[0].Ingresses) != 1 {
+		t.Errorf("expected web service routed by one ingress, got %+v", deployment.Services)
+	}
+}
+
+func TestGraphQLMaxDepth(t *testing.T) {
+	query := `{ snapshot { pods { node { pods { node { name } } } } } }`
+
+	response := execGraphQL(t, allowAll, 4, query)
+	if len(response.Errors) == 0 {
+		t.Fatal("expected query deeper than the limit to be rejected")
+	}
+	if !strings.Contains(response.Errors[0].Message, "depth") {
+		t.Errorf("expected depth error, got %v", response.Errors[0])
+	}
+
+	if response := execGraphQL(t, allowAll, 0, query); len(response.Errors) > 0 {
+		t.Errorf("expected query within the default limit to succeed, got %v", response.Errors)
+	}
+}
+
+func TestGraphQLAuthorization(t *testing.T) {
+	// Only Deployments and Pods in shop
+	allowed := func(kind, namespace string) bool {
+		return (kind == models.KindDeployment || kind == models.KindPod) && namespace == "shop"
+	}
+	response := execGraphQL(t, allowed, 0, `{
+		snapshot {
+			deployments { name }
+			pods { name node { name } services { name } }
+			nodes { name }
+		}
+	}`)
+	if len(response.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", response.Errors)
+	}
+
+	var result struct {
+		Snapshot struct {
+			Deployments []struct{ Name string }
+			Pods        []struct {
+				Name     string
+				Node     *struct{ Name string }
+				Services []struct{ Name string }
+			}
+			Nodes []struct{ Name string }
+		}
+	}
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(result.Snapshot.Deployments) != 1 || len(result.Snapshot.Pods) != 2 {
+		t.Errorf("expected only shop objects, got %s", response.Data)
+	}
+	if len(result.Snapshot.Nodes) != 0 {
+		t.Errorf("expected nodes to be hidden, got %s", response.Data)
+	}
+	for _, pod := range result.Snapshot.Pods {
+		if pod.Node != nil || len(pod.Services) != 0 {
+			t.Errorf("expected relationships to hidden kinds to be empty, got %s", response.Data)
+		}
+	}
+}
+
+func TestGraphQLNilLists(t *testing.T) {
+	response := execGraphQL(t, allowAll, 0, `{ snapshot { nodes { name labels } services { externalIPs ports { port } } } }`)
+	if len(response.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", response.Errors)
+	}
+}
+
+func TestGraphQLSnapshotLoads(t *testing.T) {
+	var mutex sync.Mutex
+	loads := 0
+	root := &graphqlRoot{
+		load: func(ctx context.Context, cluster string, id *int32, at *graphql.Time) (*snapshotResolver, error) {
+			mutex.Lock()
+			loads++
+			mutex.Unlock()
+			ref := models.SnapshotRef{ID: int(*id), Cluster: "prod"}
+			return &snapshotResolver{ref: ref, graph: newSnapshotGraph(graphqlFixture(), allowAll)}, nil
+		},
+	}
+	schema, err := newGraphQLSchema(root, 0)
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+	exec := func(query string) *graphql.Response {
+		loads = 0
+		return schema.Exec(withGraphQLSnapshots(context.Background()), query, "", nil)
+	}
+
+	// Aliases of the same snapshot share one load
+	response := exec(`{ a: snapshot(id: 1) { id } b: snapshot(id: 1) { pods { name } } c: snapshot(id: 2) { id } }`)
+	if len(response.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", response.Errors)
+	}
+	if loads != 2 {
+		t.Errorf("expected 2 loads, got %d", loads)
+	}
+
+	var fields []string
+	for i := 1; i <= maxGraphQLSnapshots+1; i++ {
+		fields = append(fields, fmt.Sprintf("s%d: snapshot(id: %d) { id }", i, i))
+	}
+	response = exec("{ " + strings.Join(fields, " ") + " }")
+	if len(response.Errors) != 1 || !strings.Contains(response.Errors[0].Message, "at most") {
+		t.Errorf("expected one snapshot over the limit to fail, got %v", response.Errors)
+	}
+	if loads != maxGraphQLSnapshots {
+		t.Errorf("expected %d loads, got %d", maxGraphQLSnapshots, loads)
+	}
+}
@@ -0,0 +1,573 @@
+package api
+
+import (
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"k8s-cluster-info-collector/internal/models"
+)
+
+// snapshotGraph indexes the objects of one snapshot for relationship lookups.
+// It only holds the objects the caller is allowed to see.
+type snapshotGraph struct {
+	info models.ClusterInfo
+
+	deployments map[string]*models.DeploymentInfo
+	pods        map[string]*models.PodInfo
+	nodes       map[string]*models.NodeInfo
+	services    map[string]*models.ServiceInfo
+	pvs         map[string]*models.PersistentVolumeInfo
+	pvcs        map[string]*models.PersistentVolumeClaimInfo
+}
+
+func newSnapshotGraph(info models.ClusterInfo, allowed func(kind, namespace string) bool) *snapshotGraph {
+	var visible models.ClusterInfo
+	visible.Timestamp = info.Timestamp
+	for _, d := range info.Deployments {
+		if allowed(models.KindDeployment, d.Namespace) {
+			visible.Deployments = append(visible.Deployments, d)
+		}
+	}
+	for _, p := range info.Pods {
+		if allowed(models.KindPod, p.Namespace) {
+			visible.Pods = append(visible.Pods, p)
+		}
+	}
+	for _, n := range info.Nodes {
+		if allowed(models.KindNode, "") {
+			visible.Nodes = append(visible.Nodes, n)
+		}
+	}
+	for _, svc := range info.Services {
+		if allowed(models.KindService, svc.Namespace) {
+			visible.Services = append(visible.Services, svc)
+		}
+	}
+	for _, ing := range info.Ingresses {
+		if allowed(models.KindIngress, ing.Namespace) {
+			visible.Ingresses = append(visible.Ingresses, ing)
+		}
+	}
+	for _, cm := range info.ConfigMaps {
+		if allowed(models.KindConfigMap, cm.Namespace) {
+			visible.ConfigMaps = append(visible.ConfigMaps, cm)
+		}
+	}
+	for _, secret := range info.Secrets {
+		if allowed(models.KindSecret, secret.Namespace) {
+			visible.Secrets = append(visible.Secrets, secret)
+		}
+	}
+	for _, pv := range info.PersistentVolumes {
+		if allowed(models.KindPersistentVolume, "") {
+			visible.PersistentVolumes = append(visible.PersistentVolumes, pv)
+		}
+	}
+	for _, pvc := range info.PersistentVolumeClaims {
+		if allowed(models.KindPersistentVolumeClaim, pvc.Namespace) {
+			visible.PersistentVolumeClaims = append(visible.PersistentVolumeClaims, pvc)
+		}
+	}
+
+	g := &snapshotGraph{
+		info:        visible,
+		deployments: make(map[string]*models.DeploymentInfo),
+		pods:        make(map[string]*models.PodInfo),
+		nodes:       make(map[string]*models.NodeInfo),
+		services:    make(map[string]*models.ServiceInfo),
+		pvs:         make(map[string]*models.PersistentVolumeInfo),
+		pvcs:        make(map[string]*models.PersistentVolumeClaimInfo),
+	}
+	for i := range visible.Deployments {
+		g.deployments[objectKey(visible.Deployments[i].Namespace, visible.Deployments[i].Name)] = &visible.Deployments[i]
+	}
+	for i := range visible.Pods {
+		g.pods[objectKey(visible.Pods[i].Namespace, visible.Pods[i].Name)] = &visible.Pods[i]
+	}
+	for i := range visible.Nodes {
+		g.nodes[visible.Nodes[i].Name] = &visible.Nodes[i]
+	}
+	for i := range visible.Services {
+		g.services[objectKey(visible.Services[i].Namespace, visible.Services[i].Name)] = &visible.Services[i]
+	}
+	for i := range visible.PersistentVolumes {
+		g.pvs[visible.PersistentVolumes[i].Name] = &visible.PersistentVolumes[i]
+	}
+	for i := range visible.PersistentVolumeClaims {
+		g.pvcs[objectKey(visible.PersistentVolumeClaims[i].Namespace, visible.PersistentVolumeClaims[i].Name)] = &visible.PersistentVolumeClaims[i]
+	}
+	return g
+}
+
+func objectKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// podDeployment returns the deployment owning a pod. The collector records
+// the owning ReplicaSet, whose name is the deployment name followed by the
+// pod template hash.
+func (g *snapshotGraph) podDeployment(pod *models.PodInfo) *models.DeploymentInfo {
+	if pod.DeploymentName == "" {
+		return nil
+	}
+	if i := strings.LastIndex(pod.DeploymentName, "-"); i > 0 {
+		if d, ok := g.deployments[objectKey(pod.Namespace, pod.DeploymentName[:i])]; ok {
+			return d
+		}
+	}
+	return g.deployments[objectKey(pod.Namespace, pod.DeploymentName)]
+}
+
+// selects reports whether a service selector matches pod labels. Services
+// without a selector select nothing.
+func selects(selector, labels map[string]string) bool {
+	if len(selector) == 0 {
+		return false
+	}
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *snapshotGraph) podsWhere(match func(*models.PodInfo) bool) []*podResolver {
+	resolvers := []*podResolver{}
+	for i := range g.info.Pods {
+		if match(&g.info.Pods[i]) {
+			resolvers = append(resolvers, &podResolver{g.info.Pods[i], g})
+		}
+	}
+	return resolvers
+}
+
+func (g *snapshotGraph) servicesWhere(match func(*models.ServiceInfo) bool) []*serviceResolver {
+	resolvers := []*serviceResolver{}
+	for i := range g.info.Services {
+		if match(&g.info.Services[i]) {
+			resolvers = append(resolvers, &serviceResolver{g.info.Services[i], g})
+		}
+	}
+	return resolvers
+}
+
+func (g *snapshotGraph) claimsFor(pods []*podResolver) []*pvcResolver {
+	resolvers := []*pvcResolver{}
+	seen := make(map[string]bool)
+	for _, pod := range pods {
+		for _, claim := range pod.VolumeClaims {
+			key := objectKey(pod.Namespace, claim)
+			if pvc, ok := g.pvcs[key]; ok && !seen[key] {
+				seen[key] = true
+				resolvers = append(resolvers, &pvcResolver{*pvc, g})
+			}
+		}
+	}
+	return resolvers
+}
+
+// inNamespace matches every namespace when namespace is nil
+func inNamespace(namespace *string, value string) bool {
+	return namespace == nil || *namespace == value
+}
+
+// snapshotResolver resolves the Snapshot type
+type snapshotResolver struct {
+	ref   models.SnapshotRef
+	graph *snapshotGraph
+}
+
+type namespaceArgs struct {
+	Namespace *string
+}
+
+type objectArgs struct {
+	Namespace string
+	Name      string
+}
+
+type nameArgs struct {
+	Name string
+}
+
+func (r *snapshotResolver) ID() int32 { return int32(r.ref.ID) }
+
+func (r *snapshotResolver) Timestamp() graphql.Time { return graphqlTime(r.ref.Timestamp) }
+
+func (r *snapshotResolver) Cluster() string { return r.ref.Cluster }
+
+func (r *snapshotResolver) Deployments(args namespaceArgs) []*deploymentResolver {
+	resolvers := []*deploymentResolver{}
+	for i := range r.graph.info.Deployments {
+		if d := &r.graph.info.Deployments[i]; inNamespace(args.Namespace, d.Namespace) {
+			resolvers = append(resolvers, &deploymentResolver{*d, r.graph})
+		}
+	}
+	return resolvers
+}
+
+func (r *snapshotResolver) Deployment(args objectArgs) *deploymentResolver {
+	if d, ok := r.graph.deployments[objectKey(args.Namespace, args.Name)]; ok {
+		return &deploymentResolver{*d, r.graph}
+	}
+	return nil
+}
+
+func (r *snapshotResolver) Pods(args struct {
+	Namespace *string
+	Node      *string
+}) []*podResolver {
+	return r.graph.podsWhere(func(p *models.PodInfo) bool {
+		return inNamespace(args.Namespace, p.Namespace) && (args.Node == nil || *args.Node == p.NodeName)
+	})
+}
+
+func (r *snapshotResolver) Pod(args objectArgs) *podResolver {
+	if p, ok := r.graph.pods[objectKey(args.Namespace, args.Name)]; ok {
+		return &podResolver{*p, r.graph}
+	}
+	return nil
+}
+
+func (r *snapshotResolver) Nodes() []*nodeResolver {
+	resolvers := []*nodeResolver{}
+	for i := range r.graph.info.Nodes {
+		resolvers = append(resolvers, &nodeResolver{r.graph.info.Nodes[i], r.graph})
+	}
+	return resolvers
+}
+
+func (r *snapshotResolver) Node(args nameArgs) *nodeResolver {
+	if n, ok := r.graph.nodes[args.Name]; ok {
+		return &nodeResolver{*n, r.graph}
+	}
+	return nil
+}
+
+func (r *snapshotResolver) Services(args namespaceArgs) []*serviceResolver {
+	return r.graph.servicesWhere(func(svc *models.ServiceInfo) bool {
+		return inNamespace(args.Namespace, svc.Namespace)
+	})
+}
+
+func (r *snapshotResolver) Service(args objectArgs) *serviceResolver {
+	if svc, ok := r.graph.services[objectKey(args.Namespace, args.Name)]; ok {
+		return &serviceResolver{*svc, r.graph}
+	}
+	return nil
+}
+
+func (r *snapshotResolver) Ingresses(args namespaceArgs) []*ingressResolver {
+	resolvers := []*ingressResolver{}
+	for i := range r.graph.info.Ingresses {
+		if ing := &r.graph.info.Ingresses[i]; inNamespace(args.Namespace, ing.Namespace) {
+			resolvers = append(resolvers, &ingressResolver{*ing, r.graph})
+		}
+	}
+	return resolvers
+}
+
+func (r *snapshotResolver) Ingress(args objectArgs) *ingressResolver {
+	for i := range r.graph.info.Ingresses {
+		if ing := &r.graph.info.Ingresses[i]; ing.Namespace == args.Namespace && ing.Name == args.Name {
+			return &ingressResolver{*ing, r.graph}
+		}
+	}
+	return nil
+}
+
+func (r *snapshotResolver) ConfigMaps(args namespaceArgs) []*configMapResolver {
+	resolvers := []*configMapResolver{}
+	for i := range r.graph.info.ConfigMaps {
+		if cm := &r.graph.info.ConfigMaps[i]; inNamespace(args.Namespace, cm.Namespace) {
+			resolvers = append(resolvers, &configMapResolver{*cm})
+		}
+	}
+	return resolvers
+}
+
+func (r *snapshotResolver) ConfigMap(args objectArgs) *configMapResolver {
+	for i := range r.graph.info.ConfigMaps {
+		if cm := &r.graph.info.ConfigMaps[i]; cm.Namespace == args.Namespace && cm.Name == args.Name {
+			return &configMapResolver{*cm}
+		}
+	}
+	return nil
+}
+
+func (r *snapshotResolver) Secrets(args namespaceArgs) []*secretResolver {
+	resolvers := []*secretResolver{}
+	for i := range r.graph.info.Secrets {
+		if secret := &r.graph.info.Secrets[i]; inNamespace(args.Namespace, secret.Namespace) {
+			resolvers = append(resolvers, &secretResolver{*secret})
+		}
+	}
+	return resolvers
+}
+
+func (r *snapshotResolver) Secret(args objectArgs) *secretResolver {
+	for i := range r.graph.info.Secrets {
+		if secret := &r.graph.info.Secrets[i]; secret.Namespace == args.Namespace && secret.Name == args.Name {
+			return &secretResolver{*secret}
+		}
+	}
+	return nil
+}
+
+func (r *snapshotResolver) PersistentVolumes() []*pvResolver {
+	resolvers := []*pvResolver{}
+	for i := range r.graph.info.PersistentVolumes {
+		resolvers = append(resolvers, &pvResolver{r.graph.info.PersistentVolumes[i], r.graph})
+	}
+	return resolvers
+}
+
+func (r *snapshotResolver) PersistentVolume(args nameArgs) *pvResolver {
+	if pv, ok := r.graph.pvs[args.Name]; ok {
+		return &pvResolver{*pv, r.graph}
+	}
+	return nil
+}
+
+func (r *snapshotResolver) PersistentVolumeClaims(args namespaceArgs) []*pvcResolver {
+	resolvers := []*pvcResolver{}
+	for i := range r.graph.info.PersistentVolumeClaims {
+		if pvc := &r.graph.info.PersistentVolumeClaims[i]; inNamespace(args.Namespace, pvc.Namespace) {
+			resolvers = append(resolvers, &pvcResolver{*pvc, r.graph})
+		}
+	}
+	return resolvers
+}
+
+func (r *snapshotResolver) PersistentVolumeClaim(args objectArgs) *pvcResolver {
+	if pvc, ok := r.graph.pvcs[objectKey(args.Namespace, args.Name)]; ok {
+		return &pvcResolver{*pvc, r.graph}
+	}
+	return nil
+}
+
+// The object resolvers embed the model so plain fields resolve directly;
+// methods cover type conversions and relationships.
+
+type deploymentResolver struct {
+	models.DeploymentInfo
+	graph *snapshotGraph
+}
+
+func (r *deploymentResolver) Generation() int32 { return int32(r.DeploymentInfo.Generation) }
+func (r *deploymentResolver) CreatedTime() graphql.Time {
+	return graphqlTime(r.DeploymentInfo.CreatedTime)
+}
+func (r *deploymentResolver) Conditions() *jsonValue  { return jsonOf(r.DeploymentInfo.Conditions) }
+func (r *deploymentResolver) Labels() *jsonValue      { return jsonOf(r.DeploymentInfo.Labels) }
+func (r *deploymentResolver) Annotations() *jsonValue { return jsonOf(r.DeploymentInfo.Annotations) }
+
+func (r *deploymentResolver) Pods() []*podResolver {
+	return r.graph.podsWhere(func(p *models.PodInfo) bool {
+		d := r.graph.podDeployment(p)
+		return d != nil && d.Namespace == r.Namespace && d.Name == r.Name
+	})
+}
+
+func (r *deploymentResolver) Services() []*serviceResolver {
+	pods := r.Pods()
+	return r.graph.servicesWhere(func(svc *models.ServiceInfo) bool {
+		for _, pod := range pods {
+			if svc.Namespace == pod.Namespace && selects(svc.Selector, pod.PodInfo.Labels) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+type podResolver struct {
+	models.PodInfo
+	graph *snapshotGraph
+}
+
+func (r *podResolver) Generation() int32         { return int32(r.PodInfo.Generation) }
+func (r *podResolver) CreatedTime() graphql.Time { return graphqlTime(r.PodInfo.CreatedTime) }
+func (r *podResolver) Labels() *jsonValue        { return jsonOf(r.PodInfo.Labels) }
+func (r *podResolver) Annotations() *jsonValue   { return jsonOf(r.PodInfo.Annotations) }
+
+func (r *podResolver) Owner() *deploymentResolver {
+	if d := r.graph.podDeployment(&r.PodInfo); d != nil {
+		return &deploymentResolver{*d, r.graph}
+	}
+	return nil
+}
+
+func (r *podResolver) Node() *nodeResolver {
+	if n, ok := r.graph.nodes[r.NodeName]; ok {
+		return &nodeResolver{*n, r.graph}
+	}
+	return nil
+}
+
+func (r *podResolver) Services() []*serviceResolver {
+	return r.graph.servicesWhere(func(svc *models.ServiceInfo) bool {
+		return svc.Namespace == r.Namespace && selects(svc.Selector, r.PodInfo.Labels)
+	})
+}
+
+func (r *podResolver) PersistentVolumeClaims() []*pvcResolver {
+	return r.graph.claimsFor([]*podResolver{r})
+}
+
+type nodeResolver struct {
+	models.NodeInfo
+	graph *snapshotGraph
+}
+
+func (r *nodeResolver) Generation() int32         { return int32(r.NodeInfo.Generation) }
+func (r *nodeResolver) CreatedTime() graphql.Time { return graphqlTime(r.NodeInfo.CreatedTime) }
+func (r *nodeResolver) Labels() *jsonValue        { return jsonOf(r.NodeInfo.Labels) }
+func (r *nodeResolver) Annotations() *jsonValue   { return jsonOf(r.NodeInfo.Annotations) }
+
+func (r *nodeResolver) Pods() []*podResolver {
+	return r.graph.podsWhere(func(p *models.PodInfo) bool {
+		return p.NodeName == r.Name
+	})
+}
+
+func (r *nodeResolver) PersistentVolumeClaims() []*pvcResolver {
+	return r.graph.claimsFor(r.Pods())
+}
+
+type serviceResolver struct {
+	models.ServiceInfo
+	graph *snapshotGraph
+}
+
+func (r *serviceResolver) Generation() int32         { return int32(r.ServiceInfo.Generation) }
+func (r *serviceResolver) CreatedTime() graphql.Time { return graphqlTime(r.ServiceInfo.CreatedTime) }
+func (r *serviceResolver) Selector() *jsonValue      { return jsonOf(r.ServiceInfo.Selector) }
+func (r *serviceResolver) Labels() *jsonValue        { return jsonOf(r.ServiceInfo.Labels) }
+func (r *serviceResolver) Annotations() *jsonValue   { return jsonOf(r.ServiceInfo.Annotations) }
+
+func (r *serviceResolver) Pods() []*podResolver {
+	return r.graph.podsWhere(func(p *models.PodInfo) bool {
+		return p.Namespace == r.Namespace && selects(r.ServiceInfo.Selector, p.Labels)
+	})
+}
+
+func (r *serviceResolver) Ingresses() []*ingressResolver {
+	resolvers := []*ingressResolver{}
+	for i := range r.graph.info.Ingresses {
+		ing := &r.graph.info.Ingresses[i]
+		if ing.Namespace != r.Namespace {
+			continue
+		}
+		for _, path := range ing.Paths {
+			if path.ServiceName == r.Name {
+				resolvers = append(resolvers, &ingressResolver{*ing, r.graph})
+				break
+			}
+		}
+	}
+	return resolvers
+}
+
+type ingressResolver struct {
+	models.IngressInfo
+	graph *snapshotGraph
+}
+
+func (r *ingressResolver) Generation() int32         { return int32(r.IngressInfo.Generation) }
+func (r *ingressResolver) CreatedTime() graphql.Time { return graphqlTime(r.IngressInfo.CreatedTime) }
+func (r *ingressResolver) Labels() *jsonValue        { return jsonOf(r.IngressInfo.Labels) }
+func (r *ingressResolver) Annotations() *jsonValue   { return jsonOf(r.IngressInfo.Annotations) }
+
+func (r *ingressResolver) Services() []*serviceResolver {
+	resolvers := []*serviceResolver{}
+	seen := make(map[string]bool)
+	for _, path := range r.Paths {
+		key := objectKey(r.Namespace, path.ServiceName)
+		if svc, ok := r.graph.services[key]; ok && !seen[key] {
+			seen[key] = true
+			resolvers = append(resolvers, &serviceResolver{*svc, r.graph})
+		}
+	}
+	return resolvers
+}
+
+type configMapResolver struct {
+	models.ConfigMapInfo
+}
+
+func (r *configMapResolver) Generation() int32 { return int32(r.ConfigMapInfo.Generation) }
+func (r *configMapResolver) CreatedTime() graphql.Time {
+	return graphqlTime(r.ConfigMapInfo.CreatedTime)
+}
+func (r *configMapResolver) Data() *jsonValue        { return jsonOf(r.ConfigMapInfo.Data) }
+func (r *configMapResolver) Labels() *jsonValue      { return jsonOf(r.ConfigMapInfo.Labels) }
+func (r *configMapResolver) Annotations() *jsonValue { return jsonOf(r.ConfigMapInfo.Annotations) }
+
+type secretResolver struct {
+	models.SecretInfo
+}
+
+func (r *secretResolver) Generation() int32         { return int32(r.SecretInfo.Generation) }
+func (r *secretResolver) CreatedTime() graphql.Time { return graphqlTime(r.SecretInfo.CreatedTime) }
+func (r *secretResolver) Labels() *jsonValue        { return jsonOf(r.SecretInfo.Labels) }
+func (r *secretResolver) Annotations() *jsonValue   { return jsonOf(r.SecretInfo.Annotations) }
+
+type pvResolver struct {
+	models.PersistentVolumeInfo
+	graph *snapshotGraph
+}
+
+func (r *pvResolver) Generation() int32 { return int32(r.PersistentVolumeInfo.Generation) }
+func (r *pvResolver) CreatedTime() graphql.Time {
+	return graphqlTime(r.PersistentVolumeInfo.CreatedTime)
+}
+func (r *pvResolver) Labels() *jsonValue      { return jsonOf(r.PersistentVolumeInfo.Labels) }
+func (r *pvResolver) Annotations() *jsonValue { return jsonOf(r.PersistentVolumeInfo.Annotations) }
+
+// Claim resolves the ClaimRef, recorded by the collector as namespace/name
+func (r *pvResolver) Claim() *pvcResolver {
+	if pvc, ok := r.graph.pvcs[r.ClaimRef]; ok {
+		return &pvcResolver{*pvc, r.graph}
+	}
+	return nil
+}
+
+type pvcResolver struct {
+	models.PersistentVolumeClaimInfo
+	graph *snapshotGraph
+}
+
+func (r *pvcResolver) Generation() int32 {
+	return int32(r.PersistentVolumeClaimInfo.Generation)
+}
+func (r *pvcResolver) CreatedTime() graphql.Time {
+	return graphqlTime(r.PersistentVolumeClaimInfo.CreatedTime)
+}
+func (r *pvcResolver) Labels() *jsonValue { return jsonOf(r.PersistentVolumeClaimInfo.Labels) }
+func (r *pvcResolver) Annotations() *jsonValue {
+	return jsonOf(r.PersistentVolumeClaimInfo.Annotations)
+}
+
+func (r *pvcResolver) Volume() *pvResolver {
+	if pv, ok := r.graph.pvs[r.VolumeName]; ok {
+		return &pvResolver{*pv, r.graph}
+	}
+	return nil
+}
+
+func (r *pvcResolver) Pods() []*podResolver {
+	return r.graph.podsWhere(func(p *models.PodInfo) bool {
+		if p.Namespace != r.Namespace {
+			return false
+		}
+		for _, claim := range p.VolumeClaims {
+			if claim == r.Name {
+				return true
+			}
+		}
+		return false
+	})
+}
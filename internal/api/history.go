@@ -0,0 +1,256 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// historyFields maps, per resource table, the response field names of a
+// history entry to SQL expressions over the table row r
+var historyFields = map[string]map[string]string{
+	"deployments": {
+		"replicas":         "r.replicas",
+		"ready_replicas":   "r.ready_replicas",
+		"updated_replicas": "r.updated_replicas",
+		"images":           "r.data->'images'",
+		"labels":           "r.data->'labels'",
+	},
+	"pods": {
+		"phase":          "r.phase",
+		"node_name":      "r.node_name",
+		"restart_count":  "r.restart_count",
+		"images":         "jsonb_path_query_array(r.data, '$.container_statuses[*].image')",
+		"cpu_request":    "r.cpu_request",
+		"memory_request": "r.memory_request",
+		"labels":         "r.data->'labels'",
+	},
+	"nodes": {
+		"ready":              "r.ready",
+		"kubelet_version":    "r.kubelet_version",
+		"os_image":           "r.os_image",
+		"kernel_version":     "r.kernel_version",
+		"cpu_allocatable":    "r.cpu_allocatable",
+		"memory_allocatable": "r.memory_allocatable",
+		"labels":             "r.data->'labels'",
+	},
+	"services": {
+		"type":         "r.type",
+		"cluster_ip":   "r.cluster_ip",
+		"external_ips": "r.data->'external_ips'",
+		"ports":        "r.data->'ports'",
+		"selector":     "r.data->'selector'",
+		"labels":       "r.data->'labels'",
+	},
+	"ingresses": {
+		"hosts":  "r.data->'hosts'",
+		"paths":  "r.data->'paths'",
+		"labels": "r.data->'labels'",
+	},
+	"configmaps": {
+		"data_keys": "r.data_keys",
+		"labels":    "r.data->'labels'",
+	},
+	"secrets": {
+		"type":      "r.type",
+		"data_keys": "r.data_keys",
+		"labels":    "r.data->'labels'",
+	},
+	"persistent_volumes": {
+		"capacity":       "r.capacity",
+		"status":         "r.status",
+		"reclaim_policy": "r.reclaim_policy",
+		"claim_ref":      "r.data->'claim_ref'",
+		"labels":         "r.data->'labels'",
+	},
+	"persistent_volume_claims": {
+		"requested_size": "r.requested_size",
+		"status":         "r.status",
+		"volume_name":    "r.volume_name",
+		"labels":         "r.data->'labels'",
+	},
+}
+
+// ChangePoint is a run of consecutive snapshots in which the tracked fields
+// of an object did not change
+type ChangePoint struct {
+	SnapshotID     int                    `json:"snapshot_id"`
+	Timestamp      time.Time              `json:"timestamp"`
+	LastSnapshotID int                    `json:"last_snapshot_id"`
+	LastSeen       time.Time              `json:"last_seen"`
+	Snapshots      int                    `json:"snapshots"`
+	UID            string                 `json:"uid,omitempty"`
+	Values         map[string]interface{} `json:"values"`
+	Changed        []string               `json:"changed,omitempty"`
+}
+
+// historySample is one row of an object's history
+type historySample struct {
+	snapshotID int
+	timestamp  time.Time
+	uid        string
+	values     map[string]interface{}
+}
+
+// getObjectHistory returns how one object evolved across snapshots,
+// collapsed into change points. Query parameters: since and until (RFC3339)
+// and cluster, which is required when several clusters are stored.
+func (s *Server) getObjectHistory(w http.ResponseWriter, r *http.Request, kind resourceKind) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+	fields := historyFields[kind.table]
+
+	access, ok := s.authorizeKind(w, r, kind)
+	if !ok {
+		return
+	}
+	if kind.namespaced && !s.authorizeNamespace(w, access, vars["namespace"]) {
+		return
+	}
+	cluster, ok := s.resolveClusterFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, "'"+name+"', "+fields[name])
+	}
+
+	sqlQuery := `
+		SELECT s.id, s.timestamp, COALESCE(r.uid, ''), jsonb_build_object(` + strings.Join(pairs, ", ") + `)
+		FROM ` + kind.table + ` r
+		JOIN cluster_snapshots s ON s.id = r.snapshot_id
+		WHERE r.name = $1`
+	args := []interface{}{vars["name"]}
+	if kind.namespaced {
+		args = append(args, vars["namespace"])
+		sqlQuery += " AND r.namespace = $" + strconv.Itoa(len(args))
+	}
+	if cluster != "" {
+		args = append(args, cluster)
+		sqlQuery += " AND r.cluster = $" + strconv.Itoa(len(args))
+	}
+	for _, bound := range []struct{ param, op string }{{"since", ">="}, {"until", "<="}} {
+		value := query.Get(bound.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			s.writeError(w, "Invalid "+bound.param+" timestamp, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+		args = append(args, parsed)
+		sqlQuery += " AND s.timestamp " + bound.op + " $" + strconv.Itoa(len(args))
+	}
+	sqlQuery += " ORDER BY s.timestamp ASC"
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to query object history")
+		s.writeError(w, "Failed to fetch object history", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var samples []historySample
+	for rows.Next() {
+		var sample historySample
+		var data []byte
+		if err := rows.Scan(&sample.snapshotID, &sample.timestamp, &sample.uid, &data); err != nil {
+			s.logger.WithError(err).Error("Failed to scan object history row")
+			continue
+		}
+		if err := json.Unmarshal(data, &sample.values); err != nil {
+			s.logger.WithError(err).Error("Failed to parse object history row")
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.WithError(err).Error("Failed to read object history")
+		s.writeError(w, "Failed to fetch object history", http.StatusInternalServerError)
+		return
+	}
+
+	if len(samples) == 0 {
+		s.writeError(w, "No history found for this object", http.StatusNotFound)
+		return
+	}
+
+	changes := collapseHistory(samples)
+	s.writeJSON(w, ObjectHistory{
+		Namespace: vars["namespace"],
+		Name:      vars["name"],
+		History:   changes,
+		Count:     len(changes),
+		Snapshots: len(samples),
+	})
+}
+
+// collapseHistory merges consecutive samples with identical values into a
+// single change point. A change of UID (the object was recreated) also
+// starts a new change point.
+func collapseHistory(samples []historySample) []ChangePoint {
+	var points []ChangePoint
+	for _, sample := range samples {
+		if n := len(points); n > 0 {
+			last := &points[n-1]
+			if last.UID == sample.uid && reflect.DeepEqual(last.Values, sample.values) {
+				last.LastSnapshotID = sample.snapshotID
+				last.LastSeen = sample.timestamp
+				last.Snapshots++
+				continue
+			}
+		}
+
+		point := ChangePoint{
+			SnapshotID:     sample.snapshotID,
+			Timestamp:      sample.timestamp,
+			LastSnapshotID: sample.snapshotID,
+			LastSeen:       sample.timestamp,
+			Snapshots:      1,
+			UID:            sample.uid,
+			Values:         sample.values,
+		}
+		if n := len(points); n > 0 {
+			previous := points[n-1]
+			if previous.UID != sample.uid {
+				point.Changed = append(point.Changed, "uid")
+			}
+			point.Changed = append(point.Changed, changedFields(previous.Values, sample.values)...)
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+// changedFields returns the sorted names of fields that differ between two value sets
+func changedFields(old, current map[string]interface{}) []string {
+	var changed []string
+	for name, value := range current {
+		if !reflect.DeepEqual(old[name], value) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range old {
+		if _, ok := current[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
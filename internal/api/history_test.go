@@ -0,0 +1,42 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCollapseHistory(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	sample := func(id int, uid string, replicas float64) historySample {
+		return historySample{
+			snapshotID: id,
+			timestamp:  start.Add(time.Duration(id) * time.Minute),
+			uid:        uid,
+			values:     map[string]interface{}{"replicas": replicas, "images": []interface{}{"web:v1"}},
+		}
+	}
+
+	points := collapseHistory([]historySample{
+		sample(1, "a", 2),
+		sample(2, "a", 2),
+		sample(3, "a", 3),
+		sample(4, "a", 3),
+		sample(5, "b", 3),
+	})
+
+	if len(points) != 3 {
+		t.Fatalf("expected 3 change points, got %d: %+v", len(points), points)
+	}
+
+	first := points[0]
+	if first.SnapshotID != 1 || first.LastSnapshotID != 2 || first.Snapshots != 2 || first.Changed != nil {
+		t.Errorf("unexpected first change point: %+v", first)
+	}
+	if !reflect.DeepEqual(points[1].Changed, []string{"replicas"}) || points[1].Snapshots != 2 {
+		t.Errorf("expected replicas change over 2 snapshots, got %+v", points[1])
+	}
+	if !reflect.DeepEqual(points[2].Changed, []string{"uid"}) || points[2].UID != "b" {
+		t.Errorf("expected recreation to be a change point, got %+v", points[2])
+	}
+}
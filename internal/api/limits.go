@@ -0,0 +1,120 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"k8s-cluster-info-collector/internal/auth"
+)
+
+// limiterIdleTimeout is how long an unused client bucket is kept
+const limiterIdleTimeout = 10 * time.Minute
+
+// clientLimiter keeps one token bucket per client
+type clientLimiter struct {
+	rate  rate.Limit
+	burst int
+
+	mutex     sync.Mutex
+	clients   map[string]*clientBucket
+	lastSweep time.Time
+}
+
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newClientLimiter(perSecond float64, burst int) *clientLimiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(perSecond)))
+	}
+	return &clientLimiter{
+		rate:    rate.Limit(perSecond),
+		burst:   burst,
+		clients: make(map[string]*clientBucket),
+	}
+}
+
+// reserve takes a token for client. When none is available it returns false
+// and how long until the next one.
+func (l *clientLimiter) reserve(client string, now time.Time) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Forget idle clients so the map does not grow without bound
+	if now.Sub(l.lastSweep) > limiterIdleTimeout {
+		for key, bucket := range l.clients {
+			if now.Sub(bucket.lastSeen) > limiterIdleTimeout {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	bucket, ok := l.clients[client]
+	if !ok {
+		bucket = &clientBucket{limiter: rate.NewLimiter(l.rate, l.burst)}
+		l.clients[client] = bucket
+	}
+	bucket.lastSeen = now
+
+	reservation := bucket.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// rateLimitMiddleware applies a token bucket per authenticated subject, or
+// per remote IP for anonymous callers. Health and metrics endpoints are exempt.
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter == nil || publicPaths[strings.TrimPrefix(r.URL.Path, s.prefix())] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, retryAfter := s.limiter.reserve(clientKey(r), time.Now())
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			s.writeError(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientKey identifies the caller for rate limiting
+func clientKey(r *http.Request) string {
+	if identity := auth.IdentityFrom(r.Context()); identity != nil {
+		return "subject:" + identity.Subject
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// bodyLimitMiddleware rejects request bodies larger than the configured limit
+func (s *Server) bodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.config.MaxBodyBytes > 0 && r.Body != nil {
+			if r.ContentLength > s.config.MaxBodyBytes {
+				s.writeError(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, s.config.MaxBodyBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
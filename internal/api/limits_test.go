@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestClientLimiterReserve(t *testing.T) {
+	limiter := newClientLimiter(1, 2)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.reserve("alice", now); !ok {
+			t.Fatalf("request %d within burst was denied", i+1)
+		}
+	}
+	ok, retryAfter := limiter.reserve("alice", now)
+	if ok {
+		t.Fatal("expected request beyond burst to be denied")
+	}
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("expected retry within a second, got %v", retryAfter)
+	}
+
+	if ok, _ := limiter.reserve("bob", now); !ok {
+		t.Error("expected a separate bucket per client")
+	}
+	if ok, _ := limiter.reserve("alice", now.Add(time.Second)); !ok {
+		t.Error("expected a token to be refilled after a second")
+	}
+}
+
+func TestClientLimiterSweepsIdleClients(t *testing.T) {
+	limiter := newClientLimiter(1, 1)
+	now := time.Now()
+	limiter.reserve("alice", now)
+	limiter.reserve("bob", now.Add(2*limiterIdleTimeout))
+
+	if _, ok := limiter.clients["alice"]; ok {
+		t.Error("expected idle client to be forgotten")
+	}
+	if _, ok := limiter.clients["bob"]; !ok {
+		t.Error("expected active client to be kept")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	s := &Server{logger: logrus.New(), config: APIConfig{Prefix: "/api/v1"}}
+	s.limiter = newClientLimiter(0.001, 1)
+	handler := s.rateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("/api/v1/pods"); rec.Code != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", rec.Code)
+	}
+	rec := serve("/api/v1/pods")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+	if rec := serve("/api/v1/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("expected health endpoint to be exempt, got %d", rec.Code)
+	}
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	s := &Server{logger: logrus.New(), config: APIConfig{MaxBodyBytes: 8}}
+	handler := s.bodyLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/archives/import", strings.NewReader(`{"location":"x"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/archives/import", strings.NewReader(`{}`))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected small body to pass, got %d", rec.Code)
+	}
+}
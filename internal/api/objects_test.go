@@ -0,0 +1,64 @@
+package api
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-cluster-info-collector/internal/models"
+)
+
+func TestGetObjects(t *testing.T) {
+	created := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	deleted := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	var gotQuery string
+	var gotArgs []driver.Value
+	s := newFakeDBServer(t, func(query string, args []driver.Value) [][]driver.Value {
+		gotQuery, gotArgs = query, args
+		return [][]driver.Value{
+			{"prod", models.KindPod, "uid-1", "shop", "web-1", created, created, deleted.Add(-time.Hour), deleted, int64(3), int64(4)},
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	s.getObjects(rec, httptest.NewRequest(http.MethodGet,
+		"/api/v1/objects?event=deleted&kind=Pod&cluster=prod&since=2024-05-01T00:00:00Z&until=2024-05-02T00:00:00Z", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(gotQuery, "deleted_at >= $1 AND deleted_at < $2") ||
+		!strings.Contains(gotQuery, "kind = $3") || !strings.Contains(gotQuery, "cluster = $4") {
+		t.Errorf("unexpected query %s", gotQuery)
+	}
+	if len(gotArgs) != 4 || gotArgs[2] != models.KindPod || gotArgs[3] != "prod" {
+		t.Errorf("unexpected arguments %v", gotArgs)
+	}
+
+	var list ObjectList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if list.Count != 1 || list.Event != "deleted" {
+		t.Fatalf("unexpected list %+v", list)
+	}
+	obj := list.Objects[0]
+	if obj.Cluster != "prod" || obj.UID != "uid-1" || obj.DeletedAt == nil || !obj.DeletedAt.Equal(deleted) ||
+		obj.FirstSnapshotID != 3 || obj.LastSnapshotID != 4 {
+		t.Errorf("unexpected object %+v", obj)
+	}
+}
+
+func TestGetObjectsInvalidParameters(t *testing.T) {
+	s := newFakeDBServer(t, func(string, []driver.Value) [][]driver.Value { return nil })
+	for _, query := range []string{"event=updated", "since=yesterday", "until=2024-05-01"} {
+		rec := httptest.NewRecorder()
+		s.getObjects(rec, httptest.NewRequest(http.MethodGet, "/api/v1/objects?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
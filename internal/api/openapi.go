@@ -0,0 +1,527 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	graphql "github.com/graph-gophers/graphql-go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s-cluster-info-collector/internal/diff"
+	"k8s-cluster-info-collector/internal/kafka"
+	"k8s-cluster-info-collector/internal/retention"
+)
+
+// routeDoc documents one route. The OpenAPI document combines these with the
+// routes registered on the router and schemas derived from the Go types, so
+// it cannot drift from what the server actually serves.
+type routeDoc struct {
+	summary string
+	query   []queryParam
+	request interface{} // JSON request body, if any
+	// response is the JSON body of a successful response. Routes that
+	// answer with something else set content types instead.
+	response     interface{}
+	contentTypes []string
+	status       int // defaults to 200
+}
+
+// queryParam documents a query parameter. kind is a JSON schema type; a
+// format may follow after a colon, e.g. "string:date-time".
+type queryParam struct {
+	name        string
+	kind        string
+	description string
+}
+
+var (
+	clusterParam   = queryParam{"cluster", "string", "Only data from this cluster"}
+	snapshotParams = []queryParam{
+		{"snapshot_id", "integer", "Read from this snapshot"},
+		{"at", "string:date-time", "Read from the latest snapshot at or before this time"},
+		clusterParam,
+	}
+	rangeParams = []queryParam{
+		{"since", "string:date-time", "Start of the time range"},
+		{"until", "string:date-time", "End of the time range"},
+	}
+	limitParam = queryParam{"limit", "integer", "Maximum number of items to return"}
+	listParams = append([]queryParam{
+		limitParam,
+		{"cursor", "string", "Cursor from the previous page's next_cursor"},
+		{"sort", "string", "Sort key, prefixed with - for descending order"},
+		{"namespace", "string", "Only objects in this namespace"},
+		{"labelSelector", "string", "Kubernetes label selector"},
+		{"phase", "string", "Filter by pod phase"},
+		{"node", "string", "Filter by node name"},
+		{"status", "string", "Filter by status"},
+		{"type", "string", "Filter by type"},
+	}, snapshotParams...)
+	exportParams = append([]queryParam{
+		{"format", "string", "ndjson (default), csv or parquet"},
+		{"columns", "string", "Comma-separated columns to include"},
+	}, snapshotParams...)
+	deadLetterParams = []queryParam{
+		{"cluster", "string", "Only messages of this cluster"},
+		{"snapshot_id", "string", "Only the messages of this chunked snapshot"},
+		{"reason", "string", "invalid, incomplete, superseded, timeout or store"},
+	}
+	exportContentTypes = []string{"application/x-ndjson", "text/csv", "application/vnd.apache.parquet"}
+)
+
+// routeDocs documents every route by method and path below the API prefix
+var routeDocs = map[string]routeDoc{
+	"GET /": {summary: "List the API's endpoints", response: RootResponse{}},
+
+	"GET /clusters":         {summary: "List clusters with last-seen times", response: ClusterList{}},
+	"GET /snapshots":        {summary: "List snapshots with object counts", query: []queryParam{limitParam, clusterParam}, response: SnapshotList{}},
+	"GET /snapshots/{id}":   {summary: "Get a full snapshot", query: []queryParam{clusterParam}, response: SnapshotResponse{}},
+	"GET /snapshots/latest": {summary: "Redirect to the latest snapshot", query: []queryParam{clusterParam}, status: http.StatusFound},
+	"GET /snapshots/{a}/diff/{b}": {
+		summary:      "Compare two snapshots",
+		query:        []queryParam{{"format", "string", "json (default) or text"}, clusterParam},
+		response:     diff.Result{},
+		contentTypes: []string{"text/plain"},
+	},
+	"GET /snapshots/{id}/export": {
+		summary:      "Stream every object of a snapshot",
+		query:        append(exportParams[:2:2], clusterParam),
+		contentTypes: exportContentTypes,
+	},
+	"GET /export/{kind}": {
+		summary:      "Stream one kind from a snapshot or a time range",
+		query:        append(append([]queryParam{{"namespace", "string", "Only objects in this namespace"}}, exportParams...), rangeParams...),
+		contentTypes: exportContentTypes,
+	},
+
+	"GET /deployments":              {summary: "List deployments", query: listParams, response: ResourceList[DeploymentRow]{}},
+	"GET /pods":                     {summary: "List pods", query: listParams, response: ResourceList[PodRow]{}},
+	"GET /nodes":                    {summary: "List nodes", query: listParams, response: ResourceList[NodeRow]{}},
+	"GET /services":                 {summary: "List services", query: listParams, response: ResourceList[ServiceRow]{}},
+	"GET /ingresses":                {summary: "List ingresses", query: listParams, response: ResourceList[IngressRow]{}},
+	"GET /configmaps":               {summary: "List config maps", query: listParams, response: ResourceList[ConfigMapRow]{}},
+	"GET /secrets":                  {summary: "List secrets (keys only)", query: listParams, response: ResourceList[SecretRow]{}},
+	"GET /persistent-volumes":       {summary: "List persistent volumes", query: listParams, response: ResourceList[PersistentVolumeRow]{}},
+	"GET /persistent-volume-claims": {summary: "List persistent volume claims", query: listParams, response: ResourceList[PersistentVolumeClaimRow]{}},
+
+	"GET /objects": {
+		summary: "List objects created or deleted in a time range",
+		query: append([]queryParam{
+			{"event", "string", "created, deleted or all (default)"},
+			{"kind", "string", "Only objects of this kind"},
+			{"namespace", "string", "Only objects in this namespace"},
+			limitParam,
+			clusterParam,
+		}, rangeParams...),
+		response: ObjectList{},
+	},
+	"GET /graphql": {
+		summary: "Run a GraphQL query",
+		query: []queryParam{
+			{"query", "string", "GraphQL query"},
+			{"operationName", "string", "Operation to run"},
+			{"variables", "string", "JSON object of variables"},
+		},
+		response: graphql.Response{},
+	},
+	"POST /graphql": {summary: "Run a GraphQL query", request: GraphQLRequest{}, response: graphql.Response{}},
+	"GET /capacity": {
+		summary:  "Requested versus allocatable CPU and memory",
+		query:    append([]queryParam{{"group_by", "string", "cluster (default), node, namespace or owner"}}, snapshotParams...),
+		response: CapacityResponse{},
+	},
+	"GET /archives":         {summary: "List archived snapshots", query: []queryParam{limitParam}, response: ArchiveList{}},
+	"POST /archives/import": {summary: "Import an archive file", request: ImportArchiveRequest{}, response: ImportArchiveResponse{}},
+	"GET /ws":               {summary: "Stream cluster updates over a WebSocket", status: http.StatusSwitchingProtocols},
+	"GET /stats":            {summary: "Snapshot and object counts", query: []queryParam{clusterParam}, response: Stats{}},
+	"GET /stats/retention":  {summary: "Retention statistics and table sizes", response: RetentionStats{}},
+
+	"POST /retention/cleanup": {summary: "Run a retention cleanup now", response: retention.RunResult{}},
+	"GET /retention/dry-run":  {summary: "Show what the next cleanup would delete", response: RetentionDryRun{}},
+	"GET /retention/last-run": {summary: "Result of the last cleanup", response: retention.RunResult{}},
+
+	"GET /kafka/dead-letters": {
+		summary:  "List messages on the Kafka dead-letter topic",
+		query:    append([]queryParam{limitParam}, deadLetterParams...),
+		response: DeadLetterList{},
+	},
+	"POST /kafka/dead-letters/replay": {
+		summary:  "Replay matching dead letters to the Kafka topic",
+		query:    deadLetterParams,
+		response: DeadLetterReplay{},
+	},
+	"GET /kafka/dead-letters/{partition}/{offset}":         {summary: "Get a dead letter with its headers and payload", response: kafka.DeadLetter{}},
+	"POST /kafka/dead-letters/{partition}/{offset}/replay": {summary: "Replay a dead letter to the Kafka topic", response: DeadLetterReplay{}},
+
+	"GET /health":       {summary: "Health check", response: HealthResponse{}},
+	"GET /healthz":      {summary: "Health check (Kubernetes style)", response: HealthResponse{}},
+	"GET /metrics":      {summary: "Go runtime metrics", response: RuntimeMetrics{}},
+	"GET /ready":        {summary: "Readiness check", contentTypes: []string{"text/plain"}},
+	"GET /version":      {summary: "Build information", response: VersionResponse{}},
+	"GET /openapi.json": {summary: "This OpenAPI document", response: map[string]interface{}{}},
+}
+
+// docForRoute returns the documentation of a route, including the generated
+// per-kind object routes
+func docForRoute(method, path string) (routeDoc, bool) {
+	if doc, ok := routeDocs[method+" "+path]; ok {
+		return doc, true
+	}
+	if method != http.MethodGet {
+		return routeDoc{}, false
+	}
+	for _, kind := range resourceKinds {
+		switch path {
+		case kind.objectPath():
+			return routeDoc{
+				summary:      fmt.Sprintf("Get one %s from a snapshot", kind.kind),
+				query:        append([]queryParam{{"format", "string", "json (default), yaml or table"}}, snapshotParams...),
+				response:     ObjectDetail{},
+				contentTypes: []string{"application/yaml", "text/plain"},
+			}, true
+		case kind.objectPath() + "/history":
+			return routeDoc{
+				summary:  fmt.Sprintf("Change history of one %s", kind.kind),
+				query:    append([]queryParam{clusterParam}, rangeParams...),
+				response: ObjectHistory{},
+			}, true
+		}
+	}
+	return routeDoc{}, false
+}
+
+// OpenAPI document types, limited to what the generator emits
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers"`
+	Security   []map[string][]string                   `json:"security"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema        `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+// openAPIDocument builds the document from the registered routes. It also
+// returns the routes that have no routeDoc, as "METHOD path".
+func (s *Server) openAPIDocument() (*openAPIDocument, []string) {
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: "Cluster Info Collector API", Version: s.version},
+		Servers: []openAPIServer{{URL: s.prefix()}},
+		// Authentication is optional, so anonymous access is listed too
+		Security: []map[string][]string{{"bearerAuth": {}}, {}},
+		Paths:    make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			SecuritySchemes: map[string]openAPISecurityScheme{"bearerAuth": {Type: "http", Scheme: "bearer"}},
+		},
+	}
+	schemas := newSchemaGenerator()
+	var undocumented []string
+
+	s.router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Path prefixes and other routes without handlers
+			return nil
+		}
+		routePath := strings.TrimPrefix(template, s.prefix())
+		if routePath == "" {
+			routePath = "/"
+		}
+
+		for _, method := range methods {
+			if method == http.MethodOptions {
+				continue
+			}
+			routeDoc, ok := docForRoute(method, routePath)
+			if !ok {
+				undocumented = append(undocumented, method+" "+routePath)
+				continue
+			}
+			if doc.Paths[routePath] == nil {
+				doc.Paths[routePath] = make(map[string]*openAPIOperation)
+			}
+			doc.Paths[routePath][strings.ToLower(method)] = routeDoc.operation(routePath, schemas)
+		}
+		return nil
+	})
+
+	doc.Components.Schemas = schemas.schemas
+	sort.Strings(undocumented)
+	return doc, undocumented
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]+)?\}`)
+
+func (d routeDoc) operation(routePath string, schemas *schemaGenerator) *openAPIOperation {
+	op := &openAPIOperation{Summary: d.summary, Responses: make(map[string]openAPIResponse)}
+
+	for _, match := range pathParamPattern.FindAllStringSubmatch(routePath, -1) {
+		op.Parameters = append(op.Parameters, openAPIParameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &openAPISchema{Type: "string"},
+		})
+	}
+	for _, param := range d.query {
+		schema := &openAPISchema{Type: param.kind}
+		if kind, format, ok := strings.Cut(param.kind, ":"); ok {
+			schema = &openAPISchema{Type: kind, Format: format}
+		}
+		op.Parameters = append(op.Parameters, openAPIParameter{
+			Name:        param.name,
+			In:          "query",
+			Description: param.description,
+			Schema:      schema,
+		})
+	}
+
+	if d.request != nil {
+		op.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content: map[string]openAPIMediaType{
+				"application/json": {Schema: schemas.schemaFor(reflect.TypeOf(d.request))},
+			},
+		}
+	}
+
+	status := d.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := openAPIResponse{Description: http.StatusText(status)}
+	if d.response != nil || len(d.contentTypes) > 0 {
+		response.Content = make(map[string]openAPIMediaType)
+	}
+	if d.response != nil {
+		response.Content["application/json"] = openAPIMediaType{Schema: schemas.schemaFor(reflect.TypeOf(d.response))}
+	}
+	for _, contentType := range d.contentTypes {
+		response.Content[contentType] = openAPIMediaType{Schema: &openAPISchema{Type: "string"}}
+	}
+	op.Responses[fmt.Sprint(status)] = response
+	op.Responses["default"] = openAPIResponse{
+		Description: "Error",
+		Content: map[string]openAPIMediaType{
+			"application/json": {Schema: schemas.schemaFor(reflect.TypeOf(ErrorResponse{}))},
+		},
+	}
+	return op
+}
+
+// schemaGenerator derives JSON schemas from Go types the way encoding/json
+// would encode them. Named structs become shared component schemas.
+type schemaGenerator struct {
+	schemas map[string]*openAPISchema
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		schemas: make(map[string]*openAPISchema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	metaTimeType    = reflect.TypeOf(metav1.Time{})
+	rawMessageType  = reflect.TypeOf(json.RawMessage{})
+	jsonMarshaler   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	genericArgument = regexp.MustCompile(`\[(.*)\]`)
+)
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) *openAPISchema {
+	switch t {
+	case timeType, metaTimeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &openAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := g.schemaFor(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler) {
+			// Custom encoding; the shape is not known
+			return &openAPISchema{}
+		}
+		return g.structRef(t)
+	default:
+		// Interfaces hold any JSON value
+		return &openAPISchema{}
+	}
+}
+
+// structRef registers a named struct as a component and refers to it
+func (g *schemaGenerator) structRef(t reflect.Type) *openAPISchema {
+	if t.Name() == "" {
+		return g.structSchema(t)
+	}
+	name, ok := g.names[t]
+	if !ok {
+		name = g.componentName(t)
+		g.names[t] = name
+		// Register before recursing so self-references resolve
+		g.schemas[name] = &openAPISchema{}
+		*g.schemas[name] = *g.structSchema(t)
+	}
+	return &openAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+// componentName names a component after its type. Generic instances are
+// named after their arguments, e.g. ResourceList[DeploymentRow] becomes
+// DeploymentRowResourceList; clashing names are prefixed with the package.
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	name := t.Name()
+	if match := genericArgument.FindStringSubmatch(name); match != nil {
+		argument := match[1][strings.LastIndex(match[1], ".")+1:]
+		name = argument + name[:strings.Index(name, "[")]
+	}
+	if _, taken := g.schemas[name]; taken {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	return name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *openAPISchema {
+	schema := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	g.addFields(schema, t)
+	return schema
+}
+
+// addFields adds the JSON-encoded fields of t, flattening embedded structs
+func (g *schemaGenerator) addFields(schema *openAPISchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = g.schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// serveOpenAPI serves the generated OpenAPI document
+func (s *Server) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc, _ := s.openAPIDocument()
+	s.writeJSON(w, doc)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	s := New(nil, logrus.New(), APIConfig{Prefix: "/api/v1"}, nil, "test", "abc123")
+
+	doc, undocumented := s.openAPIDocument()
+	if len(undocumented) > 0 {
+		t.Fatalf("routes without a routeDoc entry: %s", strings.Join(undocumented, ", "))
+	}
+
+	for key := range routeDocs {
+		method, path, _ := strings.Cut(key, " ")
+		if doc.Paths[path][strings.ToLower(method)] == nil {
+			t.Errorf("routeDoc %q does not match a registered route", key)
+		}
+	}
+	for _, kind := range resourceKinds {
+		if doc.Paths[kind.objectPath()+"/history"]["get"] == nil {
+			t.Errorf("history of %s is not documented", kind.kind)
+		}
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	s := New(nil, logrus.New(), APIConfig{Prefix: "/api/v1"}, nil, "test", "abc123")
+
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+
+	var doc struct {
+		OpenAPI    string
+		Servers    []struct{ URL string }
+		Paths      map[string]map[string]json.RawMessage
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]struct {
+					Type   string
+					Format string
+					Ref    string `json:"$ref"`
+					Items  *struct{ Type string }
+				}
+				Required []string
+			}
+		}
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") || len(doc.Servers) != 1 || doc.Servers[0].URL != "/api/v1" {
+		t.Errorf("unexpected header: %s %+v", doc.OpenAPI, doc.Servers)
+	}
+	if _, ok := doc.Paths["/snapshots/{id}"]["get"]; !ok {
+		t.Error("expected /snapshots/{id} to be documented")
+	}
+
+	pods := doc.Components.Schemas["PodRowResourceList"]
+	if pods.Properties["data"].Items == nil || pods.Properties["next_cursor"].Type != "string" {
+		t.Errorf("unexpected pod list schema: %+v", pods)
+	}
+	for _, required := range pods.Required {
+		if required == "next_cursor" {
+			t.Error("omitempty fields must not be required")
+		}
+	}
+	if pods.Properties["snapshot"].Ref != "#/components/schemas/SnapshotRef" {
+		t.Errorf("expected snapshot to reference SnapshotRef, got %+v", pods.Properties["snapshot"])
+	}
+
+	ingress := doc.Components.Schemas["IngressRow"]
+	if hosts := ingress.Properties["hosts"]; hosts.Type != "array" || hosts.Items.Type != "string" {
+		t.Errorf("expected hosts to be a string array, got %+v", hosts)
+	}
+	if created := ingress.Properties["created_time"]; created.Type != "string" || created.Format != "date-time" {
+		t.Errorf("expected created_time to be a date-time, got %+v", created)
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// listTable describes the filters and sort columns a resource table supports
+// on its list endpoint
+type listTable struct {
+	namespaced bool
+	// filters maps query parameters to columns
+	filters map[string]string
+	// sorts maps sort keys to SQL expressions; nullable columns are coalesced
+	// so cursor comparisons never meet NULL
+	sorts map[string]string
+}
+
+// commonSorts are the indexed columns every resource table has. The
+// created_time expression matches the idx_<table>_created_time index.
+var commonSorts = map[string]string{
+	"name":         "COALESCE(name, '')",
+	"created_time": "COALESCE(created_time, TIMESTAMP 'epoch')",
+}
+
+// listTables holds the list options for each resource table
+var listTables = map[string]listTable{
+	"deployments": {namespaced: true},
+	"pods": {
+		namespaced: true,
+		filters:    map[string]string{"phase": "phase", "node": "node_name"},
+		sorts:      map[string]string{"node_name": "COALESCE(node_name, '')", "deployment_name": "COALESCE(deployment_name, '')"},
+	},
+	"nodes":      {},
+	"services":   {namespaced: true, filters: map[string]string{"type": "type"}},
+	"ingresses":  {namespaced: true},
+	"configmaps": {namespaced: true},
+	"secrets":    {namespaced: true, filters: map[string]string{"type": "type"}},
+	"persistent_volumes": {
+		filters: map[string]string{"status": "status"},
+	},
+	"persistent_volume_claims": {namespaced: true, filters: map[string]string{"status": "status"}},
+}
+
+// sortExpression returns the SQL expression for a sort key, if the table supports it
+func (t listTable) sortExpression(key string) (string, bool) {
+	if expr, ok := commonSorts[key]; ok {
+		return expr, true
+	}
+	if key == "namespace" && t.namespaced {
+		return "COALESCE(namespace, '')", true
+	}
+	expr, ok := t.sorts[key]
+	return expr, ok
+}
+
+// listFilterParams are the filter query parameters understood by list endpoints
+var listFilterParams = []string{"phase", "node", "status", "type"}
+
+// parseSort splits a sort parameter such as "-created_time" into its key and direction
+func parseSort(value string) (key string, descending bool) {
+	if strings.HasPrefix(value, "-") {
+		return value[1:], true
+	}
+	return strings.TrimPrefix(value, "+"), false
+}
+
+// listCursor is the decoded form of the opaque cursor returned as
+// next_cursor. It pins the snapshot and sort order so every page comes from
+// the same data, and records the last row returned.
+type listCursor struct {
+	SnapshotID int    `json:"s"`
+	Sort       string `json:"o"`
+	Value      string `json:"v"`
+	ID         int64  `json:"i"`
+}
+
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (listCursor, error) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// labelRequirement is one term of a label selector
+type labelRequirement struct {
+	key      string
+	operator string // "=", "!=", "exists" or "!exists"
+	value    string
+}
+
+// parseLabelSelector parses an equality-based Kubernetes label selector such
+// as "app=web,tier!=db,canary,!legacy"
+func parseLabelSelector(selector string) ([]labelRequirement, error) {
+	var requirements []labelRequirement
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var req labelRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			parts := strings.SplitN(term, "!=", 2)
+			req = labelRequirement{key: parts[0], operator: "!=", value: parts[1]}
+		case strings.Contains(term, "=="):
+			parts := strings.SplitN(term, "==", 2)
+			req = labelRequirement{key: parts[0], operator: "=", value: parts[1]}
+		case strings.Contains(term, "="):
+			parts := strings.SplitN(term, "=", 2)
+			req = labelRequirement{key: parts[0], operator: "=", value: parts[1]}
+		case strings.HasPrefix(term, "!"):
+			req = labelRequirement{key: term[1:], operator: "!exists"}
+		default:
+			req = labelRequirement{key: term, operator: "exists"}
+		}
+
+		req.key = strings.TrimSpace(req.key)
+		req.value = strings.TrimSpace(req.value)
+		if req.key == "" || strings.ContainsAny(req.key, "!=") {
+			return nil, fmt.Errorf("invalid label selector term %q", term)
+		}
+		requirements = append(requirements, req)
+	}
+	return requirements, nil
+}
+
+// sqlCondition renders the requirement against the JSONB labels of a
+// resource row. Positional parameters start at next; the returned args must
+// be appended in order.
+func (req labelRequirement) sqlCondition(next int) (string, []interface{}) {
+	switch req.operator {
+	case "=":
+		return fmt.Sprintf("data->'labels'->>$%d = $%d", next, next+1), []interface{}{req.key, req.value}
+	case "!=":
+		// Like Kubernetes, objects without the label match key!=value
+		return fmt.Sprintf("data->'labels'->>$%d IS DISTINCT FROM $%d", next, next+1), []interface{}{req.key, req.value}
+	case "!exists":
+		return fmt.Sprintf("NOT COALESCE(data->'labels' ? $%d, false)", next), []interface{}{req.key}
+	default:
+		return fmt.Sprintf("COALESCE(data->'labels' ? $%d, false)", next), []interface{}{req.key}
+	}
+}
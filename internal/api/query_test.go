@@ -0,0 +1,66 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		expected []labelRequirement
+		wantErr  bool
+	}{
+		{
+			selector: "app=web,tier!=db",
+			expected: []labelRequirement{
+				{key: "app", operator: "=", value: "web"},
+				{key: "tier", operator: "!=", value: "db"},
+			},
+		},
+		{
+			selector: "app==web, canary ,!legacy",
+			expected: []labelRequirement{
+				{key: "app", operator: "=", value: "web"},
+				{key: "canary", operator: "exists"},
+				{key: "legacy", operator: "!exists"},
+			},
+		},
+		{selector: "=web", wantErr: true},
+		{selector: "!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			requirements, err := parseLabelSelector(tt.selector)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", requirements)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(requirements, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, requirements)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := listCursor{SnapshotID: 42, Sort: "-created_time", Value: "2024-01-01 09:00:00", ID: 1234}
+
+	decoded, err := decodeCursor(encodeCursor(cursor))
+	if err != nil {
+		t.Fatalf("decodeCursor() error = %v", err)
+	}
+	if decoded != cursor {
+		t.Errorf("expected %+v, got %+v", cursor, decoded)
+	}
+
+	if _, err := decodeCursor("not a cursor"); err == nil {
+		t.Error("expected error for malformed cursor")
+	}
+}
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gorilla/mux"
+	"sigs.k8s.io/yaml"
+
+	"k8s-cluster-info-collector/internal/models"
+)
+
+// resourceKind ties a URL path segment to its kind and resource table
+type resourceKind struct {
+	path       string
+	kind       string
+	table      string
+	namespaced bool
+}
+
+// resourceKinds lists every kind with per-object endpoints
+var resourceKinds = []resourceKind{
+	{"deployments", models.KindDeployment, "deployments", true},
+	{"pods", models.KindPod, "pods", true},
+	{"nodes", models.KindNode, "nodes", false},
+	{"services", models.KindService, "services", true},
+	{"ingresses", models.KindIngress, "ingresses", true},
+	{"configmaps", models.KindConfigMap, "configmaps", true},
+	{"secrets", models.KindSecret, "secrets", true},
+	{"persistent-volumes", models.KindPersistentVolume, "persistent_volumes", false},
+	{"persistent-volume-claims", models.KindPersistentVolumeClaim, "persistent_volume_claims", true},
+}
+
+// objectPath returns the route of a single object of this kind
+func (k resourceKind) objectPath() string {
+	if k.namespaced {
+		return "/" + k.path + "/{namespace}/{name}"
+	}
+	return "/" + k.path + "/{name}"
+}
+
+// registerObjectRoutes adds the detail and /history endpoints for every kind
+func (s *Server) registerObjectRoutes(router *mux.Router) {
+	for _, kind := range resourceKinds {
+		kind := kind
+		router.HandleFunc(kind.objectPath()+"/history", s.withETag(func(w http.ResponseWriter, r *http.Request) {
+			s.getObjectHistory(w, r, kind)
+		})).Methods("GET")
+		router.HandleFunc(kind.objectPath(), s.withETag(func(w http.ResponseWriter, r *http.Request) {
+			s.getObjectDetail(w, r, kind)
+		})).Methods("GET")
+	}
+}
+
+// objectPaths returns the per-object routes for the endpoint listing
+func objectPaths() []string {
+	var paths []string
+	for _, kind := range resourceKinds {
+		paths = append(paths, kind.objectPath(), kind.objectPath()+"/history")
+	}
+	return paths
+}
+
+// ObjectDetail is the JSON and YAML representation of a single object
+type ObjectDetail struct {
+	Kind     string             `json:"kind"`
+	Snapshot models.SnapshotRef `json:"snapshot"`
+	Object   json.RawMessage    `json:"object"`
+}
+
+// getObjectDetail returns one object in full from a snapshot selected by
+// snapshot_id or at (default latest). The representation is chosen by the
+// format query parameter (json, yaml or table) or else the Accept header.
+func (s *Server) getObjectDetail(w http.ResponseWriter, r *http.Request, kind resourceKind) {
+	vars := mux.Vars(r)
+
+	access, ok := s.authorizeKind(w, r, kind)
+	if !ok {
+		return
+	}
+	if kind.namespaced && !s.authorizeNamespace(w, access, vars["namespace"]) {
+		return
+	}
+
+	format, ok := negotiateFormat(r)
+	if !ok {
+		s.writeError(w, "Invalid format, expected json, yaml or table", http.StatusBadRequest)
+		return
+	}
+
+	snapshot, ok := s.snapshotFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	query := fmt.Sprintf("SELECT data FROM %s WHERE snapshot_id = $1 AND name = $2", kind.table)
+	args := []interface{}{snapshot.ID, vars["name"]}
+	if kind.namespaced {
+		query += " AND namespace = $3"
+		args = append(args, vars["namespace"])
+	}
+
+	var data []byte
+	err := s.db.QueryRow(query+" LIMIT 1", args...).Scan(&data)
+	if err == sql.ErrNoRows {
+		s.writeError(w, fmt.Sprintf("%s %s not found in snapshot %d", kind.kind, objectName(vars), snapshot.ID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to query object")
+		s.writeError(w, "Failed to fetch object", http.StatusInternalServerError)
+		return
+	}
+
+	detail := ObjectDetail{Kind: kind.kind, Snapshot: snapshot, Object: data}
+	switch format {
+	case "yaml":
+		jsonData, err := json.Marshal(detail)
+		if err == nil {
+			var yamlData []byte
+			if yamlData, err = yaml.JSONToYAML(jsonData); err == nil {
+				w.Header().Set("Content-Type", "application/yaml")
+				w.Write(yamlData)
+				return
+			}
+		}
+		s.logger.WithError(err).Error("Failed to encode object as YAML")
+		s.writeError(w, "Failed to encode object", http.StatusInternalServerError)
+	case "table":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := writeObjectTable(w, kind.kind, data, time.Now()); err != nil {
+			s.logger.WithError(err).Error("Failed to render object table")
+		}
+	default:
+		s.writeJSON(w, detail)
+	}
+}
+
+// negotiateFormat picks json, yaml or table from the format parameter or the
+// Accept header. It returns false for an unknown format parameter.
+func negotiateFormat(r *http.Request) (string, bool) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "json", "yaml", "table":
+		return format, true
+	case "":
+	default:
+		return "", false
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "yaml"):
+		return "yaml", true
+	case strings.Contains(accept, "text/plain"):
+		return "table", true
+	default:
+		return "json", true
+	}
+}
+
+func objectName(vars map[string]string) string {
+	if vars["namespace"] == "" {
+		return vars["name"]
+	}
+	return vars["namespace"] + "/" + vars["name"]
+}
+
+// writeObjectTable renders a kubectl-style table for one stored object
+func writeObjectTable(w io.Writer, kind string, data []byte, now time.Time) error {
+	var headers, row []string
+	var err error
+
+	switch kind {
+	case models.KindDeployment:
+		var d models.DeploymentInfo
+		err = json.Unmarshal(data, &d)
+		headers = []string{"NAMESPACE", "NAME", "READY", "UP-TO-DATE", "AGE", "IMAGES"}
+		row = []string{d.Namespace, d.Name, fmt.Sprintf("%d/%d", d.ReadyReplicas, d.Replicas),
+			strconv.Itoa(int(d.UpdatedReplicas)), formatAge(now.Sub(d.CreatedTime)), strings.Join(d.Images, ",")}
+	case models.KindPod:
+		var p models.PodInfo
+		err = json.Unmarshal(data, &p)
+		ready := 0
+		for _, status := range p.ContainerStatuses {
+			if status.Ready {
+				ready++
+			}
+		}
+		headers = []string{"NAMESPACE", "NAME", "READY", "STATUS", "RESTARTS", "AGE", "IP", "NODE"}
+		row = []string{p.Namespace, p.Name, fmt.Sprintf("%d/%d", ready, len(p.ContainerStatuses)), p.Phase,
+			strconv.Itoa(int(p.RestartCount)), formatAge(now.Sub(p.CreatedTime)), orNone(p.PodIP), orNone(p.NodeName)}
+	case models.KindNode:
+		var n models.NodeInfo
+		err = json.Unmarshal(data, &n)
+		status := "NotReady"
+		if n.Ready {
+			status = "Ready"
+		}
+		headers = []string{"NAME", "STATUS", "AGE", "VERSION", "OS-IMAGE", "KERNEL-VERSION"}
+		row = []string{n.Name, status, formatAge(now.Sub(n.CreatedTime)), n.KubeletVersion, n.OSImage, n.KernelVersion}
+	case models.KindService:
+		var svc models.ServiceInfo
+		err = json.Unmarshal(data, &svc)
+		var ports []string
+		for _, port := range svc.Ports {
+			if port.NodePort != 0 {
+				ports = append(ports, fmt.Sprintf("%d:%d/%s", port.Port, port.NodePort, port.Protocol))
+			} else {
+				ports = append(ports, fmt.Sprintf("%d/%s", port.Port, port.Protocol))
+			}
+		}
+		headers = []string{"NAMESPACE", "NAME", "TYPE", "CLUSTER-IP", "EXTERNAL-IP", "PORT(S)", "AGE"}
+		row = []string{svc.Namespace, svc.Name, svc.Type, orNone(svc.ClusterIP), orNone(strings.Join(svc.ExternalIPs, ",")),
+			orNone(strings.Join(ports, ",")), formatAge(now.Sub(svc.CreatedTime))}
+	case models.KindIngress:
+		var ing models.IngressInfo
+		err = json.Unmarshal(data, &ing)
+		headers = []string{"NAMESPACE", "NAME", "HOSTS", "AGE"}
+		row = []string{ing.Namespace, ing.Name, orNone(strings.Join(ing.Hosts, ",")), formatAge(now.Sub(ing.CreatedTime))}
+	case models.KindConfigMap:
+		var cm models.ConfigMapInfo
+		err = json.Unmarshal(data, &cm)
+		headers = []string{"NAMESPACE", "NAME", "DATA", "AGE"}
+		row = []string{cm.Namespace, cm.Name, strconv.Itoa(len(cm.Data) + len(cm.BinaryData)), formatAge(now.Sub(cm.CreatedTime))}
+	case models.KindSecret:
+		var secret models.SecretInfo
+		err = json.Unmarshal(data, &secret)
+		headers = []string{"NAMESPACE", "NAME", "TYPE", "DATA", "AGE"}
+		row = []string{secret.Namespace, secret.Name, secret.Type, strconv.Itoa(len(secret.DataKeys)), formatAge(now.Sub(secret.CreatedTime))}
+	case models.KindPersistentVolume:
+		var pv models.PersistentVolumeInfo
+		err = json.Unmarshal(data, &pv)
+		headers = []string{"NAME", "CAPACITY", "ACCESS MODES", "RECLAIM POLICY", "STATUS", "CLAIM", "STORAGECLASS", "AGE"}
+		row = []string{pv.Name, pv.Capacity, strings.Join(pv.AccessModes, ","), pv.ReclaimPolicy, pv.Status,
+			pv.ClaimRef, pv.StorageClass, formatAge(now.Sub(pv.CreatedTime))}
+	case models.KindPersistentVolumeClaim:
+		var pvc models.PersistentVolumeClaimInfo
+		err = json.Unmarshal(data, &pvc)
+		headers = []string{"NAMESPACE", "NAME", "STATUS", "VOLUME", "CAPACITY", "ACCESS MODES", "STORAGECLASS", "AGE"}
+		row = []string{pvc.Namespace, pvc.Name, pvc.Status, pvc.VolumeName, pvc.RequestedSize,
+			strings.Join(pvc.AccessModes, ","), pvc.StorageClass, formatAge(now.Sub(pvc.CreatedTime))}
+	default:
+		return fmt.Errorf("no table format for kind %s", kind)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", kind, err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	fmt.Fprintln(tw, strings.Join(row, "\t"))
+	return tw.Flush()
+}
+
+// formatAge renders a duration the way kubectl does in its AGE column
+func formatAge(d time.Duration) string {
+	switch {
+	case d < 0:
+		return "<unknown>"
+	case d < 2*time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < 2*time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
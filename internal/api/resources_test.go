@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-cluster-info-collector/internal/models"
+)
+
+func TestWriteObjectTable(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	data, err := json.Marshal(models.PodInfo{
+		Name:         "web-1",
+		Namespace:    "default",
+		Phase:        "Running",
+		NodeName:     "worker-1",
+		RestartCount: 2,
+		CreatedTime:  now.Add(-3 * time.Hour),
+		ContainerStatuses: []models.ContainerStatus{
+			{Name: "app", Ready: true},
+			{Name: "sidecar", Ready: false},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := writeObjectTable(&b, models.KindPod, data, now); err != nil {
+		t.Fatalf("writeObjectTable() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and one row, got %q", b.String())
+	}
+	if fields := strings.Fields(lines[0]); fields[0] != "NAMESPACE" || fields[len(fields)-1] != "NODE" {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+	expected := []string{"default", "web-1", "1/2", "Running", "2", "3h", "<none>", "worker-1"}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected row %v, got %v", expected, fields)
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := map[time.Duration]string{
+		45 * time.Second: "45s",
+		30 * time.Minute: "30m",
+		5 * time.Hour:    "5h",
+		72 * time.Hour:   "3d",
+	}
+	for d, expected := range tests {
+		if got := formatAge(d); got != expected {
+			t.Errorf("formatAge(%v) = %q, expected %q", d, got, expected)
+		}
+	}
+}
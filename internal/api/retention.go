@@ -0,0 +1,107 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"k8s-cluster-info-collector/internal/retention"
+)
+
+// triggerRetentionCleanup runs a retention cleanup immediately
+func (s *Server) triggerRetentionCleanup(w http.ResponseWriter, r *http.Request) {
+	if s.retention == nil {
+		s.writeError(w, "Data retention not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	// A large cleanup can outlast the server's write timeout, so lift it for
+	// this response
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.WithError(err).Debug("Could not clear write deadline for retention cleanup")
+	}
+
+	result, err := s.retention.RunNow()
+	// Deleted snapshots change the data version right away
+	s.snapshotCount.reset()
+	if errors.Is(err, retention.ErrRunInProgress) {
+		s.writeError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.WithError(err).Error("Triggered retention cleanup failed")
+		s.writeError(w, "Retention cleanup failed", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, result)
+}
+
+// getRetentionDryRun returns the snapshots the next cleanup would delete
+// without deleting anything
+func (s *Server) getRetentionDryRun(w http.ResponseWriter, r *http.Request) {
+	if s.retention == nil {
+		s.writeError(w, "Data retention not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	plan, err := s.retention.Plan()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to plan retention cleanup")
+		s.writeError(w, "Failed to plan retention cleanup", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, RetentionDryRun{
+		Plan:  plan,
+		Total: len(plan.SnapshotIDs()),
+	})
+}
+
+// getRetentionLastRun returns the result of the most recent cleanup run
+func (s *Server) getRetentionLastRun(w http.ResponseWriter, r *http.Request) {
+	if s.retention == nil {
+		s.writeError(w, "Data retention not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	lastRun := s.retention.LastRun()
+	if lastRun == nil {
+		s.writeError(w, "No retention cleanup has run yet", http.StatusNotFound)
+		return
+	}
+
+	s.writeJSON(w, lastRun)
+}
+
+// getRetentionStats returns snapshot span, database size, per-table row
+// counts and on-disk sizes, and the last cleanup run when retention is enabled
+func (s *Server) getRetentionStats(w http.ResponseWriter, r *http.Request) {
+	tableStats, err := retention.CollectStats(s.db)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to collect retention stats")
+		s.writeError(w, "Failed to fetch retention stats", http.StatusInternalServerError)
+		return
+	}
+
+	stats := RetentionStats{
+		TotalSnapshots: tableStats.TotalSnapshots,
+		OldestSnapshot: tableStats.OldestSnapshot,
+		NewestSnapshot: tableStats.NewestSnapshot,
+		RetentionSpan:  tableStats.NewestSnapshot.Sub(tableStats.OldestSnapshot).String(),
+		Tables:         tableStats.Tables,
+		GeneratedAt:    time.Now().UTC(),
+	}
+
+	// Database size
+	var dbSize string
+	if err := s.db.QueryRow("SELECT pg_size_pretty(pg_database_size(current_database()))").Scan(&dbSize); err == nil {
+		stats.DatabaseSize = dbSize
+	}
+
+	if s.retention != nil {
+		stats.LastRun = s.retention.LastRun()
+	}
+
+	s.writeJSON(w, stats)
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s-cluster-info-collector/internal/models"
+)
+
+// errSnapshotNotFound is returned by loadSnapshot for unknown snapshot IDs
+var errSnapshotNotFound = errors.New("snapshot not found")
+
+// requestCluster returns the cluster named by the cluster query parameter.
+// Empty means any cluster; lookups that must not mix clusters go through
+// resolveClusterFromRequest instead. Queries restrict to it with a condition
+// that keeps the parameter position fixed:
+//
+//	($n = '' OR cluster = $n)
+func requestCluster(r *http.Request) string {
+	return r.URL.Query().Get("cluster")
+}
+
+// inCluster describes where a snapshot was looked for, for error messages
+func inCluster(cluster string) string {
+	if cluster == "" {
+		return ""
+	}
+	return fmt.Sprintf(" in cluster %q", cluster)
+}
+
+// errClusterRequired is returned by resolveCluster when the cluster parameter
+// is missing but several clusters are stored
+var errClusterRequired = errors.New("the cluster parameter is required when several clusters are stored")
+
+// resolveCluster returns the cluster a lookup that must not mix clusters,
+// such as the latest snapshot or an object's history, is limited to. Without
+// the cluster parameter such a lookup is only allowed while a single cluster
+// is stored; otherwise it would pick whichever cluster reported last.
+// Clusters whose snapshots have all been deleted by retention do not count.
+func (s *Server) resolveCluster(ctx context.Context, cluster string) (string, error) {
+	if cluster != "" {
+		return cluster, nil
+	}
+	var count int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM clusters c
+		WHERE EXISTS (SELECT 1 FROM cluster_snapshots s WHERE s.cluster = c.name)`).Scan(&count)
+	if err != nil {
+		return "", err
+	}
+	if count > 1 {
+		return "", errClusterRequired
+	}
+	return "", nil
+}
+
+// resolveClusterFromRequest is resolveCluster for the request's cluster
+// parameter. It writes an error response and returns false when the
+// parameter is required but missing.
+func (s *Server) resolveClusterFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	cluster, err := s.resolveCluster(r.Context(), requestCluster(r))
+	if errors.Is(err, errClusterRequired) {
+		s.writeError(w, "The cluster parameter is required when several clusters are stored", http.StatusBadRequest)
+		return "", false
+	}
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to count clusters")
+		s.writeError(w, "Failed to fetch clusters", http.StatusInternalServerError)
+		return "", false
+	}
+	return cluster, true
+}
+
+// snapshotFromRequest resolves the snapshot a request should read from:
+// snapshot_id selects one by ID, at=<RFC3339> selects the nearest snapshot at
+// or before that time, and without either the latest snapshot is used. All
+// three are limited to the cluster parameter, which at and latest require
+// when several clusters are stored. It writes an error response and returns
+// false when no snapshot can be resolved.
+func (s *Server) snapshotFromRequest(w http.ResponseWriter, r *http.Request) (models.SnapshotRef, bool) {
+	query := r.URL.Query()
+	idStr, atStr := query.Get("snapshot_id"), query.Get("at")
+	cluster := requestCluster(r)
+
+	var ref models.SnapshotRef
+	var err error
+	if idStr == "" {
+		var ok bool
+		if cluster, ok = s.resolveClusterFromRequest(w, r); !ok {
+			return ref, false
+		}
+	}
+	switch {
+	case idStr != "" && atStr != "":
+		s.writeError(w, "Use either snapshot_id or at, not both", http.StatusBadRequest)
+		return ref, false
+
+	case idStr != "":
+		id, convErr := strconv.Atoi(idStr)
+		if convErr != nil {
+			s.writeError(w, "Invalid snapshot ID", http.StatusBadRequest)
+			return ref, false
+		}
+		err = s.db.QueryRow(`
+			SELECT id, timestamp, cluster FROM cluster_snapshots
+			WHERE id = $1 AND ($2 = '' OR cluster = $2)`, id, cluster).
+			Scan(&ref.ID, &ref.Timestamp, &ref.Cluster)
+		if err == sql.ErrNoRows {
+			s.writeError(w, fmt.Sprintf("Snapshot %d not found%s", id, inCluster(cluster)), http.StatusNotFound)
+			return ref, false
+		}
+
+	case atStr != "":
+		at, parseErr := time.Parse(time.RFC3339, atStr)
+		if parseErr != nil {
+			s.writeError(w, "Invalid at timestamp, expected RFC3339", http.StatusBadRequest)
+			return ref, false
+		}
+		err = s.db.QueryRow(`
+			SELECT id, timestamp, cluster FROM cluster_snapshots
+			WHERE timestamp <= $1 AND ($2 = '' OR cluster = $2)
+			ORDER BY timestamp DESC LIMIT 1`, at, cluster).Scan(&ref.ID, &ref.Timestamp, &ref.Cluster)
+		if err == sql.ErrNoRows {
+			s.writeError(w, fmt.Sprintf("No snapshot at or before %s%s", atStr, inCluster(cluster)), http.StatusNotFound)
+			return ref, false
+		}
+
+	default:
+		err = s.db.QueryRow(`
+			SELECT id, timestamp, cluster FROM cluster_snapshots
+			WHERE ($1 = '' OR cluster = $1)
+			ORDER BY timestamp DESC LIMIT 1`, cluster).Scan(&ref.ID, &ref.Timestamp, &ref.Cluster)
+		if err == sql.ErrNoRows {
+			s.writeError(w, "No snapshots available"+inCluster(cluster), http.StatusNotFound)
+			return ref, false
+		}
+	}
+
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to query snapshot")
+		s.writeError(w, "Failed to fetch snapshot", http.StatusInternalServerError)
+		return ref, false
+	}
+	return ref, true
+}
+
+// loadSnapshotForRequest loads a snapshot by ID (or "latest") from the
+// request's cluster, writing an error response and returning false when it
+// cannot be loaded
+func (s *Server) loadSnapshotForRequest(w http.ResponseWriter, r *http.Request, idStr string) (models.SnapshotRef, models.ClusterInfo, bool) {
+	cluster := requestCluster(r)
+	var id int
+	if idStr == "latest" {
+		var ok bool
+		if cluster, ok = s.resolveClusterFromRequest(w, r); !ok {
+			return models.SnapshotRef{}, models.ClusterInfo{}, false
+		}
+		id = s.getLatestSnapshotID(cluster)
+		if id == 0 {
+			s.writeError(w, "No snapshots available"+inCluster(cluster), http.StatusNotFound)
+			return models.SnapshotRef{}, models.ClusterInfo{}, false
+		}
+	} else {
+		parsed, err := strconv.Atoi(idStr)
+		if err != nil {
+			s.writeError(w, "Invalid snapshot ID", http.StatusBadRequest)
+			return models.SnapshotRef{}, models.ClusterInfo{}, false
+		}
+		id = parsed
+	}
+
+	snapshot, info, err := s.loadSnapshot(id)
+	if err == nil && cluster != "" && snapshot.Cluster != cluster {
+		err = errSnapshotNotFound
+	}
+	if err != nil {
+		if errors.Is(err, errSnapshotNotFound) {
+			s.writeError(w, fmt.Sprintf("Snapshot %d not found%s", id, inCluster(cluster)), http.StatusNotFound)
+			return models.SnapshotRef{}, models.ClusterInfo{}, false
+		}
+		s.logger.WithError(err).WithField("snapshot_id", id).Error("Failed to load snapshot")
+		s.writeError(w, "Failed to fetch snapshot", http.StatusInternalServerError)
+		return models.SnapshotRef{}, models.ClusterInfo{}, false
+	}
+	return snapshot, info, true
+}
+
+// loadSnapshot reads and decodes the stored ClusterInfo of a snapshot
+func (s *Server) loadSnapshot(id int) (models.SnapshotRef, models.ClusterInfo, error) {
+	snapshot := models.SnapshotRef{ID: id}
+	var info models.ClusterInfo
+	var data []byte
+
+	err := s.db.QueryRow("SELECT timestamp, cluster, data FROM cluster_snapshots WHERE id = $1", id).
+		Scan(&snapshot.Timestamp, &snapshot.Cluster, &data)
+	if err == sql.ErrNoRows {
+		return snapshot, info, errSnapshotNotFound
+	}
+	if err != nil {
+		return snapshot, info, err
+	}
+
+	if err := json.Unmarshal(data, &info); err != nil {
+		return snapshot, info, fmt.Errorf("failed to parse snapshot data: %w", err)
+	}
+	return snapshot, info, nil
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+
+	"k8s-cluster-info-collector/internal/archive"
+	"k8s-cluster-info-collector/internal/kafka"
+	"k8s-cluster-info-collector/internal/models"
+	"k8s-cluster-info-collector/internal/retention"
+)
+
+// Response bodies of the REST API. The OpenAPI document is generated from
+// these types, so every JSON handler writes one of them.
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// RootResponse describes the API and lists its endpoints
+type RootResponse struct {
+	Service   string   `json:"service"`
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit"`
+	Endpoints []string `json:"endpoints"`
+}
+
+// HealthResponse reports the health of the API server and its dependencies
+type HealthResponse struct {
+	Status      string            `json:"status"`
+	Timestamp   string            `json:"timestamp"`
+	Service     string            `json:"service"`
+	Description string            `json:"description"`
+	Checks      map[string]string `json:"checks"`
+}
+
+// RuntimeMetrics describes the Go runtime of the API server
+type RuntimeMetrics struct {
+	GoVersion    string        `json:"go_version"`
+	NumGoroutine int           `json:"num_goroutine"`
+	NumCPU       int           `json:"num_cpu"`
+	Memory       MemoryMetrics `json:"memory"`
+	OS           OSInfo        `json:"os"`
+	Timestamp    string        `json:"timestamp"`
+}
+
+// MemoryMetrics holds Go memory statistics in MiB
+type MemoryMetrics struct {
+	AllocMB      uint64 `json:"alloc_mb"`
+	TotalAllocMB uint64 `json:"total_alloc_mb"`
+	SysMB        uint64 `json:"sys_mb"`
+	HeapAllocMB  uint64 `json:"heap_alloc_mb"`
+	HeapSysMB    uint64 `json:"heap_sys_mb"`
+	HeapIdleMB   uint64 `json:"heap_idle_mb"`
+	HeapInuseMB  uint64 `json:"heap_inuse_mb"`
+	NumGC        uint32 `json:"num_gc"`
+}
+
+// OSInfo identifies the platform the server runs on
+type OSInfo struct {
+	GOOS   string `json:"goos"`
+	GOARCH string `json:"goarch"`
+}
+
+// VersionResponse holds build information
+type VersionResponse struct {
+	Version    string `json:"version"`
+	CommitHash string `json:"commit_hash"`
+	GoVersion  string `json:"go_version"`
+	Service    string `json:"service"`
+}
+
+// SnapshotSummary is a snapshot with its object counts per kind
+type SnapshotSummary struct {
+	ID                     int       `json:"id"`
+	Timestamp              time.Time `json:"timestamp"`
+	Cluster                string    `json:"cluster"`
+	Deployments            int       `json:"deployments"`
+	Pods                   int       `json:"pods"`
+	Nodes                  int       `json:"nodes"`
+	Services               int       `json:"services"`
+	Ingresses              int       `json:"ingresses"`
+	ConfigMaps             int       `json:"configmaps"`
+	Secrets                int       `json:"secrets"`
+	PersistentVolumes      int       `json:"persistent_volumes"`
+	PersistentVolumeClaims int       `json:"persistent_volume_claims"`
+}
+
+// ClusterSummary is a cluster that has stored snapshots. LastSnapshotID and
+// Snapshots count the snapshots still in the database.
+type ClusterSummary struct {
+	Name           string    `json:"name"`
+	UID            string    `json:"uid,omitempty"`
+	FirstSeen      time.Time `json:"first_seen"`
+	LastSeen       time.Time `json:"last_seen"`
+	LastSnapshotID int       `json:"last_snapshot_id,omitempty"`
+	Snapshots      int       `json:"snapshots"`
+}
+
+// ClusterList is the body of GET /clusters
+type ClusterList struct {
+	Clusters []ClusterSummary `json:"clusters"`
+	Count    int              `json:"count"`
+}
+
+// SnapshotList is the body of GET /snapshots
+type SnapshotList struct {
+	Snapshots []SnapshotSummary `json:"snapshots"`
+	Count     int               `json:"count"`
+}
+
+// SnapshotResponse is a full snapshot
+type SnapshotResponse struct {
+	ID          int                `json:"id"`
+	Timestamp   time.Time          `json:"timestamp"`
+	ClusterInfo models.ClusterInfo `json:"cluster_info"`
+}
+
+// ResourceList is one page of a resource list. NextCursor is set when there
+// are more rows.
+type ResourceList[T any] struct {
+	Data       []T                `json:"data"`
+	Count      int                `json:"count"`
+	Total      int                `json:"total"`
+	Snapshot   models.SnapshotRef `json:"snapshot"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// DeploymentRow is a row of GET /deployments
+type DeploymentRow struct {
+	Name          string    `json:"name"`
+	Namespace     string    `json:"namespace"`
+	Replicas      int32     `json:"replicas"`
+	ReadyReplicas int32     `json:"ready_replicas"`
+	CreatedTime   time.Time `json:"created_time"`
+}
+
+// PodRow is a row of GET /pods
+type PodRow struct {
+	Name         string    `json:"name"`
+	Namespace    string    `json:"namespace"`
+	Phase        string    `json:"phase"`
+	NodeName     string    `json:"node_name"`
+	RestartCount int32     `json:"restart_count"`
+	CreatedTime  time.Time `json:"created_time"`
+}
+
+// NodeRow is a row of GET /nodes
+type NodeRow struct {
+	Name           string    `json:"name"`
+	Ready          bool      `json:"ready"`
+	CPUCapacity    string    `json:"cpu_capacity"`
+	MemoryCapacity string    `json:"memory_capacity"`
+	CreatedTime    time.Time `json:"created_time"`
+}
+
+// ServiceRow is a row of GET /services
+type ServiceRow struct {
+	Name        string    `json:"name"`
+	Namespace   string    `json:"namespace"`
+	Type        string    `json:"type"`
+	ClusterIP   string    `json:"cluster_ip"`
+	CreatedTime time.Time `json:"created_time"`
+}
+
+// IngressRow is a row of GET /ingresses
+type IngressRow struct {
+	Name        string         `json:"name"`
+	Namespace   string         `json:"namespace"`
+	Hosts       pq.StringArray `json:"hosts"`
+	CreatedTime time.Time      `json:"created_time"`
+}
+
+// ConfigMapRow is a row of GET /configmaps
+type ConfigMapRow struct {
+	Name        string         `json:"name"`
+	Namespace   string         `json:"namespace"`
+	DataKeys    pq.StringArray `json:"data_keys"`
+	CreatedTime time.Time      `json:"created_time"`
+}
+
+// SecretRow is a row of GET /secrets
+type SecretRow struct {
+	Name        string         `json:"name"`
+	Namespace   string         `json:"namespace"`
+	Type        string         `json:"type"`
+	DataKeys    pq.StringArray `json:"data_keys"`
+	CreatedTime time.Time      `json:"created_time"`
+}
+
+// PersistentVolumeRow is a row of GET /persistent-volumes
+type PersistentVolumeRow struct {
+	Name         string         `json:"name"`
+	Capacity     string         `json:"capacity"`
+	AccessModes  pq.StringArray `json:"access_modes"`
+	Status       string         `json:"status"`
+	StorageClass string         `json:"storage_class"`
+	CreatedTime  time.Time      `json:"created_time"`
+}
+
+// PersistentVolumeClaimRow is a row of GET /persistent-volume-claims
+type PersistentVolumeClaimRow struct {
+	Name          string         `json:"name"`
+	Namespace     string         `json:"namespace"`
+	RequestedSize string         `json:"requested_size"`
+	AccessModes   pq.StringArray `json:"access_modes"`
+	Status        string         `json:"status"`
+	CreatedTime   time.Time      `json:"created_time"`
+}
+
+// ObjectList is the body of GET /objects
+type ObjectList struct {
+	Since   time.Time                `json:"since"`
+	Until   time.Time                `json:"until"`
+	Event   string                   `json:"event"`
+	Objects []models.ObjectLifecycle `json:"objects"`
+	Count   int                      `json:"count"`
+}
+
+// ObjectHistory is the body of GET /{kind}/.../history
+type ObjectHistory struct {
+	Namespace string        `json:"namespace"`
+	Name      string        `json:"name"`
+	History   []ChangePoint `json:"history"`
+	Count     int           `json:"count"`
+	Snapshots int           `json:"snapshots"`
+}
+
+// CapacityResponse is the body of GET /capacity
+type CapacityResponse struct {
+	SnapshotID int                `json:"snapshot_id"`
+	Snapshot   models.SnapshotRef `json:"snapshot"`
+	GroupBy    string             `json:"group_by"`
+	Groups     []CapacityGroup    `json:"groups"`
+	Count      int                `json:"count"`
+}
+
+// ArchiveList is the body of GET /archives
+type ArchiveList struct {
+	Archives []archive.Entry `json:"archives"`
+	Count    int             `json:"count"`
+}
+
+// ImportArchiveResponse is the body of POST /archives/import
+type ImportArchiveResponse struct {
+	Location string `json:"location"`
+	Imported int    `json:"imported"`
+}
+
+// DeadLetterList is the body of GET /kafka/dead-letters
+type DeadLetterList struct {
+	Topic       string             `json:"topic"`
+	DeadLetters []kafka.DeadLetter `json:"dead_letters"`
+	Count       int                `json:"count"`
+	Total       int                `json:"total"`
+}
+
+// DeadLetterReplay is the body of the dead-letter replay endpoints
+type DeadLetterReplay struct {
+	Replayed int `json:"replayed"`
+}
+
+// Stats is the body of GET /stats. LatestSnapshot counts objects per table
+// in the latest snapshot.
+type Stats struct {
+	TotalSnapshots int            `json:"total_snapshots"`
+	LatestSnapshot map[string]int `json:"latest_snapshot,omitempty"`
+}
+
+// RetentionStats is the body of GET /stats/retention
+type RetentionStats struct {
+	TotalSnapshots int                    `json:"total_snapshots"`
+	OldestSnapshot time.Time              `json:"oldest_snapshot"`
+	NewestSnapshot time.Time              `json:"newest_snapshot"`
+	RetentionSpan  string                 `json:"retention_span"`
+	Tables         []retention.TableStats `json:"tables"`
+	DatabaseSize   string                 `json:"database_size,omitempty"`
+	LastRun        *retention.RunResult   `json:"last_run,omitempty"`
+	GeneratedAt    time.Time              `json:"generated_at"`
+}
+
+// RetentionDryRun is the body of GET /retention/dry-run
+type RetentionDryRun struct {
+	Plan  retention.CleanupPlan `json:"plan"`
+	Total int                   `json:"total"`
+}
@@ -8,13 +8,17 @@ import (
 
 	"k8s-cluster-info-collector/internal/alerting"
 	"k8s-cluster-info-collector/internal/api"
+	"k8s-cluster-info-collector/internal/archive"
+	"k8s-cluster-info-collector/internal/auth"
 	"k8s-cluster-info-collector/internal/collector"
 	"k8s-cluster-info-collector/internal/config"
 	"k8s-cluster-info-collector/internal/database"
 	"k8s-cluster-info-collector/internal/kafka"
 	"k8s-cluster-info-collector/internal/kubernetes"
+	"k8s-cluster-info-collector/internal/lifecycle"
 	"k8s-cluster-info-collector/internal/logger"
 	"k8s-cluster-info-collector/internal/metrics"
+	"k8s-cluster-info-collector/internal/offline"
 	"k8s-cluster-info-collector/internal/retention"
 	"k8s-cluster-info-collector/internal/store"
 	"k8s-cluster-info-collector/internal/streaming"
@@ -26,15 +30,18 @@ type App struct {
 	logger        *logrus.Logger
 	db            *database.DB
 	k8sClient     *kubernetes.Client
-	collector     *collector.ClusterCollector
+	collectors    []*collector.ClusterCollector
+	scheduler     *collector.Scheduler
 	store         *store.Store
 	kafkaProducer *kafka.Producer
+	offline       *offline.Writer
 	// Note: kafkaConsumer removed - handled by separate consumer binary
 	metrics      *metrics.Metrics
 	retention    *retention.RetentionManager
 	alerting     *alerting.AlertManager
 	apiServer    *api.Server
 	streamingHub *streaming.Hub
+	lifecycle    *lifecycle.Manager
 }
 
 // New creates a new application instance
@@ -51,8 +58,11 @@ func New(version, commitHash string) (*App, error) {
 
 	// Initialize database only if Kafka is not enabled
 	// In Kafka mode, collector writes to Kafka only, consumer handles database
+	// In offline mode, collector writes to local files only
 	var db *database.DB
-	if !cfg.Kafka.Enabled {
+	if cfg.Offline.Enabled {
+		log.WithField("directory", cfg.Offline.Directory).Info("Skipping database initialization (offline mode - collector writes to files only)")
+	} else if !cfg.Kafka.Enabled {
 		var err error
 		db, err = database.New(&cfg.Database, log)
 		if err != nil {
@@ -63,22 +73,30 @@ func New(version, commitHash string) (*App, error) {
 		log.Info("Skipping database initialization (Kafka mode - collector writes to Kafka only)")
 	}
 
-	// Initialize Kubernetes client
-	k8sClient, err := kubernetes.NewClient(&cfg.Kube, log)
+	// Initialize Kubernetes clients, one per cluster to collect
+	k8sClients, err := kubernetes.NewClients(&cfg.Kube, log)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize Kubernetes client: %w", err)
 	}
+	// The first cluster also serves API authentication (TokenReview)
+	k8sClient := k8sClients[0]
 
-	// Test Kubernetes connection
+	// Test Kubernetes connection. With several clusters an unreachable one
+	// is only logged, so it cannot keep the others from being collected.
 	ctx := context.Background()
-	if err := k8sClient.TestConnection(ctx); err != nil {
-		return nil, fmt.Errorf("failed to connect to Kubernetes cluster: %w", err)
+	for _, client := range k8sClients {
+		if err := client.TestConnection(ctx); err != nil {
+			if len(k8sClients) == 1 {
+				return nil, fmt.Errorf("failed to connect to Kubernetes cluster: %w", err)
+			}
+			log.WithError(err).WithField("cluster", client.ClusterName()).Warn("Failed to connect to Kubernetes cluster, will retry on collection")
+		}
 	}
 
 	// Initialize Kafka producer if enabled
 	var kafkaProducer *kafka.Producer
-	if cfg.Kafka.Enabled {
-		kafkaProducer, err = kafka.NewProducer(&cfg.Kafka, log)
+	if cfg.Kafka.Enabled && !cfg.Offline.Enabled {
+		kafkaProducer, err = kafka.NewProducer(&cfg.Kafka, log, version)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize Kafka producer: %w", err)
 		}
@@ -87,41 +105,79 @@ func New(version, commitHash string) (*App, error) {
 	// Initialize store and Kafka consumer
 	// Store is only needed for legacy mode (collector writes directly to database)
 	var dataStore *store.Store
-	if !cfg.Kafka.Enabled {
+	if db != nil {
 		// Legacy mode: collector uses store to write directly to database
 		dataStore = store.New(db, log)
 	}
 
+	// Initialize offline writer; files are imported later with "kcic import"
+	var offlineWriter *offline.Writer
+	if cfg.Offline.Enabled {
+		offlineWriter, err = offline.NewWriter(cfg.Offline.Directory, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize offline mode: %w", err)
+		}
+	}
+
 	// Note: Kafka consumer is handled by separate consumer binary (cmd/consumer/main.go)
 	// The collector only produces to Kafka when Kafka is enabled
 
-	// Initialize collector with Kafka producer
-	clusterCollector := collector.New(k8sClient, kafkaProducer, log)
+	// Initialize a collector per cluster with the shared Kafka producer
+	var collectors []*collector.ClusterCollector
+	for _, client := range k8sClients {
+		collectors = append(collectors, collector.New(client, kafkaProducer, log))
+	}
+
+	// All HTTP servers are started in Run and stopped together in Close
+	manager := lifecycle.New(log, cfg.Server)
 
 	// Initialize metrics if enabled
 	var metricsInstance *metrics.Metrics
 	if cfg.Metrics.Enabled {
 		metricsInstance = metrics.New(log)
+		manager.AddServer("metrics", cfg.Metrics.Address, metricsInstance.ServerHandler(), cfg.Metrics.TLS)
+	}
 
-		// Start metrics server in background
-		go func() {
-			if err := metricsInstance.StartMetricsServer(cfg.Metrics.Address); err != nil {
-				log.WithError(err).Error("Failed to start metrics server")
-			}
-		}()
+	// Initialize snapshot archiver if enabled (requires direct database access)
+	var archiver *archive.Archiver
+	if cfg.Archive.Enabled && db != nil {
+		archiveConfig := archive.Config{
+			Enabled:     cfg.Archive.Enabled,
+			Destination: cfg.Archive.Destination,
+			Directory:   cfg.Archive.Directory,
+			S3: archive.S3Config{
+				Endpoint:  cfg.Archive.S3.Endpoint,
+				Bucket:    cfg.Archive.S3.Bucket,
+				Prefix:    cfg.Archive.S3.Prefix,
+				AccessKey: cfg.Archive.S3.AccessKey,
+				SecretKey: cfg.Archive.S3.SecretKey,
+				UseSSL:    cfg.Archive.S3.UseSSL,
+			},
+		}
+		archiver, err = archive.New(db, log, archiveConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize snapshot archiver: %w", err)
+		}
 	}
 
 	// Initialize retention manager if enabled
 	var retentionManager *retention.RetentionManager
 	if cfg.Retention.Enabled {
+		var tiers []retention.Tier
+		for _, tier := range cfg.Retention.Tiers {
+			tiers = append(tiers, retention.Tier{MaxAge: tier.MaxAge, Interval: tier.Interval})
+		}
 		retentionConfig := retention.RetentionConfig{
-			Enabled:         cfg.Retention.Enabled,
-			MaxAge:          cfg.Retention.MaxAge,
-			MaxSnapshots:    cfg.Retention.MaxSnapshots,
-			CleanupInterval: cfg.Retention.CleanupInterval,
-			DeleteBatchSize: cfg.Retention.DeleteBatchSize,
+			Enabled:              cfg.Retention.Enabled,
+			MaxAge:               cfg.Retention.MaxAge,
+			MaxSnapshots:         cfg.Retention.MaxSnapshots,
+			CleanupInterval:      cfg.Retention.CleanupInterval,
+			DeleteBatchSize:      cfg.Retention.DeleteBatchSize,
+			PreserveLatestPerDay: cfg.Retention.PreserveLatestPerDay,
+			Tiers:                tiers,
+			DryRun:               cfg.Retention.DryRun,
 		}
-		retentionManager = retention.New(db, log, retentionConfig)
+		retentionManager = retention.New(db, log, retentionConfig, archiver, metricsInstance)
 
 		// Start retention manager
 		retentionManager.Start()
@@ -152,35 +208,86 @@ func New(version, commitHash string) (*App, error) {
 	var apiServer *api.Server
 	if cfg.API.Enabled {
 		apiConfig := api.APIConfig{
-			Enabled: cfg.API.Enabled,
-			Address: cfg.API.Address,
-			Prefix:  cfg.API.Prefix,
+			Enabled:            cfg.API.Enabled,
+			Address:            cfg.API.Address,
+			Prefix:             cfg.API.Prefix,
+			CORSAllowedOrigins: cfg.API.CORSAllowedOrigins,
+			RateLimit:          cfg.API.RateLimit,
+			RateLimitBurst:     cfg.API.RateLimitBurst,
+			MaxBodyBytes:       cfg.API.MaxBodyBytes,
+			CacheEnabled:       cfg.API.CacheEnabled,
+			GraphQLMaxDepth:    cfg.API.GraphQLMaxDepth,
 		}
 		apiServer = api.New(db, log, apiConfig, streamingHub, version, commitHash)
+		apiServer.SetArchiver(archiver)
+		apiServer.SetRetention(retentionManager)
 
-		// Start API server in background
-		go func() {
-			if err := apiServer.Start(); err != nil {
-				log.WithError(err).Error("Failed to start API server")
+		if cfg.Auth.Enabled {
+			authenticator, policy, err := auth.New(&cfg.Auth, k8sClient.Clientset, log)
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize API authentication: %w", err)
 			}
-		}()
+			apiServer.SetAuth(authenticator, policy)
+		}
+
+		manager.AddServer("api", cfg.API.Address, apiServer.Handler(), cfg.API.TLS)
 	}
 
-	return &App{
+	app := &App{
 		config:        cfg,
 		logger:        log,
 		db:            db,
 		k8sClient:     k8sClient,
-		collector:     clusterCollector,
+		collectors:    collectors,
 		store:         dataStore,
 		kafkaProducer: kafkaProducer,
+		offline:       offlineWriter,
 		// kafkaConsumer removed - handled by separate consumer binary
 		metrics:      metricsInstance,
 		retention:    retentionManager,
 		alerting:     alertingManager,
 		apiServer:    apiServer,
 		streamingHub: streamingHub,
-	}, nil
+		lifecycle:    manager,
+	}
+
+	// Each cluster is collected on its own interval
+	var jobs []collector.Job
+	for _, c := range collectors {
+		interval := cfg.Collection.Interval
+		if override, ok := cfg.Collection.ClusterIntervals[c.ClusterName()]; ok {
+			interval = override
+		}
+		jobs = append(jobs, collector.Job{
+			Cluster:  c.ClusterName(),
+			Interval: interval,
+			Run: func(ctx context.Context) error {
+				return app.collectAndStore(ctx, c)
+			},
+		})
+	}
+	app.scheduler = collector.NewScheduler(jobs, log)
+
+	// Shutdown steps run in order once the servers have stopped
+	manager.OnShutdown("collections", app.scheduler.Stop)
+	if streamingHub != nil {
+		manager.OnShutdown("websocket clients", streamingHub.Shutdown)
+	}
+	if retentionManager != nil {
+		manager.OnShutdown("retention", retentionManager.Stop)
+	}
+	if kafkaProducer != nil {
+		manager.OnShutdown("kafka producer", func(context.Context) error {
+			return kafkaProducer.Close()
+		})
+	}
+	if db != nil {
+		manager.OnShutdown("database", func(context.Context) error {
+			return db.Close()
+		})
+	}
+
+	return app, nil
 }
 
 // Run executes the main application logic
@@ -194,42 +301,55 @@ func (a *App) Run(ctx context.Context) error {
 
 	if isService {
 		a.logger.Info("Starting cluster information collector in service mode")
-		// Run initial collection
-		if err := a.collectAndStore(ctx); err != nil {
+		a.lifecycle.Start()
+
+		// Collect every cluster on its schedule, or once without one
+		if a.scheduler.Scheduled() {
+			a.scheduler.Start(ctx)
+		} else if err := a.scheduler.RunOnce(ctx); err != nil {
 			return err
 		}
 
-		// Keep running until context is cancelled
+		// Keep running until context is cancelled or a server fails
 		a.logger.Info("Service mode: keeping application running...")
-		<-ctx.Done()
-		a.logger.Info("Received shutdown signal")
-		return nil
+		select {
+		case <-ctx.Done():
+			a.logger.Info("Received shutdown signal")
+			return nil
+		case err := <-a.lifecycle.Errors():
+			return err
+		}
 	} else {
 		a.logger.Info("Starting cluster information collection (one-shot mode)")
-		// Run single collection and exit
-		return a.collectAndStore(ctx)
+		// Collect every cluster once and exit
+		return a.scheduler.RunOnce(ctx)
 	}
 }
 
-// collectAndStore performs a single collection and storage cycle
-func (a *App) collectAndStore(ctx context.Context) error {
-	a.logger.Info("Starting cluster information collection")
+// collectAndStore performs a single collection and storage cycle for the
+// cluster of c
+func (a *App) collectAndStore(ctx context.Context, c *collector.ClusterCollector) error {
+	a.logger.WithField("cluster", c.ClusterName()).Info("Starting cluster information collection")
 
 	// Record collection start
 	if a.metrics != nil {
 		a.metrics.RecordCollectionStart()
 	}
 
+	if a.offline != nil {
+		return a.collectToFile(ctx, c)
+	}
+
 	// Collect cluster information and send to Kafka (if enabled) or store directly
 	if a.config.Kafka.Enabled {
 		// Collect and send to Kafka
-		if err := a.collector.Collect(ctx); err != nil {
+		if err := c.Collect(ctx); err != nil {
 			if a.metrics != nil {
 				a.metrics.RecordCollectionError()
 			}
 			// Send alert for collection failure
 			if a.alerting != nil {
-				a.alerting.SendCollectionFailureAlert(err, "default")
+				a.alerting.SendCollectionFailureAlert(err, c.ClusterName())
 			}
 			return fmt.Errorf("failed to collect and send cluster information: %w", err)
 		}
@@ -238,21 +358,21 @@ func (a *App) collectAndStore(ctx context.Context) error {
 		if a.metrics != nil {
 			a.metrics.RecordCollectionSuccess()
 		}
-		a.logger.Info("Cluster information collection completed and sent to Kafka")
+		a.logger.WithField("cluster", c.ClusterName()).Info("Cluster information collection completed and sent to Kafka")
 		return nil
 	} else {
 		// Legacy mode: collect and store directly to database
 		a.logger.Info("Running in legacy mode - storing directly to database")
 
 		// Collect cluster information
-		clusterInfo, err := a.collector.CollectClusterInfo(ctx)
+		clusterInfo, err := c.CollectClusterInfo(ctx)
 		if err != nil {
 			if a.metrics != nil {
 				a.metrics.RecordCollectionError()
 			}
 			// Send alert for collection failure
 			if a.alerting != nil {
-				a.alerting.SendCollectionFailureAlert(err, "default")
+				a.alerting.SendCollectionFailureAlert(err, c.ClusterName())
 			}
 			return fmt.Errorf("failed to collect cluster information: %w", err)
 		}
@@ -268,29 +388,48 @@ func (a *App) collectAndStore(ctx context.Context) error {
 		if a.metrics != nil {
 			a.metrics.RecordCollectionSuccess()
 		}
-		a.logger.Info("Cluster information collection completed and stored to database")
+		a.logger.WithField("cluster", c.ClusterName()).Info("Cluster information collection completed and stored to database")
 		return nil
 	}
 }
 
-// Close gracefully shuts down the application
-func (a *App) Close() error {
-	a.logger.Info("Shutting down application")
-
-	// Note: Kafka consumer is handled by separate consumer binary
-
-	// Close Kafka producer
-	if a.kafkaProducer != nil {
-		if err := a.kafkaProducer.Close(); err != nil {
-			a.logger.WithError(err).Error("Failed to close Kafka producer")
+// collectToFile performs a single collection and writes it to the offline
+// directory
+func (a *App) collectToFile(ctx context.Context, c *collector.ClusterCollector) error {
+	clusterInfo, err := c.CollectClusterInfo(ctx)
+	if err == nil {
+		_, err = a.offline.Write(*clusterInfo)
+	}
+	if err != nil {
+		if a.metrics != nil {
+			a.metrics.RecordCollectionError()
+		}
+		if a.alerting != nil {
+			a.alerting.SendCollectionFailureAlert(err, c.ClusterName())
 		}
+		return fmt.Errorf("failed to collect cluster information to file: %w", err)
 	}
 
-	if a.db != nil {
-		if err := a.db.Close(); err != nil {
-			a.logger.WithError(err).Error("Failed to close database connection")
-			return err
-		}
+	if a.metrics != nil {
+		a.metrics.RecordCollectionSuccess()
+	}
+	a.logger.WithField("cluster", c.ClusterName()).Info("Cluster information collection completed and written to offline directory")
+	return nil
+}
+
+// Close gracefully shuts down the application: servers stop accepting
+// requests and finish in-flight ones, WebSocket clients are drained, and
+// then the retention manager, Kafka producer and database are closed, all
+// within the configured shutdown timeout
+func (a *App) Close() error {
+	a.logger.WithField("timeout", a.config.Server.ShutdownTimeout).Info("Shutting down application")
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.config.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := a.lifecycle.Shutdown(ctx); err != nil {
+		a.logger.WithError(err).Error("Application shutdown incomplete")
+		return err
 	}
 
 	a.logger.Info("Application shutdown complete")
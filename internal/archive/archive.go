@@ -0,0 +1,295 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/database"
+	"k8s-cluster-info-collector/internal/models"
+	"k8s-cluster-info-collector/internal/store"
+)
+
+// Archiver exports snapshots to compressed NDJSON files before they are
+// deleted and re-imports them on demand
+type Archiver struct {
+	db      *database.DB
+	logger  *logrus.Logger
+	config  Config
+	backend backend
+}
+
+// Config holds snapshot archive configuration
+type Config struct {
+	Enabled     bool
+	Destination string // "file" or "s3"
+	Directory   string
+	S3          S3Config
+}
+
+// S3Config holds configuration for S3-compatible object storage (e.g. MinIO)
+type S3Config struct {
+	Endpoint  string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// Record is a single line of an archive file
+type Record struct {
+	SnapshotID int             `json:"snapshot_id"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Entry is a catalog row describing where an archived snapshot lives
+type Entry struct {
+	SnapshotID        int       `json:"snapshot_id"`
+	SnapshotTimestamp time.Time `json:"snapshot_timestamp"`
+	Location          string    `json:"location"`
+	ArchivedAt        time.Time `json:"archived_at"`
+}
+
+// backend stores and retrieves archive files
+type backend interface {
+	put(ctx context.Context, name string, file *os.File, size int64) (string, error)
+	get(ctx context.Context, location string) (io.ReadCloser, error)
+}
+
+// New creates a new archiver
+func New(db *database.DB, logger *logrus.Logger, config Config) (*Archiver, error) {
+	var b backend
+	var err error
+	switch config.Destination {
+	case "", "file":
+		b, err = newFileBackend(config.Directory)
+	case "s3":
+		b, err = newS3Backend(config.S3)
+	default:
+		return nil, fmt.Errorf("unknown archive destination %q", config.Destination)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.WithFields(logrus.Fields{
+		"destination": config.Destination,
+		"directory":   config.Directory,
+		"bucket":      config.S3.Bucket,
+	}).Info("Snapshot archiver initialized")
+
+	return &Archiver{
+		db:      db,
+		logger:  logger,
+		config:  config,
+		backend: b,
+	}, nil
+}
+
+// ArchiveSnapshots writes the given snapshots to a single gzip-compressed
+// NDJSON file, uploads it to the configured destination and records each
+// snapshot in the archive catalog. It returns the archive location.
+func (a *Archiver) ArchiveSnapshots(ctx context.Context, snapshotIDs []int) (string, error) {
+	if len(snapshotIDs) == 0 {
+		return "", nil
+	}
+
+	tmp, err := os.CreateTemp("", "snapshots-*.ndjson.gz")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary archive file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	entries, err := a.writeRecords(ctx, tmp, snapshotIDs)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", nil
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine archive size: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind archive file: %w", err)
+	}
+
+	first, last := entries[0].SnapshotTimestamp, entries[len(entries)-1].SnapshotTimestamp
+	name := fmt.Sprintf("snapshots-%s-%s-%d.ndjson.gz",
+		first.UTC().Format("20060102T150405Z"), last.UTC().Format("20060102T150405Z"), len(entries))
+
+	location, err := a.backend.put(ctx, name, tmp, size)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload archive: %w", err)
+	}
+
+	if err := a.recordCatalog(ctx, entries, location); err != nil {
+		return "", err
+	}
+
+	a.logger.WithFields(logrus.Fields{
+		"location":  location,
+		"snapshots": len(entries),
+		"size":      size,
+	}).Info("Archived snapshots")
+
+	return location, nil
+}
+
+// writeRecords streams the snapshots into w as gzip-compressed NDJSON
+func (a *Archiver) writeRecords(ctx context.Context, w io.Writer, snapshotIDs []int) ([]Entry, error) {
+	rows, err := a.db.QueryContext(ctx,
+		"SELECT id, timestamp, data FROM cluster_snapshots WHERE id = ANY($1) ORDER BY timestamp ASC",
+		pq.Array(snapshotIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+
+	var entries []Entry
+	for rows.Next() {
+		var record Record
+		var data []byte
+		if err := rows.Scan(&record.SnapshotID, &record.Timestamp, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+		record.Data = data
+
+		if err := encoder.Encode(record); err != nil {
+			return nil, fmt.Errorf("failed to write snapshot %d: %w", record.SnapshotID, err)
+		}
+		entries = append(entries, Entry{
+			SnapshotID:        record.SnapshotID,
+			SnapshotTimestamp: record.Timestamp,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive compression: %w", err)
+	}
+	return entries, nil
+}
+
+// recordCatalog stores the archive location of each snapshot
+func (a *Archiver) recordCatalog(ctx context.Context, entries []Entry, location string) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, entry := range entries {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO snapshot_archives (snapshot_id, snapshot_timestamp, location)
+			VALUES ($1, $2, $3)`,
+			entry.SnapshotID, entry.SnapshotTimestamp, location)
+		if err != nil {
+			return fmt.Errorf("failed to record archive of snapshot %d: %w", entry.SnapshotID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit archive catalog: %w", err)
+	}
+	return nil
+}
+
+// Import reads an archive file and stores every snapshot it contains.
+// Snapshots whose cluster and timestamp already exist in the database are
+// skipped, so importing the same archive twice is harmless. It returns the number of
+// snapshots imported.
+func (a *Archiver) Import(ctx context.Context, location string, dataStore *store.Store) (int, error) {
+	reader, err := a.backend.get(ctx, location)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open archive %s: %w", location, err)
+	}
+	defer reader.Close()
+
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decompress archive %s: %w", location, err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	// Snapshots of large clusters can be many megabytes on a single line
+	scanner.Buffer(make([]byte, 1024*1024), 512*1024*1024)
+
+	imported := 0
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return imported, fmt.Errorf("failed to parse archive record: %w", err)
+		}
+
+		var info models.ClusterInfo
+		if err := json.Unmarshal(record.Data, &info); err != nil {
+			return imported, fmt.Errorf("failed to parse archived snapshot %d: %w", record.SnapshotID, err)
+		}
+
+		exists, err := dataStore.HasSnapshot(ctx, info.ClusterName(), record.Timestamp)
+		if err != nil {
+			return imported, err
+		}
+		if exists {
+			a.logger.WithField("timestamp", record.Timestamp).Debug("Snapshot already present, skipping")
+			continue
+		}
+		if err := dataStore.StoreClusterInfo(info); err != nil {
+			return imported, fmt.Errorf("failed to store archived snapshot %d: %w", record.SnapshotID, err)
+		}
+		imported++
+	}
+	if err := scanner.Err(); err != nil {
+		return imported, fmt.Errorf("failed to read archive %s: %w", location, err)
+	}
+
+	a.logger.WithFields(logrus.Fields{
+		"location": location,
+		"imported": imported,
+	}).Info("Imported snapshot archive")
+
+	return imported, nil
+}
+
+// ListArchives returns catalog entries, most recent snapshots first
+func (a *Archiver) ListArchives(ctx context.Context, limit int) ([]Entry, error) {
+	rows, err := a.db.QueryContext(ctx, `
+		SELECT snapshot_id, snapshot_timestamp, location, archived_at
+		FROM snapshot_archives
+		ORDER BY snapshot_timestamp DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query archive catalog: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		if err := rows.Scan(&entry.SnapshotID, &entry.SnapshotTimestamp, &entry.Location, &entry.ArchivedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan archive entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
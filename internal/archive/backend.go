@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ErrOutsideDestination is returned when an archive location does not point
+// into the configured directory, or bucket and prefix
+var ErrOutsideDestination = errors.New("archive location is outside the archive destination")
+
+// fileBackend stores archives in a local directory
+type fileBackend struct {
+	directory string
+}
+
+func newFileBackend(directory string) (*fileBackend, error) {
+	if directory == "" {
+		return nil, fmt.Errorf("archive directory is required for file destination")
+	}
+	if err := os.MkdirAll(directory, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &fileBackend{directory: directory}, nil
+}
+
+func (b *fileBackend) put(ctx context.Context, name string, file *os.File, size int64) (string, error) {
+	path := filepath.Join(b.directory, name)
+
+	// Write to a temporary name first so a partial file is never mistaken for an archive
+	out, err := os.CreateTemp(b.directory, "."+name+".*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(out.Name())
+
+	if _, err := io.Copy(out, file); err != nil {
+		out.Close()
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(out.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (b *fileBackend) get(ctx context.Context, location string) (io.ReadCloser, error) {
+	// Only read archives from the configured directory
+	rel, err := filepath.Rel(b.directory, filepath.Clean(location))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%w: %q", ErrOutsideDestination, location)
+	}
+	return os.Open(filepath.Join(b.directory, rel))
+}
+
+// s3Backend stores archives in an S3-compatible bucket such as MinIO
+type s3Backend struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func newS3Backend(cfg S3Config) (*s3Backend, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("archive S3 endpoint and bucket are required for s3 destination")
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	return &s3Backend{
+		client: client,
+		bucket: cfg.Bucket,
+		prefix: strings.Trim(cfg.Prefix, "/"),
+	}, nil
+}
+
+func (b *s3Backend) put(ctx context.Context, name string, file *os.File, size int64) (string, error) {
+	key := name
+	if b.prefix != "" {
+		key = b.prefix + "/" + name
+	}
+
+	_, err := b.client.PutObject(ctx, b.bucket, key, file, size, minio.PutObjectOptions{
+		ContentType: "application/x-ndjson",
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("s3://%s/%s", b.bucket, key), nil
+}
+
+func (b *s3Backend) get(ctx context.Context, location string) (io.ReadCloser, error) {
+	trimmed := strings.TrimPrefix(location, "s3://")
+	bucket, key, ok := strings.Cut(trimmed, "/")
+	if !ok || trimmed == location {
+		return nil, fmt.Errorf("invalid S3 archive location %q", location)
+	}
+
+	// Only read archives from the configured bucket and prefix
+	if bucket != b.bucket || key == "" || path.Clean("/"+key) != "/"+key ||
+		(b.prefix != "" && !strings.HasPrefix(key, b.prefix+"/")) {
+		return nil, fmt.Errorf("%w: %q", ErrOutsideDestination, location)
+	}
+	return b.client.GetObject(ctx, b.bucket, key, minio.GetObjectOptions{})
+}
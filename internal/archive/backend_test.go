@@ -0,0 +1,72 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileBackendRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	backend, err := newFileBackend(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src, err := os.CreateTemp(t.TempDir(), "archive")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer src.Close()
+	src.WriteString("payload")
+	src.Seek(0, io.SeekStart)
+
+	location, err := backend.put(context.Background(), "snapshots.ndjson.gz", src, 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location != filepath.Join(dir, "snapshots.ndjson.gz") {
+		t.Errorf("unexpected location %s", location)
+	}
+
+	reader, err := backend.get(context.Background(), location)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer reader.Close()
+
+	data, _ := io.ReadAll(reader)
+	if string(data) != "payload" {
+		t.Errorf("expected 'payload', got %q", data)
+	}
+}
+
+func TestFileBackendRejectsOutsideDirectory(t *testing.T) {
+	backend, err := newFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := backend.get(context.Background(), "/etc/passwd"); !errors.Is(err, ErrOutsideDestination) {
+		t.Errorf("expected ErrOutsideDestination for location outside archive directory, got %v", err)
+	}
+}
+
+func TestS3BackendRejectsOutsidePrefix(t *testing.T) {
+	backend := &s3Backend{bucket: "archives", prefix: "collector"}
+
+	for _, location := range []string{
+		"s3://other/collector/snapshots.ndjson.gz",
+		"s3://archives/snapshots.ndjson.gz",
+		"s3://archives/collector-other/snapshots.ndjson.gz",
+		"s3://archives/collector/../secrets/snapshots.ndjson.gz",
+		"s3://archives/collector/",
+	} {
+		if _, err := backend.get(context.Background(), location); !errors.Is(err, ErrOutsideDestination) {
+			t.Errorf("get(%q) error = %v, want ErrOutsideDestination", location, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrUnauthenticated is returned when no authenticator accepts a token
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Identity is an authenticated caller
+type Identity struct {
+	Subject string   `json:"subject"`
+	Groups  []string `json:"groups,omitempty"`
+	Method  string   `json:"method"` // "token", "tokenreview" or "oidc"
+}
+
+// Authenticator validates a bearer token and returns the caller's identity.
+// It returns ErrUnauthenticated (possibly wrapped) when it rejects the token,
+// and any other error when it could not decide, such as when the service it
+// checks tokens with is unavailable.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+// tokenOwner is implemented by authenticators that can recognize their own
+// tokens without verifying them
+type tokenOwner interface {
+	owns(token string) bool
+}
+
+// Chain tries each authenticator in order and returns the first identity. A
+// token recognized by one of them is only tried there, so it is never passed
+// on to another service. The token is only rejected with ErrUnauthenticated
+// when every authenticator rejected it; otherwise the errors of those that
+// could not decide are returned.
+type Chain []Authenticator
+
+// Authenticate implements Authenticator
+func (c Chain) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	for _, authenticator := range c {
+		if owner, ok := authenticator.(tokenOwner); ok && owner.owns(token) {
+			return authenticator.Authenticate(ctx, token)
+		}
+	}
+
+	var rejections, failures []error
+	for _, authenticator := range c {
+		identity, err := authenticator.Authenticate(ctx, token)
+		switch {
+		case err == nil:
+			return identity, nil
+		case errors.Is(err, ErrUnauthenticated):
+			rejections = append(rejections, err)
+		default:
+			failures = append(failures, err)
+		}
+	}
+	if len(failures) > 0 {
+		return nil, errors.Join(failures...)
+	}
+	return nil, errors.Join(append([]error{ErrUnauthenticated}, rejections...)...)
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer" header
+func BearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(prefix):])
+	return token, token != ""
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// IdentityFrom returns the identity stored in ctx, or nil
+func IdentityFrom(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(contextKey{}).(*Identity)
+	return identity
+}
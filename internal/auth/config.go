@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+
+	"k8s-cluster-info-collector/internal/config"
+)
+
+// tokenReviewCacheTTL is how long an accepted TokenReview result is reused
+const tokenReviewCacheTTL = time.Minute
+
+// New builds the authenticator chain and authorization policy from cfg.
+// clientset is only used when TokenReview is enabled. The returned policy is
+// nil when no policy file is configured.
+func New(cfg *config.AuthConfig, clientset kubernetes.Interface, logger *logrus.Logger) (Authenticator, *Policy, error) {
+	var chain Chain
+	var methods []string
+
+	if cfg.TokensFile != "" {
+		tokens, err := LoadStaticTokens(cfg.TokensFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		chain = append(chain, tokens)
+		methods = append(methods, "token")
+	}
+	// OIDC is verified locally, so it goes before TokenReview, which would
+	// send every token to the API server
+	if cfg.OIDC.JWKSURL != "" {
+		// Without both, any token signed by the JWKS would do, including
+		// every service account token when it is the cluster's own
+		if cfg.OIDC.IssuerURL == "" || cfg.OIDC.Audience == "" {
+			return nil, nil, fmt.Errorf("AUTH_OIDC_JWKS_URL requires AUTH_OIDC_ISSUER_URL and AUTH_OIDC_AUDIENCE")
+		}
+		chain = append(chain, NewOIDC(OIDCConfig{
+			IssuerURL:     cfg.OIDC.IssuerURL,
+			JWKSURL:       cfg.OIDC.JWKSURL,
+			Audience:      cfg.OIDC.Audience,
+			UsernameClaim: cfg.OIDC.UsernameClaim,
+			GroupsClaim:   cfg.OIDC.GroupsClaim,
+		}))
+		methods = append(methods, "oidc")
+	}
+	if cfg.TokenReview {
+		if clientset == nil {
+			return nil, nil, fmt.Errorf("token review requires a Kubernetes client")
+		}
+		chain = append(chain, NewTokenReview(clientset, cfg.TokenReviewAudiences, tokenReviewCacheTTL))
+		methods = append(methods, "tokenreview")
+	}
+	if len(chain) == 0 {
+		return nil, nil, fmt.Errorf("authentication is enabled but no authenticator is configured")
+	}
+
+	var policy *Policy
+	if cfg.PolicyFile != "" {
+		var err error
+		if policy, err = LoadPolicy(cfg.PolicyFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"methods": methods,
+		"policy":  cfg.PolicyFile,
+	}).Info("API authentication enabled")
+
+	return chain, policy, nil
+}
@@ -0,0 +1,363 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockSkew is the leeway allowed when checking exp and nbf
+const clockSkew = time.Minute
+
+// jwksRefreshInterval limits how often an unknown key ID triggers a JWKS fetch
+const jwksRefreshInterval = time.Minute
+
+// OIDCConfig configures JWT validation
+type OIDCConfig struct {
+	IssuerURL     string
+	JWKSURL       string
+	Audience      string
+	UsernameClaim string // default "sub"
+	GroupsClaim   string // default "groups"
+}
+
+// OIDC authenticates JWTs signed by a key from the configured JWKS and issued
+// by the configured issuer for the configured audience. RS256, RS384, RS512,
+// ES256, ES384 and ES512 signatures are supported.
+type OIDC struct {
+	config OIDCConfig
+	client *http.Client
+
+	mutex       sync.Mutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+	refreshing  chan struct{} // closed when the JWKS fetch in flight ends
+}
+
+// NewOIDC creates an OIDC authenticator. Keys are fetched on first use.
+func NewOIDC(config OIDCConfig) *OIDC {
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "sub"
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	return &OIDC{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   make(map[string]crypto.PublicKey),
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Authenticate implements Authenticator
+func (o *OIDC) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a JWT", ErrUnauthenticated)
+	}
+	// Tokens of other issuers cannot pass validateClaims; rejecting them
+	// here saves a JWKS fetch for their unknown key IDs
+	if !o.owns(token) {
+		return nil, fmt.Errorf("%w: not issued by %s", ErrUnauthenticated, o.config.IssuerURL)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: invalid JWT header", ErrUnauthenticated)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid JWT signature encoding", ErrUnauthenticated)
+	}
+
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: invalid JWT claims", ErrUnauthenticated)
+	}
+	if err := o.validateClaims(claims, time.Now()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	subject, _ := claims[o.config.UsernameClaim].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrUnauthenticated, o.config.UsernameClaim)
+	}
+	return &Identity{
+		Subject: subject,
+		Groups:  stringList(claims[o.config.GroupsClaim]),
+		Method:  "oidc",
+	}, nil
+}
+
+// validateClaims checks issuer, audience and validity window. A missing
+// issuer or audience in the configuration matches no token.
+func (o *OIDC) validateClaims(claims map[string]interface{}, now time.Time) error {
+	if iss, _ := claims["iss"].(string); o.config.IssuerURL == "" || iss != o.config.IssuerURL {
+		return fmt.Errorf("unexpected issuer %q", iss)
+	}
+	found := false
+	for _, aud := range stringList(claims["aud"]) {
+		if o.config.Audience != "" && aud == o.config.Audience {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("token not issued for audience %q", o.config.Audience)
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("missing exp claim")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("token not yet valid")
+	}
+	return nil
+}
+
+// key returns the public key for kid, refreshing the JWKS when the key is
+// unknown (keys rotate) but no more than once per jwksRefreshInterval. The
+// JWKS is fetched without holding the lock; requests for an unknown key
+// meanwhile wait for that one fetch.
+func (o *OIDC) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	for {
+		o.mutex.Lock()
+		if key, ok := o.keys[kid]; ok {
+			o.mutex.Unlock()
+			return key, nil
+		}
+		if refreshing := o.refreshing; refreshing != nil {
+			o.mutex.Unlock()
+			select {
+			case <-refreshing:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if time.Since(o.lastRefresh) < jwksRefreshInterval {
+			o.mutex.Unlock()
+			return nil, fmt.Errorf("%w: unknown signing key %q", ErrUnauthenticated, kid)
+		}
+		o.lastRefresh = time.Now()
+		refreshing := make(chan struct{})
+		o.refreshing = refreshing
+		o.mutex.Unlock()
+
+		keys, err := o.fetchKeys(ctx)
+
+		o.mutex.Lock()
+		if err == nil {
+			o.keys = keys
+		}
+		o.refreshing = nil
+		close(refreshing)
+		o.mutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// owns reports whether token is a JWT from the configured issuer, judged by
+// its unverified iss claim
+func (o *OIDC) owns(token string) bool {
+	if o.config.IssuerURL == "" {
+		return false
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	return decodeSegment(parts[1], &claims) == nil && claims.Issuer == o.config.IssuerURL
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (o *OIDC) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.config.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Skip keys we cannot use rather than rejecting the whole set
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// verifySignature checks a JWS signature over signed with the given algorithm
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	hasher := hash.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %s does not match RSA key", alg)
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("algorithm %s does not match EC key", alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("invalid ECDSA signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// stringList accepts a claim that is either a string or a list of strings
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+)
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	signed := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	signed := encodeSegment(t, map[string]string{"alg": "ES256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDCAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetches := 0
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{
+				"kty": "RSA", "kid": "rsa-1", "use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC", "kid": "ec-1", "crv": "P-256",
+				"x": base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+				"y": base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+		}})
+	}))
+	defer jwks.Close()
+
+	oidc := NewOIDC(OIDCConfig{
+		IssuerURL:     "https://issuer.example.com",
+		JWKSURL:       jwks.URL,
+		Audience:      "cluster-info",
+		UsernameClaim: "email",
+	})
+
+	now := time.Now().Unix()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":    "https://issuer.example.com",
+			"aud":    []string{"other", "cluster-info"},
+			"exp":    now + 300,
+			"sub":    "1234",
+			"email":  "alice@example.com",
+			"groups": []string{"sre"},
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	identity, err := oidc.Authenticate(context.Background(), signRS256(t, rsaKey, "rsa-1", claims(nil)))
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	expected := &Identity{Subject: "alice@example.com", Groups: []string{"sre"}, Method: "oidc"}
+	if !reflect.DeepEqual(identity, expected) {
+		t.Errorf("expected %+v, got %+v", expected, identity)
+	}
+
+	if _, err := oidc.Authenticate(context.Background(), signES256(t, ecKey, "ec-1", claims(map[string]interface{}{"aud": "cluster-info"}))); err != nil {
+		t.Errorf("ES256 token rejected: %v", err)
+	}
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rejected := map[string]string{
+		"expired":        signRS256(t, rsaKey, "rsa-1", claims(map[string]interface{}{"exp": now - 3600})),
+		"not yet valid":  signRS256(t, rsaKey, "rsa-1", claims(map[string]interface{}{"nbf": now + 3600})),
+		"wrong issuer":   signRS256(t, rsaKey, "rsa-1", claims(map[string]interface{}{"iss": "https://evil.example.com"})),
+		"wrong audience": signRS256(t, rsaKey, "rsa-1", claims(map[string]interface{}{"aud": "other"})),
+		"bad signature":  signRS256(t, otherKey, "rsa-1", claims(nil)),
+		"unknown key":    signRS256(t, rsaKey, "rsa-2", claims(nil)),
+		"not a JWT":      "opaque-token",
+	}
+	for name, token := range rejected {
+		t.Run(name, func(t *testing.T) {
+			if _, err := oidc.Authenticate(context.Background(), token); !errors.Is(err, ErrUnauthenticated) {
+				t.Errorf("expected ErrUnauthenticated, got %v", err)
+			}
+		})
+	}
+
+	// The unknown key must not trigger another fetch within the refresh interval
+	if fetches != 1 {
+		t.Errorf("expected 1 JWKS fetch, got %d", fetches)
+	}
+}
+
+// rsaJWKS serves key as the only key of a JWKS, calling before first on
+// every fetch
+func rsaJWKS(key *rsa.PrivateKey, kid string, before func()) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": kid,
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+}
+
+// countingAuthenticator rejects every token and counts the attempts
+type countingAuthenticator struct{ calls int }
+
+func (c *countingAuthenticator) Authenticate(context.Context, string) (*Identity, error) {
+	c.calls++
+	return nil, ErrUnauthenticated
+}
+
+func TestChainKeepsOIDCTokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := rsaJWKS(rsaKey, "rsa-1", func() {})
+	defer jwks.Close()
+
+	oidc := NewOIDC(OIDCConfig{IssuerURL: "https://issuer.example.com", JWKSURL: jwks.URL, Audience: "cluster-info"})
+	next := &countingAuthenticator{}
+	chain := Chain{oidc, next}
+
+	expired := signRS256(t, rsaKey, "rsa-1", map[string]interface{}{
+		"iss": "https://issuer.example.com", "aud": "cluster-info", "sub": "alice", "exp": time.Now().Add(-time.Hour).Unix(),
+	})
+	if _, err := chain.Authenticate(context.Background(), expired); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected ErrUnauthenticated, got %v", err)
+	}
+	if next.calls != 0 {
+		t.Errorf("expected a token of the OIDC issuer not to be passed on, got %d calls", next.calls)
+	}
+
+	other := signRS256(t, rsaKey, "rsa-1", map[string]interface{}{"iss": "kubernetes/serviceaccount", "sub": "bob"})
+	if _, err := chain.Authenticate(context.Background(), other); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected ErrUnauthenticated, got %v", err)
+	}
+	if next.calls != 1 {
+		t.Errorf("expected a token of another issuer to be passed on, got %d calls", next.calls)
+	}
+}
+
+func TestOIDCKnownKeyDuringRefresh(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, release := make(chan struct{}, 2), make(chan struct{})
+	first := true
+	jwks := rsaJWKS(rsaKey, "rsa-1", func() {
+		if !first {
+			fetched <- struct{}{}
+			<-release
+		}
+		first = false
+	})
+	defer jwks.Close()
+
+	oidc := NewOIDC(OIDCConfig{IssuerURL: "https://issuer.example.com", JWKSURL: jwks.URL, Audience: "cluster-info"})
+	claims := map[string]interface{}{
+		"iss": "https://issuer.example.com", "aud": "cluster-info", "sub": "alice", "exp": time.Now().Add(time.Hour).Unix(),
+	}
+	if _, err := oidc.Authenticate(context.Background(), signRS256(t, rsaKey, "rsa-1", claims)); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	// An unknown key starts a refresh that hangs until released
+	oidc.mutex.Lock()
+	oidc.lastRefresh = time.Time{}
+	oidc.mutex.Unlock()
+	unknown, known := signRS256(t, rsaKey, "rsa-2", claims), signRS256(t, rsaKey, "rsa-1", claims)
+	done := make(chan error)
+	go func() {
+		_, err := oidc.Authenticate(context.Background(), unknown)
+		done <- err
+	}()
+	<-fetched
+
+	result := make(chan error)
+	go func() {
+		_, err := oidc.Authenticate(context.Background(), known)
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Authenticate() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("a known key waited for the JWKS fetch")
+	}
+
+	close(release)
+	if err := <-done; !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected ErrUnauthenticated for the unknown key, got %v", err)
+	}
+}
+
+func TestNewRequiresOIDCIssuerAndAudience(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := rsaJWKS(rsaKey, "rsa-1", func() {})
+	defer jwks.Close()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	incomplete := []config.OIDCConfig{
+		{JWKSURL: jwks.URL},
+		{JWKSURL: jwks.URL, IssuerURL: "https://issuer.example.com"},
+		{JWKSURL: jwks.URL, Audience: "cluster-info"},
+	}
+	for _, oidc := range incomplete {
+		if _, _, err := New(&config.AuthConfig{OIDC: oidc}, nil, logger); err == nil {
+			t.Errorf("expected an error for %+v", oidc)
+		}
+	}
+
+	authenticator, _, err := New(&config.AuthConfig{OIDC: config.OIDCConfig{
+		JWKSURL: jwks.URL, IssuerURL: "https://issuer.example.com", Audience: "cluster-info",
+	}}, nil, logger)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	claims := map[string]interface{}{
+		"iss": "https://issuer.example.com", "aud": "cluster-info", "sub": "alice", "exp": time.Now().Add(time.Hour).Unix(),
+	}
+	if _, err := authenticator.Authenticate(context.Background(), signRS256(t, rsaKey, "rsa-1", claims)); err != nil {
+		t.Errorf("Authenticate() error = %v", err)
+	}
+	claims["aud"] = "another-service"
+	if _, err := authenticator.Authenticate(context.Background(), signRS256(t, rsaKey, "rsa-1", claims)); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected a token for another audience to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Wildcard matches every subject, namespace or kind in a policy rule
+const Wildcard = "*"
+
+// Policy restricts which namespaces and kinds an identity may read. A nil
+// Policy grants every authenticated caller full access.
+//
+// Example policy file:
+//
+//	rules:
+//	  - subjects: ["group:sre"]
+//	    namespaces: ["*"]
+//	    kinds: ["*"]
+//	  - subjects: ["alice", "system:serviceaccount:team-a:reader"]
+//	    namespaces: ["team-a"]
+//	    kinds: ["Deployment", "Pod", "Service"]
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule grants its subjects read access to kinds in namespaces. Subjects are
+// user names, "group:<name>" or "*" for any authenticated caller.
+type Rule struct {
+	Subjects   []string `json:"subjects"`
+	Namespaces []string `json:"namespaces"`
+	Kinds      []string `json:"kinds"`
+}
+
+// LoadPolicy reads a YAML (or JSON) policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	return parsePolicy(data)
+}
+
+func parsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	for i, rule := range policy.Rules {
+		if len(rule.Subjects) == 0 || len(rule.Namespaces) == 0 || len(rule.Kinds) == 0 {
+			return nil, fmt.Errorf("policy rule %d: subjects, namespaces and kinds are required", i+1)
+		}
+	}
+	return &policy, nil
+}
+
+// Access is what an identity may see of one kind
+type Access struct {
+	AllNamespaces bool
+	Namespaces    []string
+}
+
+// Allowed reports whether any object of the kind is visible
+func (a Access) Allowed() bool {
+	return a.AllNamespaces || len(a.Namespaces) > 0
+}
+
+// Allows reports whether objects in namespace are visible. Cluster-scoped
+// objects (empty namespace) require access to all namespaces.
+func (a Access) Allows(namespace string) bool {
+	if a.AllNamespaces {
+		return true
+	}
+	for _, allowed := range a.Namespaces {
+		if allowed == namespace && namespace != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Access returns the union of every rule granting identity access to kind
+func (p *Policy) Access(identity *Identity, kind string) Access {
+	if p == nil {
+		return Access{AllNamespaces: true}
+	}
+	if identity == nil {
+		return Access{}
+	}
+
+	var access Access
+	seen := make(map[string]bool)
+	for _, rule := range p.Rules {
+		if !rule.matchesSubject(identity) || !contains(rule.Kinds, kind) {
+			continue
+		}
+		for _, namespace := range rule.Namespaces {
+			if namespace == Wildcard {
+				return Access{AllNamespaces: true}
+			}
+			if !seen[namespace] {
+				seen[namespace] = true
+				access.Namespaces = append(access.Namespaces, namespace)
+			}
+		}
+	}
+	return access
+}
+
+// FullAccess reports whether identity may see every kind in every namespace,
+// which endpoints spanning whole snapshots require
+func (p *Policy) FullAccess(identity *Identity) bool {
+	if p == nil {
+		return true
+	}
+	if identity == nil {
+		return false
+	}
+	for _, rule := range p.Rules {
+		if rule.matchesSubject(identity) && contains(rule.Kinds, Wildcard) && contains(rule.Namespaces, Wildcard) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matchesSubject(identity *Identity) bool {
+	for _, subject := range r.Subjects {
+		if subject == Wildcard || subject == identity.Subject {
+			return true
+		}
+		if group, ok := strings.CutPrefix(subject, "group:"); ok {
+			for _, g := range identity.Groups {
+				if g == group {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// contains reports whether values holds value or the wildcard
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == Wildcard {
+			return true
+		}
+	}
+	return false
+}
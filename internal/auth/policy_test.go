@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testPolicy = `
+rules:
+  - subjects: ["group:sre"]
+    namespaces: ["*"]
+    kinds: ["*"]
+  - subjects: ["alice"]
+    namespaces: ["team-a"]
+    kinds: ["Deployment", "Pod"]
+  - subjects: ["alice"]
+    namespaces: ["team-b"]
+    kinds: ["Pod"]
+  - subjects: ["*"]
+    namespaces: ["*"]
+    kinds: ["Node"]
+`
+
+func TestPolicyAccess(t *testing.T) {
+	policy, err := parsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("parsePolicy() error = %v", err)
+	}
+
+	alice := &Identity{Subject: "alice"}
+	sre := &Identity{Subject: "carol", Groups: []string{"sre"}}
+
+	tests := []struct {
+		name     string
+		identity *Identity
+		kind     string
+		expected Access
+	}{
+		{"group wildcard", sre, "Secret", Access{AllNamespaces: true}},
+		{"single namespace", alice, "Deployment", Access{Namespaces: []string{"team-a"}}},
+		{"rules are merged", alice, "Pod", Access{Namespaces: []string{"team-a", "team-b"}}},
+		{"no matching rule", alice, "Secret", Access{}},
+		{"any subject", alice, "Node", Access{AllNamespaces: true}},
+		{"anonymous", nil, "Node", Access{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if access := policy.Access(tt.identity, tt.kind); !reflect.DeepEqual(access, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, access)
+			}
+		})
+	}
+
+	if !policy.FullAccess(sre) {
+		t.Error("expected sre group to have full access")
+	}
+	if policy.FullAccess(alice) {
+		t.Error("expected alice not to have full access")
+	}
+}
+
+func TestAccessAllows(t *testing.T) {
+	access := Access{Namespaces: []string{"team-a"}}
+	if !access.Allows("team-a") || access.Allows("team-b") {
+		t.Errorf("unexpected namespace access for %+v", access)
+	}
+	if access.Allows("") {
+		t.Error("cluster-scoped objects must require access to all namespaces")
+	}
+}
+
+func TestNilPolicy(t *testing.T) {
+	var policy *Policy
+	if !policy.FullAccess(&Identity{Subject: "anyone"}) || !policy.Access(nil, "Secret").AllNamespaces {
+		t.Error("nil policy should grant full access")
+	}
+}
+
+func TestParsePolicyInvalid(t *testing.T) {
+	for _, data := range []string{
+		"rules:\n  - subjects: [alice]\n    kinds: ['*']\n",
+		"rules:\n  - subject: [alice]\n",
+	} {
+		if _, err := parsePolicy([]byte(data)); err == nil {
+			t.Errorf("expected error for policy %q", data)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// StaticTokens authenticates tokens listed in a file using the Kubernetes
+// static token file format: token,user,uid,"group1,group2"
+type StaticTokens struct {
+	tokens map[string]Identity
+}
+
+// LoadStaticTokens reads a static token file. Lines starting with # are ignored.
+func LoadStaticTokens(path string) (*StaticTokens, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token file: %w", err)
+	}
+	defer file.Close()
+	return parseStaticTokens(file)
+}
+
+func parseStaticTokens(r io.Reader) (*StaticTokens, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token file: %w", err)
+	}
+
+	tokens := make(map[string]Identity, len(records))
+	for i, record := range records {
+		if len(record) < 2 || record[0] == "" || record[1] == "" {
+			return nil, fmt.Errorf("token file line %d: expected token,user[,uid[,groups]]", i+1)
+		}
+		identity := Identity{Subject: record[1], Method: "token"}
+		if len(record) >= 4 && record[3] != "" {
+			for _, group := range strings.Split(record[3], ",") {
+				if group = strings.TrimSpace(group); group != "" {
+					identity.Groups = append(identity.Groups, group)
+				}
+			}
+		}
+		tokens[record[0]] = identity
+	}
+	return &StaticTokens{tokens: tokens}, nil
+}
+
+// Authenticate implements Authenticator
+func (s *StaticTokens) Authenticate(_ context.Context, token string) (*Identity, error) {
+	for candidate, identity := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			identity := identity
+			return &identity, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown static token", ErrUnauthenticated)
+}
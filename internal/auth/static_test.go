@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStaticTokens(t *testing.T) {
+	file := `# token,user,uid,groups
+s3cr3t,alice,1001,"sre,readers"
+t0ken,bob,1002
+`
+	tokens, err := parseStaticTokens(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("parseStaticTokens() error = %v", err)
+	}
+
+	identity, err := tokens.Authenticate(context.Background(), "s3cr3t")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	expected := &Identity{Subject: "alice", Groups: []string{"sre", "readers"}, Method: "token"}
+	if !reflect.DeepEqual(identity, expected) {
+		t.Errorf("expected %+v, got %+v", expected, identity)
+	}
+
+	if identity, err := tokens.Authenticate(context.Background(), "t0ken"); err != nil || identity.Subject != "bob" || identity.Groups != nil {
+		t.Errorf("unexpected identity %+v, error %v", identity, err)
+	}
+	if _, err := tokens.Authenticate(context.Background(), "wrong"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected ErrUnauthenticated, got %v", err)
+	}
+}
+
+func TestParseStaticTokensInvalid(t *testing.T) {
+	if _, err := parseStaticTokens(strings.NewReader("tokenonly\n")); err == nil {
+		t.Error("expected error for line without user")
+	}
+}
+
+func TestChain(t *testing.T) {
+	first, _ := parseStaticTokens(strings.NewReader("a,alice\n"))
+	second, _ := parseStaticTokens(strings.NewReader("b,bob\n"))
+	chain := Chain{first, second}
+
+	identity, err := chain.Authenticate(context.Background(), "b")
+	if err != nil || identity.Subject != "bob" {
+		t.Errorf("expected bob, got %+v, error %v", identity, err)
+	}
+	if _, err := chain.Authenticate(context.Background(), "c"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected ErrUnauthenticated, got %v", err)
+	}
+
+	// An authenticator that cannot decide turns a rejection into its error,
+	// but does not stop another authenticator from accepting the token
+	failing := Chain{first, failingAuthenticator{}, second}
+	if _, err := failing.Authenticate(context.Background(), "c"); err == nil || errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected the authenticator failure, got %v", err)
+	}
+	if identity, err := failing.Authenticate(context.Background(), "b"); err != nil || identity.Subject != "bob" {
+		t.Errorf("expected bob, got %+v, error %v", identity, err)
+	}
+}
+
+// failingAuthenticator cannot reach the service it checks tokens with
+type failingAuthenticator struct{}
+
+func (failingAuthenticator) Authenticate(context.Context, string) (*Identity, error) {
+	return nil, errors.New("service unavailable")
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// TokenReview authenticates Kubernetes service account and user tokens by
+// submitting them to the API server's TokenReview API. Accepted tokens are
+// cached for a short time so every request does not cost an API call.
+type TokenReview struct {
+	client    kubernetes.Interface
+	audiences []string
+	ttl       time.Duration
+
+	mutex sync.Mutex
+	cache map[[sha256.Size]byte]cachedIdentity
+}
+
+type cachedIdentity struct {
+	identity Identity
+	expires  time.Time
+}
+
+// NewTokenReview creates a TokenReview authenticator. Audiences may be empty
+// to accept the API server's default audience.
+func NewTokenReview(client kubernetes.Interface, audiences []string, ttl time.Duration) *TokenReview {
+	return &TokenReview{
+		client:    client,
+		audiences: audiences,
+		ttl:       ttl,
+		cache:     make(map[[sha256.Size]byte]cachedIdentity),
+	}
+}
+
+// Authenticate implements Authenticator
+func (t *TokenReview) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	t.mutex.Lock()
+	if cached, ok := t.cache[key]; ok && now.Before(cached.expires) {
+		t.mutex.Unlock()
+		identity := cached.identity
+		return &identity, nil
+	}
+	t.mutex.Unlock()
+
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: t.audiences},
+	}
+	result, err := t.client.AuthenticationV1().TokenReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("token review failed: %w", err)
+	}
+	if !result.Status.Authenticated {
+		return nil, fmt.Errorf("%w: token review rejected token: %s", ErrUnauthenticated, result.Status.Error)
+	}
+
+	identity := Identity{
+		Subject: result.Status.User.Username,
+		Groups:  result.Status.User.Groups,
+		Method:  "tokenreview",
+	}
+
+	t.mutex.Lock()
+	// Drop expired entries so the cache cannot grow without bound
+	for k, cached := range t.cache {
+		if now.After(cached.expires) {
+			delete(t.cache, k)
+		}
+	}
+	t.cache[key] = cachedIdentity{identity: identity, expires: now.Add(t.ttl)}
+	t.mutex.Unlock()
+
+	return &identity, nil
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestTokenReview(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	reviews := 0
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "valid" {
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User: authenticationv1.UserInfo{
+					Username: "system:serviceaccount:monitoring:reader",
+					Groups:   []string{"system:serviceaccounts"},
+				},
+			}
+		} else {
+			review.Status = authenticationv1.TokenReviewStatus{Error: "invalid token"}
+		}
+		return true, review, nil
+	})
+
+	tokenReview := NewTokenReview(client, nil, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		identity, err := tokenReview.Authenticate(context.Background(), "valid")
+		if err != nil {
+			t.Fatalf("Authenticate() error = %v", err)
+		}
+		expected := &Identity{
+			Subject: "system:serviceaccount:monitoring:reader",
+			Groups:  []string{"system:serviceaccounts"},
+			Method:  "tokenreview",
+		}
+		if !reflect.DeepEqual(identity, expected) {
+			t.Errorf("expected %+v, got %+v", expected, identity)
+		}
+	}
+	if reviews != 1 {
+		t.Errorf("expected the second request to be served from cache, got %d reviews", reviews)
+	}
+
+	if _, err := tokenReview.Authenticate(context.Background(), "invalid"); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("expected ErrUnauthenticated, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package collector
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -18,6 +19,9 @@ type ClusterCollector struct {
 	client   *kubernetes.Client
 	producer *kafka.Producer
 	logger   *logrus.Logger
+
+	mu         sync.Mutex
+	clusterUID string
 }
 
 // New creates a new cluster collector
@@ -102,6 +106,7 @@ func (c *ClusterCollector) Collect(ctx context.Context) error {
 		PersistentVolumes:      persistentVolumes,
 		PersistentVolumeClaims: persistentVolumeClaims,
 	}
+	c.identify(ctx, clusterInfo)
 
 	// Send to Kafka
 	if c.producer != nil {
@@ -188,11 +193,42 @@ func (c *ClusterCollector) CollectClusterInfo(ctx context.Context) (*models.Clus
 		PersistentVolumes:      persistentVolumes,
 		PersistentVolumeClaims: persistentVolumeClaims,
 	}
+	c.identify(ctx, clusterInfo)
 
 	c.logger.Info("Cluster information collection completed (legacy mode)")
 	return clusterInfo, nil
 }
 
+// ClusterName returns the name stored with this collector's snapshots: the
+// configured name, else models.DefaultCluster. The kube-system UID is only
+// recorded alongside it, so snapshots stay under one name whether or not the
+// UID could be read, and under the name rows stored before clusters were
+// identified already carry.
+func (c *ClusterCollector) ClusterName() string {
+	if name := c.client.ClusterName(); name != "" {
+		return name
+	}
+	return models.DefaultCluster
+}
+
+// identify sets the cluster identity of a snapshot. The kube-system UID is
+// looked up once; if it cannot be read, the lookup is retried on the next
+// collection and the snapshot is stored without it.
+func (c *ClusterCollector) identify(ctx context.Context, info *models.ClusterInfo) {
+	c.mu.Lock()
+	if c.clusterUID == "" {
+		uid, err := c.client.ClusterUID(ctx)
+		if err != nil {
+			c.logger.WithError(err).Warn("Failed to read kube-system namespace UID for the cluster identity")
+		}
+		c.clusterUID = uid
+	}
+	info.ClusterUID = c.clusterUID
+	c.mu.Unlock()
+
+	info.Cluster = c.ClusterName()
+}
+
 // collectDeployments gathers deployment information
 func (c *ClusterCollector) collectDeployments(ctx context.Context) ([]models.DeploymentInfo, error) {
 	deploymentList, err := c.client.Clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
@@ -210,10 +246,14 @@ func (c *ClusterCollector) collectDeployments(ctx context.Context) ([]models.Dep
 		deployments = append(deployments, models.DeploymentInfo{
 			Name:            deploy.Name,
 			Namespace:       deploy.Namespace,
+			UID:             string(deploy.UID),
+			ResourceVersion: deploy.ResourceVersion,
+			Generation:      deploy.Generation,
 			CreatedTime:     deploy.CreationTimestamp.Time,
 			Replicas:        replicas,
 			ReadyReplicas:   deploy.Status.ReadyReplicas,
 			UpdatedReplicas: deploy.Status.UpdatedReplicas,
+			Images:          containerImages(deploy.Spec.Template.Spec.Containers),
 			Conditions:      deploy.Status.Conditions,
 			Labels:          deploy.Labels,
 			Annotations:     deploy.Annotations,
@@ -263,9 +303,19 @@ func (c *ClusterCollector) collectPods(ctx context.Context) ([]models.PodInfo, e
 			})
 		}
 
+		var volumeClaims []string
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				volumeClaims = append(volumeClaims, volume.PersistentVolumeClaim.ClaimName)
+			}
+		}
+
 		pods = append(pods, models.PodInfo{
 			Name:              pod.Name,
 			Namespace:         pod.Namespace,
+			UID:               string(pod.UID),
+			ResourceVersion:   pod.ResourceVersion,
+			Generation:        pod.Generation,
 			DeploymentName:    deploymentName,
 			CreatedTime:       pod.CreationTimestamp.Time,
 			Phase:             string(pod.Status.Phase),
@@ -281,6 +331,7 @@ func (c *ClusterCollector) collectPods(ctx context.Context) ([]models.PodInfo, e
 			Labels:            pod.Labels,
 			Annotations:       pod.Annotations,
 			ContainerStatuses: containerStatuses,
+			VolumeClaims:      volumeClaims,
 		})
 	}
 
@@ -333,6 +384,9 @@ func (c *ClusterCollector) collectNodes(ctx context.Context) ([]models.NodeInfo,
 
 		nodes = append(nodes, models.NodeInfo{
 			Name:               node.Name,
+			UID:                string(node.UID),
+			ResourceVersion:    node.ResourceVersion,
+			Generation:         node.Generation,
 			CreatedTime:        node.CreationTimestamp.Time,
 			Ready:              ready,
 			CPUCapacity:        cpuCapacity,
@@ -374,16 +428,19 @@ func (c *ClusterCollector) collectServices(ctx context.Context) ([]models.Servic
 		}
 
 		services = append(services, models.ServiceInfo{
-			Name:        svc.Name,
-			Namespace:   svc.Namespace,
-			CreatedTime: svc.CreationTimestamp.Time,
-			Type:        string(svc.Spec.Type),
-			ClusterIP:   svc.Spec.ClusterIP,
-			ExternalIPs: svc.Spec.ExternalIPs,
-			Ports:       ports,
-			Selector:    svc.Spec.Selector,
-			Labels:      svc.Labels,
-			Annotations: svc.Annotations,
+			Name:            svc.Name,
+			Namespace:       svc.Namespace,
+			UID:             string(svc.UID),
+			ResourceVersion: svc.ResourceVersion,
+			Generation:      svc.Generation,
+			CreatedTime:     svc.CreationTimestamp.Time,
+			Type:            string(svc.Spec.Type),
+			ClusterIP:       svc.Spec.ClusterIP,
+			ExternalIPs:     svc.Spec.ExternalIPs,
+			Ports:           ports,
+			Selector:        svc.Spec.Selector,
+			Labels:          svc.Labels,
+			Annotations:     svc.Annotations,
 		})
 	}
 
@@ -444,14 +501,17 @@ func (c *ClusterCollector) collectIngresses(ctx context.Context) ([]models.Ingre
 		}
 
 		ingresses = append(ingresses, models.IngressInfo{
-			Name:        ing.Name,
-			Namespace:   ing.Namespace,
-			CreatedTime: ing.CreationTimestamp.Time,
-			Hosts:       hosts,
-			Paths:       paths,
-			TLS:         tls,
-			Labels:      ing.Labels,
-			Annotations: ing.Annotations,
+			Name:            ing.Name,
+			Namespace:       ing.Namespace,
+			UID:             string(ing.UID),
+			ResourceVersion: ing.ResourceVersion,
+			Generation:      ing.Generation,
+			CreatedTime:     ing.CreationTimestamp.Time,
+			Hosts:           hosts,
+			Paths:           paths,
+			TLS:             tls,
+			Labels:          ing.Labels,
+			Annotations:     ing.Annotations,
 		})
 	}
 
@@ -468,13 +528,16 @@ func (c *ClusterCollector) collectConfigMaps(ctx context.Context) ([]models.Conf
 	var configMaps []models.ConfigMapInfo
 	for _, cm := range configMapList.Items {
 		configMaps = append(configMaps, models.ConfigMapInfo{
-			Name:        cm.Name,
-			Namespace:   cm.Namespace,
-			CreatedTime: cm.CreationTimestamp.Time,
-			Data:        cm.Data,
-			BinaryData:  cm.BinaryData,
-			Labels:      cm.Labels,
-			Annotations: cm.Annotations,
+			Name:            cm.Name,
+			Namespace:       cm.Namespace,
+			UID:             string(cm.UID),
+			ResourceVersion: cm.ResourceVersion,
+			Generation:      cm.Generation,
+			CreatedTime:     cm.CreationTimestamp.Time,
+			Data:            cm.Data,
+			BinaryData:      cm.BinaryData,
+			Labels:          cm.Labels,
+			Annotations:     cm.Annotations,
 		})
 	}
 
@@ -497,13 +560,16 @@ func (c *ClusterCollector) collectSecrets(ctx context.Context) ([]models.SecretI
 		}
 
 		secrets = append(secrets, models.SecretInfo{
-			Name:        secret.Name,
-			Namespace:   secret.Namespace,
-			CreatedTime: secret.CreationTimestamp.Time,
-			Type:        string(secret.Type),
-			DataKeys:    dataKeys,
-			Labels:      secret.Labels,
-			Annotations: secret.Annotations,
+			Name:            secret.Name,
+			Namespace:       secret.Namespace,
+			UID:             string(secret.UID),
+			ResourceVersion: secret.ResourceVersion,
+			Generation:      secret.Generation,
+			CreatedTime:     secret.CreationTimestamp.Time,
+			Type:            string(secret.Type),
+			DataKeys:        dataKeys,
+			Labels:          secret.Labels,
+			Annotations:     secret.Annotations,
 		})
 	}
 
@@ -558,18 +624,21 @@ func (c *ClusterCollector) collectPersistentVolumes(ctx context.Context) ([]mode
 		}
 
 		pvs = append(pvs, models.PersistentVolumeInfo{
-			Name:          pv.Name,
-			CreatedTime:   pv.CreationTimestamp.Time,
-			Capacity:      capacity,
-			AccessModes:   accessModes,
-			ReclaimPolicy: string(pv.Spec.PersistentVolumeReclaimPolicy),
-			StorageClass:  pv.Spec.StorageClassName,
-			VolumeMode:    volumeMode,
-			Status:        string(pv.Status.Phase),
-			ClaimRef:      claimRef,
-			VolumeSource:  volumeSource,
-			Labels:        pv.Labels,
-			Annotations:   pv.Annotations,
+			Name:            pv.Name,
+			UID:             string(pv.UID),
+			ResourceVersion: pv.ResourceVersion,
+			Generation:      pv.Generation,
+			CreatedTime:     pv.CreationTimestamp.Time,
+			Capacity:        capacity,
+			AccessModes:     accessModes,
+			ReclaimPolicy:   string(pv.Spec.PersistentVolumeReclaimPolicy),
+			StorageClass:    pv.Spec.StorageClassName,
+			VolumeMode:      volumeMode,
+			Status:          string(pv.Status.Phase),
+			ClaimRef:        claimRef,
+			VolumeSource:    volumeSource,
+			Labels:          pv.Labels,
+			Annotations:     pv.Annotations,
 		})
 	}
 
@@ -610,19 +679,31 @@ func (c *ClusterCollector) collectPersistentVolumeClaims(ctx context.Context) ([
 		}
 
 		pvcs = append(pvcs, models.PersistentVolumeClaimInfo{
-			Name:          pvc.Name,
-			Namespace:     pvc.Namespace,
-			CreatedTime:   pvc.CreationTimestamp.Time,
-			RequestedSize: requestedSize,
-			AccessModes:   accessModes,
-			StorageClass:  storageClass,
-			VolumeMode:    volumeMode,
-			Status:        string(pvc.Status.Phase),
-			VolumeName:    pvc.Spec.VolumeName,
-			Labels:        pvc.Labels,
-			Annotations:   pvc.Annotations,
+			Name:            pvc.Name,
+			Namespace:       pvc.Namespace,
+			UID:             string(pvc.UID),
+			ResourceVersion: pvc.ResourceVersion,
+			Generation:      pvc.Generation,
+			CreatedTime:     pvc.CreationTimestamp.Time,
+			RequestedSize:   requestedSize,
+			AccessModes:     accessModes,
+			StorageClass:    storageClass,
+			VolumeMode:      volumeMode,
+			Status:          string(pvc.Status.Phase),
+			VolumeName:      pvc.Spec.VolumeName,
+			Labels:          pvc.Labels,
+			Annotations:     pvc.Annotations,
 		})
 	}
 
 	return pvcs, nil
 }
+
+// containerImages returns the image of each container, in container order
+func containerImages(containers []corev1.Container) []string {
+	images := make([]string, 0, len(containers))
+	for _, container := range containers {
+		images = append(images, container.Image)
+	}
+	return images
+}
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Job is the collection of one cluster
+type Job struct {
+	Cluster  string
+	Interval time.Duration // zero collects once
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs the jobs of several clusters concurrently, each on its own
+// interval, so a slow or failing cluster never delays or stops the others
+type Scheduler struct {
+	jobs   []Job
+	logger *logrus.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler for jobs
+func NewScheduler(jobs []Job, logger *logrus.Logger) *Scheduler {
+	return &Scheduler{jobs: jobs, logger: logger}
+}
+
+// Scheduled reports whether any job repeats
+func (s *Scheduler) Scheduled() bool {
+	for _, job := range s.jobs {
+		if job.Interval > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RunOnce runs every job once, concurrently, and waits for all of them. The
+// returned error joins the failures of every cluster that failed.
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	if len(s.jobs) == 1 {
+		return s.jobs[0].Run(ctx)
+	}
+
+	errs := make([]error, len(s.jobs))
+	var wg sync.WaitGroup
+	for i, job := range s.jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := job.Run(ctx); err != nil {
+				errs[i] = fmt.Errorf("cluster %s: %w", job.Cluster, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Start runs every job immediately and then on its interval until Stop is
+// called or ctx is done. Failures are logged and the job runs again at its
+// next interval.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	for _, job := range s.jobs {
+		s.logger.WithFields(logrus.Fields{
+			"cluster":  job.Cluster,
+			"interval": job.Interval,
+		}).Info("Scheduling cluster collection")
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}()
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	run := func() {
+		if err := job.Run(ctx); err != nil && ctx.Err() == nil {
+			s.logger.WithError(err).WithField("cluster", job.Cluster).Error("Cluster collection failed")
+		}
+	}
+
+	run()
+	if job.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run()
+		}
+	}
+}
+
+// Stop cancels scheduled collections and waits for those in progress to
+// finish, or until ctx expires
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("cluster collections still running: %w", ctx.Err())
+	}
+}
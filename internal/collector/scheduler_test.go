@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func discardLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestSchedulerRunOnceIsolatesFailures(t *testing.T) {
+	var collected atomic.Int32
+	jobs := []Job{
+		{Cluster: "dev-a", Run: func(context.Context) error { collected.Add(1); return nil }},
+		{Cluster: "dev-b", Run: func(context.Context) error { return errors.New("connection refused") }},
+		{Cluster: "dev-c", Run: func(context.Context) error { collected.Add(1); return nil }},
+	}
+
+	err := NewScheduler(jobs, discardLogger()).RunOnce(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "cluster dev-b: connection refused") {
+		t.Errorf("expected the dev-b failure, got %v", err)
+	}
+	if collected.Load() != 2 {
+		t.Errorf("expected the other clusters to be collected, got %d", collected.Load())
+	}
+}
+
+func TestSchedulerIntervals(t *testing.T) {
+	var fast, once atomic.Int32
+	failing := make(chan struct{}, 10)
+	scheduler := NewScheduler([]Job{
+		{Cluster: "fast", Interval: 10 * time.Millisecond, Run: func(context.Context) error { fast.Add(1); return nil }},
+		{Cluster: "once", Run: func(context.Context) error { once.Add(1); return nil }},
+		{Cluster: "failing", Interval: 10 * time.Millisecond, Run: func(context.Context) error {
+			select {
+			case failing <- struct{}{}:
+			default:
+			}
+			return errors.New("unreachable")
+		}},
+	}, discardLogger())
+	if !scheduler.Scheduled() {
+		t.Fatal("expected a scheduled job")
+	}
+
+	scheduler.Start(context.Background())
+	time.Sleep(100 * time.Millisecond)
+	if err := scheduler.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	if fast.Load() < 3 {
+		t.Errorf("expected the fast cluster to be collected repeatedly, got %d", fast.Load())
+	}
+	if once.Load() != 1 {
+		t.Errorf("expected a cluster without interval to be collected once, got %d", once.Load())
+	}
+	if len(failing) < 2 {
+		t.Errorf("expected the failing cluster to be retried, got %d attempts", len(failing))
+	}
+}
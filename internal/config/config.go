@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
@@ -12,16 +13,30 @@ import (
 
 // Config holds application configuration
 type Config struct {
-	Database  DatabaseConfig
-	Logger    LoggerConfig
-	Kube      KubeConfig
-	Metrics   MetricsConfig
-	Retention RetentionConfig
-	API       APIConfig
-	Alerting  AlertingConfig
-	Streaming StreamingConfig
-	Kafka     KafkaConfig
-	Consumer  ConsumerConfig
+	Database   DatabaseConfig
+	Logger     LoggerConfig
+	Kube       KubeConfig
+	Collection CollectionConfig
+	Metrics    MetricsConfig
+	Retention  RetentionConfig
+	Archive    ArchiveConfig
+	Offline    OfflineConfig
+	API        APIConfig
+	Alerting   AlertingConfig
+	Streaming  StreamingConfig
+	Kafka      KafkaConfig
+	Consumer   ConsumerConfig
+	Auth       AuthConfig
+	Server     ServerConfig
+}
+
+// ServerConfig holds timeouts shared by every HTTP server
+type ServerConfig struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration // streaming and long-running responses lift this per request
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration // how long in-flight work may take on SIGTERM
 }
 
 // DatabaseConfig holds database connection configuration
@@ -42,29 +57,111 @@ type LoggerConfig struct {
 
 // KubeConfig holds Kubernetes configuration
 type KubeConfig struct {
-	ConfigPath string
+	ConfigPath  string
+	ConfigDir   string   // every kubeconfig file in this directory is a cluster
+	Contexts    []string // contexts to collect; "*" selects all, empty the current one
+	ClusterName string   // identifies the cluster in stored data; empty uses the kube-system namespace UID
+}
+
+// CollectionConfig holds collection scheduling configuration
+type CollectionConfig struct {
+	Interval         time.Duration            // zero collects once
+	ClusterIntervals map[string]time.Duration // per-cluster overrides of Interval
 }
 
 // MetricsConfig holds metrics configuration
 type MetricsConfig struct {
 	Enabled bool
 	Address string
+	TLS     TLSConfig
+}
+
+// TLSConfig holds TLS configuration for an HTTP listener. Certificate, key
+// and CA files are re-read when they change so rotation needs no restart.
+type TLSConfig struct {
+	Enabled        bool
+	CertFile       string
+	KeyFile        string
+	ClientCAFile   string        // CA bundle for verifying client certificates
+	ClientAuth     string        // none, request or require
+	ReloadInterval time.Duration // how often files are checked for changes
 }
 
 // RetentionConfig holds data retention configuration
 type RetentionConfig struct {
-	Enabled         bool
-	MaxAge          time.Duration
-	MaxSnapshots    int
-	CleanupInterval time.Duration
-	DeleteBatchSize int
+	Enabled              bool
+	MaxAge               time.Duration
+	MaxSnapshots         int
+	CleanupInterval      time.Duration
+	DeleteBatchSize      int
+	PreserveLatestPerDay bool
+	Tiers                []RetentionTier
+	DryRun               bool
+}
+
+// RetentionTier is one step of a downsampling retention policy
+type RetentionTier struct {
+	MaxAge   time.Duration // zero means forever
+	Interval time.Duration // zero means keep every snapshot
+}
+
+// ArchiveConfig holds snapshot archive configuration
+type ArchiveConfig struct {
+	Enabled     bool
+	Destination string // file or s3
+	Directory   string
+	S3          ArchiveS3Config
+}
+
+// ArchiveS3Config holds S3-compatible object storage configuration for archives
+type ArchiveS3Config struct {
+	Endpoint  string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// OfflineConfig holds offline mode configuration. In offline mode every
+// collection is written to a compressed file in Directory instead of Kafka or
+// the database.
+type OfflineConfig struct {
+	Enabled   bool
+	Directory string
 }
 
 // APIConfig holds REST API configuration
 type APIConfig struct {
-	Enabled bool
-	Address string
-	Prefix  string
+	Enabled            bool
+	Address            string
+	Prefix             string
+	CORSAllowedOrigins []string
+	TLS                TLSConfig
+	RateLimit          float64 // requests per second per client, 0 disables
+	RateLimitBurst     int
+	MaxBodyBytes       int64
+	CacheEnabled       bool
+	GraphQLMaxDepth    int
+}
+
+// AuthConfig holds API authentication and authorization configuration
+type AuthConfig struct {
+	Enabled              bool
+	TokensFile           string
+	TokenReview          bool
+	TokenReviewAudiences []string
+	OIDC                 OIDCConfig
+	PolicyFile           string
+}
+
+// OIDCConfig holds OIDC JWT validation configuration
+type OIDCConfig struct {
+	IssuerURL     string
+	JWKSURL       string
+	Audience      string
+	UsernameClaim string
+	GroupsClaim   string
 }
 
 // AlertingConfig holds alerting configuration
@@ -88,9 +185,37 @@ type KafkaConfig struct {
 	Enabled   bool
 	Brokers   []string
 	Topic     string
-	Partition int32
+	Partition int32 // negative partitions messages by cluster key; deprecated otherwise
+
+	// MessageMode is KafkaMessageModeSnapshot to send each snapshot as one
+	// message, or KafkaMessageModeChunked to send it as parts of at most
+	// ChunkSize objects followed by a completion marker
+	MessageMode string
+	ChunkSize   int
+	// AssemblyTimeout is how long the consumer keeps the parts of a chunked
+	// snapshot whose completion marker has not arrived
+	AssemblyTimeout time.Duration
+
+	// RetryMaxAttempts bounds how often the consumer tries to store a
+	// snapshot when the database fails transiently, waiting RetryBackoff
+	// after the first failure and twice as long after each further one, up
+	// to RetryMaxBackoff
+	RetryMaxAttempts int
+	RetryBackoff     time.Duration
+	RetryMaxBackoff  time.Duration
+
+	// DeadLetterEnabled sends messages the consumer cannot process to
+	// DeadLetterTopic instead of dropping them
+	DeadLetterEnabled bool
+	DeadLetterTopic   string
 }
 
+// Kafka message modes
+const (
+	KafkaMessageModeSnapshot = "snapshot"
+	KafkaMessageModeChunked  = "chunked"
+)
+
 // ConsumerConfig holds consumer HTTP server configuration
 type ConsumerConfig struct {
 	Server ConsumerServerConfig
@@ -101,6 +226,7 @@ type ConsumerServerConfig struct {
 	Enabled bool
 	Address string
 	Port    int
+	TLS     TLSConfig
 }
 
 // Load loads configuration from environment variables and .env file
@@ -160,6 +286,34 @@ func Load() (*Config, error) {
 		}
 	}
 
+	// Per-cluster collection intervals, e.g. "dev-a=15m,prod=1m"
+	var clusterIntervals map[string]time.Duration
+	if value := os.Getenv("COLLECTION_INTERVALS"); value != "" {
+		intervals, err := parseClusterIntervals(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid COLLECTION_INTERVALS: %w", err)
+		}
+		clusterIntervals = intervals
+	}
+
+	// Downsampling tiers, e.g. "2d:all,14d:1h,90d:1d,forever:1w"
+	var retentionTiers []RetentionTier
+	if value := os.Getenv("RETENTION_TIERS"); value != "" {
+		tiers, err := parseRetentionTiers(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RETENTION_TIERS: %w", err)
+		}
+		retentionTiers = tiers
+
+		// Tiers replace the default age and count limits unless those are set explicitly
+		if os.Getenv("RETENTION_MAX_AGE") == "" {
+			retentionMaxAge = 0
+		}
+		if os.Getenv("RETENTION_MAX_SNAPSHOTS") == "" {
+			retentionMaxSnapshots = 0
+		}
+	}
+
 	// Parse API configuration
 	apiEnabled := false
 	if value := os.Getenv("API_ENABLED"); value != "" {
@@ -205,13 +359,32 @@ func Load() (*Config, error) {
 		kafkaBrokers = strings.Split(strings.ReplaceAll(value, " ", ""), ",")
 	}
 
-	kafkaPartition := int32(0)
+	kafkaPartition := int32(-1) // Default: hash partitioning by cluster
 	if value := os.Getenv("KAFKA_PARTITION"); value != "" {
 		if parsedValue, err := strconv.ParseInt(value, 10, 32); err == nil {
 			kafkaPartition = int32(parsedValue)
 		}
 	}
 
+	kafkaMessageMode := getEnvOrDefault("KAFKA_MESSAGE_MODE", KafkaMessageModeSnapshot)
+	if kafkaMessageMode != KafkaMessageModeSnapshot && kafkaMessageMode != KafkaMessageModeChunked {
+		return nil, fmt.Errorf("invalid KAFKA_MESSAGE_MODE %q, expected %s or %s", kafkaMessageMode, KafkaMessageModeSnapshot, KafkaMessageModeChunked)
+	}
+
+	kafkaChunkSize := 500
+	if value := os.Getenv("KAFKA_CHUNK_SIZE"); value != "" {
+		if parsedValue, err := strconv.Atoi(value); err == nil && parsedValue > 0 {
+			kafkaChunkSize = parsedValue
+		}
+	}
+
+	kafkaRetryMaxAttempts := 5
+	if value := os.Getenv("KAFKA_RETRY_MAX_ATTEMPTS"); value != "" {
+		if parsedValue, err := strconv.Atoi(value); err == nil && parsedValue > 0 {
+			kafkaRetryMaxAttempts = parsedValue
+		}
+	}
+
 	// Parse consumer configuration
 	consumerServerEnabled := false
 	if value := os.Getenv("CONSUMER_SERVER_ENABLED"); value != "" {
@@ -227,6 +400,54 @@ func Load() (*Config, error) {
 		}
 	}
 
+	// API request limits
+	apiRateLimit := 20.0 // Default: 20 requests per second per client
+	if value := os.Getenv("API_RATE_LIMIT"); value != "" {
+		if parsedValue, err := strconv.ParseFloat(value, 64); err == nil && parsedValue >= 0 {
+			apiRateLimit = parsedValue
+		}
+	}
+
+	apiRateLimitBurst := 100
+	if value := os.Getenv("API_RATE_LIMIT_BURST"); value != "" {
+		if parsedValue, err := strconv.Atoi(value); err == nil {
+			apiRateLimitBurst = parsedValue
+		}
+	}
+
+	apiMaxBodyBytes := int64(1 << 20) // Default: 1 MiB
+	if value := os.Getenv("API_MAX_BODY_BYTES"); value != "" {
+		if parsedValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+			apiMaxBodyBytes = parsedValue
+		}
+	}
+
+	apiGraphQLMaxDepth := 10
+	if value := os.Getenv("API_GRAPHQL_MAX_DEPTH"); value != "" {
+		if parsedValue, err := strconv.Atoi(value); err == nil {
+			apiGraphQLMaxDepth = parsedValue
+		}
+	}
+
+	// TLS_* settings apply to every listener unless overridden with a
+	// listener prefix, e.g. API_TLS_CERT_FILE
+	tlsDefaults, err := loadTLSConfig("", TLSConfig{ReloadInterval: 30 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	apiTLS, err := loadTLSConfig("API_", tlsDefaults)
+	if err != nil {
+		return nil, err
+	}
+	metricsTLS, err := loadTLSConfig("METRICS_", tlsDefaults)
+	if err != nil {
+		return nil, err
+	}
+	consumerServerTLS, err := loadTLSConfig("CONSUMER_SERVER_", tlsDefaults)
+	if err != nil {
+		return nil, err
+	}
+
 	config := &Config{
 		Database: DatabaseConfig{
 			Host:     getEnvOrDefault("DB_HOST", "localhost"),
@@ -241,23 +462,58 @@ func Load() (*Config, error) {
 			Format: getEnvOrDefault("LOG_FORMAT", "json"), // json or text
 		},
 		Kube: KubeConfig{
-			ConfigPath: os.Getenv("KUBECONFIG"),
+			ConfigPath:  os.Getenv("KUBECONFIG"),
+			ConfigDir:   os.Getenv("KUBECONFIG_DIR"),
+			Contexts:    getEnvAsList("KUBE_CONTEXTS", nil),
+			ClusterName: os.Getenv("CLUSTER_NAME"),
+		},
+		Collection: CollectionConfig{
+			Interval:         getEnvAsDuration("COLLECTION_INTERVAL", 0),
+			ClusterIntervals: clusterIntervals,
 		},
 		Metrics: MetricsConfig{
 			Enabled: metricsEnabled,
 			Address: getEnvOrDefault("METRICS_ADDRESS", ":8080"),
+			TLS:     metricsTLS,
 		},
 		Retention: RetentionConfig{
-			Enabled:         retentionEnabled,
-			MaxAge:          retentionMaxAge,
-			MaxSnapshots:    retentionMaxSnapshots,
-			CleanupInterval: retentionCleanupInterval,
-			DeleteBatchSize: retentionDeleteBatchSize,
+			Enabled:              retentionEnabled,
+			MaxAge:               retentionMaxAge,
+			MaxSnapshots:         retentionMaxSnapshots,
+			CleanupInterval:      retentionCleanupInterval,
+			DeleteBatchSize:      retentionDeleteBatchSize,
+			PreserveLatestPerDay: getEnvAsBool("RETENTION_PRESERVE_LATEST_PER_DAY", false),
+			Tiers:                retentionTiers,
+			DryRun:               getEnvAsBool("RETENTION_DRY_RUN", false),
+		},
+		Archive: ArchiveConfig{
+			Enabled:     getEnvAsBool("ARCHIVE_ENABLED", false),
+			Destination: getEnvOrDefault("ARCHIVE_DESTINATION", "file"),
+			Directory:   getEnvOrDefault("ARCHIVE_DIRECTORY", "./archives"),
+			S3: ArchiveS3Config{
+				Endpoint:  os.Getenv("ARCHIVE_S3_ENDPOINT"),
+				Bucket:    getEnvOrDefault("ARCHIVE_S3_BUCKET", "cluster-info-archives"),
+				Prefix:    os.Getenv("ARCHIVE_S3_PREFIX"),
+				AccessKey: os.Getenv("ARCHIVE_S3_ACCESS_KEY"),
+				SecretKey: os.Getenv("ARCHIVE_S3_SECRET_KEY"),
+				UseSSL:    getEnvAsBool("ARCHIVE_S3_USE_SSL", true),
+			},
+		},
+		Offline: OfflineConfig{
+			Enabled:   getEnvAsBool("OFFLINE_ENABLED", false),
+			Directory: getEnvOrDefault("OFFLINE_DIRECTORY", "./offline"),
 		},
 		API: APIConfig{
-			Enabled: apiEnabled,
-			Address: getEnvOrDefault("API_ADDRESS", ":8081"),
-			Prefix:  getEnvOrDefault("API_PREFIX", "/api/v1"),
+			Enabled:            apiEnabled,
+			Address:            getEnvOrDefault("API_ADDRESS", ":8081"),
+			Prefix:             getEnvOrDefault("API_PREFIX", "/api/v1"),
+			CORSAllowedOrigins: getEnvAsList("API_CORS_ALLOWED_ORIGINS", nil),
+			TLS:                apiTLS,
+			RateLimit:          apiRateLimit,
+			RateLimitBurst:     apiRateLimitBurst,
+			MaxBodyBytes:       apiMaxBodyBytes,
+			CacheEnabled:       getEnvAsBool("API_CACHE_ENABLED", true),
+			GraphQLMaxDepth:    apiGraphQLMaxDepth,
 		},
 		Alerting: AlertingConfig{
 			Enabled:            alertingEnabled,
@@ -276,13 +532,46 @@ func Load() (*Config, error) {
 			Brokers:   kafkaBrokers,
 			Topic:     getEnvOrDefault("KAFKA_TOPIC", "cluster-info"),
 			Partition: kafkaPartition,
+
+			MessageMode:     kafkaMessageMode,
+			ChunkSize:       kafkaChunkSize,
+			AssemblyTimeout: getEnvAsDuration("KAFKA_ASSEMBLY_TIMEOUT", 10*time.Minute),
+
+			RetryMaxAttempts: kafkaRetryMaxAttempts,
+			RetryBackoff:     getEnvAsDuration("KAFKA_RETRY_BACKOFF", time.Second),
+			RetryMaxBackoff:  getEnvAsDuration("KAFKA_RETRY_MAX_BACKOFF", 30*time.Second),
+
+			DeadLetterEnabled: getEnvAsBool("KAFKA_DLQ_ENABLED", false),
+			DeadLetterTopic:   getEnvOrDefault("KAFKA_DLQ_TOPIC", "cluster-info-dlq"),
 		},
 		Consumer: ConsumerConfig{
 			Server: ConsumerServerConfig{
 				Enabled: consumerServerEnabled,
 				Address: getEnvOrDefault("CONSUMER_SERVER_ADDRESS", ""),
 				Port:    consumerServerPort,
+				TLS:     consumerServerTLS,
+			},
+		},
+		Server: ServerConfig{
+			ReadTimeout:       getEnvAsDuration("SERVER_READ_TIMEOUT", 15*time.Second),
+			ReadHeaderTimeout: getEnvAsDuration("SERVER_READ_HEADER_TIMEOUT", 5*time.Second),
+			WriteTimeout:      getEnvAsDuration("SERVER_WRITE_TIMEOUT", 60*time.Second),
+			IdleTimeout:       getEnvAsDuration("SERVER_IDLE_TIMEOUT", 120*time.Second),
+			ShutdownTimeout:   getEnvAsDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		},
+		Auth: AuthConfig{
+			Enabled:              getEnvAsBool("AUTH_ENABLED", false),
+			TokensFile:           os.Getenv("AUTH_TOKENS_FILE"),
+			TokenReview:          getEnvAsBool("AUTH_TOKEN_REVIEW", false),
+			TokenReviewAudiences: getEnvAsList("AUTH_TOKEN_REVIEW_AUDIENCES", nil),
+			OIDC: OIDCConfig{
+				IssuerURL:     os.Getenv("AUTH_OIDC_ISSUER_URL"),
+				JWKSURL:       os.Getenv("AUTH_OIDC_JWKS_URL"),
+				Audience:      os.Getenv("AUTH_OIDC_AUDIENCE"),
+				UsernameClaim: getEnvOrDefault("AUTH_OIDC_USERNAME_CLAIM", "sub"),
+				GroupsClaim:   getEnvOrDefault("AUTH_OIDC_GROUPS_CLAIM", "groups"),
 			},
+			PolicyFile: os.Getenv("AUTH_POLICY_FILE"),
 		},
 	}
 
@@ -306,3 +595,145 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// loadTLSConfig reads <prefix>TLS_* variables, falling back to defaults for
+// anything not set
+func loadTLSConfig(prefix string, defaults TLSConfig) (TLSConfig, error) {
+	tlsConfig := TLSConfig{
+		Enabled:        getEnvAsBool(prefix+"TLS_ENABLED", defaults.Enabled),
+		CertFile:       getEnvOrDefault(prefix+"TLS_CERT_FILE", defaults.CertFile),
+		KeyFile:        getEnvOrDefault(prefix+"TLS_KEY_FILE", defaults.KeyFile),
+		ClientCAFile:   getEnvOrDefault(prefix+"TLS_CLIENT_CA_FILE", defaults.ClientCAFile),
+		ClientAuth:     getEnvOrDefault(prefix+"TLS_CLIENT_AUTH", defaults.ClientAuth),
+		ReloadInterval: defaults.ReloadInterval,
+	}
+	if value := os.Getenv(prefix + "TLS_RELOAD_INTERVAL"); value != "" {
+		parsedValue, err := time.ParseDuration(value)
+		if err != nil || parsedValue <= 0 {
+			return TLSConfig{}, fmt.Errorf("invalid %sTLS_RELOAD_INTERVAL %q", prefix, value)
+		}
+		tlsConfig.ReloadInterval = parsedValue
+	}
+
+	switch tlsConfig.ClientAuth {
+	case "", "none", "request", "require":
+	default:
+		return TLSConfig{}, fmt.Errorf("invalid %sTLS_CLIENT_AUTH %q, expected none, request or require", prefix, tlsConfig.ClientAuth)
+	}
+	if tlsConfig.Enabled && (tlsConfig.CertFile == "" || tlsConfig.KeyFile == "") {
+		return TLSConfig{}, fmt.Errorf("%sTLS_ENABLED requires %sTLS_CERT_FILE and %sTLS_KEY_FILE", prefix, prefix, prefix)
+	}
+	if tlsConfig.ClientAuth != "" && tlsConfig.ClientAuth != "none" && tlsConfig.ClientCAFile == "" {
+		return TLSConfig{}, fmt.Errorf("%sTLS_CLIENT_AUTH %q requires %sTLS_CLIENT_CA_FILE", prefix, tlsConfig.ClientAuth, prefix)
+	}
+	return tlsConfig, nil
+}
+
+// getEnvAsDuration returns environment variable value as a duration or
+// default if not set
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsedValue, err := time.ParseDuration(value); err == nil {
+			return parsedValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsList returns a comma-separated environment variable as a list or
+// default if not set
+func getEnvAsList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseClusterIntervals parses a comma-separated list of "cluster=interval"
+// pairs
+func parseClusterIntervals(value string) (map[string]time.Duration, error) {
+	intervals := make(map[string]time.Duration)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		cluster, intervalStr, ok := strings.Cut(part, "=")
+		if !ok || strings.TrimSpace(cluster) == "" {
+			return nil, fmt.Errorf("entry %q must be cluster=interval", part)
+		}
+		interval, err := time.ParseDuration(strings.TrimSpace(intervalStr))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid interval %q for cluster %q", intervalStr, cluster)
+		}
+		intervals[strings.TrimSpace(cluster)] = interval
+	}
+	return intervals, nil
+}
+
+// parseRetentionTiers parses a comma-separated list of "max_age:interval"
+// pairs. max_age may be "forever" and interval may be "all"; durations accept
+// Go syntax plus "d" (days) and "w" (weeks) suffixes. Tiers must be listed in
+// increasing age order.
+func parseRetentionTiers(value string) ([]RetentionTier, error) {
+	var tiers []RetentionTier
+	var previous time.Duration
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		ageStr, intervalStr, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("tier %q must be max_age:interval", part)
+		}
+
+		var tier RetentionTier
+		if ageStr != "forever" {
+			age, err := parseRetentionDuration(ageStr)
+			if err != nil || age <= 0 {
+				return nil, fmt.Errorf("invalid max age %q", ageStr)
+			}
+			tier.MaxAge = age
+		}
+		if intervalStr != "all" {
+			interval, err := parseRetentionDuration(intervalStr)
+			if err != nil || interval <= 0 {
+				return nil, fmt.Errorf("invalid interval %q", intervalStr)
+			}
+			tier.Interval = interval
+		}
+
+		if len(tiers) > 0 && (previous == 0 || (tier.MaxAge != 0 && tier.MaxAge <= previous)) {
+			return nil, fmt.Errorf("tier %q must be older than the previous tier", part)
+		}
+		previous = tier.MaxAge
+		tiers = append(tiers, tier)
+	}
+
+	return tiers, nil
+}
+
+// parseRetentionDuration parses a duration with optional day and week units
+func parseRetentionDuration(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	return time.ParseDuration(value)
+}
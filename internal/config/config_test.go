@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -41,6 +42,13 @@ func TestLoad(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "invalid kafka message mode",
+			envVars: map[string]string{
+				"KAFKA_MESSAGE_MODE": "per-object",
+			},
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -155,3 +163,94 @@ func TestLogLevelParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestParseRetentionTiers(t *testing.T) {
+	tiers, err := parseRetentionTiers("2d:all,14d:1h,90d:1d,forever:1w")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []RetentionTier{
+		{MaxAge: 48 * time.Hour, Interval: 0},
+		{MaxAge: 14 * 24 * time.Hour, Interval: time.Hour},
+		{MaxAge: 90 * 24 * time.Hour, Interval: 24 * time.Hour},
+		{MaxAge: 0, Interval: 7 * 24 * time.Hour},
+	}
+	if len(tiers) != len(expected) {
+		t.Fatalf("expected %d tiers, got %d", len(expected), len(tiers))
+	}
+	for i := range expected {
+		if tiers[i] != expected[i] {
+			t.Errorf("tier %d: expected %+v, got %+v", i, expected[i], tiers[i])
+		}
+	}
+
+	invalid := []string{
+		"2d",                // missing interval
+		"2x:all",            // bad duration
+		"14d:1h,2d:all",     // not increasing
+		"forever:1w,90d:1d", // tier after forever
+	}
+	for _, value := range invalid {
+		if _, err := parseRetentionTiers(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestParseClusterIntervals(t *testing.T) {
+	intervals, err := parseClusterIntervals("dev-a=15m, prod=1m")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(intervals) != 2 || intervals["dev-a"] != 15*time.Minute || intervals["prod"] != time.Minute {
+		t.Errorf("unexpected intervals %v", intervals)
+	}
+
+	for _, value := range []string{"dev-a", "=15m", "dev-a=soon", "dev-a=0s"} {
+		if _, err := parseClusterIntervals(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	os.Setenv("TLS_CERT_FILE", "/certs/tls.crt")
+	os.Setenv("TLS_KEY_FILE", "/certs/tls.key")
+	os.Setenv("API_TLS_ENABLED", "true")
+	os.Setenv("API_TLS_CLIENT_CA_FILE", "/certs/ca.crt")
+	defer func() {
+		for _, key := range []string{"TLS_CERT_FILE", "TLS_KEY_FILE", "API_TLS_ENABLED", "API_TLS_CLIENT_CA_FILE"} {
+			os.Unsetenv(key)
+		}
+	}()
+
+	defaults, err := loadTLSConfig("", TLSConfig{ReloadInterval: 30 * time.Second})
+	if err != nil {
+		t.Fatalf("loadTLSConfig() error = %v", err)
+	}
+	apiTLS, err := loadTLSConfig("API_", defaults)
+	if err != nil {
+		t.Fatalf("loadTLSConfig(API_) error = %v", err)
+	}
+
+	expected := TLSConfig{
+		Enabled:        true,
+		CertFile:       "/certs/tls.crt",
+		KeyFile:        "/certs/tls.key",
+		ClientCAFile:   "/certs/ca.crt",
+		ReloadInterval: 30 * time.Second,
+	}
+	if apiTLS != expected {
+		t.Errorf("expected %+v, got %+v", expected, apiTLS)
+	}
+	if defaults.Enabled {
+		t.Error("listener override should not enable TLS globally")
+	}
+
+	os.Setenv("API_TLS_CLIENT_AUTH", "sometimes")
+	defer os.Unsetenv("API_TLS_CLIENT_AUTH")
+	if _, err := loadTLSConfig("API_", defaults); err == nil {
+		t.Error("expected error for invalid client auth mode")
+	}
+}
@@ -10,6 +10,9 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+	"k8s-cluster-info-collector/internal/tlsutil"
 )
 
 // Server represents the HTTP server for consumer health and metrics
@@ -27,9 +30,10 @@ type Server struct {
 
 // Config holds server configuration
 type Config struct {
-	Enabled bool   `json:"enabled"`
-	Address string `json:"address"`
-	Port    int    `json:"port"`
+	Enabled bool             `json:"enabled"`
+	Address string           `json:"address"`
+	Port    int              `json:"port"`
+	TLS     config.TLSConfig `json:"-"`
 }
 
 // HealthResponse represents the health check response
@@ -205,7 +209,7 @@ func (s *Server) Start() error {
 	s.logger.Infof("Starting consumer HTTP server on %s", s.httpServer.Addr)
 
 	go func() {
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := tlsutil.ListenAndServe(s.httpServer, s.config.TLS, s.logger); err != nil && err != http.ErrServerClosed {
 			s.logger.Errorf("Consumer HTTP server error: %v", err)
 		}
 	}()
@@ -8,6 +8,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"k8s-cluster-info-collector/internal/config"
+	"k8s-cluster-info-collector/internal/models"
 )
 
 // DB wraps the database connection with additional functionality
@@ -40,6 +41,11 @@ func New(cfg *config.DatabaseConfig, logger *logrus.Logger) (*DB, error) {
 		return nil, fmt.Errorf("failed to create tables: %w", err)
 	}
 
+	// Bring tables created by older versions up to date
+	if err := dbWrapper.migrateTables(); err != nil {
+		return nil, fmt.Errorf("failed to migrate tables: %w", err)
+	}
+
 	logger.Info("Database connection established successfully")
 	return dbWrapper, nil
 }
@@ -182,6 +188,37 @@ func (db *DB) createTables() error {
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
 
+	CREATE TABLE IF NOT EXISTS objects (
+		id SERIAL PRIMARY KEY,
+		cluster VARCHAR(255) NOT NULL DEFAULT 'default',
+		kind VARCHAR(50) NOT NULL,
+		uid VARCHAR(64) NOT NULL,
+		namespace VARCHAR(255),
+		name VARCHAR(255) NOT NULL,
+		created_time TIMESTAMP,
+		first_seen TIMESTAMP NOT NULL,
+		last_seen TIMESTAMP NOT NULL,
+		deleted_at TIMESTAMP,
+		first_snapshot_id INTEGER NOT NULL,
+		last_snapshot_id INTEGER NOT NULL,
+		UNIQUE (cluster, kind, uid)
+	);
+
+	CREATE TABLE IF NOT EXISTS clusters (
+		name VARCHAR(255) PRIMARY KEY,
+		uid VARCHAR(64),
+		first_seen TIMESTAMP NOT NULL,
+		last_seen TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS snapshot_archives (
+		id SERIAL PRIMARY KEY,
+		snapshot_id INTEGER NOT NULL,
+		snapshot_timestamp TIMESTAMP NOT NULL,
+		location TEXT NOT NULL,
+		archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
 	-- Create indexes for better query performance
 	CREATE INDEX IF NOT EXISTS idx_deployments_namespace ON deployments(namespace);
 	CREATE INDEX IF NOT EXISTS idx_deployments_name ON deployments(name);
@@ -210,6 +247,11 @@ func (db *DB) createTables() error {
 	CREATE INDEX IF NOT EXISTS idx_persistent_volume_claims_name ON persistent_volume_claims(name);
 	CREATE INDEX IF NOT EXISTS idx_persistent_volume_claims_snapshot ON persistent_volume_claims(snapshot_id);
 	CREATE INDEX IF NOT EXISTS idx_snapshots_timestamp ON cluster_snapshots(timestamp);
+	CREATE INDEX IF NOT EXISTS idx_objects_namespace ON objects(namespace);
+	CREATE INDEX IF NOT EXISTS idx_objects_created_time ON objects(created_time);
+	CREATE INDEX IF NOT EXISTS idx_objects_deleted_at ON objects(deleted_at);
+	CREATE INDEX IF NOT EXISTS idx_objects_last_snapshot ON objects(last_snapshot_id);
+	CREATE INDEX IF NOT EXISTS idx_snapshot_archives_timestamp ON snapshot_archives(snapshot_timestamp);
 	`
 
 	_, err := db.Exec(query)
@@ -221,6 +263,211 @@ func (db *DB) createTables() error {
 	return nil
 }
 
+// resourceTables lists the per-snapshot resource tables
+var resourceTables = []string{
+	"deployments",
+	"pods",
+	"nodes",
+	"services",
+	"ingresses",
+	"configmaps",
+	"secrets",
+	"persistent_volumes",
+	"persistent_volume_claims",
+}
+
+// migrateTables adds columns introduced after the initial schema. Every
+// statement must be idempotent since it runs on each startup.
+func (db *DB) migrateTables() error {
+	var statements []string
+
+	// Object identity columns
+	for _, table := range resourceTables {
+		statements = append(statements,
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS uid VARCHAR(64)", table),
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS resource_version VARCHAR(64)", table),
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS generation BIGINT", table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_uid ON %s(uid)", table, table),
+		)
+	}
+
+	// Composite indexes for per-object history lookups
+	for _, table := range resourceTables {
+		columns := "namespace, name, snapshot_id"
+		if table == "nodes" || table == "persistent_volumes" {
+			columns = "name, snapshot_id"
+		}
+		statements = append(statements,
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_history ON %s(%s)", table, table, columns))
+	}
+
+	// Indexes for created_time sorting and cursor pagination on list endpoints.
+	// The expression must match the created_time sort in the API.
+	for _, table := range resourceTables {
+		statements = append(statements,
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_created_time ON %s(snapshot_id, COALESCE(created_time, TIMESTAMP 'epoch'), id)", table, table))
+	}
+
+	// Numeric quantity columns (millicores and bytes) alongside the string values
+	statements = append(statements,
+		"ALTER TABLE pods ADD COLUMN IF NOT EXISTS cpu_request_millicores BIGINT",
+		"ALTER TABLE pods ADD COLUMN IF NOT EXISTS cpu_limit_millicores BIGINT",
+		"ALTER TABLE pods ADD COLUMN IF NOT EXISTS memory_request_bytes BIGINT",
+		"ALTER TABLE pods ADD COLUMN IF NOT EXISTS memory_limit_bytes BIGINT",
+		"ALTER TABLE pods ADD COLUMN IF NOT EXISTS storage_request_bytes BIGINT",
+		"ALTER TABLE nodes ADD COLUMN IF NOT EXISTS cpu_capacity_millicores BIGINT",
+		"ALTER TABLE nodes ADD COLUMN IF NOT EXISTS memory_capacity_bytes BIGINT",
+		"ALTER TABLE nodes ADD COLUMN IF NOT EXISTS storage_capacity_bytes BIGINT",
+		"ALTER TABLE nodes ADD COLUMN IF NOT EXISTS cpu_allocatable_millicores BIGINT",
+		"ALTER TABLE nodes ADD COLUMN IF NOT EXISTS memory_allocatable_bytes BIGINT",
+		"ALTER TABLE nodes ADD COLUMN IF NOT EXISTS storage_allocatable_bytes BIGINT",
+	)
+
+	// Cluster identity. Rows written before clusters were identified belong
+	// to the default cluster. The clusters table is filled from existing
+	// snapshots once, when it is still empty.
+	statements = append(statements,
+		"ALTER TABLE cluster_snapshots ADD COLUMN IF NOT EXISTS cluster VARCHAR(255) NOT NULL DEFAULT 'default'",
+		"CREATE INDEX IF NOT EXISTS idx_snapshots_cluster_timestamp ON cluster_snapshots(cluster, timestamp)",
+	)
+	for _, table := range resourceTables {
+		statements = append(statements,
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS cluster VARCHAR(255) NOT NULL DEFAULT 'default'", table))
+	}
+	statements = append(statements, `
+		INSERT INTO clusters (name, first_seen, last_seen)
+		SELECT cluster, MIN(timestamp), MAX(timestamp)
+		FROM cluster_snapshots
+		WHERE NOT EXISTS (SELECT 1 FROM clusters)
+		GROUP BY cluster
+		ON CONFLICT (name) DO NOTHING`)
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("failed to execute migration %q: %w", statement, err)
+		}
+	}
+
+	if err := db.migrateObjectIdentity(); err != nil {
+		return err
+	}
+
+	if err := db.backfillQuantities(); err != nil {
+		return err
+	}
+
+	db.logger.Info("Database migrations applied successfully")
+	return nil
+}
+
+// migrateObjectIdentity moves objects tables created before clusters were
+// tracked from the (kind, uid) key to (cluster, kind, uid). It runs once: the
+// old key constraint is dropped in the same transaction, and tables created
+// since never have it.
+func (db *DB) migrateObjectIdentity() error {
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM pg_constraint
+			WHERE conname = 'objects_kind_uid_key' AND conrelid = 'objects'::regclass
+		)`).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check objects key: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin objects key migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, statement := range []string{
+		"ALTER TABLE objects ADD COLUMN IF NOT EXISTS cluster VARCHAR(255) NOT NULL DEFAULT 'default'",
+		"ALTER TABLE objects DROP CONSTRAINT objects_kind_uid_key",
+		"ALTER TABLE objects ADD CONSTRAINT objects_cluster_kind_uid_key UNIQUE (cluster, kind, uid)",
+	} {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to execute migration %q: %w", statement, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit objects key migration: %w", err)
+	}
+	db.logger.Info("Migrated objects to per-cluster identity")
+	return nil
+}
+
+// quantityColumn pairs a quantity string column with its numeric column
+type quantityColumn struct {
+	table    string
+	quantity string
+	numeric  string
+	parse    func(string) (int64, bool)
+}
+
+// quantityColumns lists the numeric columns derived from quantity strings
+var quantityColumns = []quantityColumn{
+	{"pods", "cpu_request", "cpu_request_millicores", models.ParseMilliCores},
+	{"pods", "cpu_limit", "cpu_limit_millicores", models.ParseMilliCores},
+	{"pods", "memory_request", "memory_request_bytes", models.ParseBytes},
+	{"pods", "memory_limit", "memory_limit_bytes", models.ParseBytes},
+	{"pods", "storage_request", "storage_request_bytes", models.ParseBytes},
+	{"nodes", "cpu_capacity", "cpu_capacity_millicores", models.ParseMilliCores},
+	{"nodes", "memory_capacity", "memory_capacity_bytes", models.ParseBytes},
+	{"nodes", "storage_capacity", "storage_capacity_bytes", models.ParseBytes},
+	{"nodes", "cpu_allocatable", "cpu_allocatable_millicores", models.ParseMilliCores},
+	{"nodes", "memory_allocatable", "memory_allocatable_bytes", models.ParseBytes},
+	{"nodes", "storage_allocatable", "storage_allocatable_bytes", models.ParseBytes},
+}
+
+// backfillQuantities fills the numeric quantity columns of rows stored before
+// they existed. Rows are updated per distinct quantity string, so the work is
+// bounded by the number of distinct values rather than rows; strings that do
+// not parse stay NULL, as they would for a new row.
+func (db *DB) backfillQuantities() error {
+	for _, column := range quantityColumns {
+		rows, err := db.Query(fmt.Sprintf(
+			"SELECT DISTINCT %s FROM %s WHERE %s IS NULL AND %s IS NOT NULL AND %s <> ''",
+			column.quantity, column.table, column.numeric, column.quantity, column.quantity))
+		if err != nil {
+			return fmt.Errorf("failed to read %s.%s for backfill: %w", column.table, column.quantity, err)
+		}
+		var quantities []string
+		for rows.Next() {
+			var quantity string
+			if err := rows.Scan(&quantity); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan %s.%s: %w", column.table, column.quantity, err)
+			}
+			quantities = append(quantities, quantity)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read %s.%s for backfill: %w", column.table, column.quantity, err)
+		}
+
+		update := fmt.Sprintf("UPDATE %s SET %s = $1 WHERE %s = $2 AND %s IS NULL",
+			column.table, column.numeric, column.quantity, column.numeric)
+		for _, quantity := range quantities {
+			value, ok := column.parse(quantity)
+			if !ok {
+				continue
+			}
+			if _, err := db.Exec(update, value, quantity); err != nil {
+				return fmt.Errorf("failed to backfill %s.%s: %w", column.table, column.numeric, err)
+			}
+		}
+		if len(quantities) > 0 {
+			db.logger.WithField("column", column.table+"."+column.numeric).
+				WithField("values", len(quantities)).Info("Backfilled quantity column")
+		}
+	}
+	return nil
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
 	db.logger.Info("Closing database connection")
@@ -0,0 +1,274 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s-cluster-info-collector/internal/models"
+)
+
+// ignoredFields change on every write or on status heartbeats and would bury
+// the changes people actually care about
+var ignoredFields = map[string]bool{
+	"resource_version": true,
+	"generation":       true,
+	"conditions":       true,
+}
+
+// Change is a single field-level difference. Old or New is nil when the field
+// was added or removed.
+type Change struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// ObjectDiff describes an added, removed or modified object
+type ObjectDiff struct {
+	Namespace string   `json:"namespace,omitempty"`
+	Name      string   `json:"name"`
+	Changes   []Change `json:"changes,omitempty"`
+}
+
+// KindDiff groups the differences for one kind
+type KindDiff struct {
+	Kind     string       `json:"kind"`
+	Added    []ObjectDiff `json:"added"`
+	Removed  []ObjectDiff `json:"removed"`
+	Modified []ObjectDiff `json:"modified"`
+}
+
+// Empty reports whether nothing of this kind changed
+func (k KindDiff) Empty() bool {
+	return len(k.Added) == 0 && len(k.Removed) == 0 && len(k.Modified) == 0
+}
+
+// Result is the difference between two snapshots
+type Result struct {
+	From  models.SnapshotRef `json:"from"`
+	To    models.SnapshotRef `json:"to"`
+	Kinds []KindDiff         `json:"kinds"`
+}
+
+// Compare computes the differences from one snapshot to another. Objects are
+// matched by kind, namespace and name; a recreated object shows up as
+// modified with a changed uid.
+func Compare(from, to models.SnapshotRef, fromInfo, toInfo models.ClusterInfo) (Result, error) {
+	result := Result{From: from, To: to}
+
+	before := groupByKind(fromInfo.Objects())
+	after := groupByKind(toInfo.Objects())
+
+	for _, kind := range models.Kinds {
+		kindDiff := KindDiff{
+			Kind:     kind,
+			Added:    []ObjectDiff{},
+			Removed:  []ObjectDiff{},
+			Modified: []ObjectDiff{},
+		}
+		old, current := before[kind], after[kind]
+
+		for _, key := range sortedKeys(current) {
+			obj := current[key]
+			prev, ok := old[key]
+			if !ok {
+				kindDiff.Added = append(kindDiff.Added, ObjectDiff{Namespace: obj.Namespace, Name: obj.Name})
+				continue
+			}
+			changes, err := compareObjects(prev.Value, obj.Value)
+			if err != nil {
+				return result, fmt.Errorf("failed to compare %s %s: %w", kind, key, err)
+			}
+			if len(changes) > 0 {
+				kindDiff.Modified = append(kindDiff.Modified, ObjectDiff{Namespace: obj.Namespace, Name: obj.Name, Changes: changes})
+			}
+		}
+		for _, key := range sortedKeys(old) {
+			if _, ok := current[key]; !ok {
+				obj := old[key]
+				kindDiff.Removed = append(kindDiff.Removed, ObjectDiff{Namespace: obj.Namespace, Name: obj.Name})
+			}
+		}
+
+		result.Kinds = append(result.Kinds, kindDiff)
+	}
+
+	return result, nil
+}
+
+// groupByKind indexes objects by kind and then by namespace/name
+func groupByKind(objects []models.Object) map[string]map[string]models.Object {
+	grouped := make(map[string]map[string]models.Object)
+	for _, obj := range objects {
+		if grouped[obj.Kind] == nil {
+			grouped[obj.Kind] = make(map[string]models.Object)
+		}
+		grouped[obj.Kind][obj.Namespace+"/"+obj.Name] = obj
+	}
+	return grouped
+}
+
+func sortedKeys(objects map[string]models.Object) []string {
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// compareObjects returns the field-level changes between two *Info values
+func compareObjects(old, current interface{}) ([]Change, error) {
+	oldFields, err := flatten(old)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := flatten(current)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for path := range oldFields {
+		paths[path] = true
+	}
+	for path := range newFields {
+		paths[path] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	for _, path := range sorted {
+		oldValue, newValue := oldFields[path], newFields[path]
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, Change{Field: path, Old: oldValue, New: newValue})
+		}
+	}
+	return changes, nil
+}
+
+// flatten turns a value into a map of dotted field paths to leaf values.
+// Map keys become path segments, lists of objects with a name are keyed by
+// that name (container_statuses[nginx].image) and lists of scalars are
+// compared as a whole.
+func flatten(value interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{})
+	flattenInto(fields, "", generic)
+	return fields, nil
+}
+
+func flattenInto(fields map[string]interface{}, path string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if path == "" && ignoredFields[key] {
+				continue
+			}
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			flattenInto(fields, childPath, child)
+		}
+	case []interface{}:
+		if !isObjectList(v) {
+			if len(v) > 0 {
+				fields[path] = v
+			}
+			return
+		}
+		for i, child := range v {
+			segment := fmt.Sprintf("%d", i)
+			if name, ok := child.(map[string]interface{})["name"].(string); ok && name != "" {
+				segment = name
+			}
+			flattenInto(fields, fmt.Sprintf("%s[%s]", path, segment), child)
+		}
+	case nil:
+		// Absent and null are the same thing for diffing purposes
+	default:
+		fields[path] = v
+	}
+}
+
+func isObjectList(list []interface{}) bool {
+	for _, item := range list {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return len(list) > 0
+}
+
+// WriteText writes a human-readable summary of the diff, one line per object
+// and one indented line per changed field
+func (r Result) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Snapshot %d (%s) -> %d (%s)\n",
+		r.From.ID, r.From.Timestamp.UTC().Format(time.RFC3339),
+		r.To.ID, r.To.Timestamp.UTC().Format(time.RFC3339))
+
+	changed := false
+	for _, kind := range r.Kinds {
+		if kind.Empty() {
+			continue
+		}
+		changed = true
+		fmt.Fprintf(&b, "\n%s: %d added, %d removed, %d modified\n",
+			kind.Kind, len(kind.Added), len(kind.Removed), len(kind.Modified))
+		for _, obj := range kind.Added {
+			fmt.Fprintf(&b, "  + %s\n", obj.displayName())
+		}
+		for _, obj := range kind.Removed {
+			fmt.Fprintf(&b, "  - %s\n", obj.displayName())
+		}
+		for _, obj := range kind.Modified {
+			fmt.Fprintf(&b, "  ~ %s\n", obj.displayName())
+			for _, change := range obj.Changes {
+				fmt.Fprintf(&b, "      %s: %s -> %s\n", change.Field, formatValue(change.Old), formatValue(change.New))
+			}
+		}
+	}
+	if !changed {
+		b.WriteString("\nNo changes\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (o ObjectDiff) displayName() string {
+	if o.Namespace == "" {
+		return o.Name
+	}
+	return o.Namespace + "/" + o.Name
+}
+
+func formatValue(value interface{}) string {
+	if value == nil {
+		return "<none>"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
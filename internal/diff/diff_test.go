@@ -0,0 +1,119 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-cluster-info-collector/internal/models"
+)
+
+func TestCompare(t *testing.T) {
+	from := models.ClusterInfo{
+		Deployments: []models.DeploymentInfo{
+			{Name: "web", Namespace: "default", Replicas: 2, ResourceVersion: "1", Labels: map[string]string{"version": "v1"}},
+			{Name: "old", Namespace: "default", Replicas: 1},
+		},
+		Pods: []models.PodInfo{
+			{Name: "web-1", Namespace: "default", CPURequest: "100m", ContainerStatuses: []models.ContainerStatus{
+				{Name: "nginx", Image: "nginx:1.24"},
+			}},
+		},
+		Nodes: []models.NodeInfo{{Name: "node-1", Ready: true}},
+	}
+	to := models.ClusterInfo{
+		Deployments: []models.DeploymentInfo{
+			{Name: "web", Namespace: "default", Replicas: 3, ResourceVersion: "2", Labels: map[string]string{"version": "v2", "tier": "frontend"}},
+			{Name: "new", Namespace: "default", Replicas: 1},
+		},
+		Pods: []models.PodInfo{
+			{Name: "web-1", Namespace: "default", CPURequest: "200m", ContainerStatuses: []models.ContainerStatus{
+				{Name: "nginx", Image: "nginx:1.25"},
+			}},
+		},
+		Nodes: []models.NodeInfo{{Name: "node-1", Ready: true}},
+	}
+
+	result, err := Compare(models.SnapshotRef{ID: 1}, models.SnapshotRef{ID: 2}, from, to)
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+	if len(result.Kinds) != len(models.Kinds) {
+		t.Fatalf("expected %d kinds, got %d", len(models.Kinds), len(result.Kinds))
+	}
+
+	deployments := result.Kinds[0]
+	if len(deployments.Added) != 1 || deployments.Added[0].Name != "new" {
+		t.Errorf("expected deployment new to be added, got %+v", deployments.Added)
+	}
+	if len(deployments.Removed) != 1 || deployments.Removed[0].Name != "old" {
+		t.Errorf("expected deployment old to be removed, got %+v", deployments.Removed)
+	}
+	if len(deployments.Modified) != 1 {
+		t.Fatalf("expected one modified deployment, got %+v", deployments.Modified)
+	}
+	assertChanges(t, deployments.Modified[0].Changes, map[string][2]interface{}{
+		"labels.tier":    {nil, "frontend"},
+		"labels.version": {"v1", "v2"},
+		"replicas":       {float64(2), float64(3)},
+	})
+
+	pods := result.Kinds[1]
+	if len(pods.Modified) != 1 {
+		t.Fatalf("expected one modified pod, got %+v", pods.Modified)
+	}
+	assertChanges(t, pods.Modified[0].Changes, map[string][2]interface{}{
+		"container_statuses[nginx].image": {"nginx:1.24", "nginx:1.25"},
+		"cpu_request":                     {"100m", "200m"},
+	})
+
+	if !result.Kinds[2].Empty() {
+		t.Errorf("expected no node changes, got %+v", result.Kinds[2])
+	}
+}
+
+func assertChanges(t *testing.T, changes []Change, expected map[string][2]interface{}) {
+	t.Helper()
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %+v", len(expected), changes)
+	}
+	for _, change := range changes {
+		want, ok := expected[change.Field]
+		if !ok {
+			t.Errorf("unexpected change to %s", change.Field)
+			continue
+		}
+		if change.Old != want[0] || change.New != want[1] {
+			t.Errorf("%s: expected %v -> %v, got %v -> %v", change.Field, want[0], want[1], change.Old, change.New)
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	result, err := Compare(models.SnapshotRef{ID: 1, Timestamp: ts}, models.SnapshotRef{ID: 2, Timestamp: ts.Add(time.Hour)},
+		models.ClusterInfo{Nodes: []models.NodeInfo{{Name: "node-1", Ready: true}}},
+		models.ClusterInfo{Nodes: []models.NodeInfo{{Name: "node-1", Ready: false}}})
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+
+	var b strings.Builder
+	if err := result.WriteText(&b); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	text := b.String()
+	for _, want := range []string{
+		"Snapshot 1 (2024-01-01T09:00:00Z) -> 2 (2024-01-01T10:00:00Z)",
+		"Node: 0 added, 0 removed, 1 modified",
+		"  ~ node-1",
+		"      ready: true -> false",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "Pod:") {
+		t.Errorf("expected unchanged kinds to be omitted, got:\n%s", text)
+	}
+}
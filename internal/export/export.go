@@ -0,0 +1,255 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// ColumnType is the logical type of an exported column
+type ColumnType int
+
+const (
+	String ColumnType = iota
+	Int
+	Float
+	Bool
+	Time
+	JSON // Raw JSON, embedded as-is in NDJSON and as text elsewhere
+)
+
+// Column describes one exported column
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Formats lists the supported export formats
+var Formats = []string{"ndjson", "csv", "parquet"}
+
+// parquetRowGroupSize bounds how many rows are buffered before a parquet row
+// group is written out
+const parquetRowGroupSize = 10000
+
+// Writer encodes rows one at a time. Values must be in column order and may
+// be nil, int64, float64, bool, string, []byte or time.Time.
+type Writer interface {
+	WriteRow(values []interface{}) error
+	// Close flushes buffered data and writes any trailer; it does not close
+	// the underlying io.Writer
+	Close() error
+}
+
+// NewWriter returns a Writer for the given format
+func NewWriter(format string, w io.Writer, columns []Column) (Writer, error) {
+	switch format {
+	case "ndjson":
+		return &ndjsonWriter{encoder: json.NewEncoder(w), columns: columns}, nil
+	case "csv":
+		return newCSVWriter(w, columns)
+	case "parquet":
+		return newParquetWriter(w, columns), nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv"
+	case "parquet":
+		return "application/vnd.apache.parquet"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// ndjsonWriter writes one JSON object per line
+type ndjsonWriter struct {
+	encoder *json.Encoder
+	columns []Column
+}
+
+func (n *ndjsonWriter) WriteRow(values []interface{}) error {
+	row := make(map[string]interface{}, len(n.columns))
+	for i, column := range n.columns {
+		value := values[i]
+		if b, ok := value.([]byte); ok {
+			if column.Type == JSON {
+				value = json.RawMessage(b)
+			} else {
+				value = string(b)
+			}
+		}
+		row[column.Name] = value
+	}
+	return n.encoder.Encode(row)
+}
+
+func (n *ndjsonWriter) Close() error { return nil }
+
+// csvWriter writes a header line followed by one line per row
+type csvWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer, columns []Column) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvWriter{writer: writer, record: make([]string, len(columns))}, nil
+}
+
+func (c *csvWriter) WriteRow(values []interface{}) error {
+	for i, value := range values {
+		c.record[i] = formatText(value)
+	}
+	return c.writer.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// formatText renders a value for text formats
+func formatText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parquetWriter writes every column as an optional leaf. Row groups are
+// flushed every parquetRowGroupSize rows so memory use stays bounded.
+type parquetWriter struct {
+	writer  *parquet.Writer
+	columns []Column
+	// index maps the schema's (alphabetical) leaf order to column positions
+	index    []int
+	buffered int
+	row      parquet.Row
+}
+
+func newParquetWriter(w io.Writer, columns []Column) *parquetWriter {
+	group := make(parquet.Group, len(columns))
+	for _, column := range columns {
+		group[column.Name] = parquet.Optional(parquetNode(column.Type))
+	}
+	schema := parquet.NewSchema("row", group)
+
+	positions := make(map[string]int, len(columns))
+	for i, column := range columns {
+		positions[column.Name] = i
+	}
+	index := make([]int, 0, len(columns))
+	for _, field := range schema.Fields() {
+		index = append(index, positions[field.Name()])
+	}
+
+	return &parquetWriter{
+		writer:  parquet.NewWriter(w, schema),
+		columns: columns,
+		index:   index,
+		row:     make(parquet.Row, len(columns)),
+	}
+}
+
+func parquetNode(columnType ColumnType) parquet.Node {
+	switch columnType {
+	case Int:
+		return parquet.Leaf(parquet.Int64Type)
+	case Float:
+		return parquet.Leaf(parquet.DoubleType)
+	case Bool:
+		return parquet.Leaf(parquet.BooleanType)
+	case Time:
+		return parquet.Timestamp(parquet.Microsecond)
+	default:
+		return parquet.String()
+	}
+}
+
+func (p *parquetWriter) WriteRow(values []interface{}) error {
+	for leaf, position := range p.index {
+		value, err := parquetValue(p.columns[position].Type, values[position])
+		if err != nil {
+			return fmt.Errorf("column %s: %w", p.columns[position].Name, err)
+		}
+		definition := 1
+		if value.IsNull() {
+			definition = 0
+		}
+		p.row[leaf] = value.Level(0, definition, leaf)
+	}
+
+	if _, err := p.writer.WriteRows([]parquet.Row{p.row}); err != nil {
+		return err
+	}
+
+	p.buffered++
+	if p.buffered >= parquetRowGroupSize {
+		p.buffered = 0
+		return p.writer.Flush()
+	}
+	return nil
+}
+
+// parquetValue converts a database value to the physical type of its column
+func parquetValue(columnType ColumnType, value interface{}) (parquet.Value, error) {
+	if value == nil {
+		return parquet.Value{}, nil
+	}
+	switch columnType {
+	case Int:
+		if v, ok := value.(int64); ok {
+			return parquet.Int64Value(v), nil
+		}
+	case Float:
+		if v, ok := value.(float64); ok {
+			return parquet.DoubleValue(v), nil
+		}
+	case Bool:
+		if v, ok := value.(bool); ok {
+			return parquet.BooleanValue(v), nil
+		}
+	case Time:
+		if v, ok := value.(time.Time); ok {
+			return parquet.Int64Value(v.UnixMicro()), nil
+		}
+	default:
+		return parquet.ByteArrayValue([]byte(formatText(value))), nil
+	}
+	return parquet.Value{}, fmt.Errorf("unexpected value %T", value)
+}
+
+func (p *parquetWriter) Close() error {
+	return p.writer.Close()
+}
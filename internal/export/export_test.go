@@ -0,0 +1,103 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+var testColumns = []Column{
+	{Name: "name", Type: String},
+	{Name: "replicas", Type: Int},
+	{Name: "ready", Type: Bool},
+	{Name: "created_time", Type: Time},
+	{Name: "labels", Type: JSON},
+}
+
+var testCreated = time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+func writeTestRows(t *testing.T, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer, err := NewWriter(format, &buf, testColumns)
+	if err != nil {
+		t.Fatalf("NewWriter(%s) error = %v", format, err)
+	}
+	rows := [][]interface{}{
+		{"web", int64(3), true, testCreated, []byte(`{"app":"web"}`)},
+		{[]byte("db"), nil, false, testCreated, nil},
+	}
+	for _, row := range rows {
+		if err := writer.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(string(writeTestRows(t, "ndjson"))), "\n")
+	expected := []string{
+		`{"created_time":"2024-01-01T09:00:00Z","labels":{"app":"web"},"name":"web","ready":true,"replicas":3}`,
+		`{"created_time":"2024-01-01T09:00:00Z","labels":null,"name":"db","ready":false,"replicas":null}`,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %q", len(expected), lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d: expected %s, got %s", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	expected := "name,replicas,ready,created_time,labels\n" +
+		"web,3,true,2024-01-01T09:00:00Z,\"{\"\"app\"\":\"\"web\"\"}\"\n" +
+		"db,,false,2024-01-01T09:00:00Z,\n"
+	if got := string(writeTestRows(t, "csv")); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	type row struct {
+		Name        *string `parquet:"name,optional"`
+		Replicas    *int64  `parquet:"replicas,optional"`
+		Ready       *bool   `parquet:"ready,optional"`
+		CreatedTime *int64  `parquet:"created_time,optional"`
+		Labels      *string `parquet:"labels,optional"`
+	}
+
+	data := writeTestRows(t, "parquet")
+	rows, err := parquet.Read[row](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to read parquet output: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+
+	first, second := rows[0], rows[1]
+	if *first.Name != "web" || *first.Replicas != 3 || !*first.Ready || *first.Labels != `{"app":"web"}` {
+		t.Errorf("unexpected first row: %+v", first)
+	}
+	if *first.CreatedTime != testCreated.UnixMicro() {
+		t.Errorf("expected created_time %d, got %d", testCreated.UnixMicro(), *first.CreatedTime)
+	}
+	if *second.Name != "db" || second.Replicas != nil || *second.Ready || second.Labels != nil {
+		t.Errorf("unexpected second row: %+v", second)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("xml", &bytes.Buffer{}, testColumns); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,303 @@
+package kafka
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/models"
+)
+
+// Part is one message of a chunked snapshot: up to the chunk size objects of
+// one kind in one namespace
+type Part struct {
+	Kind      string          `json:"kind"`
+	Namespace string          `json:"namespace,omitempty"`
+	Items     json.RawMessage `json:"items"`
+}
+
+// Complete is the marker ending a chunked snapshot. It carries the snapshot
+// fields that are not objects and the number of parts sent before it.
+type Complete struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Cluster    string    `json:"cluster,omitempty"`
+	ClusterUID string    `json:"cluster_uid,omitempty"`
+	Parts      int       `json:"parts"`
+}
+
+// newSnapshotID returns a random ID tying the parts of a snapshot together
+func newSnapshotID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate snapshot ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// splitSnapshot splits the objects of a snapshot into parts of at most size
+// objects, each holding a single kind and namespace
+func splitSnapshot(info *models.ClusterInfo, size int) ([]Part, error) {
+	if size < 1 {
+		size = 1
+	}
+
+	objects := info.Objects()
+	var parts []Part
+	for start := 0; start < len(objects); {
+		first := objects[start]
+		end := start + 1
+		for end < len(objects) && end-start < size &&
+			objects[end].Kind == first.Kind && objects[end].Namespace == first.Namespace {
+			end++
+		}
+
+		values := make([]interface{}, 0, end-start)
+		for _, object := range objects[start:end] {
+			values = append(values, object.Value)
+		}
+		items, err := json.Marshal(values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s objects: %w", first.Kind, err)
+		}
+		parts = append(parts, Part{Kind: first.Kind, Namespace: first.Namespace, Items: items})
+		start = end
+	}
+	return parts, nil
+}
+
+// assembleSnapshot rebuilds a snapshot from its completion marker and its
+// parts in sequence order
+func assembleSnapshot(complete Complete, parts []Part) (*models.ClusterInfo, error) {
+	info := &models.ClusterInfo{
+		Timestamp:  complete.Timestamp,
+		Cluster:    complete.Cluster,
+		ClusterUID: complete.ClusterUID,
+	}
+	for i, part := range parts {
+		if err := appendItems(info, part); err != nil {
+			return nil, fmt.Errorf("part %d: %w", i, err)
+		}
+	}
+	return info, nil
+}
+
+// appendItems appends the objects of a part to the matching list of info
+func appendItems(info *models.ClusterInfo, part Part) error {
+	switch part.Kind {
+	case models.KindDeployment:
+		return appendJSON(&info.Deployments, part.Items)
+	case models.KindPod:
+		return appendJSON(&info.Pods, part.Items)
+	case models.KindNode:
+		return appendJSON(&info.Nodes, part.Items)
+	case models.KindService:
+		return appendJSON(&info.Services, part.Items)
+	case models.KindIngress:
+		return appendJSON(&info.Ingresses, part.Items)
+	case models.KindConfigMap:
+		return appendJSON(&info.ConfigMaps, part.Items)
+	case models.KindSecret:
+		return appendJSON(&info.Secrets, part.Items)
+	case models.KindPersistentVolume:
+		return appendJSON(&info.PersistentVolumes, part.Items)
+	case models.KindPersistentVolumeClaim:
+		return appendJSON(&info.PersistentVolumeClaims, part.Items)
+	}
+	return fmt.Errorf("unknown kind %q", part.Kind)
+}
+
+func appendJSON[T any](list *[]T, items json.RawMessage) error {
+	var decoded []T
+	if err := json.Unmarshal(items, &decoded); err != nil {
+		return fmt.Errorf("failed to unmarshal items: %w", err)
+	}
+	*list = append(*list, decoded...)
+	return nil
+}
+
+// errIncompleteSnapshot is returned for a completion marker whose parts have
+// not all arrived
+var errIncompleteSnapshot = errors.New("incomplete snapshot")
+
+// pendingSnapshot is a chunked snapshot whose completion marker has not
+// arrived yet
+type pendingSnapshot struct {
+	cluster     string
+	firstOffset int64     // offset of its first message on the partition
+	firstSeen   time.Time // timestamp of its first message
+	parts       map[int]Part
+	messages    map[int]*sarama.ConsumerMessage
+}
+
+// sortedMessages returns the messages of the parts received, in sequence order
+func (s *pendingSnapshot) sortedMessages() []*sarama.ConsumerMessage {
+	if s == nil {
+		return nil
+	}
+	sequences := make([]int, 0, len(s.messages))
+	for sequence := range s.messages {
+		sequences = append(sequences, sequence)
+	}
+	sort.Ints(sequences)
+	messages := make([]*sarama.ConsumerMessage, 0, len(sequences))
+	for _, sequence := range sequences {
+		messages = append(messages, s.messages[sequence])
+	}
+	return messages
+}
+
+// discardedSnapshot is an incomplete snapshot dropped by the assembler
+type discardedSnapshot struct {
+	messages []*sarama.ConsumerMessage
+	reason   string
+	err      error
+}
+
+// assembler reassembles the chunked snapshots of one partition. Parts are
+// held in memory until their completion marker arrives; the offset of the
+// oldest incomplete snapshot bounds the offset that may be committed, so
+// parts are read again after a restart instead of being lost.
+type assembler struct {
+	timeout   time.Duration
+	pending   map[string]*pendingSnapshot // by snapshot ID
+	discarded []discardedSnapshot
+	logger    *logrus.Logger
+}
+
+func newAssembler(timeout time.Duration, logger *logrus.Logger) *assembler {
+	return &assembler{
+		timeout: timeout,
+		pending: make(map[string]*pendingSnapshot),
+		logger:  logger,
+	}
+}
+
+// add adds a part or completion marker. It returns the snapshot once its
+// marker arrives and every part is present, and nil while parts are pending.
+// A marker whose parts are missing discards the snapshot with an error. The
+// returned messages are those the snapshot or the error was built from.
+func (a *assembler) add(message *sarama.ConsumerMessage) (*models.ClusterInfo, []*sarama.ConsumerMessage, error) {
+	messages := []*sarama.ConsumerMessage{message}
+	id := header(message, HeaderSnapshotID)
+	if id == "" {
+		return nil, messages, fmt.Errorf("%s message without %s header", messageType(message), HeaderSnapshotID)
+	}
+	cluster := messageCluster(message)
+	a.expire(id, cluster, message.Timestamp)
+
+	switch messageType(message) {
+	case MessageTypePart:
+		sequence, err := strconv.Atoi(header(message, HeaderSequence))
+		if err != nil || sequence < 0 {
+			return nil, messages, fmt.Errorf("snapshot %s: invalid %s header %q", id, HeaderSequence, header(message, HeaderSequence))
+		}
+		var part Part
+		if err := json.Unmarshal(message.Value, &part); err != nil {
+			return nil, messages, fmt.Errorf("snapshot %s: failed to unmarshal part %d: %w", id, sequence, err)
+		}
+
+		snapshot, ok := a.pending[id]
+		if !ok {
+			snapshot = &pendingSnapshot{
+				cluster:     cluster,
+				firstOffset: message.Offset,
+				firstSeen:   message.Timestamp,
+				parts:       make(map[int]Part),
+				messages:    make(map[int]*sarama.ConsumerMessage),
+			}
+			a.pending[id] = snapshot
+		}
+		// Redelivered parts simply replace themselves
+		snapshot.parts[sequence] = part
+		snapshot.messages[sequence] = message
+		return nil, nil, nil
+
+	case MessageTypeComplete:
+		snapshot := a.pending[id]
+		delete(a.pending, id)
+		messages = append(snapshot.sortedMessages(), message)
+
+		var complete Complete
+		if err := json.Unmarshal(message.Value, &complete); err != nil {
+			return nil, messages, fmt.Errorf("snapshot %s: failed to unmarshal completion marker: %w", id, err)
+		}
+
+		var received map[int]Part
+		if snapshot != nil {
+			received = snapshot.parts
+		}
+		parts := make([]Part, complete.Parts)
+		for sequence := range parts {
+			part, ok := received[sequence]
+			if !ok {
+				return nil, messages, fmt.Errorf("snapshot %s: %w: received %d of %d parts", id, errIncompleteSnapshot, len(received), complete.Parts)
+			}
+			parts[sequence] = part
+		}
+		info, err := assembleSnapshot(complete, parts)
+		return info, messages, err
+	}
+	return nil, messages, fmt.Errorf("unknown message type %q", messageType(message))
+}
+
+// expire drops incomplete snapshots that can no longer complete: those of
+// cluster superseded by the newer snapshot id, whose parts follow theirs on
+// the partition, and those older than the timeout at time now. Dropped
+// snapshots are kept for takeDiscarded.
+func (a *assembler) expire(id, cluster string, now time.Time) {
+	for pendingID, snapshot := range a.pending {
+		var reason string
+		var err error
+		switch {
+		case pendingID != id && snapshot.cluster == cluster:
+			reason = ReasonSuperseded
+			err = fmt.Errorf("snapshot %s: %w: superseded by snapshot %s", pendingID, errIncompleteSnapshot, id)
+		case a.timeout > 0 && !now.IsZero() && now.Sub(snapshot.firstSeen) > a.timeout:
+			reason = ReasonTimeout
+			err = fmt.Errorf("snapshot %s: %w: no completion marker within %s", pendingID, errIncompleteSnapshot, a.timeout)
+		default:
+			continue
+		}
+
+		a.logger.WithFields(logrus.Fields{
+			"snapshot_id": pendingID,
+			"cluster":     snapshot.cluster,
+			"parts":       len(snapshot.parts),
+			"reason":      reason,
+		}).Warn("Discarding incomplete chunked snapshot")
+		a.discarded = append(a.discarded, discardedSnapshot{
+			messages: snapshot.sortedMessages(),
+			reason:   reason,
+			err:      err,
+		})
+		delete(a.pending, pendingID)
+	}
+}
+
+// takeDiscarded returns the snapshots dropped since the last call
+func (a *assembler) takeDiscarded() []discardedSnapshot {
+	discarded := a.discarded
+	a.discarded = nil
+	return discarded
+}
+
+// commitOffset returns the offset that may be committed once the message
+// before next has been handled: next itself, or the first offset of the
+// oldest incomplete snapshot
+func (a *assembler) commitOffset(next int64) int64 {
+	offset := next
+	for _, snapshot := range a.pending {
+		if snapshot.firstOffset < offset {
+			offset = snapshot.firstOffset
+		}
+	}
+	return offset
+}
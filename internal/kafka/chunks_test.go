@@ -0,0 +1,200 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+	"k8s-cluster-info-collector/internal/models"
+)
+
+// consumed converts a produced message to the message a consumer reads
+func consumed(t *testing.T, msg *sarama.ProducerMessage, offset int64) *sarama.ConsumerMessage {
+	t.Helper()
+	key, _ := msg.Key.Encode()
+	value, err := msg.Value.Encode()
+	if err != nil {
+		t.Fatalf("failed to encode message: %v", err)
+	}
+	message := &sarama.ConsumerMessage{Key: key, Value: value, Offset: offset, Timestamp: msg.Timestamp}
+	for i := range msg.Headers {
+		message.Headers = append(message.Headers, &msg.Headers[i])
+	}
+	return message
+}
+
+func TestChunkedRoundTrip(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	info := &models.ClusterInfo{
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Cluster:   "prod",
+		Pods: []models.PodInfo{
+			{Name: "api-1", Namespace: "shop"},
+			{Name: "api-2", Namespace: "shop"},
+			{Name: "api-3", Namespace: "shop"},
+			{Name: "dns", Namespace: "kube-system"},
+		},
+		Nodes: []models.NodeInfo{{Name: "node-1"}},
+	}
+
+	var sent []*sarama.ProducerMessage
+	capture := func(msg *sarama.ProducerMessage) error {
+		sent = append(sent, msg)
+		return nil
+	}
+	mock := mocks.NewSyncProducer(t, nil)
+	// shop pods in two parts, kube-system pods, nodes, and the marker
+	for i := 0; i < 5; i++ {
+		mock.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(capture)
+	}
+
+	cfg := &config.KafkaConfig{Topic: "cluster-info", Partition: -1, MessageMode: config.KafkaMessageModeChunked, ChunkSize: 2}
+	producer := newProducer(mock, cfg, logger, "1.2.3")
+	if err := producer.SendClusterInfo(info); err != nil {
+		t.Fatalf("SendClusterInfo() error = %v", err)
+	}
+	if err := producer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	assembler := newAssembler(time.Hour, logger)
+	var assembled *models.ClusterInfo
+	for i, msg := range sent {
+		message := consumed(t, msg, int64(i))
+		if err := checkHeaders(message); err != nil {
+			t.Fatalf("message %d: checkHeaders() error = %v", i, err)
+		}
+		result, _, err := assembler.add(message)
+		if err != nil {
+			t.Fatalf("message %d: add() error = %v", i, err)
+		}
+		if result != nil && i != len(sent)-1 {
+			t.Fatalf("snapshot assembled before its completion marker, at message %d", i)
+		}
+		assembled = result
+	}
+	if assembled == nil {
+		t.Fatal("expected the snapshot to be assembled")
+	}
+
+	want, _ := json.Marshal(info)
+	got, _ := json.Marshal(assembled)
+	if string(got) != string(want) {
+		t.Errorf("assembled snapshot differs:\ngot  %s\nwant %s", got, want)
+	}
+	if len(assembler.pending) != 0 {
+		t.Errorf("expected no pending snapshots, got %d", len(assembler.pending))
+	}
+}
+
+func TestChunkedFailedPartsSkipMarker(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	mock := mocks.NewSyncProducer(t, nil)
+	mock.ExpectSendMessageAndFail(errors.New("broker down"))
+
+	cfg := &config.KafkaConfig{Topic: "cluster-info", Partition: -1, MessageMode: config.KafkaMessageModeChunked, ChunkSize: 10}
+	producer := newProducer(mock, cfg, logger, "1.2.3")
+	info := &models.ClusterInfo{Timestamp: time.Now(), Nodes: []models.NodeInfo{{Name: "node-1"}}}
+	if err := producer.SendClusterInfo(info); err == nil {
+		t.Error("expected the failed part to fail the send")
+	}
+	// Close fails if the marker had been sent without an expectation
+	if err := producer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestAssembler(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	message := func(offset int64, cluster, id, msgType, sequence string, value interface{}) *sarama.ConsumerMessage {
+		data, _ := json.Marshal(value)
+		message := &sarama.ConsumerMessage{Key: []byte(cluster), Value: data, Offset: offset, Timestamp: start.Add(time.Duration(offset) * time.Minute)}
+		for _, h := range [][2]string{{HeaderMessageType, msgType}, {HeaderSnapshotID, id}, {HeaderSequence, sequence}} {
+			message.Headers = append(message.Headers, &sarama.RecordHeader{Key: []byte(h[0]), Value: []byte(h[1])})
+		}
+		return message
+	}
+	node := func(name string) Part {
+		items, _ := json.Marshal([]models.NodeInfo{{Name: name}})
+		return Part{Kind: models.KindNode, Items: items}
+	}
+
+	assembler := newAssembler(30*time.Minute, logger)
+	add := func(m *sarama.ConsumerMessage) *models.ClusterInfo {
+		t.Helper()
+		info, _, err := assembler.add(m)
+		if err != nil {
+			t.Fatalf("offset %d: add() error = %v", m.Offset, err)
+		}
+		return info
+	}
+
+	// Interleaved snapshots of two clusters, parts out of order and redelivered
+	add(message(10, "dev-a", "a1", MessageTypePart, "1", node("a-2")))
+	add(message(11, "dev-b", "b1", MessageTypePart, "0", node("b-1")))
+	add(message(12, "dev-a", "a1", MessageTypePart, "0", node("a-1")))
+	add(message(13, "dev-a", "a1", MessageTypePart, "0", node("a-1")))
+	if got := assembler.commitOffset(14); got != 10 {
+		t.Errorf("commitOffset() = %d, want the first part of the oldest snapshot", got)
+	}
+
+	info := add(message(14, "dev-a", "a1", MessageTypeComplete, "", Complete{Cluster: "dev-a", Parts: 2}))
+	if info == nil || len(info.Nodes) != 2 || info.Nodes[0].Name != "a-1" || info.Nodes[1].Name != "a-2" {
+		t.Fatalf("expected dev-a's nodes in sequence order, got %+v", info)
+	}
+	if got := assembler.commitOffset(15); got != 11 {
+		t.Errorf("commitOffset() = %d, want dev-b's first part", got)
+	}
+
+	// A newer dev-b snapshot supersedes the one that never completed
+	add(message(15, "dev-b", "b2", MessageTypePart, "0", node("b-2")))
+	if got := assembler.commitOffset(16); got != 15 {
+		t.Errorf("commitOffset() = %d, want the superseding snapshot's first part", got)
+	}
+
+	discarded := assembler.takeDiscarded()
+	if len(discarded) != 1 || discarded[0].reason != ReasonSuperseded || len(discarded[0].messages) != 1 || discarded[0].messages[0].Offset != 11 {
+		t.Errorf("expected dev-b's first snapshot to be discarded as superseded, got %+v", discarded)
+	}
+
+	// A marker with missing parts discards the snapshot with the messages received
+	_, messages, err := assembler.add(message(16, "dev-b", "b2", MessageTypeComplete, "", Complete{Cluster: "dev-b", Parts: 2}))
+	if !errors.Is(err, errIncompleteSnapshot) {
+		t.Errorf("expected an incomplete snapshot to be rejected, got %v", err)
+	}
+	if len(messages) != 2 || messages[0].Offset != 15 || messages[1].Offset != 16 {
+		t.Errorf("expected the part and marker of the incomplete snapshot, got %d messages", len(messages))
+	}
+
+	// Parts whose marker never arrives time out
+	add(message(17, "dev-c", "c1", MessageTypePart, "0", node("c-1")))
+	add(message(60, "dev-d", "d1", MessageTypePart, "0", node("d-1")))
+	if got := assembler.commitOffset(61); got != 60 {
+		t.Errorf("commitOffset() = %d, want the timed out snapshot dropped", got)
+	}
+	if discarded := assembler.takeDiscarded(); len(discarded) != 1 || discarded[0].reason != ReasonTimeout {
+		t.Errorf("expected dev-c's snapshot to time out, got %+v", discarded)
+	}
+
+	if _, _, err := assembler.add(message(61, "dev-d", "", MessageTypePart, "1", node("d-2"))); err == nil {
+		t.Error("expected a part without snapshot ID to be rejected")
+	}
+	if !reflect.DeepEqual(assembler.pending["d1"].parts, map[int]Part{0: node("d-1")}) {
+		t.Errorf("unexpected pending parts %+v", assembler.pending["d1"].parts)
+	}
+}
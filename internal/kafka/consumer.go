@@ -3,6 +3,7 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -11,23 +12,53 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"k8s-cluster-info-collector/internal/config"
+	"k8s-cluster-info-collector/internal/metrics"
 	"k8s-cluster-info-collector/internal/models"
 	"k8s-cluster-info-collector/internal/store"
 )
 
-// Consumer handles consuming messages from Kafka
+// Consumer handles consuming messages from Kafka. Sarama runs ConsumeClaim
+// in its own goroutine for every assigned partition, so partitions are
+// processed concurrently while each partition, and with it each cluster, is
+// processed in order.
+//
+// Every message is either stored or, when it cannot be decoded or storing
+// it keeps failing, sent to the dead-letter topic before its offset is
+// marked, so no message is skipped silently. Without a dead-letter topic only
+// messages that can never be stored are dropped; transient store failures
+// are retried until they succeed or the session ends.
 type Consumer struct {
 	consumer sarama.ConsumerGroup
-	store    *store.Store
+	store    snapshotStore
 	topic    string
 	groupID  string
 	logger   *logrus.Logger
+	metrics  *metrics.Metrics
 	wg       sync.WaitGroup
 	cancel   context.CancelFunc
+
+	// assemblyTimeout bounds how long the parts of a chunked snapshot wait
+	// for their completion marker
+	assemblyTimeout time.Duration
+
+	retry       retryPolicy
+	deadLetters *DeadLetterQueue // nil drops failed messages
+
+	// clusterLocks holds a *sync.Mutex per cluster. A cluster's messages
+	// normally arrive on one partition, but can briefly span two after the
+	// topic gains partitions; the lock keeps their stores from interleaving.
+	clusterLocks sync.Map
+}
+
+// snapshotStore is the part of *store.Store the consumer uses
+type snapshotStore interface {
+	HasSnapshot(ctx context.Context, cluster string, timestamp time.Time) (bool, error)
+	StoreClusterInfo(info models.ClusterInfo) error
 }
 
-// NewConsumer creates a new Kafka consumer
-func NewConsumer(cfg *config.KafkaConfig, store *store.Store, logger *logrus.Logger) (*Consumer, error) {
+// NewConsumer creates a new Kafka consumer, and the dead-letter queue when
+// enabled; metrics may be nil
+func NewConsumer(cfg *config.KafkaConfig, store *store.Store, logger *logrus.Logger, metrics *metrics.Metrics) (*Consumer, error) {
 	if !cfg.Enabled {
 		return nil, fmt.Errorf("kafka is not enabled")
 	}
@@ -46,6 +77,15 @@ func NewConsumer(cfg *config.KafkaConfig, store *store.Store, logger *logrus.Log
 		return nil, fmt.Errorf("failed to create Kafka consumer group: %w", err)
 	}
 
+	var deadLetters *DeadLetterQueue
+	if cfg.DeadLetterEnabled {
+		deadLetters, err = NewDeadLetterQueue(cfg, logger, metrics)
+		if err != nil {
+			consumer.Close()
+			return nil, err
+		}
+	}
+
 	logger.WithFields(logrus.Fields{
 		"brokers": cfg.Brokers,
 		"topic":   cfg.Topic,
@@ -58,9 +98,24 @@ func NewConsumer(cfg *config.KafkaConfig, store *store.Store, logger *logrus.Log
 		topic:    cfg.Topic,
 		groupID:  groupID,
 		logger:   logger,
+		metrics:  metrics,
+
+		assemblyTimeout: cfg.AssemblyTimeout,
+
+		retry: retryPolicy{
+			maxAttempts:    cfg.RetryMaxAttempts,
+			initialBackoff: cfg.RetryBackoff,
+			maxBackoff:     cfg.RetryMaxBackoff,
+		},
+		deadLetters: deadLetters,
 	}, nil
 }
 
+// DeadLetters returns the dead-letter queue, or nil when it is disabled
+func (c *Consumer) DeadLetters() *DeadLetterQueue {
+	return c.deadLetters
+}
+
 // Start starts consuming messages from Kafka
 func (c *Consumer) Start(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
@@ -87,15 +142,37 @@ func (c *Consumer) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop stops the Kafka consumer
+// Stop stops the Kafka consumer, waiting for the message being processed
 func (c *Consumer) Stop() error {
+	return c.Shutdown(context.Background())
+}
+
+// Shutdown stops fetching new messages, waits until the message being
+// processed has been stored and marked (or ctx expires), and then closes the
+// consumer group, which commits the marked offsets
+func (c *Consumer) Shutdown(ctx context.Context) error {
 	c.logger.Info("Stopping Kafka consumer")
 
 	if c.cancel != nil {
 		c.cancel()
 	}
 
-	c.wg.Wait()
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		c.logger.Warn("Timed out waiting for in-flight Kafka messages; they will be redelivered")
+	}
+
+	if c.deadLetters != nil {
+		if err := c.deadLetters.Close(); err != nil {
+			c.logger.WithError(err).Warn("Error closing Kafka dead-letter queue")
+		}
+	}
 
 	if c.consumer != nil {
 		err := c.consumer.Close()
@@ -131,28 +208,39 @@ func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
 	return nil
 }
 
-// ConsumeClaim must start a consumer loop of ConsumerGroupClaim's Messages().
+// ConsumeClaim processes the messages of one partition in order. The parts
+// of chunked snapshots are reassembled per partition, and offsets are only
+// committed up to the first part of the oldest snapshot still incomplete.
 func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	assembler := newAssembler(c.assemblyTimeout, c.logger)
+
 	// Process messages
 	for {
+		// Do not start another message once shutdown has begun; select
+		// below would otherwise pick randomly between the two
+		if session.Context().Err() != nil {
+			return nil
+		}
+
 		select {
 		case message := <-claim.Messages():
 			if message == nil {
 				return nil
 			}
 
-			if err := c.processMessage(message); err != nil {
+			if err := c.handleMessage(session.Context(), assembler, message); err != nil {
+				// Only shutdown or a rebalance stops a message from being
+				// stored or dead-lettered; it stays unmarked for redelivery
 				c.logger.WithError(err).WithFields(logrus.Fields{
 					"topic":     message.Topic,
 					"partition": message.Partition,
 					"offset":    message.Offset,
-				}).Error("Failed to process message")
-				// Continue processing other messages instead of failing
-				continue
+				}).Warn("Stopped processing message before it was stored")
+				return nil
 			}
 
 			// Mark message as processed
-			session.MarkMessage(message, "")
+			session.MarkOffset(message.Topic, message.Partition, assembler.commitOffset(message.Offset+1), "")
 
 		case <-session.Context().Done():
 			return nil
@@ -160,20 +248,151 @@ func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim saram
 	}
 }
 
-// processMessage processes a single Kafka message
-func (c *Consumer) processMessage(message *sarama.ConsumerMessage) error {
+// handleMessage processes a message, retrying transient store failures, and
+// dead-letters it when that fails, along with any incomplete snapshot the
+// assembler dropped. It only returns an error when ctx ends first.
+func (c *Consumer) handleMessage(ctx context.Context, assembler *assembler, message *sarama.ConsumerMessage) error {
+	clusterInfo, messages, err := c.decodeMessage(assembler, message)
+	for _, discarded := range assembler.takeDiscarded() {
+		if err := c.deadLetter(ctx, discarded.messages, discarded.reason, discarded.err, 1); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		reason := ReasonInvalid
+		if errors.Is(err, errIncompleteSnapshot) {
+			reason = ReasonIncomplete
+		}
+		return c.deadLetter(ctx, messages, reason, err, 1)
+	}
+	if clusterInfo == nil {
+		return nil
+	}
+
+	attempts, err := c.storeWithRetry(ctx, *clusterInfo)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return c.deadLetter(ctx, messages, ReasonStore, err, attempts)
+	}
+	return nil
+}
+
+// decodeMessage decodes a single Kafka message, a whole snapshot or part of a
+// chunked one. It returns the snapshot once complete and the messages it
+// was built from.
+func (c *Consumer) decodeMessage(assembler *assembler, message *sarama.ConsumerMessage) (*models.ClusterInfo, []*sarama.ConsumerMessage, error) {
+	messages := []*sarama.ConsumerMessage{message}
+	cluster := messageCluster(message)
 	c.logger.WithFields(logrus.Fields{
 		"topic":     message.Topic,
 		"partition": message.Partition,
 		"offset":    message.Offset,
+		"cluster":   cluster,
+		"type":      messageType(message),
 		"timestamp": message.Timestamp,
 		"size":      len(message.Value),
 	}).Info("Processing message from Kafka")
 
+	if err := checkHeaders(message); err != nil {
+		return nil, messages, err
+	}
+
+	if messageType(message) != MessageTypeSnapshot {
+		return assembler.add(message)
+	}
+
 	// Deserialize cluster info from JSON
 	var clusterInfo models.ClusterInfo
 	if err := json.Unmarshal(message.Value, &clusterInfo); err != nil {
-		return fmt.Errorf("failed to unmarshal cluster info: %w", err)
+		return nil, messages, fmt.Errorf("failed to unmarshal cluster info: %w", err)
+	}
+	return &clusterInfo, messages, nil
+}
+
+// storeWithRetry stores a snapshot, retrying transient failures with
+// exponential backoff. It gives up after the configured attempts only when
+// there is a dead-letter topic to keep the snapshot; otherwise it retries
+// until ctx ends, leaving the message unmarked for redelivery. It returns
+// the number of attempts made.
+func (c *Consumer) storeWithRetry(ctx context.Context, clusterInfo models.ClusterInfo) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := c.storeClusterInfo(clusterInfo)
+		if err == nil || !isTransient(err) || (c.deadLetters != nil && attempt >= c.retry.maxAttempts) {
+			return attempt, err
+		}
+
+		c.logger.WithError(err).WithFields(logrus.Fields{
+			"cluster": clusterInfo.ClusterName(),
+			"attempt": attempt,
+			"backoff": c.retry.backoff(attempt),
+		}).Warn("Failed to store cluster info, retrying")
+		if c.metrics != nil {
+			c.metrics.RecordKafkaRetry()
+		}
+		if err := c.retry.wait(ctx, attempt); err != nil {
+			return attempt, err
+		}
+	}
+}
+
+// deadLetter sends messages that failed to the dead-letter topic, retrying
+// until it succeeds or ctx ends, since their offsets must not be marked
+// before. Without a dead-letter topic the messages are dropped; they can
+// never be stored, since transient failures are retried until they pass.
+func (c *Consumer) deadLetter(ctx context.Context, messages []*sarama.ConsumerMessage, reason string, cause error, attempts int) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	fields := logrus.Fields{
+		"topic":     messages[0].Topic,
+		"partition": messages[0].Partition,
+		"offset":    messages[0].Offset,
+		"messages":  len(messages),
+		"reason":    reason,
+		"attempts":  attempts,
+	}
+	if c.deadLetters == nil {
+		c.logger.WithError(cause).WithFields(fields).Error("Failed to process message, dropping it")
+		if c.metrics != nil {
+			c.metrics.RecordKafkaDroppedMessages(reason, len(messages))
+		}
+		return nil
+	}
+
+	c.logger.WithError(cause).WithFields(fields).Error("Failed to process message, sending it to the dead-letter topic")
+	for attempt := 1; ; attempt++ {
+		err := c.deadLetters.send(messages, reason, cause, attempts)
+		if err == nil {
+			return nil
+		}
+		c.logger.WithError(err).WithFields(fields).Error("Failed to dead-letter message, retrying")
+		if err := c.retry.wait(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// storeClusterInfo stores a snapshot unless it is already stored, as it is
+// when messages are read again after a restart. It does not use the session
+// context, so a snapshot being stored during shutdown is still stored.
+func (c *Consumer) storeClusterInfo(clusterInfo models.ClusterInfo) error {
+	lock := c.clusterLock(clusterInfo.ClusterName())
+	lock.Lock()
+	defer lock.Unlock()
+
+	exists, err := c.store.HasSnapshot(context.Background(), clusterInfo.ClusterName(), clusterInfo.Timestamp)
+	if err != nil {
+		return err
+	}
+	if exists {
+		c.logger.WithFields(logrus.Fields{
+			"cluster":   clusterInfo.ClusterName(),
+			"timestamp": clusterInfo.Timestamp,
+		}).Info("Snapshot already stored, skipping")
+		return nil
 	}
 
 	// Store cluster info in database
@@ -181,6 +400,15 @@ func (c *Consumer) processMessage(message *sarama.ConsumerMessage) error {
 		return fmt.Errorf("failed to store cluster info: %w", err)
 	}
 
-	c.logger.WithField("timestamp", clusterInfo.Timestamp).Info("Successfully stored cluster info from Kafka")
+	c.logger.WithFields(logrus.Fields{
+		"cluster":   clusterInfo.ClusterName(),
+		"timestamp": clusterInfo.Timestamp,
+	}).Info("Successfully stored cluster info from Kafka")
 	return nil
 }
+
+// clusterLock returns the lock serializing the stores of one cluster
+func (c *Consumer) clusterLock(cluster string) *sync.Mutex {
+	lock, _ := c.clusterLocks.LoadOrStore(cluster, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
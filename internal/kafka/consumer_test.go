@@ -0,0 +1,209 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+	"k8s-cluster-info-collector/internal/models"
+)
+
+// fakeStore fails StoreClusterInfo with each of failures in turn
+type fakeStore struct {
+	failures []error
+	attempts int
+	stored   []models.ClusterInfo
+}
+
+func (f *fakeStore) HasSnapshot(context.Context, string, time.Time) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeStore) StoreClusterInfo(info models.ClusterInfo) error {
+	f.attempts++
+	if len(f.failures) > 0 {
+		err := f.failures[0]
+		f.failures = f.failures[1:]
+		return err
+	}
+	f.stored = append(f.stored, info)
+	return nil
+}
+
+func newTestConsumer(t *testing.T, store *fakeStore, deadLetters sarama.SyncProducer) *Consumer {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &Consumer{
+		store:  store,
+		logger: logger,
+		retry:  retryPolicy{maxAttempts: 3, initialBackoff: time.Millisecond, maxBackoff: 2 * time.Millisecond},
+	}
+	if deadLetters != nil {
+		cfg := &config.KafkaConfig{Topic: "cluster-info", DeadLetterTopic: "cluster-info-dlq"}
+		c.deadLetters = newDeadLetterQueue(mocks.NewConsumer(t, nil), deadLetters, cfg, logger, nil)
+	}
+	return c
+}
+
+func snapshotMessage(value string) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{Topic: "cluster-info", Partition: 2, Offset: 41, Key: []byte("prod"), Value: []byte(value)}
+}
+
+// expectDeadLetter expects one message on the dead-letter topic with reason
+// and attempts headers
+func expectDeadLetter(producer *mocks.SyncProducer, reason, attempts string) {
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		message := &sarama.ConsumerMessage{}
+		for i := range msg.Headers {
+			message.Headers = append(message.Headers, &msg.Headers[i])
+		}
+		for name, want := range map[string]string{
+			HeaderDLQReason:    reason,
+			HeaderDLQAttempts:  attempts,
+			HeaderDLQTopic:     "cluster-info",
+			HeaderDLQPartition: "2",
+			HeaderDLQOffset:    "41",
+		} {
+			if got := header(message, name); got != want {
+				return errors.New("header " + name + " = " + got + ", want " + want)
+			}
+		}
+		if header(message, HeaderDLQError) == "" {
+			return errors.New("missing " + HeaderDLQError + " header")
+		}
+		if msg.Topic != "cluster-info-dlq" {
+			return errors.New("sent to " + msg.Topic)
+		}
+		return nil
+	})
+}
+
+func TestHandleMessageRetriesTransientErrors(t *testing.T) {
+	store := &fakeStore{failures: []error{errors.New("connection reset by peer"), errors.New("connection refused")}}
+	producer := mocks.NewSyncProducer(t, nil)
+	c := newTestConsumer(t, store, producer)
+
+	err := c.handleMessage(context.Background(), newAssembler(0, c.logger), snapshotMessage(`{"cluster":"prod"}`))
+	if err != nil {
+		t.Fatalf("handleMessage() error = %v", err)
+	}
+	if store.attempts != 3 || len(store.stored) != 1 {
+		t.Errorf("expected the snapshot stored on the third attempt, got %d attempts and %d stored", store.attempts, len(store.stored))
+	}
+	if err := producer.Close(); err != nil {
+		t.Errorf("expected nothing dead-lettered: %v", err)
+	}
+}
+
+func TestHandleMessageDeadLetters(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		failures []error
+		reason   string
+		attempts string
+	}{
+		{name: "invalid JSON", value: `{"cluster":`, reason: ReasonInvalid, attempts: "1"},
+		{
+			name:     "permanent store error",
+			value:    `{"cluster":"prod"}`,
+			failures: []error{&pq.Error{Code: "23505"}},
+			reason:   ReasonStore,
+			attempts: "1",
+		},
+		{
+			name:     "retries exhausted",
+			value:    `{"cluster":"prod"}`,
+			failures: []error{errors.New("timeout"), errors.New("timeout"), errors.New("timeout")},
+			reason:   ReasonStore,
+			attempts: "3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := mocks.NewSyncProducer(t, nil)
+			expectDeadLetter(producer, tt.reason, tt.attempts)
+			c := newTestConsumer(t, &fakeStore{failures: tt.failures}, producer)
+
+			if err := c.handleMessage(context.Background(), newAssembler(0, c.logger), snapshotMessage(tt.value)); err != nil {
+				t.Fatalf("handleMessage() error = %v", err)
+			}
+			if err := producer.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestHandleMessageKeepsMessageOnShutdown(t *testing.T) {
+	store := &fakeStore{failures: []error{errors.New("connection refused")}}
+	producer := mocks.NewSyncProducer(t, nil)
+	c := newTestConsumer(t, store, producer)
+	c.retry.initialBackoff = time.Hour
+	c.retry.maxBackoff = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.handleMessage(ctx, newAssembler(0, c.logger), snapshotMessage(`{"cluster":"prod"}`)); err == nil {
+		t.Error("expected an error so the message stays unmarked")
+	}
+	if err := producer.Close(); err != nil {
+		t.Errorf("expected nothing dead-lettered: %v", err)
+	}
+}
+
+func TestHandleMessageWithoutDeadLetterTopic(t *testing.T) {
+	c := newTestConsumer(t, &fakeStore{}, nil)
+	if err := c.handleMessage(context.Background(), newAssembler(0, c.logger), snapshotMessage("not json")); err != nil {
+		t.Errorf("expected the message to be dropped, got %v", err)
+	}
+
+	// Transient failures outlast the attempts of the retry policy
+	var failures []error
+	for i := 0; i < 2*c.retry.maxAttempts; i++ {
+		failures = append(failures, errors.New("connection refused"))
+	}
+	store := &fakeStore{failures: failures}
+	c = newTestConsumer(t, store, nil)
+	if err := c.handleMessage(context.Background(), newAssembler(0, c.logger), snapshotMessage(`{"cluster":"prod"}`)); err != nil {
+		t.Fatalf("handleMessage() error = %v", err)
+	}
+	if len(store.stored) != 1 {
+		t.Errorf("expected the snapshot to be stored once the database is back, got %d attempts", store.attempts)
+	}
+
+	// or until the session ends, which leaves the message for redelivery
+	for len(failures) < 1000 {
+		failures = append(failures, errors.New("connection refused"))
+	}
+	c = newTestConsumer(t, &fakeStore{failures: failures}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := c.handleMessage(ctx, newAssembler(0, c.logger), snapshotMessage(`{"cluster":"prod"}`)); err == nil {
+		t.Error("expected an error so the message stays unmarked")
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := retryPolicy{initialBackoff: time.Second, maxBackoff: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := policy.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	if isTransient(&pq.Error{Code: "22P02"}) {
+		t.Error("expected invalid data to be permanent")
+	}
+	if !isTransient(&pq.Error{Code: "40001"}) || !isTransient(errors.New("driver: bad connection")) {
+		t.Error("expected serialization and connection failures to be transient")
+	}
+}
@@ -0,0 +1,527 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+	"k8s-cluster-info-collector/internal/metrics"
+)
+
+// Headers added to dead-lettered messages next to their original headers
+const (
+	HeaderDLQReason    = "dlq-reason"
+	HeaderDLQError     = "dlq-error"
+	HeaderDLQAttempts  = "dlq-attempts"
+	HeaderDLQFailedAt  = "dlq-failed-at"
+	HeaderDLQTopic     = "dlq-original-topic"
+	HeaderDLQPartition = "dlq-original-partition"
+	HeaderDLQOffset    = "dlq-original-offset"
+)
+
+// Headers of the marker a replay writes to the dead-letter topic for every
+// message it replays, so that later replays skip the message
+const (
+	HeaderDLQReplayedPartition = "dlq-replayed-partition"
+	HeaderDLQReplayedOffset    = "dlq-replayed-offset"
+	HeaderDLQReplayedAt        = "dlq-replayed-at"
+)
+
+// Reasons a message is dead-lettered
+const (
+	ReasonInvalid    = "invalid"    // the message cannot be decoded
+	ReasonIncomplete = "incomplete" // parts of a chunked snapshot never arrived
+	ReasonSuperseded = "superseded" // a newer snapshot started before this one completed
+	ReasonTimeout    = "timeout"    // the completion marker did not arrive in time
+	ReasonStore      = "store"      // storing failed permanently or after every retry
+)
+
+// ErrDeadLetterNotFound is returned for an offset not on the dead-letter topic
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is a message on the dead-letter topic. Headers and Payload are
+// only filled in when a single message is read; Payload is the original
+// value, as a JSON string when it is not JSON itself. Replayed is only
+// filled in when listing.
+type DeadLetter struct {
+	Partition         int32             `json:"partition"`
+	Offset            int64             `json:"offset"`
+	Cluster           string            `json:"cluster"`
+	MessageType       string            `json:"message_type"`
+	SnapshotID        string            `json:"snapshot_id,omitempty"`
+	Reason            string            `json:"reason"`
+	Error             string            `json:"error"`
+	Attempts          int               `json:"attempts"`
+	FailedAt          time.Time         `json:"failed_at"`
+	OriginalTopic     string            `json:"original_topic"`
+	OriginalPartition int32             `json:"original_partition"`
+	OriginalOffset    int64             `json:"original_offset"`
+	Size              int               `json:"size"`
+	Replayed          bool              `json:"replayed"`
+	Headers           map[string]string `json:"headers,omitempty"`
+	Payload           json.RawMessage   `json:"payload,omitempty"`
+}
+
+// DeadLetterFilter selects dead letters; empty fields match every message
+type DeadLetterFilter struct {
+	Cluster    string
+	SnapshotID string
+	Reason     string
+}
+
+func (f DeadLetterFilter) matches(letter DeadLetter) bool {
+	return (f.Cluster == "" || letter.Cluster == f.Cluster) &&
+		(f.SnapshotID == "" || letter.SnapshotID == f.SnapshotID) &&
+		(f.Reason == "" || letter.Reason == f.Reason)
+}
+
+// DeadLetterQueue writes the messages the consumer cannot process to the
+// dead-letter topic, and lists and replays them. Listing and replaying read
+// the whole topic, which is expected to stay small. Replays are recorded on
+// the topic itself with a marker per replayed message.
+type DeadLetterQueue struct {
+	client   sarama.Client // nil in tests
+	consumer sarama.Consumer
+	producer sarama.SyncProducer
+	topic    string // the dead-letter topic
+	target   string // the topic replayed messages are sent to
+	metrics  *metrics.Metrics
+	logger   *logrus.Logger
+
+	// replayMutex keeps concurrent replays from sending a message twice
+	replayMutex sync.Mutex
+
+	// offsets returns the oldest and next offset of a partition
+	offsets func(topic string, partition int32) (oldest, newest int64, err error)
+}
+
+// NewDeadLetterQueue connects to the dead-letter topic of cfg; metrics may
+// be nil
+func NewDeadLetterQueue(cfg *config.KafkaConfig, logger *logrus.Logger, metrics *metrics.Metrics) (*DeadLetterQueue, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	saramaConfig.Producer.Retry.Max = 5
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Partitioner = sarama.NewHashPartitioner
+	// Retries must not reorder the messages of a chunked snapshot
+	saramaConfig.Net.MaxOpenRequests = 1
+	saramaConfig.Consumer.Return.Errors = true
+
+	client, err := sarama.NewClient(cfg.Brokers, saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client for dead-letter topic: %w", err)
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create dead-letter producer: %w", err)
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		producer.Close()
+		client.Close()
+		return nil, fmt.Errorf("failed to create dead-letter reader: %w", err)
+	}
+
+	logger.WithField("topic", cfg.DeadLetterTopic).Info("Kafka dead-letter queue initialized")
+
+	q := newDeadLetterQueue(consumer, producer, cfg, logger, metrics)
+	q.client = client
+	q.offsets = func(topic string, partition int32) (int64, int64, error) {
+		oldest, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return 0, 0, err
+		}
+		newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+		return oldest, newest, err
+	}
+	return q, nil
+}
+
+// newDeadLetterQueue wraps a Sarama consumer and producer configured as in
+// NewDeadLetterQueue
+func newDeadLetterQueue(consumer sarama.Consumer, producer sarama.SyncProducer, cfg *config.KafkaConfig, logger *logrus.Logger, metrics *metrics.Metrics) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		consumer: consumer,
+		producer: producer,
+		topic:    cfg.DeadLetterTopic,
+		target:   cfg.Topic,
+		metrics:  metrics,
+		logger:   logger,
+	}
+}
+
+// Topic returns the dead-letter topic
+func (q *DeadLetterQueue) Topic() string {
+	return q.topic
+}
+
+// send writes messages to the dead-letter topic with their original key,
+// value and headers, and headers recording why and where they failed
+func (q *DeadLetterQueue) send(messages []*sarama.ConsumerMessage, reason string, cause error, attempts int) error {
+	failedAt := time.Now().UTC().Format(time.RFC3339)
+	produced := make([]*sarama.ProducerMessage, 0, len(messages))
+	for _, message := range messages {
+		headers := append(originalHeaders(message),
+			recordHeader(HeaderDLQReason, reason),
+			recordHeader(HeaderDLQError, cause.Error()),
+			recordHeader(HeaderDLQAttempts, strconv.Itoa(attempts)),
+			recordHeader(HeaderDLQFailedAt, failedAt),
+			recordHeader(HeaderDLQTopic, message.Topic),
+			recordHeader(HeaderDLQPartition, strconv.Itoa(int(message.Partition))),
+			recordHeader(HeaderDLQOffset, strconv.FormatInt(message.Offset, 10)),
+		)
+		produced = append(produced, q.message(q.topic, message, headers))
+	}
+
+	if err := q.producer.SendMessages(produced); err != nil {
+		return fmt.Errorf("failed to send to dead-letter topic %s: %w", q.topic, err)
+	}
+	if q.metrics != nil {
+		q.metrics.RecordKafkaDeadLetters(reason, len(messages))
+	}
+	return nil
+}
+
+// List returns the dead letters matching filter, newest first, and how many
+// matched. A positive limit caps the number returned.
+func (q *DeadLetterQueue) List(ctx context.Context, filter DeadLetterFilter, limit int) ([]DeadLetter, int, error) {
+	messages, replayed, err := q.scanLetters(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	var letters []DeadLetter
+	for _, message := range messages {
+		if letter := decodeDeadLetter(message, false); filter.matches(letter) {
+			letter.Replayed = replayed[positionOf(message)]
+			letters = append(letters, letter)
+		}
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		if !letters[i].FailedAt.Equal(letters[j].FailedAt) {
+			return letters[i].FailedAt.After(letters[j].FailedAt)
+		}
+		if letters[i].Partition != letters[j].Partition {
+			return letters[i].Partition < letters[j].Partition
+		}
+		return letters[i].Offset > letters[j].Offset
+	})
+	total := len(letters)
+	if limit > 0 && len(letters) > limit {
+		letters = letters[:limit]
+	}
+	return letters, total, nil
+}
+
+// Get returns one dead letter with its headers and payload
+func (q *DeadLetterQueue) Get(ctx context.Context, partition int32, offset int64) (*DeadLetter, error) {
+	message, err := q.read(ctx, partition, offset)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := replayedPosition(message); ok {
+		return nil, ErrDeadLetterNotFound
+	}
+	letter := decodeDeadLetter(message, true)
+	return &letter, nil
+}
+
+// Replay sends the dead letters matching filter that were not replayed
+// before back to the consumed topic, in the order they failed, and returns
+// how many were sent. Replaying the messages of a chunked snapshot together
+// reassembles it; snapshots that were stored in the meantime are skipped by
+// the consumer. Messages that fail again are dead-lettered anew.
+func (q *DeadLetterQueue) Replay(ctx context.Context, filter DeadLetterFilter) (int, error) {
+	q.replayMutex.Lock()
+	defer q.replayMutex.Unlock()
+
+	letters, replayed, err := q.scanLetters(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var messages []*sarama.ConsumerMessage
+	for _, message := range letters {
+		if !replayed[positionOf(message)] && filter.matches(decodeDeadLetter(message, false)) {
+			messages = append(messages, message)
+		}
+	}
+	// scan reads one partition after another; the parts of a chunked
+	// snapshot share a partition and failure time, so a stable sort keeps
+	// them in order
+	sort.SliceStable(messages, func(i, j int) bool {
+		return header(messages[i], HeaderDLQFailedAt) < header(messages[j], HeaderDLQFailedAt)
+	})
+	return len(messages), q.replay(messages)
+}
+
+// ReplayMessage sends one dead letter back to the consumed topic, even when
+// it was replayed before
+func (q *DeadLetterQueue) ReplayMessage(ctx context.Context, partition int32, offset int64) error {
+	q.replayMutex.Lock()
+	defer q.replayMutex.Unlock()
+
+	message, err := q.read(ctx, partition, offset)
+	if err != nil {
+		return err
+	}
+	if _, ok := replayedPosition(message); ok {
+		return ErrDeadLetterNotFound
+	}
+	return q.replay([]*sarama.ConsumerMessage{message})
+}
+
+// replay sends messages back to the consumed topic and then marks them as
+// replayed on the dead-letter topic
+func (q *DeadLetterQueue) replay(messages []*sarama.ConsumerMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	produced := make([]*sarama.ProducerMessage, 0, len(messages))
+	markers := make([]*sarama.ProducerMessage, 0, len(messages))
+	replayedAt := time.Now().UTC().Format(time.RFC3339)
+	for _, message := range messages {
+		produced = append(produced, q.message(q.target, message, originalHeaders(message)))
+		markers = append(markers, &sarama.ProducerMessage{
+			Topic: q.topic,
+			Key:   sarama.ByteEncoder(message.Key),
+			Headers: []sarama.RecordHeader{
+				recordHeader(HeaderDLQReplayedPartition, strconv.Itoa(int(message.Partition))),
+				recordHeader(HeaderDLQReplayedOffset, strconv.FormatInt(message.Offset, 10)),
+				recordHeader(HeaderDLQReplayedAt, replayedAt),
+			},
+		})
+	}
+	if err := q.producer.SendMessages(produced); err != nil {
+		return fmt.Errorf("failed to replay dead letters to %s: %w", q.target, err)
+	}
+	if err := q.producer.SendMessages(markers); err != nil {
+		// The consumer skips snapshots already stored, so replaying these
+		// messages again is harmless
+		return fmt.Errorf("failed to mark replayed dead letters on %s: %w", q.topic, err)
+	}
+
+	if q.metrics != nil {
+		q.metrics.RecordKafkaDeadLetterReplays(len(messages))
+	}
+	q.logger.WithFields(logrus.Fields{
+		"topic":    q.target,
+		"messages": len(messages),
+	}).Info("Replayed dead-lettered messages")
+	return nil
+}
+
+// message copies a consumed message into a message for topic
+func (q *DeadLetterQueue) message(topic string, message *sarama.ConsumerMessage, headers []sarama.RecordHeader) *sarama.ProducerMessage {
+	produced := &sarama.ProducerMessage{
+		Topic:     topic,
+		Value:     sarama.ByteEncoder(message.Value),
+		Headers:   headers,
+		Timestamp: message.Timestamp,
+	}
+	if len(message.Key) > 0 {
+		produced.Key = sarama.ByteEncoder(message.Key)
+	}
+	return produced
+}
+
+// scanLetters reads the dead-letter topic and returns its dead letters, in
+// scan order, and the positions of those marked as replayed
+func (q *DeadLetterQueue) scanLetters(ctx context.Context) ([]*sarama.ConsumerMessage, map[deadLetterPosition]bool, error) {
+	var letters []*sarama.ConsumerMessage
+	replayed := make(map[deadLetterPosition]bool)
+	err := q.scan(ctx, func(message *sarama.ConsumerMessage) {
+		if position, ok := replayedPosition(message); ok {
+			replayed[position] = true
+		} else {
+			letters = append(letters, message)
+		}
+	})
+	return letters, replayed, err
+}
+
+// deadLetterPosition identifies a message on the dead-letter topic
+type deadLetterPosition struct {
+	partition int32
+	offset    int64
+}
+
+func positionOf(message *sarama.ConsumerMessage) deadLetterPosition {
+	return deadLetterPosition{message.Partition, message.Offset}
+}
+
+// replayedPosition returns the dead letter a replay marker refers to, and
+// false for messages that are not markers
+func replayedPosition(message *sarama.ConsumerMessage) (deadLetterPosition, bool) {
+	offset := header(message, HeaderDLQReplayedOffset)
+	if offset == "" {
+		return deadLetterPosition{}, false
+	}
+	partition, err := strconv.ParseInt(header(message, HeaderDLQReplayedPartition), 10, 32)
+	if err != nil {
+		return deadLetterPosition{}, false
+	}
+	parsed, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil {
+		return deadLetterPosition{}, false
+	}
+	return deadLetterPosition{int32(partition), parsed}, true
+}
+
+// scan calls fn for every message on the dead-letter topic, partition by
+// partition in offset order
+func (q *DeadLetterQueue) scan(ctx context.Context, fn func(*sarama.ConsumerMessage)) error {
+	partitions, err := q.consumer.Partitions(q.topic)
+	if err != nil {
+		return fmt.Errorf("failed to list partitions of %s: %w", q.topic, err)
+	}
+	for _, partition := range partitions {
+		oldest, newest, err := q.offsets(q.topic, partition)
+		if err != nil {
+			return fmt.Errorf("failed to get offsets of %s/%d: %w", q.topic, partition, err)
+		}
+		if err := q.consume(ctx, partition, oldest, newest, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read returns the message at offset of partition
+func (q *DeadLetterQueue) read(ctx context.Context, partition int32, offset int64) (*sarama.ConsumerMessage, error) {
+	partitions, err := q.consumer.Partitions(q.topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions of %s: %w", q.topic, err)
+	}
+	found := false
+	for _, p := range partitions {
+		found = found || p == partition
+	}
+	if !found {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	oldest, newest, err := q.offsets(q.topic, partition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offsets of %s/%d: %w", q.topic, partition, err)
+	}
+	if offset < oldest || offset >= newest {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	var message *sarama.ConsumerMessage
+	err = q.consume(ctx, partition, offset, offset+1, func(m *sarama.ConsumerMessage) {
+		if m.Offset == offset {
+			message = m
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if message == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	return message, nil
+}
+
+// consume calls fn for the messages of partition from offset up to end
+func (q *DeadLetterQueue) consume(ctx context.Context, partition int32, offset, end int64, fn func(*sarama.ConsumerMessage)) error {
+	if offset >= end {
+		return nil
+	}
+	partitionConsumer, err := q.consumer.ConsumePartition(q.topic, partition, offset)
+	if err != nil {
+		return fmt.Errorf("failed to read %s/%d: %w", q.topic, partition, err)
+	}
+	defer partitionConsumer.Close()
+
+	for offset < end {
+		select {
+		case message := <-partitionConsumer.Messages():
+			if message == nil {
+				return fmt.Errorf("reading %s/%d stopped at offset %d", q.topic, partition, offset)
+			}
+			fn(message)
+			offset = message.Offset + 1
+		case err := <-partitionConsumer.Errors():
+			return fmt.Errorf("failed to read %s/%d: %w", q.topic, partition, err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Close closes the dead-letter producer and reader
+func (q *DeadLetterQueue) Close() error {
+	err := errors.Join(q.producer.Close(), q.consumer.Close())
+	if q.client != nil {
+		err = errors.Join(err, q.client.Close())
+	}
+	return err
+}
+
+// decodeDeadLetter describes a message read from the dead-letter topic
+func decodeDeadLetter(message *sarama.ConsumerMessage, details bool) DeadLetter {
+	attempts, _ := strconv.Atoi(header(message, HeaderDLQAttempts))
+	failedAt, _ := time.Parse(time.RFC3339, header(message, HeaderDLQFailedAt))
+	originalPartition, _ := strconv.ParseInt(header(message, HeaderDLQPartition), 10, 32)
+	originalOffset, _ := strconv.ParseInt(header(message, HeaderDLQOffset), 10, 64)
+
+	letter := DeadLetter{
+		Partition:         message.Partition,
+		Offset:            message.Offset,
+		Cluster:           messageCluster(message),
+		MessageType:       messageType(message),
+		SnapshotID:        header(message, HeaderSnapshotID),
+		Reason:            header(message, HeaderDLQReason),
+		Error:             header(message, HeaderDLQError),
+		Attempts:          attempts,
+		FailedAt:          failedAt,
+		OriginalTopic:     header(message, HeaderDLQTopic),
+		OriginalPartition: int32(originalPartition),
+		OriginalOffset:    originalOffset,
+		Size:              len(message.Value),
+	}
+	if details {
+		letter.Headers = make(map[string]string, len(message.Headers))
+		for _, h := range message.Headers {
+			if h != nil {
+				letter.Headers[string(h.Key)] = string(h.Value)
+			}
+		}
+		if json.Valid(message.Value) {
+			letter.Payload = message.Value
+		} else {
+			letter.Payload, _ = json.Marshal(string(message.Value))
+		}
+	}
+	return letter
+}
+
+// originalHeaders returns the headers of message without those added when
+// it was dead-lettered
+func originalHeaders(message *sarama.ConsumerMessage) []sarama.RecordHeader {
+	var headers []sarama.RecordHeader
+	for _, h := range message.Headers {
+		if h != nil && !strings.HasPrefix(string(h.Key), "dlq-") {
+			headers = append(headers, *h)
+		}
+	}
+	return headers
+}
+
+func recordHeader(key, value string) sarama.RecordHeader {
+	return sarama.RecordHeader{Key: []byte(key), Value: []byte(value)}
+}
@@ -0,0 +1,220 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+)
+
+// deadLetterMessage builds a message as found on the dead-letter topic
+func deadLetterMessage(cluster, messageType, snapshotID, reason, failedAt, value string) *sarama.ConsumerMessage {
+	message := &sarama.ConsumerMessage{Key: []byte(cluster), Value: []byte(value)}
+	for _, h := range [][2]string{
+		{HeaderCluster, cluster},
+		{HeaderMessageType, messageType},
+		{HeaderSnapshotID, snapshotID},
+		{HeaderDLQReason, reason},
+		{HeaderDLQError, "failed"},
+		{HeaderDLQAttempts, "1"},
+		{HeaderDLQFailedAt, failedAt},
+		{HeaderDLQTopic, "cluster-info"},
+		{HeaderDLQPartition, "0"},
+		{HeaderDLQOffset, "7"},
+	} {
+		message.Headers = append(message.Headers, &sarama.RecordHeader{Key: []byte(h[0]), Value: []byte(h[1])})
+	}
+	return message
+}
+
+// newTestQueue returns a queue reading partitions from a mock consumer. Every
+// partition can be read once.
+func newTestQueue(t *testing.T, partitions map[int32][]*sarama.ConsumerMessage, producer sarama.SyncProducer) *DeadLetterQueue {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	consumer := mocks.NewConsumer(t, nil)
+	metadata := map[string][]int32{"cluster-info-dlq": nil}
+	for partition, messages := range partitions {
+		metadata["cluster-info-dlq"] = append(metadata["cluster-info-dlq"], partition)
+		expectation := consumer.ExpectConsumePartition("cluster-info-dlq", partition, 0)
+		for _, message := range messages {
+			message.Partition = partition
+			expectation.YieldMessage(message)
+		}
+	}
+	consumer.SetTopicMetadata(metadata)
+
+	cfg := &config.KafkaConfig{Topic: "cluster-info", DeadLetterTopic: "cluster-info-dlq"}
+	q := newDeadLetterQueue(consumer, producer, cfg, logger, nil)
+	q.offsets = func(topic string, partition int32) (int64, int64, error) {
+		return 0, int64(len(partitions[partition])), nil
+	}
+	return q
+}
+
+func testPartitions() map[int32][]*sarama.ConsumerMessage {
+	return map[int32][]*sarama.ConsumerMessage{
+		0: {
+			deadLetterMessage("dev-a", MessageTypePart, "s1", ReasonIncomplete, "2024-05-01T12:00:00Z", `{"kind":"Pod","items":[]}`),
+			deadLetterMessage("dev-a", MessageTypeComplete, "s1", ReasonIncomplete, "2024-05-01T12:00:00Z", `{"parts":2}`),
+		},
+		1: {
+			deadLetterMessage("prod", "", "", ReasonInvalid, "2024-05-01T13:00:00Z", `{"cluster":`),
+		},
+	}
+}
+
+func TestDeadLetterQueueList(t *testing.T) {
+	q := newTestQueue(t, testPartitions(), mocks.NewSyncProducer(t, nil))
+
+	letters, total, err := q.List(context.Background(), DeadLetterFilter{}, 2)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if total != 3 || len(letters) != 2 {
+		t.Fatalf("expected 2 of 3 dead letters, got %d of %d", len(letters), total)
+	}
+	if letters[0].Cluster != "prod" || letters[0].Reason != ReasonInvalid || letters[0].MessageType != MessageTypeSnapshot {
+		t.Errorf("expected the newest dead letter first, got %+v", letters[0])
+	}
+	if letters[1].Offset != 1 || letters[1].SnapshotID != "s1" || letters[1].OriginalOffset != 7 || letters[1].Attempts != 1 {
+		t.Errorf("unexpected second dead letter %+v", letters[1])
+	}
+	if letters[0].Payload != nil || letters[0].Headers != nil {
+		t.Error("expected listed dead letters without payload and headers")
+	}
+}
+
+func TestDeadLetterQueueGet(t *testing.T) {
+	q := newTestQueue(t, testPartitions(), mocks.NewSyncProducer(t, nil))
+
+	letter, err := q.Get(context.Background(), 1, 0)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if string(letter.Payload) != `"{\"cluster\":"` {
+		t.Errorf("expected an invalid payload as a JSON string, got %s", letter.Payload)
+	}
+	if letter.Headers[HeaderDLQReason] != ReasonInvalid {
+		t.Errorf("expected the headers, got %v", letter.Headers)
+	}
+
+	for _, position := range [][2]int64{{1, 1}, {5, 0}} {
+		if _, err := q.Get(context.Background(), int32(position[0]), position[1]); !errors.Is(err, ErrDeadLetterNotFound) {
+			t.Errorf("Get(%d, %d) error = %v, want ErrDeadLetterNotFound", position[0], position[1], err)
+		}
+	}
+}
+
+func TestDeadLetterQueueReplay(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	for sequence := 0; sequence < 2; sequence++ {
+		producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			if msg.Topic != "cluster-info" {
+				return errors.New("replayed to " + msg.Topic)
+			}
+			key, _ := msg.Key.Encode()
+			if string(key) != "dev-a" {
+				return errors.New("expected the original key, got " + string(key))
+			}
+			for _, h := range msg.Headers {
+				if strings.HasPrefix(string(h.Key), "dlq-") {
+					return errors.New("replayed with header " + string(h.Key))
+				}
+			}
+			consumed := &sarama.ConsumerMessage{}
+			for i := range msg.Headers {
+				consumed.Headers = append(consumed.Headers, &msg.Headers[i])
+			}
+			if want := []string{MessageTypePart, MessageTypeComplete}[sequence]; messageType(consumed) != want {
+				return errors.New("message " + strconv.Itoa(sequence) + " is a " + messageType(consumed))
+			}
+			return nil
+		})
+	}
+
+	for offset := 0; offset < 2; offset++ {
+		producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			if msg.Topic != "cluster-info-dlq" || msg.Value != nil {
+				return errors.New("expected a marker on the dead-letter topic, got a message to " + msg.Topic)
+			}
+			headers := map[string]string{}
+			for _, h := range msg.Headers {
+				headers[string(h.Key)] = string(h.Value)
+			}
+			if headers[HeaderDLQReplayedPartition] != "0" || headers[HeaderDLQReplayedOffset] != strconv.Itoa(offset) {
+				return errors.New("marker for the wrong dead letter")
+			}
+			return nil
+		})
+	}
+
+	q := newTestQueue(t, testPartitions(), producer)
+	replayed, err := q.Replay(context.Background(), DeadLetterFilter{SnapshotID: "s1"})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if replayed != 2 {
+		t.Errorf("expected both messages of the snapshot replayed, got %d", replayed)
+	}
+	if err := producer.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+// replayMarker builds the marker a replay of the dead letter at partition and
+// offset leaves on the dead-letter topic
+func replayMarker(partition int32, offset int64) *sarama.ConsumerMessage {
+	message := &sarama.ConsumerMessage{Key: []byte("prod")}
+	for _, h := range [][2]string{
+		{HeaderDLQReplayedPartition, strconv.Itoa(int(partition))},
+		{HeaderDLQReplayedOffset, strconv.FormatInt(offset, 10)},
+		{HeaderDLQReplayedAt, "2024-05-01T14:00:00Z"},
+	} {
+		message.Headers = append(message.Headers, &sarama.RecordHeader{Key: []byte(h[0]), Value: []byte(h[1])})
+	}
+	return message
+}
+
+func TestDeadLetterQueueListMarksReplayed(t *testing.T) {
+	partitions := testPartitions()
+	partitions[1] = append(partitions[1], replayMarker(1, 0))
+	q := newTestQueue(t, partitions, mocks.NewSyncProducer(t, nil))
+
+	letters, total, err := q.List(context.Background(), DeadLetterFilter{}, 0)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if total != 3 || len(letters) != 3 {
+		t.Fatalf("expected the markers left out of 3 dead letters, got %d of %d", len(letters), total)
+	}
+	for _, letter := range letters {
+		if replayed := letter.Partition == 1; letter.Replayed != replayed {
+			t.Errorf("dead letter %d/%d: expected replayed %v", letter.Partition, letter.Offset, replayed)
+		}
+	}
+}
+
+func TestDeadLetterQueueReplaySkipsReplayed(t *testing.T) {
+	partitions := testPartitions()
+	partitions[1] = append(partitions[1], replayMarker(0, 0), replayMarker(0, 1))
+	// Nothing is left to send, so the producer expects no messages
+	q := newTestQueue(t, partitions, mocks.NewSyncProducer(t, nil))
+
+	replayed, err := q.Replay(context.Background(), DeadLetterFilter{SnapshotID: "s1"})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if replayed != 0 {
+		t.Errorf("expected dead letters replayed before to be skipped, got %d replayed", replayed)
+	}
+}
@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/IBM/sarama"
+)
+
+// Headers set on every message by the producer
+const (
+	HeaderSchemaVersion    = "schema-version"
+	HeaderCluster          = "cluster"
+	HeaderCollectorVersion = "collector-version"
+	HeaderContentType      = "content-type"
+)
+
+// Headers of chunked snapshots, see KafkaMessageModeChunked. HeaderSequence
+// numbers the parts of a snapshot from 0.
+const (
+	HeaderMessageType = "message-type"
+	HeaderSnapshotID  = "snapshot-id"
+	HeaderSequence    = "sequence"
+)
+
+// Message types. Messages without a message-type header are snapshots.
+const (
+	MessageTypeSnapshot = "snapshot"
+	MessageTypePart     = "part"
+	MessageTypeComplete = "complete"
+)
+
+// SchemaVersion is the version of the message format. Consumers reject
+// messages of a newer major version instead of misreading them.
+const SchemaVersion = "1"
+
+// ContentTypeJSON is the content type of JSON-encoded ClusterInfo messages
+const ContentTypeJSON = "application/json"
+
+// header returns the value of a message header, or "" without it
+func header(message *sarama.ConsumerMessage, key string) string {
+	for _, h := range message.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// checkHeaders rejects messages this consumer cannot decode. Messages
+// without headers predate them and are read as schema version 1 JSON.
+func checkHeaders(message *sarama.ConsumerMessage) error {
+	if version := header(message, HeaderSchemaVersion); version != "" {
+		major, _, _ := strings.Cut(version, ".")
+		if major != SchemaVersion {
+			return fmt.Errorf("unsupported schema version %q", version)
+		}
+	}
+	if contentType := header(message, HeaderContentType); contentType != "" && contentType != ContentTypeJSON {
+		return fmt.Errorf("unsupported content type %q", contentType)
+	}
+	return nil
+}
+
+// messageType returns the type of a message, defaulting to a whole snapshot
+func messageType(message *sarama.ConsumerMessage) string {
+	if messageType := header(message, HeaderMessageType); messageType != "" {
+		return messageType
+	}
+	return MessageTypeSnapshot
+}
+
+// messageCluster returns the cluster a message belongs to, from its header
+// or, failing that, its key
+func messageCluster(message *sarama.ConsumerMessage) string {
+	if cluster := header(message, HeaderCluster); cluster != "" {
+		return cluster
+	}
+	return string(message.Key)
+}
@@ -3,24 +3,32 @@ package kafka
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/sirupsen/logrus"
 
-	"k8s-cluster-info-collector/internal/config"
+	appconfig "k8s-cluster-info-collector/internal/config"
 	"k8s-cluster-info-collector/internal/models"
 )
 
-// Producer handles sending messages to Kafka
+// Producer handles sending messages to Kafka. Messages are keyed by cluster,
+// so the hash partitioner spreads clusters across all partitions while the
+// snapshots of one cluster stay in order on one partition.
 type Producer struct {
 	producer  sarama.SyncProducer
 	topic     string
-	partition int32
+	partition int32 // pinned partition, or negative to partition by key
+	chunked   bool
+	chunkSize int
+	version   string
 	logger    *logrus.Logger
 }
 
-// NewProducer creates a new Kafka producer
-func NewProducer(cfg *config.KafkaConfig, logger *logrus.Logger) (*Producer, error) {
+// NewProducer creates a new Kafka producer. version is the collector version
+// sent in every message's headers.
+func NewProducer(cfg *appconfig.KafkaConfig, logger *logrus.Logger, version string) (*Producer, error) {
 	if !cfg.Enabled {
 		return nil, fmt.Errorf("kafka is not enabled")
 	}
@@ -31,6 +39,15 @@ func NewProducer(cfg *config.KafkaConfig, logger *logrus.Logger) (*Producer, err
 	config.Producer.Retry.Max = 5                    // Retry up to 5 times to produce the message
 	config.Producer.Return.Successes = true
 	config.Producer.Compression = sarama.CompressionSnappy
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+	if cfg.Partition >= 0 {
+		// Deprecated pinning to one partition, kept for existing deployments
+		config.Producer.Partitioner = sarama.NewManualPartitioner
+	}
+	if cfg.MessageMode == appconfig.KafkaMessageModeChunked {
+		// Retries must not reorder the parts of a snapshot and its marker
+		config.Net.MaxOpenRequests = 1
+	}
 
 	// Create producer
 	producer, err := sarama.NewSyncProducer(cfg.Brokers, config)
@@ -42,31 +59,41 @@ func NewProducer(cfg *config.KafkaConfig, logger *logrus.Logger) (*Producer, err
 		"brokers":   cfg.Brokers,
 		"topic":     cfg.Topic,
 		"partition": cfg.Partition,
+		"mode":      cfg.MessageMode,
 	}).Info("Kafka producer initialized")
 
+	return newProducer(producer, cfg, logger, version), nil
+}
+
+// newProducer wraps a Sarama producer configured as in NewProducer
+func newProducer(producer sarama.SyncProducer, cfg *appconfig.KafkaConfig, logger *logrus.Logger, version string) *Producer {
 	return &Producer{
 		producer:  producer,
 		topic:     cfg.Topic,
 		partition: cfg.Partition,
+		chunked:   cfg.MessageMode == appconfig.KafkaMessageModeChunked,
+		chunkSize: cfg.ChunkSize,
+		version:   version,
 		logger:    logger,
-	}, nil
+	}
 }
 
-// SendClusterInfo sends cluster information to Kafka
+// SendClusterInfo sends cluster information to Kafka, as one message or, in
+// chunked mode, as parts followed by a completion marker
 func (p *Producer) SendClusterInfo(clusterInfo *models.ClusterInfo) error {
+	if p.chunked {
+		return p.sendChunked(clusterInfo)
+	}
+
 	// Serialize cluster info to JSON
 	data, err := json.Marshal(clusterInfo)
 	if err != nil {
 		return fmt.Errorf("failed to marshal cluster info: %w", err)
 	}
 
-	// Create Kafka message
-	message := &sarama.ProducerMessage{
-		Topic:     p.topic,
-		Partition: p.partition,
-		Value:     sarama.ByteEncoder(data),
-		Timestamp: clusterInfo.Timestamp,
-	}
+	// Create Kafka message, keyed by cluster
+	cluster := clusterInfo.ClusterName()
+	message := p.message(cluster, data, clusterInfo.Timestamp)
 
 	// Send message
 	partition, offset, err := p.producer.SendMessage(message)
@@ -77,6 +104,7 @@ func (p *Producer) SendClusterInfo(clusterInfo *models.ClusterInfo) error {
 
 	p.logger.WithFields(logrus.Fields{
 		"topic":     p.topic,
+		"cluster":   cluster,
 		"partition": partition,
 		"offset":    offset,
 		"timestamp": clusterInfo.Timestamp,
@@ -86,6 +114,96 @@ func (p *Producer) SendClusterInfo(clusterInfo *models.ClusterInfo) error {
 	return nil
 }
 
+// sendChunked sends a snapshot as parts of at most chunkSize objects and,
+// once every part has been written, the completion marker. Without the
+// marker the consumer never stores the snapshot, so a failed send leaves no
+// partial snapshot behind.
+func (p *Producer) sendChunked(clusterInfo *models.ClusterInfo) error {
+	id, err := newSnapshotID()
+	if err != nil {
+		return err
+	}
+	parts, err := splitSnapshot(clusterInfo, p.chunkSize)
+	if err != nil {
+		return err
+	}
+
+	cluster := clusterInfo.ClusterName()
+	messages := make([]*sarama.ProducerMessage, 0, len(parts))
+	size := 0
+	for sequence, part := range parts {
+		data, err := json.Marshal(part)
+		if err != nil {
+			return fmt.Errorf("failed to marshal part %d: %w", sequence, err)
+		}
+		size += len(data)
+		messages = append(messages, p.message(cluster, data, clusterInfo.Timestamp,
+			sarama.RecordHeader{Key: []byte(HeaderMessageType), Value: []byte(MessageTypePart)},
+			sarama.RecordHeader{Key: []byte(HeaderSnapshotID), Value: []byte(id)},
+			sarama.RecordHeader{Key: []byte(HeaderSequence), Value: []byte(strconv.Itoa(sequence))},
+		))
+	}
+	if len(messages) > 0 {
+		if err := p.producer.SendMessages(messages); err != nil {
+			p.logger.WithError(err).WithField("snapshot_id", id).Error("Failed to send snapshot parts to Kafka")
+			return fmt.Errorf("failed to send snapshot parts to Kafka: %w", err)
+		}
+	}
+
+	data, err := json.Marshal(Complete{
+		Timestamp:  clusterInfo.Timestamp,
+		Cluster:    clusterInfo.Cluster,
+		ClusterUID: clusterInfo.ClusterUID,
+		Parts:      len(parts),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal completion marker: %w", err)
+	}
+	partition, offset, err := p.producer.SendMessage(p.message(cluster, data, clusterInfo.Timestamp,
+		sarama.RecordHeader{Key: []byte(HeaderMessageType), Value: []byte(MessageTypeComplete)},
+		sarama.RecordHeader{Key: []byte(HeaderSnapshotID), Value: []byte(id)},
+	))
+	if err != nil {
+		p.logger.WithError(err).WithField("snapshot_id", id).Error("Failed to send snapshot completion marker to Kafka")
+		return fmt.Errorf("failed to send snapshot completion marker to Kafka: %w", err)
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"topic":       p.topic,
+		"cluster":     cluster,
+		"snapshot_id": id,
+		"parts":       len(parts),
+		"partition":   partition,
+		"offset":      offset,
+		"timestamp":   clusterInfo.Timestamp,
+		"size":        size + len(data),
+	}).Info("Cluster info sent to Kafka in parts")
+
+	return nil
+}
+
+// message builds a message of cluster with the common headers and extra
+func (p *Producer) message(cluster string, data []byte, timestamp time.Time, extra ...sarama.RecordHeader) *sarama.ProducerMessage {
+	return &sarama.ProducerMessage{
+		Topic:     p.topic,
+		Partition: p.partition,
+		Key:       sarama.StringEncoder(cluster),
+		Value:     sarama.ByteEncoder(data),
+		Headers:   append(p.headers(cluster), extra...),
+		Timestamp: timestamp,
+	}
+}
+
+// headers returns the headers of a message of cluster
+func (p *Producer) headers(cluster string) []sarama.RecordHeader {
+	return []sarama.RecordHeader{
+		{Key: []byte(HeaderSchemaVersion), Value: []byte(SchemaVersion)},
+		{Key: []byte(HeaderCluster), Value: []byte(cluster)},
+		{Key: []byte(HeaderCollectorVersion), Value: []byte(p.version)},
+		{Key: []byte(HeaderContentType), Value: []byte(ContentTypeJSON)},
+	}
+}
+
 // Close closes the Kafka producer
 func (p *Producer) Close() error {
 	if p.producer != nil {
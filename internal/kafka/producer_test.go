@@ -0,0 +1,84 @@
+package kafka
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+	"k8s-cluster-info-collector/internal/models"
+)
+
+func TestSendClusterInfoKeysAndHeaders(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	mock := mocks.NewSyncProducer(t, nil)
+	mock.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		key, _ := msg.Key.Encode()
+		if string(key) != "prod" {
+			return errors.New("expected the message to be keyed by cluster, got " + string(key))
+		}
+		consumed := &sarama.ConsumerMessage{Key: key}
+		for i := range msg.Headers {
+			consumed.Headers = append(consumed.Headers, &msg.Headers[i])
+		}
+		for name, want := range map[string]string{
+			HeaderSchemaVersion:    SchemaVersion,
+			HeaderCluster:          "prod",
+			HeaderCollectorVersion: "1.2.3",
+			HeaderContentType:      ContentTypeJSON,
+		} {
+			if got := header(consumed, name); got != want {
+				return errors.New("header " + name + " = " + got + ", want " + want)
+			}
+		}
+		return checkHeaders(consumed)
+	})
+
+	producer := newProducer(mock, &config.KafkaConfig{Topic: "cluster-info", Partition: -1}, logger, "1.2.3")
+	err := producer.SendClusterInfo(&models.ClusterInfo{Timestamp: time.Now(), Cluster: "prod"})
+	if err != nil {
+		t.Fatalf("SendClusterInfo() error = %v", err)
+	}
+	if err := producer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestCheckHeaders(t *testing.T) {
+	withHeaders := func(pairs ...string) *sarama.ConsumerMessage {
+		message := &sarama.ConsumerMessage{Key: []byte("dev-a")}
+		for i := 0; i < len(pairs); i += 2 {
+			message.Headers = append(message.Headers, &sarama.RecordHeader{Key: []byte(pairs[i]), Value: []byte(pairs[i+1])})
+		}
+		return message
+	}
+
+	tests := []struct {
+		message *sarama.ConsumerMessage
+		wantErr bool
+	}{
+		{message: withHeaders()},
+		{message: withHeaders(HeaderSchemaVersion, "1.4", HeaderContentType, ContentTypeJSON)},
+		{message: withHeaders(HeaderSchemaVersion, "2"), wantErr: true},
+		{message: withHeaders(HeaderContentType, "application/avro"), wantErr: true},
+	}
+	for i, tt := range tests {
+		if err := checkHeaders(tt.message); (err != nil) != tt.wantErr {
+			t.Errorf("case %d: checkHeaders() error = %v, wantErr %v", i, err, tt.wantErr)
+		}
+	}
+
+	if cluster := messageCluster(withHeaders()); cluster != "dev-a" {
+		t.Errorf("expected the key as cluster without a header, got %q", cluster)
+	}
+	if cluster := messageCluster(withHeaders(HeaderCluster, "dev-b")); cluster != "dev-b" {
+		t.Errorf("expected the cluster header, got %q", cluster)
+	}
+}
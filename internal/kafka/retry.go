@@ -0,0 +1,55 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// retryPolicy is how often and how long the consumer retries a failing
+// store, doubling the wait after every attempt
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// backoff returns the wait after the given failed attempt, counted from 1
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	wait := p.initialBackoff
+	for i := 1; i < attempt && wait < p.maxBackoff; i++ {
+		wait *= 2
+	}
+	if p.maxBackoff > 0 && wait > p.maxBackoff {
+		wait = p.maxBackoff
+	}
+	return wait
+}
+
+// wait sleeps for the backoff of attempt, or until ctx is done
+func (p retryPolicy) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(p.backoff(attempt))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isTransient reports whether storing may succeed when retried. PostgreSQL
+// rejecting the data itself is permanent; anything else, such as a lost
+// connection or a serialization failure, is worth retrying.
+func isTransient(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "22", "23", "42": // data exception, integrity constraint violation, syntax error or access rule violation
+			return false
+		}
+	}
+	return true
+}
@@ -2,11 +2,19 @@ package kubernetes
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	metricsv1beta1 "k8s.io/metrics/pkg/client/clientset/versioned"
 
 	"k8s-cluster-info-collector/internal/config"
@@ -17,9 +25,11 @@ type Client struct {
 	Clientset     *kubernetes.Clientset
 	MetricsClient *metricsv1beta1.Clientset
 	logger        *logrus.Logger
+	clusterName   string
 }
 
-// NewClient creates a new Kubernetes client
+// NewClient creates a new Kubernetes client for the current context of the
+// kubeconfig at cfg.ConfigPath, or the in-cluster configuration without one
 func NewClient(cfg *config.KubeConfig, logger *logrus.Logger) (*Client, error) {
 	var kubeConfig *rest.Config
 	var err error
@@ -36,6 +46,122 @@ func NewClient(cfg *config.KubeConfig, logger *logrus.Logger) (*Client, error) {
 		return nil, err
 	}
 
+	return newClient(kubeConfig, cfg.ClusterName, logger)
+}
+
+// NewClients creates one client per cluster to collect. With cfg.ConfigDir,
+// every kubeconfig file in the directory contributes its current context, or
+// the contexts named by cfg.Contexts. Otherwise cfg.Contexts selects contexts
+// of the kubeconfig at cfg.ConfigPath, "*" meaning all of them. Each client
+// is named after its context. Without either setting, the single client of
+// NewClient is returned.
+func NewClients(cfg *config.KubeConfig, logger *logrus.Logger) ([]*Client, error) {
+	if cfg.ConfigDir == "" && len(cfg.Contexts) == 0 {
+		client, err := NewClient(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return []*Client{client}, nil
+	}
+
+	var files []string
+	if cfg.ConfigDir != "" {
+		entries, err := os.ReadDir(cfg.ConfigDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubeconfig directory: %w", err)
+		}
+		for _, entry := range entries {
+			// Skip hidden files, such as the ..data links of mounted secrets
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			files = append(files, filepath.Join(cfg.ConfigDir, entry.Name()))
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no kubeconfig files in %s", cfg.ConfigDir)
+		}
+	} else {
+		if cfg.ConfigPath == "" {
+			return nil, errors.New("KUBE_CONTEXTS requires KUBECONFIG or KUBECONFIG_DIR")
+		}
+		files = []string{cfg.ConfigPath}
+	}
+
+	var clients []*Client
+	origins := make(map[string]string)
+	for _, file := range files {
+		rawConfig, err := (&clientcmd.ClientConfigLoadingRules{Precedence: filepath.SplitList(file)}).Load()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig %s: %w", file, err)
+		}
+		contexts, err := selectContexts(rawConfig, cfg.Contexts, cfg.ConfigDir != "")
+		if err != nil {
+			return nil, fmt.Errorf("kubeconfig %s: %w", file, err)
+		}
+
+		for _, name := range contexts {
+			if origin, ok := origins[name]; ok {
+				return nil, fmt.Errorf("context %q is defined in both %s and %s", name, origin, file)
+			}
+			origins[name] = file
+
+			kubeConfig, err := clientcmd.NewNonInteractiveClientConfig(*rawConfig, name, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+			if err != nil {
+				return nil, fmt.Errorf("invalid context %q in %s: %w", name, file, err)
+			}
+			logger.WithFields(logrus.Fields{
+				"context":     name,
+				"config_path": file,
+			}).Info("Using kubeconfig context")
+
+			client, err := newClient(kubeConfig, name, logger)
+			if err != nil {
+				return nil, fmt.Errorf("context %q: %w", name, err)
+			}
+			clients = append(clients, client)
+		}
+	}
+
+	for _, name := range cfg.Contexts {
+		if _, ok := origins[name]; !ok && name != "*" {
+			return nil, fmt.Errorf("context %q not found in %s", name, cfg.ConfigDir)
+		}
+	}
+	return clients, nil
+}
+
+// selectContexts returns the contexts of rawConfig to collect, sorted by
+// name. Named contexts a directory file lacks are skipped, since another
+// file may define them; from a single kubeconfig they are an error.
+func selectContexts(rawConfig *clientcmdapi.Config, wanted []string, optional bool) ([]string, error) {
+	if len(wanted) == 0 {
+		if rawConfig.CurrentContext == "" {
+			return nil, errors.New("no current context")
+		}
+		return []string{rawConfig.CurrentContext}, nil
+	}
+
+	var contexts []string
+	for _, name := range wanted {
+		if name == "*" {
+			contexts = contexts[:0]
+			for context := range rawConfig.Contexts {
+				contexts = append(contexts, context)
+			}
+			break
+		}
+		if _, ok := rawConfig.Contexts[name]; ok {
+			contexts = append(contexts, name)
+		} else if !optional {
+			return nil, fmt.Errorf("context %q not found", name)
+		}
+	}
+	sort.Strings(contexts)
+	return contexts, nil
+}
+
+// newClient creates the clients for one cluster
+func newClient(kubeConfig *rest.Config, clusterName string, logger *logrus.Logger) (*Client, error) {
 	clientset, err := kubernetes.NewForConfig(kubeConfig)
 	if err != nil {
 		return nil, err
@@ -48,15 +174,32 @@ func NewClient(cfg *config.KubeConfig, logger *logrus.Logger) (*Client, error) {
 		metricsClient = nil
 	}
 
-	logger.Info("Kubernetes client initialized successfully")
+	logger.WithField("cluster", clusterName).Info("Kubernetes client initialized successfully")
 
 	return &Client{
 		Clientset:     clientset,
 		MetricsClient: metricsClient,
 		logger:        logger,
+		clusterName:   clusterName,
 	}, nil
 }
 
+// ClusterName returns the configured cluster name, which may be empty
+func (c *Client) ClusterName() string {
+	return c.clusterName
+}
+
+// ClusterUID returns the UID of the kube-system namespace. It is created with
+// the cluster and never changes, so it identifies the cluster when no name
+// is configured.
+func (c *Client) ClusterUID(ctx context.Context) (string, error) {
+	namespace, err := c.Clientset.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return string(namespace.UID), nil
+}
+
 // TestConnection tests the Kubernetes connection
 func (c *Client) TestConnection(ctx context.Context) error {
 	_, err := c.Clientset.Discovery().ServerVersion()
@@ -0,0 +1,91 @@
+package kubernetes
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+)
+
+// writeKubeconfig writes a kubeconfig with one context per name, the first
+// being the current one
+func writeKubeconfig(t *testing.T, path string, contexts ...string) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("apiVersion: v1\nkind: Config\ncurrent-context: " + contexts[0] + "\nclusters:\n")
+	for _, name := range contexts {
+		b.WriteString("- name: " + name + "\n  cluster:\n    server: https://" + name + ".example.com\n")
+	}
+	b.WriteString("users:\n- name: user\n  user:\n    token: secret\ncontexts:\n")
+	for _, name := range contexts {
+		b.WriteString("- name: " + name + "\n  context:\n    cluster: " + name + "\n    user: user\n")
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+}
+
+func clusterNames(clients []*Client) []string {
+	names := make([]string, len(clients))
+	for i, client := range clients {
+		names[i] = client.ClusterName()
+	}
+	return names
+}
+
+func TestNewClientsContexts(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	path := filepath.Join(t.TempDir(), "config")
+	writeKubeconfig(t, path, "dev-b", "dev-a", "prod")
+
+	tests := []struct {
+		contexts []string
+		want     string
+	}{
+		{contexts: []string{"*"}, want: "dev-a,dev-b,prod"},
+		{contexts: []string{"prod", "dev-a"}, want: "dev-a,prod"},
+	}
+	for _, tt := range tests {
+		clients, err := NewClients(&config.KubeConfig{ConfigPath: path, Contexts: tt.contexts}, logger)
+		if err != nil {
+			t.Fatalf("NewClients(%v) error = %v", tt.contexts, err)
+		}
+		if got := strings.Join(clusterNames(clients), ","); got != tt.want {
+			t.Errorf("NewClients(%v) = %s, want %s", tt.contexts, got, tt.want)
+		}
+	}
+
+	if _, err := NewClients(&config.KubeConfig{ConfigPath: path, Contexts: []string{"staging"}}, logger); err == nil {
+		t.Error("expected an unknown context to be rejected")
+	}
+}
+
+func TestNewClientsDirectory(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	dir := t.TempDir()
+	writeKubeconfig(t, filepath.Join(dir, "dev-a.yaml"), "dev-a", "dev-a-admin")
+	writeKubeconfig(t, filepath.Join(dir, "dev-b.yaml"), "dev-b")
+	if err := os.Mkdir(filepath.Join(dir, "..data"), 0o755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clients, err := NewClients(&config.KubeConfig{ConfigDir: dir}, logger)
+	if err != nil {
+		t.Fatalf("NewClients() error = %v", err)
+	}
+	if got := strings.Join(clusterNames(clients), ","); got != "dev-a,dev-b" {
+		t.Errorf("expected the current context of each file, got %s", got)
+	}
+
+	writeKubeconfig(t, filepath.Join(dir, "copy.yaml"), "dev-b")
+	if _, err := NewClients(&config.KubeConfig{ConfigDir: dir}, logger); err == nil {
+		t.Error("expected a context defined twice to be rejected")
+	}
+}
@@ -0,0 +1,138 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+	"k8s-cluster-info-collector/internal/tlsutil"
+)
+
+// Manager owns every HTTP server of a process. It applies the configured
+// timeouts, starts the servers together and shuts them down together,
+// followed by the registered shutdown hooks in order.
+type Manager struct {
+	logger *logrus.Logger
+	config config.ServerConfig
+
+	servers []managedServer
+	hooks   []hook
+	errors  chan error
+	wg      sync.WaitGroup
+}
+
+type managedServer struct {
+	name   string
+	server *http.Server
+	tls    config.TLSConfig
+}
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// New creates a lifecycle manager
+func New(logger *logrus.Logger, cfg config.ServerConfig) *Manager {
+	return &Manager{
+		logger: logger,
+		config: cfg,
+		errors: make(chan error, 1),
+	}
+}
+
+// AddServer registers a server to listen on address. Servers must be added
+// before Start.
+func (m *Manager) AddServer(name, address string, handler http.Handler, tlsConfig config.TLSConfig) {
+	m.servers = append(m.servers, managedServer{
+		name: name,
+		server: &http.Server{
+			Addr:              address,
+			Handler:           handler,
+			ReadTimeout:       m.config.ReadTimeout,
+			ReadHeaderTimeout: m.config.ReadHeaderTimeout,
+			WriteTimeout:      m.config.WriteTimeout,
+			IdleTimeout:       m.config.IdleTimeout,
+		},
+		tls: tlsConfig,
+	})
+}
+
+// OnShutdown registers fn to run after the servers have stopped. Hooks run
+// in registration order, so register consumers of a resource before the
+// resource itself (e.g. the Kafka consumer before the database).
+func (m *Manager) OnShutdown(name string, fn func(ctx context.Context) error) {
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// Start starts every server in the background. A server that fails to
+// listen is reported on Errors.
+func (m *Manager) Start() {
+	for _, s := range m.servers {
+		s := s
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.logger.WithFields(logrus.Fields{
+				"server":  s.name,
+				"address": s.server.Addr,
+				"tls":     s.tls.Enabled,
+			}).Info("Starting HTTP server")
+
+			err := tlsutil.ListenAndServe(s.server, s.tls, m.logger)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				select {
+				case m.errors <- fmt.Errorf("%s server: %w", s.name, err):
+				default:
+				}
+			}
+		}()
+	}
+}
+
+// Errors delivers the first server that stopped unexpectedly
+func (m *Manager) Errors() <-chan error {
+	return m.errors
+}
+
+// Shutdown stops accepting connections, waits for in-flight requests and
+// then runs the shutdown hooks. Everything shares the deadline of ctx; work
+// still running when it expires is abandoned.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, s := range m.servers {
+		s := s
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.server.Shutdown(ctx); err != nil {
+				// Force-close connections that did not finish in time
+				s.server.Close()
+				mutex.Lock()
+				errs = append(errs, fmt.Errorf("%s server: %w", s.name, err))
+				mutex.Unlock()
+			}
+			m.logger.WithField("server", s.name).Info("HTTP server stopped")
+		}()
+	}
+	wg.Wait()
+	m.wg.Wait()
+
+	for _, h := range m.hooks {
+		if err := h.fn(ctx); err != nil {
+			m.logger.WithError(err).WithField("component", h.name).Error("Shutdown step failed")
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		m.logger.WithField("component", h.name).Debug("Shutdown step completed")
+	}
+	return errors.Join(errs...)
+}
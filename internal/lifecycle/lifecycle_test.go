@@ -0,0 +1,112 @@
+package lifecycle
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+)
+
+func freeAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestShutdownWaitsForInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+
+	manager := New(logrus.New(), config.ServerConfig{ReadTimeout: 5 * time.Second})
+	address := freeAddress(t)
+	manager.AddServer("test", address, handler, config.TLSConfig{})
+
+	var steps []string
+	manager.OnShutdown("first", func(context.Context) error {
+		steps = append(steps, "first")
+		return nil
+	})
+	manager.OnShutdown("second", func(context.Context) error {
+		steps = append(steps, "second")
+		return nil
+	})
+	manager.Start()
+
+	// Wait for the listener to come up
+	var resp *http.Response
+	result := make(chan string, 1)
+	go func() {
+		var err error
+		for i := 0; i < 50; i++ {
+			if resp, err = http.Get("http://" + address); err == nil {
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				result <- string(body)
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		result <- err.Error()
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- manager.Shutdown(context.Background())
+	}()
+
+	select {
+	case <-shutdown:
+		t.Fatal("Shutdown returned while a request was in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	if body := <-result; body != "done" {
+		t.Errorf("expected in-flight request to complete, got %q", body)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	if !reflect.DeepEqual(steps, []string{"first", "second"}) {
+		t.Errorf("expected hooks to run in order, got %v", steps)
+	}
+}
+
+func TestStartReportsListenErrors(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	manager := New(logrus.New(), config.ServerConfig{})
+	manager.AddServer("test", listener.Addr().String(), http.NotFoundHandler(), config.TLSConfig{})
+	manager.Start()
+
+	select {
+	case err := <-manager.Errors():
+		if err == nil {
+			t.Error("expected an error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected an error for an address already in use")
+	}
+	manager.Shutdown(context.Background())
+}
@@ -17,6 +17,14 @@ type Metrics struct {
 	collectionErrors          *prometheus.CounterVec
 	databaseOperations        *prometheus.CounterVec
 	databaseOperationDuration *prometheus.HistogramVec
+	retentionRuns             *prometheus.CounterVec
+	retentionRunDuration      prometheus.Histogram
+	retentionDeletedSnapshots *prometheus.CounterVec
+	retentionLastRun          prometheus.Gauge
+	kafkaConsumerRetries      prometheus.Counter
+	kafkaDeadLetters          *prometheus.CounterVec
+	kafkaDroppedMessages      *prometheus.CounterVec
+	kafkaDeadLetterReplays    prometheus.Counter
 }
 
 // New creates a new metrics instance
@@ -67,6 +75,59 @@ func New(logger *logrus.Logger) *Metrics {
 			},
 			[]string{"operation"},
 		),
+		retentionRuns: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cluster_info_retention_runs_total",
+				Help: "Total number of retention cleanup runs",
+			},
+			[]string{"status"},
+		),
+		retentionRunDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "cluster_info_retention_run_duration_seconds",
+				Help:    "Duration of retention cleanup runs in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+		),
+		retentionDeletedSnapshots: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cluster_info_retention_deleted_snapshots_total",
+				Help: "Total number of snapshots deleted by retention",
+			},
+			[]string{"reason"},
+		),
+		retentionLastRun: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "cluster_info_retention_last_run_timestamp_seconds",
+				Help: "Unix timestamp of the last retention cleanup run",
+			},
+		),
+		kafkaConsumerRetries: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cluster_info_kafka_consumer_retries_total",
+				Help: "Total number of retried attempts to store a Kafka message",
+			},
+		),
+		kafkaDeadLetters: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cluster_info_kafka_dead_letters_total",
+				Help: "Total number of Kafka messages sent to the dead-letter topic",
+			},
+			[]string{"reason"},
+		),
+		kafkaDroppedMessages: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cluster_info_kafka_dropped_messages_total",
+				Help: "Total number of failed Kafka messages dropped without a dead-letter topic",
+			},
+			[]string{"reason"},
+		),
+		kafkaDeadLetterReplays: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cluster_info_kafka_dead_letter_replays_total",
+				Help: "Total number of dead-lettered messages replayed to the Kafka topic",
+			},
+		),
 	}
 
 	// Register metrics
@@ -77,6 +138,14 @@ func New(logger *logrus.Logger) *Metrics {
 		m.collectionErrors,
 		m.databaseOperations,
 		m.databaseOperationDuration,
+		m.retentionRuns,
+		m.retentionRunDuration,
+		m.retentionDeletedSnapshots,
+		m.retentionLastRun,
+		m.kafkaConsumerRetries,
+		m.kafkaDeadLetters,
+		m.kafkaDroppedMessages,
+		m.kafkaDeadLetterReplays,
 	)
 
 	return m
@@ -122,20 +191,52 @@ func (m *Metrics) RecordDatabaseOperationDuration(operation string, duration flo
 	m.databaseOperationDuration.WithLabelValues(operation).Observe(duration)
 }
 
+// RecordRetentionRun records a retention cleanup run
+func (m *Metrics) RecordRetentionRun(status string, duration float64) {
+	m.retentionRuns.WithLabelValues(status).Inc()
+	m.retentionRunDuration.Observe(duration)
+	m.retentionLastRun.SetToCurrentTime()
+}
+
+// RecordRetentionDeleted records snapshots deleted by retention for a reason
+func (m *Metrics) RecordRetentionDeleted(reason string, count int) {
+	m.retentionDeletedSnapshots.WithLabelValues(reason).Add(float64(count))
+}
+
+// RecordKafkaRetry records a retried attempt to store a Kafka message
+func (m *Metrics) RecordKafkaRetry() {
+	m.kafkaConsumerRetries.Inc()
+}
+
+// RecordKafkaDeadLetters records messages sent to the dead-letter topic
+func (m *Metrics) RecordKafkaDeadLetters(reason string, count int) {
+	m.kafkaDeadLetters.WithLabelValues(reason).Add(float64(count))
+}
+
+// RecordKafkaDroppedMessages records failed messages dropped because no
+// dead-letter topic is configured
+func (m *Metrics) RecordKafkaDroppedMessages(reason string, count int) {
+	m.kafkaDroppedMessages.WithLabelValues(reason).Add(float64(count))
+}
+
+// RecordKafkaDeadLetterReplays records dead-lettered messages replayed
+func (m *Metrics) RecordKafkaDeadLetterReplays(count int) {
+	m.kafkaDeadLetterReplays.Add(float64(count))
+}
+
 // Handler returns the HTTP handler for Prometheus metrics
 func (m *Metrics) Handler() http.Handler {
 	return promhttp.Handler()
 }
 
-// StartMetricsServer starts the metrics HTTP server
-func (m *Metrics) StartMetricsServer(addr string) error {
-	m.logger.WithField("address", addr).Info("Starting metrics server")
-
-	http.Handle("/metrics", m.Handler())
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+// ServerHandler returns the handler of the metrics server: /metrics and a
+// plain /health check
+func (m *Metrics) ServerHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
-
-	return http.ListenAndServe(addr, nil)
+	return mux
 }
@@ -5,12 +5,21 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// ClusterInfo represents a complete snapshot of cluster state
+// DefaultCluster is the cluster name of snapshots stored without one, such
+// as those written before clusters were identified
+const DefaultCluster = "default"
+
+// ClusterInfo represents a complete snapshot of cluster state. Cluster
+// names the cluster it was collected from; ClusterUID is the UID of its
+// kube-system namespace, which stays the same for the life of the cluster.
 type ClusterInfo struct {
 	Timestamp              time.Time                   `json:"timestamp"`
+	Cluster                string                      `json:"cluster,omitempty"`
+	ClusterUID             string                      `json:"cluster_uid,omitempty"`
 	Deployments            []DeploymentInfo            `json:"deployments"`
 	Pods                   []PodInfo                   `json:"pods"`
 	Nodes                  []NodeInfo                  `json:"nodes"`
@@ -22,14 +31,34 @@ type ClusterInfo struct {
 	PersistentVolumeClaims []PersistentVolumeClaimInfo `json:"persistent_volume_claims"`
 }
 
+// ClusterName returns the cluster of the snapshot, or DefaultCluster when
+// none is set
+func (c ClusterInfo) ClusterName() string {
+	if c.Cluster == "" {
+		return DefaultCluster
+	}
+	return c.Cluster
+}
+
+// SnapshotRef identifies a stored snapshot
+type SnapshotRef struct {
+	ID        int       `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Cluster   string    `json:"cluster"`
+}
+
 // DeploymentInfo contains deployment details
 type DeploymentInfo struct {
 	Name            string                       `json:"name"`
 	Namespace       string                       `json:"namespace"`
+	UID             string                       `json:"uid"`
+	ResourceVersion string                       `json:"resource_version"`
+	Generation      int64                        `json:"generation"`
 	CreatedTime     time.Time                    `json:"created_time"`
 	Replicas        int32                        `json:"replicas"`
 	ReadyReplicas   int32                        `json:"ready_replicas"`
 	UpdatedReplicas int32                        `json:"updated_replicas"`
+	Images          []string                     `json:"images"`
 	Conditions      []appsv1.DeploymentCondition `json:"conditions"`
 	Labels          map[string]string            `json:"labels"`
 	Annotations     map[string]string            `json:"annotations"`
@@ -39,6 +68,9 @@ type DeploymentInfo struct {
 type PodInfo struct {
 	Name              string            `json:"name"`
 	Namespace         string            `json:"namespace"`
+	UID               string            `json:"uid"`
+	ResourceVersion   string            `json:"resource_version"`
+	Generation        int64             `json:"generation"`
 	DeploymentName    string            `json:"deployment_name"`
 	CreatedTime       time.Time         `json:"created_time"`
 	Phase             string            `json:"phase"`
@@ -54,6 +86,7 @@ type PodInfo struct {
 	Labels            map[string]string `json:"labels"`
 	Annotations       map[string]string `json:"annotations"`
 	ContainerStatuses []ContainerStatus `json:"container_statuses"`
+	VolumeClaims      []string          `json:"volume_claims,omitempty"` // Names of mounted PersistentVolumeClaims
 }
 
 // ContainerStatus represents the status of a container within a pod
@@ -68,6 +101,9 @@ type ContainerStatus struct {
 // NodeInfo contains node resource information and status
 type NodeInfo struct {
 	Name               string            `json:"name"`
+	UID                string            `json:"uid"`
+	ResourceVersion    string            `json:"resource_version"`
+	Generation         int64             `json:"generation"`
 	CreatedTime        time.Time         `json:"created_time"`
 	Ready              bool              `json:"ready"`
 	CPUCapacity        string            `json:"cpu_capacity"`
@@ -85,16 +121,19 @@ type NodeInfo struct {
 
 // ServiceInfo contains service details
 type ServiceInfo struct {
-	Name        string            `json:"name"`
-	Namespace   string            `json:"namespace"`
-	CreatedTime time.Time         `json:"created_time"`
-	Type        string            `json:"type"`
-	ClusterIP   string            `json:"cluster_ip"`
-	ExternalIPs []string          `json:"external_ips"`
-	Ports       []ServicePort     `json:"ports"`
-	Selector    map[string]string `json:"selector"`
-	Labels      map[string]string `json:"labels"`
-	Annotations map[string]string `json:"annotations"`
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	UID             string            `json:"uid"`
+	ResourceVersion string            `json:"resource_version"`
+	Generation      int64             `json:"generation"`
+	CreatedTime     time.Time         `json:"created_time"`
+	Type            string            `json:"type"`
+	ClusterIP       string            `json:"cluster_ip"`
+	ExternalIPs     []string          `json:"external_ips"`
+	Ports           []ServicePort     `json:"ports"`
+	Selector        map[string]string `json:"selector"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
 }
 
 // ServicePort represents a service port
@@ -108,14 +147,17 @@ type ServicePort struct {
 
 // IngressInfo contains ingress details
 type IngressInfo struct {
-	Name        string            `json:"name"`
-	Namespace   string            `json:"namespace"`
-	CreatedTime time.Time         `json:"created_time"`
-	Hosts       []string          `json:"hosts"`
-	Paths       []IngressPath     `json:"paths"`
-	TLS         []IngressTLS      `json:"tls"`
-	Labels      map[string]string `json:"labels"`
-	Annotations map[string]string `json:"annotations"`
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	UID             string            `json:"uid"`
+	ResourceVersion string            `json:"resource_version"`
+	Generation      int64             `json:"generation"`
+	CreatedTime     time.Time         `json:"created_time"`
+	Hosts           []string          `json:"hosts"`
+	Paths           []IngressPath     `json:"paths"`
+	TLS             []IngressTLS      `json:"tls"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
 }
 
 // IngressPath represents an ingress path rule
@@ -134,55 +176,67 @@ type IngressTLS struct {
 
 // ConfigMapInfo contains ConfigMap details
 type ConfigMapInfo struct {
-	Name        string            `json:"name"`
-	Namespace   string            `json:"namespace"`
-	CreatedTime time.Time         `json:"created_time"`
-	Data        map[string]string `json:"data"`
-	BinaryData  map[string][]byte `json:"binary_data,omitempty"`
-	Labels      map[string]string `json:"labels"`
-	Annotations map[string]string `json:"annotations"`
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	UID             string            `json:"uid"`
+	ResourceVersion string            `json:"resource_version"`
+	Generation      int64             `json:"generation"`
+	CreatedTime     time.Time         `json:"created_time"`
+	Data            map[string]string `json:"data"`
+	BinaryData      map[string][]byte `json:"binary_data,omitempty"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
 }
 
 // SecretInfo contains Secret details
 type SecretInfo struct {
-	Name        string            `json:"name"`
-	Namespace   string            `json:"namespace"`
-	CreatedTime time.Time         `json:"created_time"`
-	Type        string            `json:"type"`
-	DataKeys    []string          `json:"data_keys"` // Only store keys, not actual secret data
-	Labels      map[string]string `json:"labels"`
-	Annotations map[string]string `json:"annotations"`
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	UID             string            `json:"uid"`
+	ResourceVersion string            `json:"resource_version"`
+	Generation      int64             `json:"generation"`
+	CreatedTime     time.Time         `json:"created_time"`
+	Type            string            `json:"type"`
+	DataKeys        []string          `json:"data_keys"` // Only store keys, not actual secret data
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
 }
 
 // PersistentVolumeInfo contains PersistentVolume details
 type PersistentVolumeInfo struct {
-	Name          string            `json:"name"`
-	CreatedTime   time.Time         `json:"created_time"`
-	Capacity      string            `json:"capacity"`
-	AccessModes   []string          `json:"access_modes"`
-	ReclaimPolicy string            `json:"reclaim_policy"`
-	StorageClass  string            `json:"storage_class"`
-	VolumeMode    string            `json:"volume_mode"`
-	Status        string            `json:"status"`
-	ClaimRef      string            `json:"claim_ref,omitempty"`
-	VolumeSource  string            `json:"volume_source"`
-	Labels        map[string]string `json:"labels"`
-	Annotations   map[string]string `json:"annotations"`
+	Name            string            `json:"name"`
+	UID             string            `json:"uid"`
+	ResourceVersion string            `json:"resource_version"`
+	Generation      int64             `json:"generation"`
+	CreatedTime     time.Time         `json:"created_time"`
+	Capacity        string            `json:"capacity"`
+	AccessModes     []string          `json:"access_modes"`
+	ReclaimPolicy   string            `json:"reclaim_policy"`
+	StorageClass    string            `json:"storage_class"`
+	VolumeMode      string            `json:"volume_mode"`
+	Status          string            `json:"status"`
+	ClaimRef        string            `json:"claim_ref,omitempty"`
+	VolumeSource    string            `json:"volume_source"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
 }
 
 // PersistentVolumeClaimInfo contains PersistentVolumeClaim details
 type PersistentVolumeClaimInfo struct {
-	Name          string            `json:"name"`
-	Namespace     string            `json:"namespace"`
-	CreatedTime   time.Time         `json:"created_time"`
-	RequestedSize string            `json:"requested_size"`
-	AccessModes   []string          `json:"access_modes"`
-	StorageClass  string            `json:"storage_class,omitempty"`
-	VolumeMode    string            `json:"volume_mode"`
-	Status        string            `json:"status"`
-	VolumeName    string            `json:"volume_name,omitempty"`
-	Labels        map[string]string `json:"labels"`
-	Annotations   map[string]string `json:"annotations"`
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	UID             string            `json:"uid"`
+	ResourceVersion string            `json:"resource_version"`
+	Generation      int64             `json:"generation"`
+	CreatedTime     time.Time         `json:"created_time"`
+	RequestedSize   string            `json:"requested_size"`
+	AccessModes     []string          `json:"access_modes"`
+	StorageClass    string            `json:"storage_class,omitempty"`
+	VolumeMode      string            `json:"volume_mode"`
+	Status          string            `json:"status"`
+	VolumeName      string            `json:"volume_name,omitempty"`
+	Labels          map[string]string `json:"labels"`
+	Annotations     map[string]string `json:"annotations"`
 }
 
 // GetDeploymentName extracts deployment name from owner references
@@ -198,20 +252,32 @@ func GetDeploymentName(ownerRefs []metav1.OwnerReference) string {
 	return ""
 }
 
-// ExtractResourceInfo extracts resource requests and limits from containers
+// ExtractResourceInfo extracts resource requests and limits from containers,
+// summed across all containers in the pod
 func ExtractResourceInfo(containers []corev1.Container, resourceName corev1.ResourceName) (string, string) {
-	var totalRequest, totalLimit string
+	var totalRequest, totalLimit resource.Quantity
+	var hasRequest, hasLimit bool
 	for _, container := range containers {
 		if container.Resources.Requests != nil {
 			if req, ok := container.Resources.Requests[resourceName]; ok {
-				totalRequest = req.String()
+				totalRequest.Add(req)
+				hasRequest = true
 			}
 		}
 		if container.Resources.Limits != nil {
 			if limit, ok := container.Resources.Limits[resourceName]; ok {
-				totalLimit = limit.String()
+				totalLimit.Add(limit)
+				hasLimit = true
 			}
 		}
 	}
-	return totalRequest, totalLimit
+
+	var request, limit string
+	if hasRequest {
+		request = totalRequest.String()
+	}
+	if hasLimit {
+		limit = totalLimit.String()
+	}
+	return request, limit
 }
@@ -23,6 +23,15 @@ func TestClusterInfo(t *testing.T) {
 	}
 }
 
+func TestClusterInfoClusterName(t *testing.T) {
+	if name := (ClusterInfo{}).ClusterName(); name != DefaultCluster {
+		t.Errorf("expected %q without a cluster, got %q", DefaultCluster, name)
+	}
+	if name := (ClusterInfo{Cluster: "prod"}).ClusterName(); name != "prod" {
+		t.Errorf("expected prod, got %q", name)
+	}
+}
+
 func TestDeploymentInfo(t *testing.T) {
 	deployment := DeploymentInfo{
 		Name:            "test-deployment",
@@ -147,6 +156,36 @@ func TestExtractResourceInfo(t *testing.T) {
 	}
 }
 
+func TestExtractResourceInfoMultipleContainers(t *testing.T) {
+	containers := []corev1.Container{
+		{
+			Name: "app",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("100m"),
+				},
+			},
+		},
+		{
+			Name: "sidecar",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("250m"),
+				},
+			},
+		},
+	}
+
+	cpuRequest, cpuLimit := ExtractResourceInfo(containers, corev1.ResourceCPU)
+
+	if cpuRequest != "350m" {
+		t.Errorf("expected CPU request '350m', got %s", cpuRequest)
+	}
+	if cpuLimit != "" {
+		t.Errorf("expected empty CPU limit, got %s", cpuLimit)
+	}
+}
+
 func TestGetDeploymentName(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// Kubernetes object kinds tracked by the collector
+const (
+	KindDeployment            = "Deployment"
+	KindPod                   = "Pod"
+	KindNode                  = "Node"
+	KindService               = "Service"
+	KindIngress               = "Ingress"
+	KindConfigMap             = "ConfigMap"
+	KindSecret                = "Secret"
+	KindPersistentVolume      = "PersistentVolume"
+	KindPersistentVolumeClaim = "PersistentVolumeClaim"
+)
+
+// ObjectLifecycle tracks a single Kubernetes object (by UID) across snapshots
+type ObjectLifecycle struct {
+	Cluster         string     `json:"cluster"`
+	Kind            string     `json:"kind"`
+	UID             string     `json:"uid"`
+	Namespace       string     `json:"namespace,omitempty"`
+	Name            string     `json:"name"`
+	CreatedTime     time.Time  `json:"created_time"`
+	FirstSeen       time.Time  `json:"first_seen"`
+	LastSeen        time.Time  `json:"last_seen"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	FirstSnapshotID int        `json:"first_snapshot_id"`
+	LastSnapshotID  int        `json:"last_snapshot_id"`
+}
+
+// Kinds lists every tracked kind in the order they appear in ClusterInfo
+var Kinds = []string{
+	KindDeployment,
+	KindPod,
+	KindNode,
+	KindService,
+	KindIngress,
+	KindConfigMap,
+	KindSecret,
+	KindPersistentVolume,
+	KindPersistentVolumeClaim,
+}
+
+// Object is a single object from a snapshot. Value holds the kind-specific
+// *Info struct.
+type Object struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Value     interface{}
+}
+
+// Objects flattens the snapshot into its objects, grouped by kind in Kinds order
+func (c ClusterInfo) Objects() []Object {
+	var objects []Object
+	for _, d := range c.Deployments {
+		objects = append(objects, Object{KindDeployment, d.Namespace, d.Name, d})
+	}
+	for _, p := range c.Pods {
+		objects = append(objects, Object{KindPod, p.Namespace, p.Name, p})
+	}
+	for _, n := range c.Nodes {
+		objects = append(objects, Object{KindNode, "", n.Name, n})
+	}
+	for _, svc := range c.Services {
+		objects = append(objects, Object{KindService, svc.Namespace, svc.Name, svc})
+	}
+	for _, ing := range c.Ingresses {
+		objects = append(objects, Object{KindIngress, ing.Namespace, ing.Name, ing})
+	}
+	for _, cm := range c.ConfigMaps {
+		objects = append(objects, Object{KindConfigMap, cm.Namespace, cm.Name, cm})
+	}
+	for _, secret := range c.Secrets {
+		objects = append(objects, Object{KindSecret, secret.Namespace, secret.Name, secret})
+	}
+	for _, pv := range c.PersistentVolumes {
+		objects = append(objects, Object{KindPersistentVolume, "", pv.Name, pv})
+	}
+	for _, pvc := range c.PersistentVolumeClaims {
+		objects = append(objects, Object{KindPersistentVolumeClaim, pvc.Namespace, pvc.Name, pvc})
+	}
+	return objects
+}
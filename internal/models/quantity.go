@@ -0,0 +1,31 @@
+package models
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ParseMilliCores parses a CPU quantity such as "500m" or "2" into millicores.
+// The second return value is false when the quantity is empty or invalid.
+func ParseMilliCores(quantity string) (int64, bool) {
+	if quantity == "" {
+		return 0, false
+	}
+	q, err := resource.ParseQuantity(quantity)
+	if err != nil {
+		return 0, false
+	}
+	return q.MilliValue(), true
+}
+
+// ParseBytes parses a memory or storage quantity such as "2Gi" or "500M" into bytes.
+// The second return value is false when the quantity is empty or invalid.
+func ParseBytes(quantity string) (int64, bool) {
+	if quantity == "" {
+		return 0, false
+	}
+	q, err := resource.ParseQuantity(quantity)
+	if err != nil {
+		return 0, false
+	}
+	return q.Value(), true
+}
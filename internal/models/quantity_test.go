@@ -0,0 +1,56 @@
+package models
+
+import "testing"
+
+func TestParseMilliCores(t *testing.T) {
+	tests := []struct {
+		quantity string
+		expected int64
+		ok       bool
+	}{
+		{"500m", 500, true},
+		{"2", 2000, true},
+		{"1.5", 1500, true},
+		{"", 0, false},
+		{"invalid", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.quantity, func(t *testing.T) {
+			value, ok := ParseMilliCores(tt.quantity)
+			if ok != tt.ok {
+				t.Errorf("expected ok=%v, got %v", tt.ok, ok)
+			}
+			if value != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		quantity string
+		expected int64
+		ok       bool
+	}{
+		{"128Mi", 128 * 1024 * 1024, true},
+		{"2Gi", 2 * 1024 * 1024 * 1024, true},
+		{"500M", 500 * 1000 * 1000, true},
+		{"1024", 1024, true},
+		{"", 0, false},
+		{"not-a-size", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.quantity, func(t *testing.T) {
+			value, ok := ParseBytes(tt.quantity)
+			if ok != tt.ok {
+				t.Errorf("expected ok=%v, got %v", tt.ok, ok)
+			}
+			if value != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, value)
+			}
+		})
+	}
+}
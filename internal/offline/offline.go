@@ -0,0 +1,301 @@
+// Package offline lets the collector run where Kafka and PostgreSQL are
+// unreachable. Each collection is written to a gzip-compressed JSON file in
+// a directory together with a manifest, and the directory can later be
+// imported into the database.
+package offline
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/models"
+	"k8s-cluster-info-collector/internal/store"
+)
+
+// ManifestName is the name of the manifest file in an offline directory
+const ManifestName = "manifest.json"
+
+// Manifest lists the snapshot files of an offline directory, oldest first
+type Manifest struct {
+	Snapshots []Entry `json:"snapshots"`
+}
+
+// Entry describes one snapshot file
+type Entry struct {
+	File      string    `json:"file"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Nodes     int       `json:"nodes"`
+	Pods      int       `json:"pods"`
+}
+
+// Writer writes collected snapshots to an offline directory. It is safe
+// for concurrent use by the collectors of several clusters.
+type Writer struct {
+	directory string
+	logger    *logrus.Logger
+	mu        sync.Mutex // serializes manifest updates
+}
+
+// NewWriter creates the directory if needed and returns a writer for it
+func NewWriter(directory string, logger *logrus.Logger) (*Writer, error) {
+	if directory == "" {
+		return nil, errors.New("offline directory is required")
+	}
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create offline directory: %w", err)
+	}
+	return &Writer{directory: directory, logger: logger}, nil
+}
+
+// Write stores info in a new snapshot file and adds it to the manifest. The
+// file is written under a temporary name and renamed into place, so an
+// interrupted run never leaves a truncated snapshot behind.
+func (w *Writer) Write(info models.ClusterInfo) (Entry, error) {
+	name := "snapshot-"
+	if info.Cluster != "" {
+		name += fileSafe(info.Cluster) + "-"
+	}
+	entry := Entry{
+		File:      name + info.Timestamp.UTC().Format("20060102T150405.000Z") + ".json.gz",
+		Cluster:   info.Cluster,
+		Timestamp: info.Timestamp,
+		Nodes:     len(info.Nodes),
+		Pods:      len(info.Pods),
+	}
+
+	tmp, err := os.CreateTemp(w.directory, ".snapshot-*.tmp")
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(tmp, hash)}
+	gz := gzip.NewWriter(counter)
+	if err := json.NewEncoder(gz).Encode(info); err != nil {
+		return Entry{}, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return Entry{}, fmt.Errorf("failed to finish snapshot compression: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return Entry{}, fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+	entry.Size = counter.n
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	if err := os.Rename(tmp.Name(), filepath.Join(w.directory, entry.File)); err != nil {
+		return Entry{}, fmt.Errorf("failed to move snapshot file into place: %w", err)
+	}
+	if err := w.addToManifest(entry); err != nil {
+		return Entry{}, err
+	}
+
+	w.logger.WithFields(logrus.Fields{
+		"file": entry.File,
+		"size": entry.Size,
+	}).Info("Wrote offline snapshot")
+
+	return entry, nil
+}
+
+// addToManifest rewrites the manifest with entry added
+func (w *Writer) addToManifest(entry Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	manifest, err := ReadManifest(w.directory)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	snapshots := manifest.Snapshots[:0]
+	for _, existing := range manifest.Snapshots {
+		if existing.File != entry.File {
+			snapshots = append(snapshots, existing)
+		}
+	}
+	manifest.Snapshots = append(snapshots, entry)
+	sort.SliceStable(manifest.Snapshots, func(i, j int) bool {
+		return manifest.Snapshots[i].Timestamp.Before(manifest.Snapshots[j].Timestamp)
+	})
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	tmp := filepath.Join(w.directory, "."+ManifestName+".tmp")
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(w.directory, ManifestName)); err != nil {
+		return fmt.Errorf("failed to move manifest into place: %w", err)
+	}
+	return nil
+}
+
+// ReadManifest reads the manifest of an offline directory. The returned
+// error wraps os.ErrNotExist when the directory has no manifest.
+func ReadManifest(directory string) (Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(directory, ManifestName))
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// ReadSnapshot reads and verifies one snapshot file of the directory
+func ReadSnapshot(directory string, entry Entry) (models.ClusterInfo, error) {
+	var info models.ClusterInfo
+	if filepath.Base(entry.File) != entry.File {
+		return info, fmt.Errorf("invalid snapshot file name %q", entry.File)
+	}
+
+	f, err := os.Open(filepath.Join(directory, entry.File))
+	if err != nil {
+		return info, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	gz, err := gzip.NewReader(io.TeeReader(f, hash))
+	if err != nil {
+		return info, fmt.Errorf("failed to decompress %s: %w", entry.File, err)
+	}
+	defer gz.Close()
+
+	if err := json.NewDecoder(gz).Decode(&info); err != nil {
+		return info, fmt.Errorf("failed to parse %s: %w", entry.File, err)
+	}
+	// Hash whatever the decoder did not need to consume
+	if _, err := io.Copy(hash, f); err != nil {
+		return info, fmt.Errorf("failed to read %s: %w", entry.File, err)
+	}
+	if entry.SHA256 != "" && hex.EncodeToString(hash.Sum(nil)) != entry.SHA256 {
+		return info, fmt.Errorf("checksum mismatch for %s", entry.File)
+	}
+	return info, nil
+}
+
+// listSnapshots returns the entries to import from directory. Without a
+// manifest, every snapshot file in the directory is imported unverified.
+func listSnapshots(directory string) ([]Entry, error) {
+	manifest, err := ReadManifest(directory)
+	if err == nil {
+		return manifest.Snapshots, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(directory, "snapshot-*.json.gz"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s or snapshot files in %s", ManifestName, directory)
+	}
+	sort.Strings(files)
+
+	entries := make([]Entry, len(files))
+	for i, file := range files {
+		entries[i] = Entry{File: filepath.Base(file)}
+	}
+	return entries, nil
+}
+
+// Result summarizes an import
+type Result struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// Import stores every snapshot of an offline directory, oldest first.
+// Snapshots whose cluster and timestamp already exist in the database are
+// skipped, so importing the same directory twice is harmless.
+func Import(ctx context.Context, directory string, dataStore *store.Store, logger *logrus.Logger) (Result, error) {
+	var result Result
+	entries, err := listSnapshots(directory)
+	if err != nil {
+		return result, err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		info, err := ReadSnapshot(directory, entry)
+		if err != nil {
+			return result, err
+		}
+
+		exists, err := dataStore.HasSnapshot(ctx, info.ClusterName(), info.Timestamp)
+		if err != nil {
+			return result, err
+		}
+		if exists {
+			logger.WithField("file", entry.File).Debug("Snapshot already present, skipping")
+			result.Skipped++
+			continue
+		}
+
+		if err := dataStore.StoreClusterInfo(info); err != nil {
+			return result, fmt.Errorf("failed to store %s: %w", entry.File, err)
+		}
+		result.Imported++
+	}
+
+	logger.WithFields(logrus.Fields{
+		"directory": directory,
+		"imported":  result.Imported,
+		"skipped":   result.Skipped,
+	}).Info("Imported offline snapshots")
+
+	return result, nil
+}
+
+// fileSafe replaces characters that are unsafe in file names, such as the
+// slashes and colons of cloud provider context names
+func fileSafe(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,135 @@
+package offline
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/models"
+)
+
+func newTestWriter(t *testing.T) (*Writer, string) {
+	dir := filepath.Join(t.TempDir(), "offline")
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	w, err := NewWriter(dir, logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return w, dir
+}
+
+func TestWriteAndRead(t *testing.T) {
+	w, dir := newTestWriter(t)
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// Written out of order; the manifest keeps them sorted
+	for _, offset := range []time.Duration{time.Hour, 0} {
+		info := models.ClusterInfo{
+			Timestamp: base.Add(offset),
+			Pods:      []models.PodInfo{{Name: "web-1", Namespace: "shop"}},
+		}
+		if _, err := w.Write(info); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(manifest.Snapshots) != 2 {
+		t.Fatalf("expected 2 manifest entries, got %d", len(manifest.Snapshots))
+	}
+	first := manifest.Snapshots[0]
+	if !first.Timestamp.Equal(base) || first.File != "snapshot-20240501T100000.000Z.json.gz" || first.Pods != 1 {
+		t.Errorf("unexpected first entry %+v", first)
+	}
+
+	info, err := ReadSnapshot(dir, first)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !info.Timestamp.Equal(base) || len(info.Pods) != 1 || info.Pods[0].Name != "web-1" {
+		t.Errorf("unexpected snapshot %+v", info)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(dir, ".*"))
+	if len(leftovers) != 0 {
+		t.Errorf("expected no temporary files, found %v", leftovers)
+	}
+}
+
+func TestReadSnapshotVerifiesChecksum(t *testing.T) {
+	w, dir := newTestWriter(t)
+	entry, err := w.Write(models.ClusterInfo{Timestamp: time.Now()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entry.SHA256 = "0000"
+	if _, err := ReadSnapshot(dir, entry); err == nil {
+		t.Error("expected a checksum mismatch")
+	}
+
+	entry.File = "../manifest.json"
+	if _, err := ReadSnapshot(dir, entry); err == nil {
+		t.Error("expected a path outside the directory to be rejected")
+	}
+}
+
+func TestListSnapshotsWithoutManifest(t *testing.T) {
+	w, dir := newTestWriter(t)
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, ts := range []time.Time{base.Add(time.Minute), base} {
+		if _, err := w.Write(models.ClusterInfo{Timestamp: ts}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := os.Remove(filepath.Join(dir, ManifestName)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries, err := listSnapshots(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].File != "snapshot-20240501T100000.000Z.json.gz" {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+
+	if _, err := listSnapshots(t.TempDir()); err == nil {
+		t.Error("expected an empty directory to be rejected")
+	}
+}
+
+func TestWriteClusterFileNames(t *testing.T) {
+	w, dir := newTestWriter(t)
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, cluster := range []string{"dev-a", "arn:aws:eks:eu-west-1:123:cluster/prod"} {
+		if _, err := w.Write(models.ClusterInfo{Timestamp: ts, Cluster: cluster}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(manifest.Snapshots) != 2 {
+		t.Fatalf("expected snapshots of the same time in two clusters, got %+v", manifest.Snapshots)
+	}
+	for _, entry := range manifest.Snapshots {
+		if filepath.Base(entry.File) != entry.File {
+			t.Errorf("expected a plain file name, got %q", entry.File)
+		}
+		info, err := ReadSnapshot(dir, entry)
+		if err != nil || info.Cluster != entry.Cluster {
+			t.Errorf("unexpected snapshot %q: %v", entry.File, err)
+		}
+	}
+}
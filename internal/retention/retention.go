@@ -1,22 +1,52 @@
 package retention
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"k8s-cluster-info-collector/internal/archive"
 	"k8s-cluster-info-collector/internal/database"
+	"k8s-cluster-info-collector/internal/metrics"
 
 	"github.com/sirupsen/logrus"
 )
 
 // RetentionManager handles automatic cleanup of old data
 type RetentionManager struct {
-	db     *database.DB
-	logger *logrus.Logger
-	config RetentionConfig
+	db       *database.DB
+	logger   *logrus.Logger
+	config   RetentionConfig
+	archiver *archive.Archiver
+	metrics  *metrics.Metrics
+
+	runMutex sync.Mutex // held while a cleanup run is in progress
+	mutex    sync.RWMutex
+	lastRun  *RunResult
+	stop     chan struct{}
 }
 
+// ErrRunInProgress is returned when a cleanup is requested while another is running
+var ErrRunInProgress = errors.New("retention cleanup already in progress")
+
+// RunResult describes the outcome of a cleanup run
+type RunResult struct {
+	StartedAt       time.Time      `json:"started_at"`
+	Duration        string         `json:"duration"`
+	DryRun          bool           `json:"dry_run"`
+	Planned         CleanupPlan    `json:"planned"`
+	Deleted         int            `json:"deleted"`
+	DeletedByReason map[string]int `json:"deleted_by_reason"`
+	Error           string         `json:"error,omitempty"` // details are only logged
+}
+
+// runFailed is reported as the error of a failed run; the cause can contain
+// database details and is left to the logs
+const runFailed = "retention cleanup failed, see the server log"
+
 // RetentionConfig holds retention policy configuration
 type RetentionConfig struct {
 	Enabled              bool
@@ -25,14 +55,51 @@ type RetentionConfig struct {
 	CleanupInterval      time.Duration
 	DeleteBatchSize      int
 	PreserveLatestPerDay bool
+	Tiers                []Tier
+	DryRun               bool
+}
+
+// Tier is one step of a downsampling policy. Snapshots younger than MaxAge
+// (and older than the previous tier) keep one snapshot per Interval; an
+// Interval of zero keeps every snapshot and a MaxAge of zero never expires.
+type Tier struct {
+	MaxAge   time.Duration
+	Interval time.Duration
+}
+
+// CleanupPlan lists the snapshots a cleanup run deletes, by reason
+type CleanupPlan struct {
+	ByAge          []int `json:"by_age"`
+	ByDownsampling []int `json:"by_downsampling"`
+	ByCount        []int `json:"by_count"`
 }
 
-// New creates a new retention manager
-func New(db *database.DB, logger *logrus.Logger, config RetentionConfig) *RetentionManager {
+// SnapshotIDs returns all snapshot IDs in the plan
+func (p CleanupPlan) SnapshotIDs() []int {
+	ids := make([]int, 0, len(p.ByAge)+len(p.ByDownsampling)+len(p.ByCount))
+	ids = append(ids, p.ByAge...)
+	ids = append(ids, p.ByDownsampling...)
+	ids = append(ids, p.ByCount...)
+	return ids
+}
+
+// snapshotRef is the minimal snapshot information needed for planning
+type snapshotRef struct {
+	id        int
+	timestamp time.Time
+	cluster   string
+}
+
+// New creates a new retention manager. When archiver is non-nil, snapshots
+// are archived before they are deleted; metrics may be nil.
+func New(db *database.DB, logger *logrus.Logger, config RetentionConfig, archiver *archive.Archiver, metrics *metrics.Metrics) *RetentionManager {
 	return &RetentionManager{
-		db:     db,
-		logger: logger,
-		config: config,
+		db:       db,
+		logger:   logger,
+		config:   config,
+		archiver: archiver,
+		metrics:  metrics,
+		stop:     make(chan struct{}),
 	}
 }
 
@@ -47,123 +114,316 @@ func (r *RetentionManager) Start() {
 		"max_age":       r.config.MaxAge,
 		"max_snapshots": r.config.MaxSnapshots,
 		"interval":      r.config.CleanupInterval,
+		"tiers":         len(r.config.Tiers),
+		"dry_run":       r.config.DryRun,
 	}).Info("Starting data retention manager")
 
 	ticker := time.NewTicker(r.config.CleanupInterval)
 	go func() {
-		for range ticker.C {
-			if err := r.cleanup(); err != nil {
-				r.logger.WithError(err).Error("Failed to perform retention cleanup")
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if _, err := r.RunNow(); err != nil {
+					r.logger.WithError(err).Error("Failed to perform retention cleanup")
+				}
 			}
 		}
 	}()
 }
 
-// cleanup performs the actual data cleanup
-func (r *RetentionManager) cleanup() error {
-	r.logger.Info("Starting retention cleanup")
+// Stop ends scheduled cleanups and waits for a run in progress to finish,
+// or until ctx expires
+func (r *RetentionManager) Stop(ctx context.Context) error {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
 
-	deletedCount := 0
+	done := make(chan struct{})
+	go func() {
+		r.runMutex.Lock()
+		r.runMutex.Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("retention cleanup still running: %w", ctx.Err())
+	}
+}
 
-	// Clean up by age
-	if r.config.MaxAge > 0 {
-		count, err := r.cleanupByAge()
-		if err != nil {
-			return fmt.Errorf("failed to cleanup by age: %w", err)
+// RunNow performs a cleanup immediately and records the result as the last
+// run. It returns ErrRunInProgress if another run has not finished yet.
+func (r *RetentionManager) RunNow() (RunResult, error) {
+	if !r.runMutex.TryLock() {
+		return RunResult{}, ErrRunInProgress
+	}
+	defer r.runMutex.Unlock()
+
+	start := time.Now()
+	result := RunResult{
+		StartedAt:       start,
+		DryRun:          r.config.DryRun,
+		DeletedByReason: make(map[string]int),
+	}
+
+	err := r.cleanup(&result)
+	duration := time.Since(start)
+	result.Duration = duration.String()
+
+	status := "success"
+	if err != nil {
+		status = "error"
+		result.Error = runFailed
+	}
+	if r.metrics != nil {
+		r.metrics.RecordRetentionRun(status, duration.Seconds())
+		for reason, count := range result.DeletedByReason {
+			r.metrics.RecordRetentionDeleted(reason, count)
 		}
-		deletedCount += count
 	}
 
-	// Clean up by count
-	if r.config.MaxSnapshots > 0 {
-		count, err := r.cleanupByCount()
+	r.mutex.Lock()
+	r.lastRun = &result
+	r.mutex.Unlock()
+
+	return result, err
+}
+
+// LastRun returns the result of the most recent cleanup run, or nil if none has run
+func (r *RetentionManager) LastRun() *RunResult {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.lastRun
+}
+
+// cleanup performs the actual data cleanup, filling in result as it goes
+func (r *RetentionManager) cleanup(result *RunResult) error {
+	r.logger.Info("Starting retention cleanup")
+
+	plan, err := r.Plan()
+	if err != nil {
+		return fmt.Errorf("failed to plan cleanup: %w", err)
+	}
+	result.Planned = plan
+
+	if r.config.DryRun {
+		r.logger.WithFields(logrus.Fields{
+			"by_age":          plan.ByAge,
+			"by_downsampling": plan.ByDownsampling,
+			"by_count":        plan.ByCount,
+		}).Info("Retention dry run: snapshots that would be deleted")
+		return nil
+	}
+
+	reasons := []struct {
+		name string
+		ids  []int
+	}{
+		{"age", plan.ByAge},
+		{"downsampling", plan.ByDownsampling},
+		{"count", plan.ByCount},
+	}
+	for _, reason := range reasons {
+		count, err := r.deleteInBatches(reason.ids)
+		if count > 0 {
+			result.DeletedByReason[reason.name] += count
+			result.Deleted += count
+		}
 		if err != nil {
-			return fmt.Errorf("failed to cleanup by count: %w", err)
+			return fmt.Errorf("failed to cleanup by %s: %w", reason.name, err)
 		}
-		deletedCount += count
 	}
 
-	r.logger.WithField("deleted_snapshots", deletedCount).Info("Retention cleanup completed")
+	r.logger.WithField("deleted_snapshots", result.Deleted).Info("Retention cleanup completed")
 	return nil
 }
 
-// cleanupByAge removes snapshots older than MaxAge
-func (r *RetentionManager) cleanupByAge() (int, error) {
-	cutoffTime := time.Now().Add(-r.config.MaxAge)
+// deleteInBatches deletes snapshots in transactions of at most DeleteBatchSize
+func (r *RetentionManager) deleteInBatches(snapshotIDs []int) (int, error) {
+	batchSize := r.config.DeleteBatchSize
+	if batchSize <= 0 {
+		batchSize = len(snapshotIDs)
+	}
 
-	var snapshotIDs []int
-	query := `
-		SELECT id FROM cluster_snapshots 
-		WHERE timestamp < $1
-		ORDER BY timestamp ASC
-		LIMIT $2
-	`
+	deletedCount := 0
+	for start := 0; start < len(snapshotIDs); start += batchSize {
+		end := start + batchSize
+		if end > len(snapshotIDs) {
+			end = len(snapshotIDs)
+		}
+		count, err := r.deleteSnapshots(snapshotIDs[start:end])
+		if err != nil {
+			return deletedCount, err
+		}
+		deletedCount += count
+	}
+	return deletedCount, nil
+}
 
-	rows, err := r.db.Query(query, cutoffTime, r.config.DeleteBatchSize)
+// Plan evaluates the retention policy against the current snapshots without
+// deleting anything
+func (r *RetentionManager) Plan() (CleanupPlan, error) {
+	rows, err := r.db.Query("SELECT id, timestamp, cluster FROM cluster_snapshots ORDER BY timestamp ASC")
 	if err != nil {
-		return 0, err
+		return CleanupPlan{}, err
 	}
 	defer rows.Close()
 
+	var snapshots []snapshotRef
 	for rows.Next() {
-		var id int
-		if err := rows.Scan(&id); err != nil {
-			return 0, err
+		var ref snapshotRef
+		if err := rows.Scan(&ref.id, &ref.timestamp, &ref.cluster); err != nil {
+			return CleanupPlan{}, err
 		}
-		snapshotIDs = append(snapshotIDs, id)
+		snapshots = append(snapshots, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return CleanupPlan{}, err
 	}
 
-	if len(snapshotIDs) == 0 {
-		return 0, nil
+	return planClusters(snapshots, r.config, time.Now()), nil
+}
+
+// planClusters applies the policy to each cluster's snapshots separately, so
+// a busy cluster cannot push a quiet one's snapshots over the count limit
+func planClusters(snapshots []snapshotRef, config RetentionConfig, now time.Time) CleanupPlan {
+	var clusters []string
+	byCluster := make(map[string][]snapshotRef)
+	for _, snapshot := range snapshots {
+		if _, ok := byCluster[snapshot.cluster]; !ok {
+			clusters = append(clusters, snapshot.cluster)
+		}
+		byCluster[snapshot.cluster] = append(byCluster[snapshot.cluster], snapshot)
 	}
 
-	return r.deleteSnapshots(snapshotIDs)
+	var plan CleanupPlan
+	for _, cluster := range clusters {
+		clusterPlan := planCleanup(byCluster[cluster], config, now)
+		plan.ByAge = append(plan.ByAge, clusterPlan.ByAge...)
+		plan.ByDownsampling = append(plan.ByDownsampling, clusterPlan.ByDownsampling...)
+		plan.ByCount = append(plan.ByCount, clusterPlan.ByCount...)
+	}
+	return plan
 }
 
-// cleanupByCount removes excess snapshots beyond MaxSnapshots
-func (r *RetentionManager) cleanupByCount() (int, error) {
-	// Count total snapshots
-	var totalCount int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM cluster_snapshots").Scan(&totalCount)
-	if err != nil {
-		return 0, err
+// planCleanup decides which snapshots to delete. Snapshots must be sorted
+// oldest first. Age is applied first, then downsampling tiers, then the
+// snapshot count limit on whatever remains. None of them deletes the latest
+// snapshot of a day when PreserveLatestPerDay is set.
+func planCleanup(snapshots []snapshotRef, config RetentionConfig, now time.Time) CleanupPlan {
+	var plan CleanupPlan
+
+	preserved := make(map[int]bool)
+	if config.PreserveLatestPerDay {
+		preserved = latestPerBucket(snapshots, 24*time.Hour)
 	}
 
-	if totalCount <= r.config.MaxSnapshots {
-		return 0, nil
+	var remaining []snapshotRef
+
+	// Clean up by age
+	for _, snapshot := range snapshots {
+		if config.MaxAge > 0 && now.Sub(snapshot.timestamp) > config.MaxAge && !preserved[snapshot.id] {
+			plan.ByAge = append(plan.ByAge, snapshot.id)
+			continue
+		}
+		remaining = append(remaining, snapshot)
 	}
 
-	excessCount := totalCount - r.config.MaxSnapshots
-	if excessCount > r.config.DeleteBatchSize {
-		excessCount = r.config.DeleteBatchSize
+	// Clean up by downsampling tiers
+	if len(config.Tiers) > 0 {
+		deleted := downsample(remaining, config.Tiers, now)
+		var kept []snapshotRef
+		for _, snapshot := range remaining {
+			if deleted[snapshot.id] && !preserved[snapshot.id] {
+				plan.ByDownsampling = append(plan.ByDownsampling, snapshot.id)
+				continue
+			}
+			kept = append(kept, snapshot)
+		}
+		remaining = kept
+	}
+
+	// Clean up by count, oldest first
+	if config.MaxSnapshots > 0 && len(remaining) > config.MaxSnapshots {
+		excess := len(remaining) - config.MaxSnapshots
+		for _, snapshot := range remaining {
+			if excess == 0 {
+				break
+			}
+			if preserved[snapshot.id] {
+				continue
+			}
+			plan.ByCount = append(plan.ByCount, snapshot.id)
+			excess--
+		}
 	}
 
-	var snapshotIDs []int
-	query := `
-		SELECT id FROM cluster_snapshots 
-		ORDER BY timestamp ASC
-		LIMIT $1
-	`
+	return plan
+}
 
-	rows, err := r.db.Query(query, excessCount)
-	if err != nil {
-		return 0, err
+// downsample returns the IDs of snapshots that fall outside the tiers or are
+// not the latest snapshot in their tier's interval bucket
+func downsample(snapshots []snapshotRef, tiers []Tier, now time.Time) map[int]bool {
+	type bucketKey struct {
+		tier   int
+		bucket time.Time
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var id int
-		if err := rows.Scan(&id); err != nil {
-			return 0, err
+	deleted := make(map[int]bool)
+	latest := make(map[bucketKey]snapshotRef)
+
+	for _, snapshot := range snapshots {
+		age := now.Sub(snapshot.timestamp)
+
+		tier := -1
+		for i, t := range tiers {
+			if t.MaxAge == 0 || age < t.MaxAge {
+				tier = i
+				break
+			}
+		}
+
+		switch {
+		case tier < 0:
+			// Older than every tier
+			deleted[snapshot.id] = true
+		case tiers[tier].Interval > 0:
+			key := bucketKey{tier: tier, bucket: snapshot.timestamp.UTC().Truncate(tiers[tier].Interval)}
+			if previous, ok := latest[key]; ok {
+				if previous.timestamp.After(snapshot.timestamp) {
+					deleted[snapshot.id] = true
+					continue
+				}
+				deleted[previous.id] = true
+			}
+			latest[key] = snapshot
 		}
-		snapshotIDs = append(snapshotIDs, id)
 	}
 
-	if len(snapshotIDs) == 0 {
-		return 0, nil
+	return deleted
+}
+
+// latestPerBucket returns the IDs of the latest snapshot in each interval bucket
+func latestPerBucket(snapshots []snapshotRef, interval time.Duration) map[int]bool {
+	latest := make(map[time.Time]snapshotRef)
+	for _, snapshot := range snapshots {
+		bucket := snapshot.timestamp.UTC().Truncate(interval)
+		if previous, ok := latest[bucket]; !ok || snapshot.timestamp.After(previous.timestamp) {
+			latest[bucket] = snapshot
+		}
 	}
 
-	return r.deleteSnapshots(snapshotIDs)
+	ids := make(map[int]bool, len(latest))
+	for _, snapshot := range latest {
+		ids[snapshot.id] = true
+	}
+	return ids
 }
 
 // deleteSnapshots removes the specified snapshots and all related data
@@ -172,6 +432,13 @@ func (r *RetentionManager) deleteSnapshots(snapshotIDs []int) (int, error) {
 		return 0, nil
 	}
 
+	// Archive before deleting; never delete history that could not be archived
+	if r.archiver != nil {
+		if _, err := r.archiver.ArchiveSnapshots(context.Background(), snapshotIDs); err != nil {
+			return 0, fmt.Errorf("failed to archive snapshots: %w", err)
+		}
+	}
+
 	tx, err := r.db.Begin()
 	if err != nil {
 		return 0, err
@@ -225,41 +492,68 @@ func (r *RetentionManager) deleteSnapshots(snapshotIDs []int) (int, error) {
 
 // GetRetentionStats returns statistics about data retention
 func (r *RetentionManager) GetRetentionStats() (RetentionStats, error) {
+	return CollectStats(r.db)
+}
+
+// CollectStats gathers snapshot and per-table storage statistics. It only
+// needs a database connection, so it can be used without a RetentionManager.
+func CollectStats(db *database.DB) (RetentionStats, error) {
 	stats := RetentionStats{}
 
-	// Total snapshots
-	err := r.db.QueryRow("SELECT COUNT(*) FROM cluster_snapshots").Scan(&stats.TotalSnapshots)
+	// Total, oldest and newest snapshots
+	var oldest, newest sql.NullTime
+	err := db.QueryRow("SELECT COUNT(*), MIN(timestamp), MAX(timestamp) FROM cluster_snapshots").
+		Scan(&stats.TotalSnapshots, &oldest, &newest)
 	if err != nil {
 		return stats, err
 	}
-
-	// Oldest snapshot
-	err = r.db.QueryRow("SELECT MIN(timestamp) FROM cluster_snapshots").Scan(&stats.OldestSnapshot)
-	if err != nil && err != sql.ErrNoRows {
-		return stats, err
-	}
-
-	// Newest snapshot
-	err = r.db.QueryRow("SELECT MAX(timestamp) FROM cluster_snapshots").Scan(&stats.NewestSnapshot)
-	if err != nil && err != sql.ErrNoRows {
-		return stats, err
-	}
+	stats.OldestSnapshot = oldest.Time
+	stats.NewestSnapshot = newest.Time
 
 	// Database size (PostgreSQL specific)
-	err = r.db.QueryRow(`
+	err = db.QueryRow(`
 		SELECT pg_size_pretty(pg_total_relation_size('cluster_snapshots')) as size
 	`).Scan(&stats.DatabaseSize)
 	if err != nil {
 		stats.DatabaseSize = "unknown"
 	}
 
-	return stats, nil
+	// Per-table row counts (planner estimates, cheap on large tables) and on-disk sizes
+	rows, err := db.Query(`
+		SELECT relname, n_live_tup, pg_total_relation_size(relid),
+			pg_size_pretty(pg_total_relation_size(relid))
+		FROM pg_stat_user_tables
+		ORDER BY pg_total_relation_size(relid) DESC
+	`)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table TableStats
+		if err := rows.Scan(&table.Table, &table.Rows, &table.SizeBytes, &table.Size); err != nil {
+			return stats, err
+		}
+		stats.Tables = append(stats.Tables, table)
+	}
+
+	return stats, rows.Err()
 }
 
 // RetentionStats holds retention statistics
 type RetentionStats struct {
-	TotalSnapshots int       `json:"total_snapshots"`
-	OldestSnapshot time.Time `json:"oldest_snapshot"`
-	NewestSnapshot time.Time `json:"newest_snapshot"`
-	DatabaseSize   string    `json:"database_size"`
+	TotalSnapshots int          `json:"total_snapshots"`
+	OldestSnapshot time.Time    `json:"oldest_snapshot"`
+	NewestSnapshot time.Time    `json:"newest_snapshot"`
+	DatabaseSize   string       `json:"database_size"`
+	Tables         []TableStats `json:"tables"`
+}
+
+// TableStats holds the row count and on-disk size of one table
+type TableStats struct {
+	Table     string `json:"table"`
+	Rows      int64  `json:"rows"`
+	SizeBytes int64  `json:"size_bytes"`
+	Size      string `json:"size"`
 }
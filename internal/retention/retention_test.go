@@ -0,0 +1,158 @@
+package retention
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/database"
+	"k8s-cluster-info-collector/internal/testutil"
+)
+
+func snapshotsEvery(start time.Time, interval time.Duration, count int) []snapshotRef {
+	var snapshots []snapshotRef
+	for i := 0; i < count; i++ {
+		snapshots = append(snapshots, snapshotRef{id: i + 1, timestamp: start.Add(time.Duration(i) * interval)})
+	}
+	return snapshots
+}
+
+func TestPlanCleanupByAgeAndCount(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	// One snapshot per day for 10 days: ids 1 (oldest) to 10
+	snapshots := snapshotsEvery(now.Add(-10*24*time.Hour), 24*time.Hour, 10)
+
+	plan := planCleanup(snapshots, RetentionConfig{
+		MaxAge:       7*24*time.Hour + time.Minute,
+		MaxSnapshots: 5,
+	}, now)
+
+	if !reflect.DeepEqual(plan.ByAge, []int{1, 2, 3}) {
+		t.Errorf("expected by_age [1 2 3], got %v", plan.ByAge)
+	}
+	if !reflect.DeepEqual(plan.ByCount, []int{4, 5}) {
+		t.Errorf("expected by_count [4 5], got %v", plan.ByCount)
+	}
+}
+
+func TestPlanCleanupTiers(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	// One snapshot every 15 minutes for the last 4 hours: ids 1 to 16
+	snapshots := snapshotsEvery(now.Add(-4*time.Hour), 15*time.Minute, 16)
+
+	plan := planCleanup(snapshots, RetentionConfig{
+		Tiers: []Tier{
+			{MaxAge: time.Hour, Interval: 0},
+			{MaxAge: 0, Interval: time.Hour},
+		},
+	}, now)
+
+	// Snapshots at least an hour old (ids 1-13) keep only the latest per
+	// hour bucket: ids 4, 8, 12 and 13 (the only one from 23:00 in that tier)
+	expected := []int{1, 2, 3, 5, 6, 7, 9, 10, 11}
+	if !reflect.DeepEqual(plan.ByDownsampling, expected) {
+		t.Errorf("expected by_downsampling %v, got %v", expected, plan.ByDownsampling)
+	}
+	if len(plan.ByAge) != 0 || len(plan.ByCount) != 0 {
+		t.Errorf("expected no age or count deletions, got %v and %v", plan.ByAge, plan.ByCount)
+	}
+}
+
+func TestPlanCleanupTiersExpire(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	snapshots := snapshotsEvery(now.Add(-72*time.Hour), 24*time.Hour, 3)
+
+	plan := planCleanup(snapshots, RetentionConfig{
+		Tiers: []Tier{{MaxAge: 60 * time.Hour, Interval: 0}},
+	}, now)
+
+	if !reflect.DeepEqual(plan.ByDownsampling, []int{1}) {
+		t.Errorf("expected by_downsampling [1], got %v", plan.ByDownsampling)
+	}
+}
+
+func TestPlanCleanupPreserveLatestPerDay(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	// Four snapshots per day for three days, all older than MaxAge
+	snapshots := snapshotsEvery(now.Add(-10*24*time.Hour), 6*time.Hour, 12)
+
+	plan := planCleanup(snapshots, RetentionConfig{
+		MaxAge:               24 * time.Hour,
+		PreserveLatestPerDay: true,
+	}, now)
+
+	if len(plan.ByAge) != 9 {
+		t.Errorf("expected 9 deletions, got %d: %v", len(plan.ByAge), plan.ByAge)
+	}
+	for _, id := range plan.ByAge {
+		if id%4 == 0 {
+			t.Errorf("latest snapshot of a day (id %d) should be preserved", id)
+		}
+	}
+}
+
+func TestPlanCleanupTiersPreserveLatestPerDay(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	// Four snapshots per day for three days, from 2023-12-31
+	snapshots := snapshotsEvery(now.Add(-10*24*time.Hour), 6*time.Hour, 12)
+
+	for name, tiers := range map[string][]Tier{
+		// Every snapshot is older than the only tier
+		"expired": {{MaxAge: 5 * 24 * time.Hour, Interval: 0}},
+		// 2023-12-31 and 2024-01-01 share a bucket whose latest is id 8
+		"two-day buckets": {{MaxAge: 0, Interval: 48 * time.Hour}},
+	} {
+		plan := planCleanup(snapshots, RetentionConfig{Tiers: tiers, PreserveLatestPerDay: true}, now)
+
+		expected := []int{1, 2, 3, 5, 6, 7, 9, 10, 11}
+		if !reflect.DeepEqual(plan.ByDownsampling, expected) {
+			t.Errorf("%s: expected by_downsampling %v, got %v", name, expected, plan.ByDownsampling)
+		}
+	}
+}
+
+func TestPlanClustersSeparately(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	// Two clusters take turns: odd ids are prod, even ids are staging
+	snapshots := snapshotsEvery(now.Add(-6*time.Hour), time.Hour, 6)
+	for i := range snapshots {
+		snapshots[i].cluster = "prod"
+		if snapshots[i].id%2 == 0 {
+			snapshots[i].cluster = "staging"
+		}
+	}
+
+	plan := planClusters(snapshots, RetentionConfig{MaxSnapshots: 2}, now)
+
+	if !reflect.DeepEqual(plan.ByCount, []int{1, 2}) {
+		t.Errorf("expected the oldest snapshot of each cluster [1 2], got %v", plan.ByCount)
+	}
+}
+
+func TestRunNowHidesErrorDetails(t *testing.T) {
+	db := testutil.FakeDB{
+		Query: func(string, []driver.Value) [][]driver.Value {
+			return [][]driver.Value{{int64(1), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "prod"}}
+		},
+		Exec: func(string, []driver.Value) error {
+			return errors.New(`pq: permission denied for table "cluster_snapshots"`)
+		},
+	}.Open(t)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	manager := New(&database.DB{DB: db}, logger, RetentionConfig{MaxAge: time.Hour}, nil, nil)
+
+	if _, err := manager.RunNow(); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected the cause to be returned, got %v", err)
+	}
+	lastRun := manager.LastRun()
+	if lastRun == nil || lastRun.Error != runFailed {
+		t.Errorf("expected the last run to report %q, got %+v", runFailed, lastRun)
+	}
+}
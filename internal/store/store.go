@@ -1,9 +1,11 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
@@ -26,8 +28,11 @@ func New(db *database.DB, logger *logrus.Logger) *Store {
 	}
 }
 
-// StoreClusterInfo stores complete cluster information in the database
+// StoreClusterInfo stores complete cluster information in the database.
+// Snapshots without a cluster are stored under models.DefaultCluster.
 func (s *Store) StoreClusterInfo(info models.ClusterInfo) error {
+	cluster := info.ClusterName()
+
 	tx, err := s.db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -42,66 +47,80 @@ func (s *Store) StoreClusterInfo(info models.ClusterInfo) error {
 
 	var snapshotID int
 	err = tx.QueryRow(
-		"INSERT INTO cluster_snapshots (timestamp, data) VALUES ($1, $2) RETURNING id",
-		info.Timestamp, dataJSON,
+		"INSERT INTO cluster_snapshots (timestamp, data, cluster) VALUES ($1, $2, $3) RETURNING id",
+		info.Timestamp, dataJSON, cluster,
 	).Scan(&snapshotID)
 	if err != nil {
 		return fmt.Errorf("failed to insert cluster snapshot: %w", err)
 	}
 
-	s.logger.WithField("snapshot_id", snapshotID).Info("Created cluster snapshot")
+	s.logger.WithFields(logrus.Fields{
+		"snapshot_id": snapshotID,
+		"cluster":     cluster,
+	}).Info("Created cluster snapshot")
+
+	// Record the cluster and when it was last seen
+	if err := s.upsertCluster(tx, cluster, info); err != nil {
+		return fmt.Errorf("failed to record cluster: %w", err)
+	}
 
 	// Store deployments
-	if err := s.storeDeployments(tx, snapshotID, info.Deployments); err != nil {
+	if err := s.storeDeployments(tx, snapshotID, cluster, info.Deployments); err != nil {
 		return fmt.Errorf("failed to store deployments: %w", err)
 	}
 
 	// Store pods
-	if err := s.storePods(tx, snapshotID, info.Pods); err != nil {
+	if err := s.storePods(tx, snapshotID, cluster, info.Pods); err != nil {
 		return fmt.Errorf("failed to store pods: %w", err)
 	}
 
 	// Store nodes
-	if err := s.storeNodes(tx, snapshotID, info.Nodes); err != nil {
+	if err := s.storeNodes(tx, snapshotID, cluster, info.Nodes); err != nil {
 		return fmt.Errorf("failed to store nodes: %w", err)
 	}
 
 	// Store services
-	if err := s.storeServices(tx, snapshotID, info.Services); err != nil {
+	if err := s.storeServices(tx, snapshotID, cluster, info.Services); err != nil {
 		return fmt.Errorf("failed to store services: %w", err)
 	}
 
 	// Store ingresses
-	if err := s.storeIngresses(tx, snapshotID, info.Ingresses); err != nil {
+	if err := s.storeIngresses(tx, snapshotID, cluster, info.Ingresses); err != nil {
 		return fmt.Errorf("failed to store ingresses: %w", err)
 	}
 
 	// Store configmaps
-	if err := s.storeConfigMaps(tx, snapshotID, info.ConfigMaps); err != nil {
+	if err := s.storeConfigMaps(tx, snapshotID, cluster, info.ConfigMaps); err != nil {
 		return fmt.Errorf("failed to store configmaps: %w", err)
 	}
 
 	// Store secrets
-	if err := s.storeSecrets(tx, snapshotID, info.Secrets); err != nil {
+	if err := s.storeSecrets(tx, snapshotID, cluster, info.Secrets); err != nil {
 		return fmt.Errorf("failed to store secrets: %w", err)
 	}
 
 	// Store persistent volumes
-	if err := s.storePersistentVolumes(tx, snapshotID, info.PersistentVolumes); err != nil {
+	if err := s.storePersistentVolumes(tx, snapshotID, cluster, info.PersistentVolumes); err != nil {
 		return fmt.Errorf("failed to store persistent volumes: %w", err)
 	}
 
 	// Store persistent volume claims
-	if err := s.storePersistentVolumeClaims(tx, snapshotID, info.PersistentVolumeClaims); err != nil {
+	if err := s.storePersistentVolumeClaims(tx, snapshotID, cluster, info.PersistentVolumeClaims); err != nil {
 		return fmt.Errorf("failed to store persistent volume claims: %w", err)
 	}
 
+	// Track object lifecycles across snapshots
+	if err := s.trackObjects(tx, snapshotID, cluster, info); err != nil {
+		return fmt.Errorf("failed to track objects: %w", err)
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	s.logger.WithFields(logrus.Fields{
 		"snapshot_id":              snapshotID,
+		"cluster":                  cluster,
 		"deployments":              len(info.Deployments),
 		"pods":                     len(info.Pods),
 		"nodes":                    len(info.Nodes),
@@ -116,8 +135,36 @@ func (s *Store) StoreClusterInfo(info models.ClusterInfo) error {
 	return nil
 }
 
+// HasSnapshot reports whether a snapshot of cluster taken at timestamp is
+// already stored, so redelivered or re-imported snapshots can be skipped
+func (s *Store) HasSnapshot(ctx context.Context, cluster string, timestamp time.Time) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM cluster_snapshots WHERE timestamp = $1 AND cluster = $2)",
+		timestamp, cluster).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for existing snapshot: %w", err)
+	}
+	return exists, nil
+}
+
+// upsertCluster adds the cluster to the clusters table or widens its first
+// and last seen times, so importing an older snapshot never moves last_seen
+// backwards
+func (s *Store) upsertCluster(tx *sql.Tx, cluster string, info models.ClusterInfo) error {
+	_, err := tx.Exec(`
+		INSERT INTO clusters (name, uid, first_seen, last_seen)
+		VALUES ($1, NULLIF($2, ''), $3, $3)
+		ON CONFLICT (name) DO UPDATE SET
+			uid = COALESCE(EXCLUDED.uid, clusters.uid),
+			first_seen = LEAST(clusters.first_seen, EXCLUDED.first_seen),
+			last_seen = GREATEST(clusters.last_seen, EXCLUDED.last_seen)`,
+		cluster, info.ClusterUID, info.Timestamp)
+	return err
+}
+
 // storeDeployments stores deployment information
-func (s *Store) storeDeployments(tx *sql.Tx, snapshotID int, deployments []models.DeploymentInfo) error {
+func (s *Store) storeDeployments(tx *sql.Tx, snapshotID int, cluster string, deployments []models.DeploymentInfo) error {
 	for _, deployment := range deployments {
 		deploymentJSON, err := json.Marshal(deployment)
 		if err != nil {
@@ -126,10 +173,11 @@ func (s *Store) storeDeployments(tx *sql.Tx, snapshotID int, deployments []model
 
 		_, err = tx.Exec(`
 			INSERT INTO deployments (snapshot_id, name, namespace, created_time, replicas, 
-				ready_replicas, updated_replicas, data) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+				ready_replicas, updated_replicas, data, uid, resource_version, generation, cluster)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
 			snapshotID, deployment.Name, deployment.Namespace, deployment.CreatedTime,
-			deployment.Replicas, deployment.ReadyReplicas, deployment.UpdatedReplicas, deploymentJSON)
+			deployment.Replicas, deployment.ReadyReplicas, deployment.UpdatedReplicas, deploymentJSON,
+			deployment.UID, deployment.ResourceVersion, deployment.Generation, cluster)
 		if err != nil {
 			return fmt.Errorf("failed to insert deployment %s: %w", deployment.Name, err)
 		}
@@ -138,7 +186,7 @@ func (s *Store) storeDeployments(tx *sql.Tx, snapshotID int, deployments []model
 }
 
 // storePods stores pod information
-func (s *Store) storePods(tx *sql.Tx, snapshotID int, pods []models.PodInfo) error {
+func (s *Store) storePods(tx *sql.Tx, snapshotID int, cluster string, pods []models.PodInfo) error {
 	for _, pod := range pods {
 		podJSON, err := json.Marshal(pod)
 		if err != nil {
@@ -148,11 +196,17 @@ func (s *Store) storePods(tx *sql.Tx, snapshotID int, pods []models.PodInfo) err
 		_, err = tx.Exec(`
 			INSERT INTO pods (snapshot_id, name, namespace, deployment_name, created_time, 
 				phase, node_name, restart_count, cpu_request, cpu_limit, memory_request, 
-				memory_limit, storage_request, data) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+				memory_limit, storage_request, data, uid, resource_version, generation,
+				cpu_request_millicores, cpu_limit_millicores, memory_request_bytes,
+				memory_limit_bytes, storage_request_bytes, cluster)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+				$18, $19, $20, $21, $22, $23)`,
 			snapshotID, pod.Name, pod.Namespace, pod.DeploymentName, pod.CreatedTime,
 			pod.Phase, pod.NodeName, pod.RestartCount, pod.CPURequest, pod.CPULimit,
-			pod.MemoryRequest, pod.MemoryLimit, pod.StorageRequest, podJSON)
+			pod.MemoryRequest, pod.MemoryLimit, pod.StorageRequest, podJSON,
+			pod.UID, pod.ResourceVersion, pod.Generation,
+			milliCores(pod.CPURequest), milliCores(pod.CPULimit), bytesQuantity(pod.MemoryRequest),
+			bytesQuantity(pod.MemoryLimit), bytesQuantity(pod.StorageRequest), cluster)
 		if err != nil {
 			return fmt.Errorf("failed to insert pod %s: %w", pod.Name, err)
 		}
@@ -161,7 +215,7 @@ func (s *Store) storePods(tx *sql.Tx, snapshotID int, pods []models.PodInfo) err
 }
 
 // storeNodes stores node information
-func (s *Store) storeNodes(tx *sql.Tx, snapshotID int, nodes []models.NodeInfo) error {
+func (s *Store) storeNodes(tx *sql.Tx, snapshotID int, cluster string, nodes []models.NodeInfo) error {
 	for _, node := range nodes {
 		nodeJSON, err := json.Marshal(node)
 		if err != nil {
@@ -171,12 +225,20 @@ func (s *Store) storeNodes(tx *sql.Tx, snapshotID int, nodes []models.NodeInfo)
 		_, err = tx.Exec(`
 			INSERT INTO nodes (snapshot_id, name, created_time, ready, cpu_capacity, 
 				memory_capacity, storage_capacity, cpu_allocatable, memory_allocatable, 
-				storage_allocatable, os_image, kernel_version, kubelet_version, data) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+				storage_allocatable, os_image, kernel_version, kubelet_version, data,
+				uid, resource_version, generation, cpu_capacity_millicores, memory_capacity_bytes,
+				storage_capacity_bytes, cpu_allocatable_millicores, memory_allocatable_bytes,
+				storage_allocatable_bytes, cluster)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+				$18, $19, $20, $21, $22, $23, $24)`,
 			snapshotID, node.Name, node.CreatedTime, node.Ready, node.CPUCapacity,
 			node.MemoryCapacity, node.StorageCapacity, node.CPUAllocatable,
 			node.MemoryAllocatable, node.StorageAllocatable, node.OSImage,
-			node.KernelVersion, node.KubeletVersion, nodeJSON)
+			node.KernelVersion, node.KubeletVersion, nodeJSON,
+			node.UID, node.ResourceVersion, node.Generation,
+			milliCores(node.CPUCapacity), bytesQuantity(node.MemoryCapacity),
+			bytesQuantity(node.StorageCapacity), milliCores(node.CPUAllocatable),
+			bytesQuantity(node.MemoryAllocatable), bytesQuantity(node.StorageAllocatable), cluster)
 		if err != nil {
 			return fmt.Errorf("failed to insert node %s: %w", node.Name, err)
 		}
@@ -185,7 +247,7 @@ func (s *Store) storeNodes(tx *sql.Tx, snapshotID int, nodes []models.NodeInfo)
 }
 
 // storeServices stores service information
-func (s *Store) storeServices(tx *sql.Tx, snapshotID int, services []models.ServiceInfo) error {
+func (s *Store) storeServices(tx *sql.Tx, snapshotID int, cluster string, services []models.ServiceInfo) error {
 	for _, service := range services {
 		serviceJSON, err := json.Marshal(service)
 		if err != nil {
@@ -194,10 +256,11 @@ func (s *Store) storeServices(tx *sql.Tx, snapshotID int, services []models.Serv
 
 		_, err = tx.Exec(`
 			INSERT INTO services (snapshot_id, name, namespace, created_time, type, 
-				cluster_ip, external_ips, data) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+				cluster_ip, external_ips, data, uid, resource_version, generation, cluster)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
 			snapshotID, service.Name, service.Namespace, service.CreatedTime,
-			service.Type, service.ClusterIP, pq.Array(service.ExternalIPs), serviceJSON)
+			service.Type, service.ClusterIP, pq.Array(service.ExternalIPs), serviceJSON,
+			service.UID, service.ResourceVersion, service.Generation, cluster)
 		if err != nil {
 			return fmt.Errorf("failed to insert service %s: %w", service.Name, err)
 		}
@@ -206,7 +269,7 @@ func (s *Store) storeServices(tx *sql.Tx, snapshotID int, services []models.Serv
 }
 
 // storeIngresses stores ingress information
-func (s *Store) storeIngresses(tx *sql.Tx, snapshotID int, ingresses []models.IngressInfo) error {
+func (s *Store) storeIngresses(tx *sql.Tx, snapshotID int, cluster string, ingresses []models.IngressInfo) error {
 	for _, ingress := range ingresses {
 		ingressJSON, err := json.Marshal(ingress)
 		if err != nil {
@@ -214,10 +277,12 @@ func (s *Store) storeIngresses(tx *sql.Tx, snapshotID int, ingresses []models.In
 		}
 
 		_, err = tx.Exec(`
-			INSERT INTO ingresses (snapshot_id, name, namespace, created_time, hosts, data) 
-			VALUES ($1, $2, $3, $4, $5, $6)`,
+			INSERT INTO ingresses (snapshot_id, name, namespace, created_time, hosts, data,
+				uid, resource_version, generation, cluster)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
 			snapshotID, ingress.Name, ingress.Namespace, ingress.CreatedTime,
-			pq.Array(ingress.Hosts), ingressJSON)
+			pq.Array(ingress.Hosts), ingressJSON,
+			ingress.UID, ingress.ResourceVersion, ingress.Generation, cluster)
 		if err != nil {
 			return fmt.Errorf("failed to insert ingress %s: %w", ingress.Name, err)
 		}
@@ -226,7 +291,7 @@ func (s *Store) storeIngresses(tx *sql.Tx, snapshotID int, ingresses []models.In
 }
 
 // storeConfigMaps stores configmap information
-func (s *Store) storeConfigMaps(tx *sql.Tx, snapshotID int, configMaps []models.ConfigMapInfo) error {
+func (s *Store) storeConfigMaps(tx *sql.Tx, snapshotID int, cluster string, configMaps []models.ConfigMapInfo) error {
 	for _, cm := range configMaps {
 		cmJSON, err := json.Marshal(cm)
 		if err != nil {
@@ -240,9 +305,11 @@ func (s *Store) storeConfigMaps(tx *sql.Tx, snapshotID int, configMaps []models.
 		}
 
 		_, err = tx.Exec(`
-			INSERT INTO configmaps (snapshot_id, name, namespace, created_time, data_keys, data) 
-			VALUES ($1, $2, $3, $4, $5, $6)`,
-			snapshotID, cm.Name, cm.Namespace, cm.CreatedTime, pq.Array(dataKeys), cmJSON)
+			INSERT INTO configmaps (snapshot_id, name, namespace, created_time, data_keys, data,
+				uid, resource_version, generation, cluster)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			snapshotID, cm.Name, cm.Namespace, cm.CreatedTime, pq.Array(dataKeys), cmJSON,
+			cm.UID, cm.ResourceVersion, cm.Generation, cluster)
 		if err != nil {
 			return fmt.Errorf("failed to insert configmap %s: %w", cm.Name, err)
 		}
@@ -251,7 +318,7 @@ func (s *Store) storeConfigMaps(tx *sql.Tx, snapshotID int, configMaps []models.
 }
 
 // storeSecrets stores secret information
-func (s *Store) storeSecrets(tx *sql.Tx, snapshotID int, secrets []models.SecretInfo) error {
+func (s *Store) storeSecrets(tx *sql.Tx, snapshotID int, cluster string, secrets []models.SecretInfo) error {
 	for _, secret := range secrets {
 		secretJSON, err := json.Marshal(secret)
 		if err != nil {
@@ -259,10 +326,12 @@ func (s *Store) storeSecrets(tx *sql.Tx, snapshotID int, secrets []models.Secret
 		}
 
 		_, err = tx.Exec(`
-			INSERT INTO secrets (snapshot_id, name, namespace, created_time, type, data_keys, data) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			INSERT INTO secrets (snapshot_id, name, namespace, created_time, type, data_keys, data,
+				uid, resource_version, generation, cluster)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
 			snapshotID, secret.Name, secret.Namespace, secret.CreatedTime,
-			secret.Type, pq.Array(secret.DataKeys), secretJSON)
+			secret.Type, pq.Array(secret.DataKeys), secretJSON,
+			secret.UID, secret.ResourceVersion, secret.Generation, cluster)
 		if err != nil {
 			return fmt.Errorf("failed to insert secret %s: %w", secret.Name, err)
 		}
@@ -271,7 +340,7 @@ func (s *Store) storeSecrets(tx *sql.Tx, snapshotID int, secrets []models.Secret
 }
 
 // storePersistentVolumes stores persistent volume information
-func (s *Store) storePersistentVolumes(tx *sql.Tx, snapshotID int, pvs []models.PersistentVolumeInfo) error {
+func (s *Store) storePersistentVolumes(tx *sql.Tx, snapshotID int, cluster string, pvs []models.PersistentVolumeInfo) error {
 	for _, pv := range pvs {
 		pvJSON, err := json.Marshal(pv)
 		if err != nil {
@@ -280,11 +349,13 @@ func (s *Store) storePersistentVolumes(tx *sql.Tx, snapshotID int, pvs []models.
 
 		_, err = tx.Exec(`
 			INSERT INTO persistent_volumes (snapshot_id, name, created_time, capacity, 
-				access_modes, reclaim_policy, storage_class, status, volume_source, data) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+				access_modes, reclaim_policy, storage_class, status, volume_source, data,
+				uid, resource_version, generation, cluster)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
 			snapshotID, pv.Name, pv.CreatedTime, pv.Capacity,
 			pq.Array(pv.AccessModes), pv.ReclaimPolicy, pv.StorageClass,
-			pv.Status, pv.VolumeSource, pvJSON)
+			pv.Status, pv.VolumeSource, pvJSON,
+			pv.UID, pv.ResourceVersion, pv.Generation, cluster)
 		if err != nil {
 			return fmt.Errorf("failed to insert persistent volume %s: %w", pv.Name, err)
 		}
@@ -293,7 +364,7 @@ func (s *Store) storePersistentVolumes(tx *sql.Tx, snapshotID int, pvs []models.
 }
 
 // storePersistentVolumeClaims stores persistent volume claim information
-func (s *Store) storePersistentVolumeClaims(tx *sql.Tx, snapshotID int, pvcs []models.PersistentVolumeClaimInfo) error {
+func (s *Store) storePersistentVolumeClaims(tx *sql.Tx, snapshotID int, cluster string, pvcs []models.PersistentVolumeClaimInfo) error {
 	for _, pvc := range pvcs {
 		pvcJSON, err := json.Marshal(pvc)
 		if err != nil {
@@ -302,14 +373,162 @@ func (s *Store) storePersistentVolumeClaims(tx *sql.Tx, snapshotID int, pvcs []m
 
 		_, err = tx.Exec(`
 			INSERT INTO persistent_volume_claims (snapshot_id, name, namespace, created_time, 
-				requested_size, access_modes, storage_class, status, volume_name, data) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+				requested_size, access_modes, storage_class, status, volume_name, data,
+				uid, resource_version, generation, cluster)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
 			snapshotID, pvc.Name, pvc.Namespace, pvc.CreatedTime,
 			pvc.RequestedSize, pq.Array(pvc.AccessModes), pvc.StorageClass,
-			pvc.Status, pvc.VolumeName, pvcJSON)
+			pvc.Status, pvc.VolumeName, pvcJSON,
+			pvc.UID, pvc.ResourceVersion, pvc.Generation, cluster)
 		if err != nil {
 			return fmt.Errorf("failed to insert persistent volume claim %s: %w", pvc.Name, err)
 		}
 	}
 	return nil
 }
+
+// milliCores converts a CPU quantity string to a nullable millicore value
+func milliCores(quantity string) sql.NullInt64 {
+	value, ok := models.ParseMilliCores(quantity)
+	return sql.NullInt64{Int64: value, Valid: ok}
+}
+
+// bytesQuantity converts a memory or storage quantity string to a nullable byte value
+func bytesQuantity(quantity string) sql.NullInt64 {
+	value, ok := models.ParseBytes(quantity)
+	return sql.NullInt64{Int64: value, Valid: ok}
+}
+
+// objectRef identifies an object for lifecycle tracking
+type objectRef struct {
+	kind        string
+	uid         string
+	namespace   string
+	name        string
+	createdTime time.Time
+}
+
+// objectRefs flattens every object in a snapshot into lifecycle references
+func objectRefs(info models.ClusterInfo) []objectRef {
+	var refs []objectRef
+	for _, d := range info.Deployments {
+		refs = append(refs, objectRef{models.KindDeployment, d.UID, d.Namespace, d.Name, d.CreatedTime})
+	}
+	for _, p := range info.Pods {
+		refs = append(refs, objectRef{models.KindPod, p.UID, p.Namespace, p.Name, p.CreatedTime})
+	}
+	for _, n := range info.Nodes {
+		refs = append(refs, objectRef{models.KindNode, n.UID, "", n.Name, n.CreatedTime})
+	}
+	for _, svc := range info.Services {
+		refs = append(refs, objectRef{models.KindService, svc.UID, svc.Namespace, svc.Name, svc.CreatedTime})
+	}
+	for _, ing := range info.Ingresses {
+		refs = append(refs, objectRef{models.KindIngress, ing.UID, ing.Namespace, ing.Name, ing.CreatedTime})
+	}
+	for _, cm := range info.ConfigMaps {
+		refs = append(refs, objectRef{models.KindConfigMap, cm.UID, cm.Namespace, cm.Name, cm.CreatedTime})
+	}
+	for _, secret := range info.Secrets {
+		refs = append(refs, objectRef{models.KindSecret, secret.UID, secret.Namespace, secret.Name, secret.CreatedTime})
+	}
+	for _, pv := range info.PersistentVolumes {
+		refs = append(refs, objectRef{models.KindPersistentVolume, pv.UID, "", pv.Name, pv.CreatedTime})
+	}
+	for _, pvc := range info.PersistentVolumeClaims {
+		refs = append(refs, objectRef{models.KindPersistentVolumeClaim, pvc.UID, pvc.Namespace, pvc.Name, pvc.CreatedTime})
+	}
+	return refs
+}
+
+// trackObjects records every object in the snapshot in the objects table and
+// marks objects of the same cluster that were present before but are missing
+// now as deleted. Snapshots may arrive out of order (imports, archive
+// re-imports, Kafka redelivery), so an older snapshot only widens first_seen
+// and never moves last_seen backwards or revives an object deleted since.
+func (s *Store) trackObjects(tx *sql.Tx, snapshotID int, cluster string, info models.ClusterInfo) error {
+	var kinds, uids, namespaces, names, createdTimes []string
+	seen := make(map[[2]string]bool)
+	for _, ref := range objectRefs(info) {
+		// Snapshots from older collectors carry no UID; a row may only be
+		// upserted once per statement
+		key := [2]string{ref.kind, ref.uid}
+		if ref.uid == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		kinds = append(kinds, ref.kind)
+		uids = append(uids, ref.uid)
+		namespaces = append(namespaces, ref.namespace)
+		names = append(names, ref.name)
+		createdTimes = append(createdTimes, ref.createdTime.Format(time.RFC3339Nano))
+	}
+
+	if len(uids) > 0 {
+		_, err := tx.Exec(`
+			INSERT INTO objects (cluster, kind, uid, namespace, name, created_time, first_seen,
+				last_seen, first_snapshot_id, last_snapshot_id)
+			SELECT $1, o.kind, o.uid, o.namespace, o.name, o.created_time, $2, $2, $3, $3
+			FROM unnest($4::text[], $5::text[], $6::text[], $7::text[], $8::timestamp[])
+				AS o(kind, uid, namespace, name, created_time)
+			ON CONFLICT (cluster, kind, uid) DO UPDATE SET
+				namespace = CASE WHEN EXCLUDED.last_seen >= objects.last_seen
+					THEN EXCLUDED.namespace ELSE objects.namespace END,
+				name = CASE WHEN EXCLUDED.last_seen >= objects.last_seen
+					THEN EXCLUDED.name ELSE objects.name END,
+				first_seen = LEAST(objects.first_seen, EXCLUDED.first_seen),
+				first_snapshot_id = CASE WHEN EXCLUDED.first_seen < objects.first_seen
+					THEN EXCLUDED.first_snapshot_id ELSE objects.first_snapshot_id END,
+				last_seen = GREATEST(objects.last_seen, EXCLUDED.last_seen),
+				last_snapshot_id = CASE WHEN EXCLUDED.last_seen >= objects.last_seen
+					THEN EXCLUDED.last_snapshot_id ELSE objects.last_snapshot_id END,
+				deleted_at = CASE WHEN EXCLUDED.last_seen >= COALESCE(objects.deleted_at, objects.last_seen)
+					THEN NULL ELSE objects.deleted_at END`,
+			cluster, info.Timestamp, snapshotID,
+			pq.Array(kinds), pq.Array(uids), pq.Array(namespaces), pq.Array(names), pq.Array(createdTimes))
+		if err != nil {
+			return fmt.Errorf("failed to upsert objects: %w", err)
+		}
+	}
+
+	// Objects last seen before this snapshot are gone by its time at the
+	// latest, which for an older snapshot moves an existing deleted_at earlier
+	result, err := tx.Exec(`
+		UPDATE objects SET deleted_at = $1
+		WHERE cluster = $2 AND last_seen < $1 AND (deleted_at IS NULL OR deleted_at > $1)`,
+		info.Timestamp, cluster)
+	if err != nil {
+		return fmt.Errorf("failed to mark deleted objects: %w", err)
+	}
+
+	deleted, _ := result.RowsAffected()
+
+	// When a later snapshot is already stored, objects of this one that it
+	// did not contain were deleted by then
+	var next sql.NullTime
+	err = tx.QueryRow("SELECT MIN(timestamp) FROM cluster_snapshots WHERE cluster = $1 AND timestamp > $2",
+		cluster, info.Timestamp).Scan(&next)
+	if err != nil {
+		return fmt.Errorf("failed to find the next snapshot: %w", err)
+	}
+	if next.Valid {
+		result, err := tx.Exec(`
+			UPDATE objects SET deleted_at = $1
+			WHERE cluster = $2 AND last_snapshot_id = $3 AND (deleted_at IS NULL OR deleted_at > $1)`,
+			next.Time, cluster, snapshotID)
+		if err != nil {
+			return fmt.Errorf("failed to mark deleted objects: %w", err)
+		}
+		gone, _ := result.RowsAffected()
+		deleted += gone
+	}
+
+	if deleted > 0 {
+		s.logger.WithFields(logrus.Fields{
+			"snapshot_id": snapshotID,
+			"deleted":     deleted,
+		}).Info("Marked objects as deleted")
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+	"k8s-cluster-info-collector/internal/database"
+	"k8s-cluster-info-collector/internal/models"
+	"k8s-cluster-info-collector/internal/testutil"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func pod(name, uid string) models.PodInfo {
+	return models.PodInfo{Name: name, Namespace: "shop", UID: uid}
+}
+
+func TestTrackObjectsBatchesUpserts(t *testing.T) {
+	var statements []string
+	var upsertArgs []driver.Value
+	db := testutil.FakeDB{
+		Query: func(string, []driver.Value) [][]driver.Value { return [][]driver.Value{{nil}} },
+		Exec: func(query string, args []driver.Value) error {
+			statements = append(statements, query)
+			if strings.Contains(query, "INSERT INTO objects") {
+				upsertArgs = args
+			}
+			return nil
+		},
+	}.Open(t)
+	s := New(&database.DB{DB: db}, testLogger())
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := models.ClusterInfo{
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Pods:      []models.PodInfo{pod("web-1", "uid-1"), pod("web-2", "uid-2"), pod("web-1", "uid-1"), pod("legacy", "")},
+		Nodes:     []models.NodeInfo{{Name: "node-1", UID: "uid-3"}},
+	}
+	if err := s.trackObjects(tx, 7, "prod", info); err != nil {
+		t.Fatalf("trackObjects() error = %v", err)
+	}
+
+	upserts := 0
+	for _, statement := range statements {
+		if strings.Contains(statement, "INSERT INTO objects") {
+			upserts++
+		}
+	}
+	if upserts != 1 {
+		t.Fatalf("expected one batched upsert, got %d", upserts)
+	}
+	if upsertArgs[0] != "prod" || upsertArgs[2] != int64(7) {
+		t.Errorf("unexpected cluster and snapshot arguments %v", upsertArgs[:3])
+	}
+	if kinds, uids := upsertArgs[3], upsertArgs[4]; kinds != `{"Pod","Pod","Node"}` || uids != `{"uid-1","uid-2","uid-3"}` {
+		t.Errorf("expected each object once and none without UID, got kinds %v and uids %v", kinds, uids)
+	}
+}
+
+// openTestDB connects to the PostgreSQL database named by the DB_*
+// environment variables, as the integration test does
+func openTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping database test in short mode")
+	}
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("DB_HOST not set, skipping database test")
+	}
+	cfg := &config.DatabaseConfig{
+		Host:     os.Getenv("DB_HOST"),
+		Port:     os.Getenv("DB_PORT"),
+		User:     os.Getenv("DB_USER"),
+		Password: os.Getenv("DB_PASSWORD"),
+		Name:     os.Getenv("DB_NAME"),
+		SSLMode:  "disable",
+	}
+	if cfg.Port == "" {
+		cfg.Port = "5432"
+	}
+	if cfg.User == "" {
+		cfg.User = "postgres"
+	}
+	if cfg.Name == "" {
+		cfg.Name = "cluster_info_test"
+	}
+	db, err := database.New(cfg, testLogger())
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestTrackObjectsOutOfOrder(t *testing.T) {
+	db := openTestDB(t)
+	s := New(db, testLogger())
+
+	// A cluster of its own keeps the test independent of other data
+	cluster := fmt.Sprintf("store-test-%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM cluster_snapshots WHERE cluster = $1", cluster)
+		db.Exec("DELETE FROM objects WHERE cluster = $1", cluster)
+		db.Exec("DELETE FROM clusters WHERE name = $1", cluster)
+	})
+
+	at := func(hour int) time.Time { return time.Date(2024, 5, 1, hour, 0, 0, 0, time.UTC) }
+	store := func(hour int, pods ...models.PodInfo) {
+		t.Helper()
+		if err := s.StoreClusterInfo(models.ClusterInfo{Timestamp: at(hour), Cluster: cluster, Pods: pods}); err != nil {
+			t.Fatalf("StoreClusterInfo() error = %v", err)
+		}
+	}
+	object := func(uid string) models.ObjectLifecycle {
+		t.Helper()
+		var obj models.ObjectLifecycle
+		var deletedAt *time.Time
+		err := db.QueryRow(`
+			SELECT first_seen, last_seen, deleted_at FROM objects
+			WHERE cluster = $1 AND kind = $2 AND uid = $3`, cluster, models.KindPod, uid).
+			Scan(&obj.FirstSeen, &obj.LastSeen, &deletedAt)
+		if err != nil {
+			t.Fatalf("failed to read object %s: %v", uid, err)
+		}
+		obj.DeletedAt = deletedAt
+		return obj
+	}
+	check := func(uid string, firstSeen, lastSeen, deletedAt int) {
+		t.Helper()
+		obj := object(uid)
+		if !obj.FirstSeen.Equal(at(firstSeen)) || !obj.LastSeen.Equal(at(lastSeen)) {
+			t.Errorf("%s: expected first/last seen %d:00/%d:00, got %v/%v", uid, firstSeen, lastSeen, obj.FirstSeen, obj.LastSeen)
+		}
+		switch {
+		case deletedAt == 0 && obj.DeletedAt != nil:
+			t.Errorf("%s: expected no deletion, got %v", uid, *obj.DeletedAt)
+		case deletedAt != 0 && (obj.DeletedAt == nil || !obj.DeletedAt.Equal(at(deletedAt))):
+			t.Errorf("%s: expected deletion at %d:00, got %v", uid, deletedAt, obj.DeletedAt)
+		}
+	}
+
+	store(10, pod("a", "uid-a"), pod("b", "uid-b"))
+	store(13, pod("a", "uid-a"))
+	check("uid-b", 10, 10, 13)
+
+	// An older snapshot stored late neither revives b nor moves a back
+	store(12, pod("a", "uid-a"), pod("b", "uid-b"))
+	check("uid-a", 10, 13, 0)
+	check("uid-b", 10, 12, 13)
+
+	// c only existed before every stored snapshot, so it is gone by 10:00
+	store(9, pod("a", "uid-a"), pod("c", "uid-c"))
+	check("uid-a", 9, 13, 0)
+	check("uid-c", 9, 9, 10)
+
+	// b was missing at 11:00 but seen again at 12:00, so it stays deleted at 13:00
+	store(11, pod("a", "uid-a"))
+	check("uid-b", 10, 12, 13)
+}
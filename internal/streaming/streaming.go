@@ -1,7 +1,9 @@
 package streaming
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
@@ -20,6 +22,8 @@ type Hub struct {
 	unregister chan *Client
 	logger     *logrus.Logger
 	mutex      sync.RWMutex
+	closing    bool
+	pumps      sync.WaitGroup // one per client write pump
 }
 
 // Client represents a WebSocket client connection
@@ -53,7 +57,11 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.register:
 			h.mutex.Lock()
-			h.clients[client] = true
+			if h.closing {
+				close(client.send)
+			} else {
+				h.clients[client] = true
+			}
 			h.mutex.Unlock()
 			h.logger.Info("Client connected")
 
@@ -67,7 +75,7 @@ func (h *Hub) Run() {
 			h.logger.Info("Client disconnected")
 
 		case message := <-h.broadcast:
-			h.mutex.RLock()
+			h.mutex.Lock()
 			for client := range h.clients {
 				select {
 				case client.send <- message:
@@ -76,7 +84,7 @@ func (h *Hub) Run() {
 					delete(h.clients, client)
 				}
 			}
-			h.mutex.RUnlock()
+			h.mutex.Unlock()
 		}
 	}
 }
@@ -147,6 +155,41 @@ func (h *Hub) BroadcastAlert(alertType string, details interface{}) {
 	}
 }
 
+// Shutdown closes every client connection with a "going away" close frame
+// and waits until their queued messages have been written and the
+// connections closed, or ctx expires. Stop the HTTP server first so no new
+// clients connect.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mutex.Lock()
+	h.closing = true
+	count := len(h.clients)
+	for client := range h.clients {
+		delete(h.clients, client)
+		close(client.send)
+	}
+	h.mutex.Unlock()
+
+	h.logger.WithField("clients", count).Info("Draining WebSocket clients")
+
+	done := make(chan struct{})
+	go func() {
+		h.pumps.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("WebSocket clients did not disconnect in time: %w", ctx.Err())
+	}
+}
+
+func (h *Hub) isClosing() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.closing
+}
+
 // GetConnectedClients returns the number of connected clients
 func (h *Hub) GetConnectedClients() int {
 	h.mutex.RLock()
@@ -164,6 +207,11 @@ var upgrader = websocket.Upgrader{
 
 // HandleWebSocket handles WebSocket connection requests
 func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if h.isClosing() {
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to upgrade connection")
@@ -176,6 +224,7 @@ func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		send: make(chan []byte, 256),
 	}
 
+	h.pumps.Add(1)
 	client.hub.register <- client
 
 	// Start goroutines for reading and writing
@@ -228,6 +277,7 @@ func (c *Client) writePump() {
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		c.hub.pumps.Done()
 	}()
 
 	for {
@@ -235,7 +285,11 @@ func (c *Client) writePump() {
 		case message, ok := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMessage := []byte{}
+				if c.hub.isClosing() {
+					closeMessage = websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
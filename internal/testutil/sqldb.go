@@ -0,0 +1,96 @@
+// Package testutil provides helpers shared by tests of several packages
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+)
+
+// QueryFunc answers a query with rows of driver values. Every row has one
+// value per selected column; no rows means an empty result.
+type QueryFunc func(query string, args []driver.Value) [][]driver.Value
+
+// ExecFunc is called for every statement executed without reading rows
+type ExecFunc func(query string, args []driver.Value) error
+
+// FakeDB is a database/sql database that answers from functions instead of
+// a server, so code issuing SQL can be tested without PostgreSQL.
+// Transactions are accepted but have no effect of their own.
+type FakeDB struct {
+	Query QueryFunc
+	Exec  ExecFunc // nil lets every statement succeed
+}
+
+// Open returns a database backed by f that is closed when the test ends
+func (f FakeDB) Open(t testing.TB) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(fakeConnector{f})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type fakeConnector struct{ db FakeDB }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(c), nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db FakeDB }
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements not supported")
+}
+func (fakeConn) Close() error              { return nil }
+func (fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.db.Query == nil {
+		return &fakeRows{}, nil
+	}
+	return &fakeRows{rows: c.db.Query(query, values(args))}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.db.Exec != nil {
+		if err := c.db.Exec(query, values(args)); err != nil {
+			return nil, err
+		}
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	list := make([]driver.Value, len(args))
+	for i, arg := range args {
+		list[i] = arg.Value
+	}
+	return list
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{ rows [][]driver.Value }
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
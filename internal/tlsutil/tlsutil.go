@@ -0,0 +1,234 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/config"
+)
+
+// Reloader serves a certificate and client CA pool that are re-read from
+// disk whenever the files change, so certificates can be rotated (e.g. by
+// cert-manager) without restarting the process
+type Reloader struct {
+	config config.TLSConfig
+	logger *logrus.Logger
+
+	mutex     sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	versions  map[string]fileVersion
+}
+
+// fileVersion identifies the content of a file without reading it
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader loads the configured files. It fails if they cannot be loaded
+// so a misconfigured listener never starts.
+func NewReloader(cfg config.TLSConfig, logger *logrus.Logger) (*Reloader, error) {
+	r := &Reloader{config: cfg, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-reads the certificate, key and client CA bundle. On failure the
+// previously loaded material stays in use.
+func (r *Reloader) Reload() error {
+	versions := make(map[string]fileVersion)
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		versions[path] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		data, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in client CA bundle %s", r.config.ClientCAFile)
+		}
+	}
+
+	r.mutex.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.versions = versions
+	r.mutex.Unlock()
+	return nil
+}
+
+// defaultReloadInterval is used when no reload interval is configured
+const defaultReloadInterval = 30 * time.Second
+
+// Watch reloads the files whenever their size or modification time changes,
+// checking every interval until ctx is cancelled
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				// Files are often replaced one at a time; retry on the next tick
+				r.logger.WithError(err).Warn("Failed to reload TLS certificate, keeping the previous one")
+				continue
+			}
+			r.logger.WithField("cert_file", r.config.CertFile).Info("Reloaded TLS certificate")
+		}
+	}
+}
+
+// changed reports whether any watched file differs from the loaded version
+func (r *Reloader) changed() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return true
+		}
+		if (fileVersion{modTime: info.ModTime(), size: info.Size()}) != r.versions[path] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+	return files
+}
+
+// TLSConfig returns a server configuration that always uses the most
+// recently loaded certificate and client CA pool. The configuration itself
+// never changes, so the server's ALPN protocols and session ticket keys
+// stay in effect across reloads.
+func (r *Reloader) TLSConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mutex.RLock()
+			defer r.mutex.RUnlock()
+			return r.cert, nil
+		},
+	}
+
+	// ClientCAs cannot be swapped on a configuration in use, so client
+	// certificates are requested here and verified against the current pool
+	// in VerifyConnection
+	switch clientAuthType(r.config) {
+	case tls.RequireAndVerifyClientCert:
+		cfg.ClientAuth = tls.RequireAnyClientCert
+	case tls.VerifyClientCertIfGiven:
+		cfg.ClientAuth = tls.RequestClientCert
+	default:
+		return cfg
+	}
+	cfg.VerifyConnection = r.verifyClientCertificate
+	return cfg
+}
+
+// verifyClientCertificate verifies the client's certificate chain, if one
+// was sent, against the most recently loaded client CA pool
+func (r *Reloader) verifyClientCertificate(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	r.mutex.RLock()
+	roots := r.clientCAs
+	r.mutex.RUnlock()
+
+	options := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		options.Intermediates.AddCert(cert)
+	}
+	if _, err := state.PeerCertificates[0].Verify(options); err != nil {
+		return fmt.Errorf("failed to verify client certificate: %w", err)
+	}
+	return nil
+}
+
+// clientAuthType maps the configured mode to a tls.ClientAuthType. A client
+// CA bundle without an explicit mode requires client certificates.
+func clientAuthType(cfg config.TLSConfig) tls.ClientAuthType {
+	switch cfg.ClientAuth {
+	case "require":
+		return tls.RequireAndVerifyClientCert
+	case "request":
+		return tls.VerifyClientCertIfGiven
+	case "none":
+		return tls.NoClientCert
+	default:
+		if cfg.ClientCAFile != "" {
+			return tls.RequireAndVerifyClientCert
+		}
+		return tls.NoClientCert
+	}
+}
+
+// ListenAndServe serves server over TLS when cfg is enabled and over plain
+// HTTP otherwise. Certificate files are watched until the server shuts down.
+func ListenAndServe(server *http.Server, cfg config.TLSConfig, logger *logrus.Logger) error {
+	if !cfg.Enabled {
+		return server.ListenAndServe()
+	}
+
+	reloader, err := NewReloader(cfg, logger)
+	if err != nil {
+		return err
+	}
+	server.TLSConfig = reloader.TLSConfig()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server.RegisterOnShutdown(cancel)
+	go reloader.Watch(ctx, cfg.ReloadInterval)
+
+	logger.WithFields(logrus.Fields{
+		"address":     server.Addr,
+		"client_auth": clientAuthType(cfg).String(),
+	}).Info("Serving HTTPS")
+	return server.ListenAndServeTLS("", "")
+}
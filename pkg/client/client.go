@@ -0,0 +1,314 @@
+// Package client is a Go client for the cluster info collector REST API.
+//
+//	c, err := client.New(client.Config{BaseURL: "http://consumer:8081/api/v1", Token: token})
+//	pods, err := c.Pods().List(ctx, client.ListOptions{Namespace: "shop"})
+//
+// Errors returned by the API are *APIError values carrying the HTTP status.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config configures a Client
+type Config struct {
+	// BaseURL is the API root including its prefix, e.g.
+	// http://localhost:8081/api/v1
+	BaseURL string
+	// Token is sent as a bearer token when set
+	Token string
+	// HTTPClient defaults to a client with a 30 second timeout
+	HTTPClient *http.Client
+	// UserAgent defaults to k8s-cluster-info-collector-client
+	UserAgent string
+	// Cluster, when set, limits every request to the snapshots of one
+	// cluster. Empty reads from any cluster.
+	Cluster string
+}
+
+// Client calls the REST API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	token      string
+	httpClient *http.Client
+	userAgent  string
+	cluster    string
+}
+
+// APIError is an error response from the API
+type APIError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is set when the server asked to back off (429)
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is an API 404 response
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// New creates a client for the API at cfg.BaseURL
+func New(cfg Config) (*Client, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("base URL is required")
+	}
+	baseURL, err := url.Parse(strings.TrimSuffix(cfg.BaseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", cfg.BaseURL)
+	}
+
+	c := &Client{
+		baseURL:    baseURL,
+		token:      cfg.Token,
+		httpClient: cfg.HTTPClient,
+		userAgent:  cfg.UserAgent,
+		cluster:    cfg.Cluster,
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if c.userAgent == "" {
+		c.userAgent = "k8s-cluster-info-collector-client"
+	}
+	return c, nil
+}
+
+// endpoint resolves a path below the base URL, adding the client's cluster
+func (c *Client) endpoint(path string, query url.Values) string {
+	if c.cluster != "" {
+		if query == nil {
+			query = url.Values{}
+		}
+		query.Set("cluster", c.cluster)
+	}
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// get decodes the JSON response of a GET request into out
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint(path, query), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, out)
+}
+
+func (c *Client) do(req *http.Request, out interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", req.URL.Path, err)
+	}
+	return nil
+}
+
+// send performs a request and turns error responses into an *APIError. The
+// caller closes the body of a successful response.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// responseError turns an error response into an *APIError
+func responseError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var decoded struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &decoded) == nil && decoded.Error != "" {
+		apiErr.Message = decoded.Error
+	} else if text := strings.TrimSpace(string(body)); text != "" {
+		apiErr.Message = text
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+// SnapshotOptions selects the snapshot a request reads from. The zero value
+// selects the latest snapshot.
+type SnapshotOptions struct {
+	SnapshotID int
+	At         time.Time
+}
+
+func (o SnapshotOptions) apply(query url.Values) {
+	if o.SnapshotID > 0 {
+		query.Set("snapshot_id", strconv.Itoa(o.SnapshotID))
+	}
+	if !o.At.IsZero() {
+		query.Set("at", o.At.Format(time.RFC3339))
+	}
+}
+
+// TimeRange bounds history queries. Zero times are left to the server.
+type TimeRange struct {
+	Since time.Time
+	Until time.Time
+}
+
+func (t TimeRange) apply(query url.Values) {
+	if !t.Since.IsZero() {
+		query.Set("since", t.Since.Format(time.RFC3339))
+	}
+	if !t.Until.IsZero() {
+		query.Set("until", t.Until.Format(time.RFC3339))
+	}
+}
+
+// Clusters lists the clusters with snapshots, with their last-seen times
+func (c *Client) Clusters(ctx context.Context) ([]ClusterSummary, error) {
+	var list ClusterList
+	if err := c.get(ctx, "/clusters", nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Clusters, nil
+}
+
+// Snapshots lists the most recent snapshots, newest first. A limit of 0 uses
+// the server default.
+func (c *Client) Snapshots(ctx context.Context, limit int) ([]SnapshotSummary, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var list SnapshotList
+	if err := c.get(ctx, "/snapshots", query, &list); err != nil {
+		return nil, err
+	}
+	return list.Snapshots, nil
+}
+
+// Snapshot returns a full snapshot
+func (c *Client) Snapshot(ctx context.Context, id int) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := c.get(ctx, "/snapshots/"+strconv.Itoa(id), nil, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// LatestSnapshot returns the most recent full snapshot
+func (c *Client) LatestSnapshot(ctx context.Context) (*Snapshot, error) {
+	// The server redirects to the snapshot, which the HTTP client follows
+	var snapshot Snapshot
+	if err := c.get(ctx, "/snapshots/latest", nil, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// Diff compares snapshot from with snapshot to
+func (c *Client) Diff(ctx context.Context, from, to int) (*DiffResult, error) {
+	var result DiffResult
+	path := fmt.Sprintf("/snapshots/%d/diff/%d", from, to)
+	if err := c.get(ctx, path, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Stats returns snapshot and object counts
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	var stats Stats
+	if err := c.get(ctx, "/stats", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// ObjectsOptions filters Objects
+type ObjectsOptions struct {
+	TimeRange
+	Event     string // created, deleted or all (default)
+	Kind      string
+	Namespace string
+	Limit     int
+}
+
+// Objects lists objects created or deleted in a time range
+func (c *Client) Objects(ctx context.Context, opts ObjectsOptions) (*ObjectList, error) {
+	query := url.Values{}
+	opts.TimeRange.apply(query)
+	setIfNotEmpty(query, "event", opts.Event)
+	setIfNotEmpty(query, "kind", opts.Kind)
+	setIfNotEmpty(query, "namespace", opts.Namespace)
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	var list ObjectList
+	if err := c.get(ctx, "/objects", query, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Version returns the server's build information
+func (c *Client) Version(ctx context.Context) (*Version, error) {
+	var version Version
+	if err := c.get(ctx, "/version", nil, &version); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// Health returns the server's health. Status is "degraded" when the
+// database is unreachable.
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var health Health
+	if err := c.get(ctx, "/health", nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+func setIfNotEmpty(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
@@ -0,0 +1,362 @@
+package client_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s-cluster-info-collector/internal/api"
+	"k8s-cluster-info-collector/internal/auth"
+	"k8s-cluster-info-collector/internal/database"
+	"k8s-cluster-info-collector/internal/models"
+	"k8s-cluster-info-collector/internal/streaming"
+	"k8s-cluster-info-collector/internal/testutil"
+	"k8s-cluster-info-collector/pkg/client"
+)
+
+// The tests run the real API server on a fake database that answers the
+// server's queries from canned rows
+
+type fakeQuery struct {
+	match string
+	rows  func(args []driver.Value) [][]driver.Value
+}
+
+// answer returns the rows of the first query whose match is part of the SQL
+// text and fails the test on any other query
+func answer(t *testing.T, queries []fakeQuery) testutil.QueryFunc {
+	return func(query string, args []driver.Value) [][]driver.Value {
+		for _, q := range queries {
+			if strings.Contains(query, q.match) {
+				return q.rows(args)
+			}
+		}
+		t.Errorf("unexpected query: %s", query)
+		return nil
+	}
+}
+
+func fixed(rows ...[]driver.Value) fakeQuery {
+	return fakeQuery{rows: func([]driver.Value) [][]driver.Value { return rows }}
+}
+
+var (
+	firstTime  = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	secondTime = time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	snapshots  = map[int64]struct {
+		timestamp time.Time
+		data      string
+	}{
+		1: {firstTime, `{"pods":[{"name":"web-1","namespace":"shop","phase":"Pending"}]}`},
+		2: {secondTime, `{"pods":[{"name":"web-1","namespace":"shop","phase":"Running"},{"name":"web-2","namespace":"shop","phase":"Running"}]}`},
+	}
+)
+
+func snapshotQueries() []fakeQuery {
+	byID := func(columns ...string) func(args []driver.Value) [][]driver.Value {
+		return func(args []driver.Value) [][]driver.Value {
+			id := args[0].(int64)
+			snapshot, ok := snapshots[id]
+			if !ok {
+				return nil
+			}
+			switch columns[0] {
+			case "id":
+				return [][]driver.Value{{id, snapshot.timestamp, "prod"}}
+			case "cluster":
+				return [][]driver.Value{{snapshot.timestamp, "prod", []byte(snapshot.data)}}
+			}
+			return [][]driver.Value{{snapshot.timestamp, []byte(snapshot.data)}}
+		}
+	}
+	pods := [][]driver.Value{
+		{int64(1), "web-1", "web-1", "shop", "Running", "node-1", int64(0), firstTime},
+		{int64(2), "web-2", "web-2", "shop", "Running", "node-1", int64(2), firstTime},
+		{int64(3), "web-3", "web-3", "shop", "Pending", "", int64(0), secondTime},
+	}
+
+	queries := []fakeQuery{
+		fixed([]driver.Value{int64(2)}),
+		fixed([]driver.Value{int64(2), secondTime, "prod", int64(0), int64(2), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0)},
+			[]driver.Value{int64(1), firstTime, "prod", int64(0), int64(1), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0)}),
+		{rows: byID("timestamp", "data")},
+		{rows: byID("cluster", "timestamp", "data")},
+		{rows: byID("id", "timestamp", "cluster")},
+		{rows: byID("id", "timestamp", "cluster")},
+		fixed([]driver.Value{int64(2)}),
+		fixed([]driver.Value{int64(2), secondTime, "prod"}),
+		fixed([]driver.Value{int64(2)}),
+		fixed([]driver.Value{int64(len(pods))}),
+		fixed([]driver.Value{int64(0)}),
+		{
+			rows: func(args []driver.Value) [][]driver.Value {
+				if args[1] != "web-1" {
+					return nil
+				}
+				return [][]driver.Value{{[]byte(`{"name":"web-1","namespace":"shop"}`)}}
+			},
+		},
+		{
+			rows: func(args []driver.Value) [][]driver.Value {
+				// Sorted by name; a cursor appends its value and row ID
+				if len(args) == 1 {
+					return pods
+				}
+				after := args[len(args)-1].(int64)
+				return pods[after:]
+			},
+		},
+		fixed([]driver.Value{int64(1), firstTime, "uid-1", []byte(`{"phase":"Pending"}`)},
+			[]driver.Value{int64(2), secondTime, "uid-1", []byte(`{"phase":"Running"}`)}),
+	}
+
+	matches := []string{
+		"SELECT COALESCE(MAX(id), 0) FROM cluster_snapshots",
+		"FROM cluster_snapshots cs",
+		"SELECT timestamp, data FROM cluster_snapshots WHERE id = $1",
+		"SELECT timestamp, cluster, data FROM cluster_snapshots WHERE id = $1",
+		"WHERE id = $1 AND ($2 = '' OR cluster = $2)",
+		"SELECT id, timestamp, cluster FROM cluster_snapshots WHERE id = $1",
+		"SELECT id FROM cluster_snapshots WHERE ($1 = '' OR cluster = $1) ORDER BY timestamp DESC LIMIT 1",
+		"WHERE ($1 = '' OR cluster = $1)\n\t\t\tORDER BY timestamp DESC LIMIT 1",
+		"SELECT COUNT(*) FROM cluster_snapshots",
+		"SELECT COUNT(*) FROM pods WHERE",
+		"SELECT COUNT(*) FROM",
+		"SELECT data FROM pods WHERE",
+		"FROM pods WHERE snapshot_id",
+		"JOIN cluster_snapshots s ON s.id = r.snapshot_id",
+	}
+	for i := range queries {
+		queries[i].match = matches[i]
+	}
+	return queries
+}
+
+type tokenAuthenticator string
+
+func (t tokenAuthenticator) Authenticate(_ context.Context, token string) (*auth.Identity, error) {
+	if token != string(t) {
+		return nil, auth.ErrUnauthenticated
+	}
+	return &auth.Identity{Subject: "test", Method: "token"}, nil
+}
+
+// newTestServer runs the API server, requiring token when it is set
+func newTestServer(t *testing.T, token string) (string, *streaming.Hub) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	db := &database.DB{DB: testutil.FakeDB{Query: answer(t, snapshotQueries())}.Open(t)}
+	hub := streaming.NewHub(logger)
+	go hub.Run()
+
+	server := api.New(db, logger, api.APIConfig{Prefix: "/api/v1"}, hub, "1.2.3", "abc123")
+	if token != "" {
+		server.SetAuth(tokenAuthenticator(token), nil)
+	}
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL + "/api/v1/", hub
+}
+
+func newClient(t *testing.T, baseURL, token string) *client.Client {
+	t.Helper()
+	c, err := client.New(client.Config{BaseURL: baseURL, Token: token})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c
+}
+
+func TestSnapshots(t *testing.T) {
+	baseURL, _ := newTestServer(t, "")
+	c := newClient(t, baseURL, "")
+	ctx := context.Background()
+
+	list, err := c.Snapshots(ctx, 10)
+	if err != nil {
+		t.Fatalf("Snapshots() error = %v", err)
+	}
+	if len(list) != 2 || list[0].ID != 2 || list[0].Pods != 2 || list[0].Cluster != "prod" || !list[1].Timestamp.Equal(firstTime) {
+		t.Errorf("unexpected snapshots: %+v", list)
+	}
+
+	latest, err := c.LatestSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("LatestSnapshot() error = %v", err)
+	}
+	if latest.ID != 2 || len(latest.ClusterInfo.Pods) != 2 {
+		t.Errorf("unexpected latest snapshot: %+v", latest)
+	}
+
+	_, err = c.Snapshot(ctx, 99)
+	var apiErr *client.APIError
+	if !client.IsNotFound(err) || !errors.As(err, &apiErr) || apiErr.Message != "Snapshot not found" {
+		t.Errorf("expected a not found APIError, got %v", err)
+	}
+
+	result, err := c.Diff(ctx, 1, 2)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if result.From.ID != 1 || result.To.ID != 2 || len(result.Kinds) == 0 {
+		t.Errorf("unexpected diff: %+v", result)
+	}
+
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if stats.TotalSnapshots != 2 || stats.LatestSnapshot["pods"] != 3 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestResources(t *testing.T) {
+	baseURL, _ := newTestServer(t, "")
+	c := newClient(t, baseURL, "")
+	ctx := context.Background()
+
+	page, err := c.Pods().List(ctx, client.ListOptions{Sort: "name", Limit: 2})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if page.Count != 2 || page.Total != 3 || page.NextCursor == "" || page.Snapshot.ID != 2 {
+		t.Errorf("unexpected first page: %+v", page)
+	}
+	if page.Data[1].Name != "web-2" || page.Data[1].RestartCount != 2 {
+		t.Errorf("unexpected rows: %+v", page.Data)
+	}
+
+	var names []string
+	for pod, err := range c.Pods().All(ctx, client.ListOptions{Sort: "name", Limit: 2}) {
+		if err != nil {
+			t.Fatalf("All() error = %v", err)
+		}
+		names = append(names, pod.Name)
+	}
+	if strings.Join(names, ",") != "web-1,web-2,web-3" {
+		t.Errorf("expected every pod across pages, got %v", names)
+	}
+
+	detail, err := c.Pods().Get(ctx, "shop", "web-1", client.SnapshotOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if detail.Kind != models.KindPod || !strings.Contains(string(detail.Object), `"web-1"`) {
+		t.Errorf("unexpected detail: %+v", detail)
+	}
+	if _, err := c.Pods().Get(ctx, "shop", "missing", client.SnapshotOptions{SnapshotID: 1}); !client.IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	history, err := c.Pods().History(ctx, "shop", "web-1", client.TimeRange{Since: firstTime})
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if history.Count != 2 || history.History[1].Values["phase"] != "Running" {
+		t.Errorf("unexpected history: %+v", history)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	baseURL, _ := newTestServer(t, "secret")
+	ctx := context.Background()
+
+	if _, err := newClient(t, baseURL, "secret").Stats(ctx); err != nil {
+		t.Fatalf("expected token to be accepted, got %v", err)
+	}
+
+	_, err := newClient(t, baseURL, "wrong").Stats(ctx)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %v", err)
+	}
+
+	version, err := newClient(t, baseURL, "").Version(ctx)
+	if err != nil || version.Version != "1.2.3" {
+		t.Errorf("expected public version endpoint without token, got %+v, %v", version, err)
+	}
+
+	if _, err := newClient(t, baseURL, "").Subscribe(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected WebSocket without token to get 401, got %v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	baseURL, hub := newTestServer(t, "secret")
+	c := newClient(t, baseURL, "secret")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	subscription, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer subscription.Close()
+
+	// The hub drops broadcasts while it is busy, so repeat until one arrives
+	received := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-received:
+				return
+			case <-ticker.C:
+				hub.BroadcastClusterUpdate(&models.ClusterInfo{Nodes: []models.NodeInfo{{Name: "node-1"}}})
+			}
+		}
+	}()
+
+	event, err := subscription.Next()
+	close(received)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	info, err := event.ClusterInfo()
+	if err != nil || len(info.Nodes) != 1 || info.Nodes[0].Name != "node-1" {
+		t.Errorf("unexpected cluster update: %+v, %v", info, err)
+	}
+	if _, err := (client.Event{Type: client.EventAlert}).ClusterInfo(); err == nil {
+		t.Error("expected alerts not to decode as cluster updates")
+	}
+
+	cancel()
+	if _, err := subscription.Next(); err == nil {
+		t.Error("expected Next to fail after the context is cancelled")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		http.Error(w, `{"error":"Rate limit exceeded"}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	c, err := client.New(client.Config{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	_, err = c.Stats(context.Background())
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests ||
+		apiErr.RetryAfter != 3*time.Second || apiErr.Message != "Rate limit exceeded" {
+		t.Errorf("unexpected error: %#v", err)
+	}
+
+	if _, err := client.New(client.Config{BaseURL: "localhost:8081"}); err == nil {
+		t.Error("expected a base URL without scheme to be rejected")
+	}
+}
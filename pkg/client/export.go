@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Export formats
+const (
+	FormatNDJSON  = "ndjson"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// ExportOptions selects what Export streams. A time range exports every
+// snapshot in it; otherwise one snapshot is exported.
+type ExportOptions struct {
+	SnapshotOptions
+	TimeRange
+	Namespace string
+	// Format is ndjson (default), csv or parquet
+	Format string
+	// Columns limits the export to these columns
+	Columns []string
+}
+
+// Export streams every object of one kind, e.g. "pods" or
+// "persistent-volume-claims". The caller closes the returned reader.
+func (c *Client) Export(ctx context.Context, kind string, opts ExportOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+	opts.SnapshotOptions.apply(query)
+	opts.TimeRange.apply(query)
+	setIfNotEmpty(query, "namespace", opts.Namespace)
+	setIfNotEmpty(query, "format", opts.Format)
+	setIfNotEmpty(query, "columns", strings.Join(opts.Columns, ","))
+	return c.stream(ctx, "/export/"+kind, query)
+}
+
+// ExportSnapshot streams every object of a snapshot in the given format
+// (ndjson when empty). The caller closes the returned reader.
+func (c *Client) ExportSnapshot(ctx context.Context, id int, format string, columns []string) (io.ReadCloser, error) {
+	query := url.Values{}
+	setIfNotEmpty(query, "format", format)
+	setIfNotEmpty(query, "columns", strings.Join(columns, ","))
+	return c.stream(ctx, fmt.Sprintf("/snapshots/%d/export", id), query)
+}
+
+// stream returns the body of a GET request without decoding it
+func (c *Client) stream(ctx context.Context, path string, query url.Values) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint(path, query), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"strconv"
+)
+
+// ListOptions filters and pages a resource list. Filters a kind does not
+// support are rejected by the server.
+type ListOptions struct {
+	SnapshotOptions
+	Namespace     string
+	LabelSelector string
+	Phase         string // pods
+	Node          string // pods
+	Status        string // persistent volumes and claims
+	Type          string // services and secrets
+	// Sort is a column name, prefixed with - for descending order
+	Sort string
+	// Limit is the page size; 0 uses the server default
+	Limit int
+	// Cursor continues from a previous page's NextCursor
+	Cursor string
+}
+
+func (o ListOptions) query() url.Values {
+	query := url.Values{}
+	o.SnapshotOptions.apply(query)
+	setIfNotEmpty(query, "namespace", o.Namespace)
+	setIfNotEmpty(query, "labelSelector", o.LabelSelector)
+	setIfNotEmpty(query, "phase", o.Phase)
+	setIfNotEmpty(query, "node", o.Node)
+	setIfNotEmpty(query, "status", o.Status)
+	setIfNotEmpty(query, "type", o.Type)
+	setIfNotEmpty(query, "sort", o.Sort)
+	setIfNotEmpty(query, "cursor", o.Cursor)
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return query
+}
+
+// Resources accesses one kind of resource. T is the row type of its list.
+type Resources[T any] struct {
+	client     *Client
+	path       string
+	namespaced bool
+}
+
+// Deployments accesses deployments
+func (c *Client) Deployments() *Resources[DeploymentRow] {
+	return &Resources[DeploymentRow]{c, "deployments", true}
+}
+
+// Pods accesses pods
+func (c *Client) Pods() *Resources[PodRow] {
+	return &Resources[PodRow]{c, "pods", true}
+}
+
+// Nodes accesses nodes
+func (c *Client) Nodes() *Resources[NodeRow] {
+	return &Resources[NodeRow]{c, "nodes", false}
+}
+
+// Services accesses services
+func (c *Client) Services() *Resources[ServiceRow] {
+	return &Resources[ServiceRow]{c, "services", true}
+}
+
+// Ingresses accesses ingresses
+func (c *Client) Ingresses() *Resources[IngressRow] {
+	return &Resources[IngressRow]{c, "ingresses", true}
+}
+
+// ConfigMaps accesses config maps
+func (c *Client) ConfigMaps() *Resources[ConfigMapRow] {
+	return &Resources[ConfigMapRow]{c, "configmaps", true}
+}
+
+// Secrets accesses secrets (keys only, never values)
+func (c *Client) Secrets() *Resources[SecretRow] {
+	return &Resources[SecretRow]{c, "secrets", true}
+}
+
+// PersistentVolumes accesses persistent volumes
+func (c *Client) PersistentVolumes() *Resources[PersistentVolumeRow] {
+	return &Resources[PersistentVolumeRow]{c, "persistent-volumes", false}
+}
+
+// PersistentVolumeClaims accesses persistent volume claims
+func (c *Client) PersistentVolumeClaims() *Resources[PersistentVolumeClaimRow] {
+	return &Resources[PersistentVolumeClaimRow]{c, "persistent-volume-claims", true}
+}
+
+// List returns one page. Pass the page's NextCursor as opts.Cursor to get
+// the next one; it is empty on the last page.
+func (r *Resources[T]) List(ctx context.Context, opts ListOptions) (*ResourceList[T], error) {
+	var list ResourceList[T]
+	if err := r.client.get(ctx, "/"+r.path, opts.query(), &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// All iterates over every row matching opts, fetching pages as needed. All
+// pages come from the snapshot the first page was read from. Iteration
+// stops after the first error.
+func (r *Resources[T]) All(ctx context.Context, opts ListOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			page, err := r.List(ctx, opts)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, row := range page.Data {
+				if !yield(row, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+}
+
+// objectPath returns the path of one object. namespace is ignored for
+// cluster-scoped kinds.
+func (r *Resources[T]) objectPath(namespace, name string) string {
+	if r.namespaced {
+		return "/" + r.path + "/" + namespace + "/" + name
+	}
+	return "/" + r.path + "/" + name
+}
+
+// Get returns one object in full. namespace is ignored for cluster-scoped
+// kinds.
+func (r *Resources[T]) Get(ctx context.Context, namespace, name string, opts SnapshotOptions) (*ObjectDetail, error) {
+	query := url.Values{}
+	opts.apply(query)
+	var detail ObjectDetail
+	if err := r.client.get(ctx, r.objectPath(namespace, name), query, &detail); err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// History returns how one object changed across snapshots
+func (r *Resources[T]) History(ctx context.Context, namespace, name string, window TimeRange) (*ObjectHistory, error) {
+	query := url.Values{}
+	window.apply(query)
+	var history ObjectHistory
+	if err := r.client.get(ctx, r.objectPath(namespace, name)+"/history", query, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
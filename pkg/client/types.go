@@ -0,0 +1,53 @@
+package client
+
+import (
+	"k8s-cluster-info-collector/internal/api"
+	"k8s-cluster-info-collector/internal/diff"
+	"k8s-cluster-info-collector/internal/models"
+)
+
+// The client decodes into the server's own response types so the two cannot
+// drift apart. The aliases make them nameable outside this module.
+
+type (
+	SnapshotSummary = api.SnapshotSummary
+	SnapshotList    = api.SnapshotList
+	Snapshot        = api.SnapshotResponse
+	SnapshotRef     = models.SnapshotRef
+	ClusterSummary  = api.ClusterSummary
+	ClusterList     = api.ClusterList
+	Stats           = api.Stats
+	ObjectList      = api.ObjectList
+	ObjectDetail    = api.ObjectDetail
+	ObjectHistory   = api.ObjectHistory
+	ChangePoint     = api.ChangePoint
+	Version         = api.VersionResponse
+	Health          = api.HealthResponse
+	DiffResult      = diff.Result
+	KindDiff        = diff.KindDiff
+	ObjectDiff      = diff.ObjectDiff
+
+	ClusterInfo               = models.ClusterInfo
+	DeploymentInfo            = models.DeploymentInfo
+	PodInfo                   = models.PodInfo
+	NodeInfo                  = models.NodeInfo
+	ServiceInfo               = models.ServiceInfo
+	IngressInfo               = models.IngressInfo
+	ConfigMapInfo             = models.ConfigMapInfo
+	SecretInfo                = models.SecretInfo
+	PersistentVolumeInfo      = models.PersistentVolumeInfo
+	PersistentVolumeClaimInfo = models.PersistentVolumeClaimInfo
+
+	DeploymentRow            = api.DeploymentRow
+	PodRow                   = api.PodRow
+	NodeRow                  = api.NodeRow
+	ServiceRow               = api.ServiceRow
+	IngressRow               = api.IngressRow
+	ConfigMapRow             = api.ConfigMapRow
+	SecretRow                = api.SecretRow
+	PersistentVolumeRow      = api.PersistentVolumeRow
+	PersistentVolumeClaimRow = api.PersistentVolumeClaimRow
+)
+
+// ResourceList is one page of a resource list
+type ResourceList[T any] = api.ResourceList[T]
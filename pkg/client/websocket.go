@@ -0,0 +1,114 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Event types sent over the WebSocket stream
+const (
+	EventClusterUpdate = "cluster_update"
+	EventMetricsUpdate = "metrics_update"
+	EventAlert         = "alert"
+)
+
+// Event is one message from the WebSocket stream
+type Event struct {
+	Type      string          `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// ClusterInfo decodes the data of a cluster_update event
+func (e Event) ClusterInfo() (*ClusterInfo, error) {
+	if e.Type != EventClusterUpdate {
+		return nil, fmt.Errorf("event %q is not a %s", e.Type, EventClusterUpdate)
+	}
+	var info ClusterInfo
+	if err := json.Unmarshal(e.Data, &info); err != nil {
+		return nil, fmt.Errorf("failed to decode cluster update: %w", err)
+	}
+	return &info, nil
+}
+
+// Subscription receives events from the WebSocket stream
+type Subscription struct {
+	conn    *websocket.Conn
+	stop    func() bool
+	pending [][]byte
+}
+
+// Subscribe connects to the WebSocket stream. The connection closes when ctx
+// is done or Close is called.
+func (c *Client) Subscribe(ctx context.Context) (*Subscription, error) {
+	u := *c.baseURL
+	u.Path += "/ws"
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: c.httpClient.Timeout,
+	}
+	if transport, ok := c.httpClient.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = transport.TLSClientConfig
+	}
+
+	header := http.Header{"User-Agent": {c.userAgent}}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			defer resp.Body.Close()
+			return nil, responseError(resp)
+		}
+		return nil, fmt.Errorf("failed to connect to %s: %w", u.Redacted(), err)
+	}
+
+	return &Subscription{
+		conn: conn,
+		stop: context.AfterFunc(ctx, func() { conn.Close() }),
+	}, nil
+}
+
+// Next blocks until the next event arrives. It returns an error once the
+// connection is closed.
+func (s *Subscription) Next() (Event, error) {
+	// The server may batch several newline-separated events in one frame
+	for len(s.pending) == 0 {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return Event{}, err
+		}
+		for _, line := range bytes.Split(data, []byte{'\n'}) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				s.pending = append(s.pending, line)
+			}
+		}
+	}
+
+	line := s.pending[0]
+	s.pending = s.pending[1:]
+	var event Event
+	if err := json.Unmarshal(line, &event); err != nil {
+		return Event{}, fmt.Errorf("failed to decode event: %w", err)
+	}
+	return event, nil
+}
+
+// Close closes the connection
+func (s *Subscription) Close() error {
+	s.stop()
+	s.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	return s.conn.Close()
+}
@@ -112,3 +112,15 @@ FROM pods
 WHERE snapshot_id = (SELECT MAX(id) FROM cluster_snapshots)
 GROUP BY phase
 ORDER BY pod_count DESC;
+
+-- Requested CPU and memory per namespace using the numeric quantity columns
+SELECT 
+    namespace,
+    COUNT(*) as pod_count,
+    SUM(cpu_request_millicores) as cpu_request_millicores,
+    ROUND(SUM(memory_request_bytes) / 1024.0 / 1024.0 / 1024.0, 2) as memory_request_gib
+FROM pods 
+WHERE snapshot_id = (SELECT MAX(id) FROM cluster_snapshots)
+    AND phase NOT IN ('Succeeded', 'Failed')
+GROUP BY namespace
+ORDER BY cpu_request_millicores DESC NULLS LAST;
@@ -1,56 +1,39 @@
 #!/bin/bash
 
-# Simple Swagger validation using basic checks
-echo "🔍 Quick Swagger Validation for v2.0"
+# Simple OpenAPI validation using basic checks against the document the
+# running API generates from its routes
+echo "🔍 Quick OpenAPI Validation for v2.0"
 echo "===================================="
 
-if [ ! -f "docs/swagger.yaml" ]; then
-    echo "❌ docs/swagger.yaml not found"
-    exit 1
-fi
-
-echo "✅ docs/swagger.yaml found"
-
-# Check for v2.0 indicators
-if grep -q "version: 2.0.0" docs/swagger.yaml; then
-    echo "✅ Version 2.0.0 detected"
-else
-    echo "⚠️  Version 2.0.0 not found"
-fi
-
-if grep -q "kafka" docs/swagger.yaml; then
-    echo "✅ Kafka endpoints present"
-else
-    echo "⚠️  Kafka endpoints missing"
-fi
+SPEC_URL="${SPEC_URL:-http://localhost:8081/api/v1/openapi.json}"
+SPEC_FILE=$(mktemp)
+trap 'rm -f "$SPEC_FILE"' EXIT
 
-if grep -q "/metrics" docs/swagger.yaml; then
-    echo "✅ Metrics endpoint present"
-else
-    echo "⚠️  Metrics endpoint missing"
+if ! curl -fsS "$SPEC_URL" -o "$SPEC_FILE"; then
+    echo "❌ Could not fetch $SPEC_URL"
+    echo "Start the API or run './scripts/port-forward.sh start', or set SPEC_URL"
+    exit 1
 fi
 
-if grep -q "/ws" docs/swagger.yaml; then
-    echo "✅ WebSocket endpoint present"
-else
-    echo "⚠️  WebSocket endpoint missing"
-fi
+echo "✅ OpenAPI document fetched from $SPEC_URL"
 
-if grep -q "services:" docs/swagger.yaml; then
-    echo "✅ Services endpoints present"
+if grep -q '"openapi"' "$SPEC_FILE" && grep -q '"paths"' "$SPEC_FILE"; then
+    echo "✅ OpenAPI structure detected"
 else
-    echo "⚠️  Services endpoints missing"
+    echo "❌ Not an OpenAPI document"
+    exit 1
 fi
 
-if grep -q "ingresses:" docs/swagger.yaml; then
-    echo "✅ Ingresses endpoints present"
-else
-    echo "⚠️  Ingresses endpoints missing"
-fi
+for path in /kafka/dead-letters /metrics /ws /services /ingresses; do
+    if grep -q "\"$path\"" "$SPEC_FILE"; then
+        echo "✅ $path endpoint present"
+    else
+        echo "⚠️  $path endpoint missing"
+    fi
+done
 
 echo ""
-echo "📊 Endpoint count: $(grep -c "^\s*/" docs/swagger.yaml)"
-echo "📊 Schema count: $(grep -c "^\s*[A-Z][a-zA-Z]*:" docs/swagger.yaml)"
+echo "📊 Endpoint count: $(grep -o '"/[^"]*": *{' "$SPEC_FILE" | wc -l)"
 
 echo ""
 echo "✅ Basic validation completed!"
@@ -1,33 +1,35 @@
 #!/bin/bash
 
 # Kubernetes Cluster Info Collector v2.0 - Swagger Validation Script
-# Validates the OpenAPI 3.0.3 specification for correctness
+# Validates the OpenAPI 3.0.3 document served by the API for correctness
 
 set -e
 
 echo "🔍 Kubernetes Cluster Info Collector v2.0 - Swagger Validation"
 echo "=============================================================="
 
-# Check if docs/swagger.yaml exists
-if [ ! -f "docs/docs/swagger.yaml" ]; then
-    echo "❌ Error: docs/docs/swagger.yaml not found in current directory"
-    echo "Please run this script from the project root directory"
+# Fetch the document the running API generates from its routes
+SPEC_URL="${SPEC_URL:-http://localhost:8081/api/v1/openapi.json}"
+if ! curl -fsS "$SPEC_URL" -o openapi.json; then
+    echo "❌ Error: could not fetch $SPEC_URL"
+    echo "Start the API or run './scripts/port-forward.sh start', or set SPEC_URL"
     exit 1
 fi
+trap 'rm -f openapi.json' EXIT
 
 echo ""
-echo "📝 Validating docs/docs/swagger.yaml..."
+echo "📝 Validating $SPEC_URL..."
 
 # Method 1: Try using swagger-codegen (if available)
 if command -v swagger-codegen >/dev/null 2>&1; then
     echo "Using swagger-codegen validator..."
-    swagger-codegen validate -i docs/swagger.yaml
+    swagger-codegen validate -i openapi.json
     echo "✅ swagger-codegen validation passed"
 elif command -v npx >/dev/null 2>&1; then
     echo "Using NPX validator..."
     
     # Try @apidevtools/swagger-parser which handles YAML properly
-    if npx --yes @apidevtools/swagger-parser validate docs/swagger.yaml 2>/dev/null; then
+    if npx --yes @apidevtools/swagger-parser validate openapi.json 2>/dev/null; then
         echo "✅ @apidevtools/swagger-parser validation passed"
     else
         echo "⚠️  Direct validation failed, creating validation script..."
@@ -38,7 +40,7 @@ const SwaggerParser = require('@apidevtools/swagger-parser');
 
 async function validate() {
     try {
-        const api = await SwaggerParser.validate('docs/swagger.yaml');
+        const api = await SwaggerParser.validate('openapi.json');
         console.log('✅ OpenAPI validation passed');
         console.log(`📋 API: ${api.info.title} v${api.info.version}`);
         console.log(`📊 Endpoints: ${Object.keys(api.paths).length}`);
@@ -74,7 +76,7 @@ elif command -v docker >/dev/null 2>&1; then
     echo "Using Docker-based validator..."
     docker run --rm -v "$(pwd):/workspace" \
         openapitools/openapi-generator-cli validate \
-        -i /workspace/docs/swagger.yaml
+        -i /workspace/openapi.json
     echo "✅ Docker validation passed"
 else
     echo "⚠️  No external validation tools found. Performing comprehensive basic checks..."
@@ -88,7 +90,7 @@ else
 import yaml
 import sys
 try:
-    with open('docs/swagger.yaml', 'r') as f:
+    with open('openapi.json', 'r') as f:
         data = yaml.safe_load(f)
     print('✅ YAML syntax is valid')
     
@@ -121,7 +123,7 @@ except Exception as e:
         ruby -e "
 require 'yaml'
 begin
-  data = YAML.load_file('docs/swagger.yaml')
+  data = YAML.load_file('openapi.json')
   puts '✅ YAML syntax is valid'
   
   # Basic structure check
@@ -142,24 +144,24 @@ end
 "
     else
         echo "⚠️  Basic validation: Checking file exists and has content..."
-        if [ -s "docs/swagger.yaml" ]; then
-            echo "✅ docs/swagger.yaml exists and has content"
+        if [ -s "openapi.json" ]; then
+            echo "✅ openapi.json exists and has content"
             
             # Basic grep-based checks
-            if grep -q "openapi:" docs/swagger.yaml && grep -q "info:" docs/swagger.yaml && grep -q "paths:" docs/swagger.yaml; then
+            if grep -q '"openapi"' openapi.json && grep -q '"info"' openapi.json && grep -q '"paths"' openapi.json; then
                 echo "✅ Contains required OpenAPI sections"
             else
                 echo "❌ Missing required OpenAPI sections"
                 VALIDATION_PASSED=false
             fi
             
-            if grep -q "version: 2.0.0" docs/swagger.yaml; then
+            if grep -q '"version": *"2.0.0"' openapi.json; then
                 echo "✅ Version 2.0.0 found"
             else
                 echo "⚠️  Version might not be updated to 2.0.0"
             fi
         else
-            echo "❌ docs/swagger.yaml is empty or doesn't exist"
+            echo "❌ openapi.json is empty or doesn't exist"
             VALIDATION_PASSED=false
         fi
     fi
@@ -176,33 +178,33 @@ echo ""
 echo "📊 API Statistics:"
 
 # Count endpoints
-ENDPOINT_COUNT=$(grep -c "^\s*/" docs/swagger.yaml || echo "0")
+ENDPOINT_COUNT=$(grep -o '"/[^"]*": *{' openapi.json | wc -l)
 echo "• Total Endpoints: $ENDPOINT_COUNT"
 
 # Count schemas
-SCHEMA_COUNT=$(grep -c "^\s*[A-Z][a-zA-Z]*Info:\|^\s*[A-Z][a-zA-Z]*Message:\|^\s*[A-Z][a-zA-Z]*Summary:" docs/swagger.yaml || echo "0")
+SCHEMA_COUNT=$(grep -o '"#/components/schemas/[A-Za-z]*"' openapi.json | sort -u | wc -l)
 echo "• Total Schemas: $SCHEMA_COUNT"
 
 # Check for v2.0 features
-if grep -q "kafka" docs/swagger.yaml; then
+if grep -q "kafka" openapi.json; then
     echo "✅ Kafka endpoints present"
 else
     echo "⚠️  Kafka endpoints missing"
 fi
 
-if grep -q "WebSocket\|websocket\|ws:" docs/swagger.yaml; then
+if grep -q "WebSocket\|websocket\|ws:" openapi.json; then
     echo "✅ WebSocket documentation present"
 else
     echo "⚠️  WebSocket documentation missing"
 fi
 
-if grep -q "metrics" docs/swagger.yaml; then
+if grep -q "metrics" openapi.json; then
     echo "✅ Metrics endpoints present"
 else
     echo "⚠️  Metrics endpoints missing"
 fi
 
-if grep -q "retention" docs/swagger.yaml; then
+if grep -q "retention" openapi.json; then
     echo "✅ Retention endpoints present"
 else
     echo "⚠️  Retention endpoints missing"
@@ -215,7 +217,7 @@ echo "2. Test API endpoints with './scripts/test-api.sh' (if available)"
 echo "3. Deploy the collector and verify endpoints are responding"
 echo ""
 echo "📚 Documentation Files:"
-echo "• docs/swagger.yaml - OpenAPI 3.0.3 specification"
+echo "• /api/v1/openapi.json - OpenAPI 3.0.3 document generated by the API"
 echo "• docs/API.md - API usage guide"
 echo "• view-api-docs.sh - Documentation viewer script"
 echo ""
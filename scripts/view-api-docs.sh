@@ -17,10 +17,11 @@ echo "• Kafka Statistics & Monitoring"
 echo "• Data Retention Management"
 echo "• Enhanced Health Checks"
 
-# Check if docs/swagger.yaml exists
-if [ ! -f "docs/swagger.yaml" ]; then
-    echo "❌ Error: docs/swagger.yaml not found in current directory"
-    echo "Please run this script from the project root directory"
+# Fetch the document the running API generates from its routes
+SPEC_URL="${SPEC_URL:-http://localhost:8081/api/v1/openapi.json}"
+if ! curl -fsS "$SPEC_URL" -o openapi.json; then
+    echo "❌ Error: could not fetch $SPEC_URL"
+    echo "Start the API or run './scripts/port-forward.sh start', or set SPEC_URL"
     exit 1
 fi
 
@@ -58,8 +59,8 @@ case $choice in
         echo "Starting Swagger UI container..."
         docker run -d --name swagger-ui-cluster-info \
             -p 8080:8080 \
-            -e SWAGGER_JSON=/app/docs/swagger.yaml \
-            -v "$(pwd)/docs/swagger.yaml:/app/docs/swagger.yaml" \
+            -e SWAGGER_JSON=/app/openapi.json \
+            -v "$(pwd)/openapi.json:/app/openapi.json" \
             swaggerapi/swagger-ui
         
         echo ""
@@ -95,54 +96,9 @@ case $choice in
         fi
         
         echo "Starting Swagger UI server..."
-        echo "Note: Converting YAML to JSON for compatibility"
-        
-        # Convert YAML to JSON with multiple fallback methods
-        YAML_CONVERTED=false
-        
-        # Method 1: Try with PyYAML if available
-        if command -v python3 >/dev/null 2>&1; then
-            echo "Converting docs/swagger.yaml to JSON (trying PyYAML)..."
-            if python3 -c "import yaml" 2>/dev/null; then
-                python3 -c "
-import yaml, json
-with open('docs/swagger.yaml', 'r') as f:
-    data = yaml.safe_load(f)
-with open('temp_swagger.json', 'w') as f:
-    json.dump(data, f, indent=2)
-print('✅ Converted to JSON format using PyYAML')
-" && YAML_CONVERTED=true
-            else
-                echo "⚠️  PyYAML not installed, trying alternative methods..."
-            fi
-        fi
-        
-        # Method 2: Try with Ruby if Python/PyYAML failed
-        if [ "$YAML_CONVERTED" = false ] && command -v ruby >/dev/null 2>&1; then
-            echo "Converting docs/swagger.yaml to JSON (using Ruby)..."
-            if ruby -e "
-require 'yaml'
-require 'json'
-data = YAML.load_file('docs/swagger.yaml')
-File.write('temp_swagger.json', JSON.pretty_generate(data))
-puts '✅ Converted to JSON format using Ruby'
-" 2>/dev/null; then
-                YAML_CONVERTED=true
-            else
-                echo "⚠️  Ruby YAML conversion failed"
-            fi
-        fi
-        
-        # Method 3: Try with Node.js if other methods failed
-        if [ "$YAML_CONVERTED" = false ]; then
-            echo "Converting docs/swagger.yaml to JSON (using Node.js)..."
-            if npx --yes js-yaml docs/swagger.yaml > temp_swagger.json 2>/dev/null; then
-                echo "✅ Converted to JSON format using js-yaml"
-                YAML_CONVERTED=true
-            else
-                echo "⚠️  Node.js YAML conversion failed"
-            fi
-        fi
+        # The API already serves JSON
+        cp openapi.json temp_swagger.json
+        YAML_CONVERTED=true
         
         if [ "$YAML_CONVERTED" = true ]; then
             # Now serve the JSON file
@@ -172,8 +128,8 @@ puts '✅ Converted to JSON format using Ruby'
             echo ""
             echo "📋 Creating comprehensive documentation with embedded YAML..."
             
-            # Read docs/swagger.yaml content and escape it for JavaScript
-            SWAGGER_CONTENT=$(cat docs/swagger.yaml | sed 's/`/\\`/g' | sed 's/\$/\\$/g')
+            # Read openapi.json content and escape it for JavaScript
+            SWAGGER_CONTENT=$(cat openapi.json | sed 's/`/\\`/g' | sed 's/\$/\\$/g')
             
             # Generate comprehensive static HTML
             cat > swagger-ui.html << EOF
@@ -258,12 +214,12 @@ EOF
         echo "🌐 Opening Online Swagger Editor..."
         echo ""
         echo "1. Go to: https://editor.swagger.io/"
-        echo "2. Copy the contents of docs/swagger.yaml"
+        echo "2. Copy the contents of openapi.json"
         echo "3. Paste into the editor"
         echo ""
-        echo "Contents of docs/swagger.yaml:"
+        echo "Contents of openapi.json:"
         echo "========================"
-        cat docs/swagger.yaml
+        cat openapi.json
         
         # Try to open in default browser
         if command -v open >/dev/null 2>&1; then
@@ -278,8 +234,8 @@ EOF
         echo "💻 VS Code Preview..."
         
         if command -v code >/dev/null 2>&1; then
-            echo "Opening docs/swagger.yaml in VS Code..."
-            code docs/swagger.yaml
+            echo "Opening openapi.json in VS Code..."
+            code openapi.json
             echo ""
             echo "Instructions:"
             echo "1. Install the 'Swagger Viewer' extension if not already installed"
@@ -353,8 +309,8 @@ EOF
         echo ""
         echo "📄 Generating Static HTML Documentation..."
         
-        # Read the docs/swagger.yaml content
-        SWAGGER_CONTENT=$(cat docs/swagger.yaml | sed 's/`/\\`/g' | sed 's/\$/\\$/g')
+        # Read the openapi.json content
+        SWAGGER_CONTENT=$(cat openapi.json | sed 's/`/\\`/g' | sed 's/\$/\\$/g')
         
         # Generate static HTML file
         cat > swagger-ui.html << EOF